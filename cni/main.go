@@ -27,11 +27,13 @@ func init() {
 }
 
 func main() {
-	versions := version.PluginSupports("0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0")
+	versions := version.PluginSupports("0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0", "1.1.0")
 	funcs := skel.CNIFuncs{
-		Add:   commands.Add,
-		Del:   commands.Del,
-		Check: commands.Check,
+		Add:    commands.Add,
+		Del:    commands.Del,
+		Check:  commands.Check,
+		GC:     commands.GC,
+		Status: commands.Status,
 	}
 	skel.PluginMainFuncs(funcs, versions, about)
 }
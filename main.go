@@ -13,7 +13,9 @@ import (
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/endpoints"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/endpointslice"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/flatnetworkip"
+	"github.com/cnrancher/rancher-flat-network/pkg/controller/flatnetworkipreservation"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/flatnetworksubnet"
+	"github.com/cnrancher/rancher-flat-network/pkg/controller/flatnetworksubnetpool"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/ingress"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/namespace"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/pod"
@@ -90,6 +92,8 @@ func main() {
 	// Register handlers
 	flatnetworkip.Register(ctx, wctx)
 	flatnetworksubnet.Register(ctx, wctx)
+	flatnetworkipreservation.Register(ctx, wctx)
+	flatnetworksubnetpool.Register(ctx, wctx)
 	service.Register(ctx, wctx)
 	pod.Register(ctx, wctx)
 	ingress.Register(ctx, wctx)
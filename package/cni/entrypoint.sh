@@ -10,7 +10,14 @@ CNI_BIN_DIR="/host/opt/cni/bin"
 CNI_LOGLEVEL_CONF="/cni-loglevel.conf"
 CNI_LOG_FILE="/var/log/rancher-flat-network/"
 
+# The node name is read by CNI as it may differ from the hostname.
+FLAT_NETWORK_CONF_DIR="/host/etc/rancher/flat-network"
+
 cp -f /opt/cni/bin/* $CNI_BIN_DIR/
 
+if [[ -n "${NODE_NAME:-}" ]]; then
+    echo -n "${NODE_NAME}" > "${FLAT_NETWORK_CONF_DIR}/node-name"
+fi
+
 echo "Entering sleep (succeed)."
 sleep infinity
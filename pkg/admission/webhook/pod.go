@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+)
+
+func deserializePod(ar *admissionv1.AdmissionReview) (*corev1.Pod, error) {
+	/* unmarshal Pod from AdmissionReview request */
+	pod := &corev1.Pod{}
+	err := json.Unmarshal(ar.Request.Object.Raw, pod)
+	return pod, err
+}
+
+// validatePod ensures the pod namespace is allowed to use the flat-network
+// subnets, to avoid creating pods directly to bypass the workload validation.
+func (h *Handler) validatePod(ar *admissionv1.AdmissionReview) (bool, error) {
+	if ar.Request.Operation != admissionv1.Create {
+		return true, nil
+	}
+	pod, err := deserializePod(ar)
+	if err != nil {
+		return false, err
+	}
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return true, nil
+	}
+	interfaces, err := common.GetPodInterfaces(pod.Annotations)
+	if err != nil {
+		return false, err
+	}
+	if len(interfaces) == 0 {
+		return true, nil
+	}
+
+	subnets := []*flv1.FlatNetworkSubnet{}
+	for _, iface := range interfaces {
+		subnetNames, err := common.CheckPodAnnotationSubnets(iface.Subnet)
+		if err != nil {
+			return false, err
+		}
+		for _, subnetName := range subnetNames {
+			subnet, err := h.subnetClient.Get(
+				flv1.SubnetNamespace, subnetName, metav1.GetOptions{})
+			if err != nil {
+				return false, fmt.Errorf("failed to get subnet %v: %w", subnetName, err)
+			}
+			subnets = append(subnets, subnet)
+		}
+	}
+	if err := h.validateSubnetsNamespace(ar.Request.Namespace, subnets); err != nil {
+		return false, err
+	}
+	logrus.Debugf("handle pod validate request [%v/%v]",
+		ar.Request.Namespace, pod.Name)
+	return true, nil
+}
+
+// validateSubnetsNamespace ensures the namespace is allowed to use the
+// subnets.
+func (h *Handler) validateSubnetsNamespace(
+	namespace string, subnets []*flv1.FlatNetworkSubnet,
+) error {
+	var ns *corev1.Namespace
+	for _, subnet := range subnets {
+		if subnet.Spec.NamespaceSelector == nil && len(subnet.Spec.Projects) == 0 {
+			continue
+		}
+		if ns == nil {
+			var err error
+			ns, err = h.namespaceClient.Get(namespace, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get namespace %v: %w", namespace, err)
+			}
+		}
+		if err := common.CheckSubnetNamespace(subnet, ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
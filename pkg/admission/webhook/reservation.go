@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+)
+
+func deserializeFlatNetworkIPReservation(
+	ar *admissionv1.AdmissionReview,
+) (*flv1.FlatNetworkIPReservation, error) {
+	/* unmarshal FlatNetworkIPReservation from AdmissionReview request */
+	r := &flv1.FlatNetworkIPReservation{}
+	err := json.Unmarshal(ar.Request.Object.Raw, r)
+	return r, err
+}
+
+func (h *Handler) validateFlatNetworkIPReservation(ar *admissionv1.AdmissionReview) (bool, error) {
+	r, err := deserializeFlatNetworkIPReservation(ar)
+	if err != nil {
+		return false, err
+	}
+	if r == nil || r.Name == "" || r.DeletionTimestamp != nil {
+		return true, nil
+	}
+
+	subnet, err := h.subnetClient.Get(flv1.SubnetNamespace, r.Spec.Subnet, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get subnet %v: %w", r.Spec.Subnet, err)
+	}
+	if err := common.ValidateIPReservation(r, subnet); err != nil {
+		return false, err
+	}
+	logrus.Infof("handle flatnetwork IP reservation validate request [%v/%v]",
+		ar.Request.Namespace, r.Name)
+	return true, nil
+}
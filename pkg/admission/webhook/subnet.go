@@ -7,10 +7,12 @@ import (
 
 	"github.com/sirupsen/logrus"
 	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
 	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 )
 
 const (
@@ -30,7 +32,16 @@ func deserializeFlatNetworkSubnet(ar *admissionv1.AdmissionReview) (*flv1.FlatNe
 	return subnet, err
 }
 
+func deserializeOldFlatNetworkSubnet(ar *admissionv1.AdmissionReview) (*flv1.FlatNetworkSubnet, error) {
+	subnet := &flv1.FlatNetworkSubnet{}
+	err := json.Unmarshal(ar.Request.OldObject.Raw, subnet)
+	return subnet, err
+}
+
 func (h *Handler) validateFlatNetworkSubnet(ar *admissionv1.AdmissionReview) (bool, error) {
+	if ar.Request.Operation == admissionv1.Delete {
+		return h.validateSubnetDelete(ar)
+	}
 	subnet, err := deserializeFlatNetworkSubnet(ar)
 	if err != nil {
 		return false, err
@@ -41,10 +52,9 @@ func (h *Handler) validateFlatNetworkSubnet(ar *admissionv1.AdmissionReview) (bo
 
 	var subnets = make([]*flv1.FlatNetworkSubnet, 0)
 	options := metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("%v=%v,%v=%v",
-			labelMaster, subnet.Spec.Master, labelVlan, subnet.Spec.VLAN),
-		Limit:    listLimit,
-		Continue: "",
+		LabelSelector: fmt.Sprintf("%v=%v", labelVlan, subnet.Spec.VLAN),
+		Limit:         listLimit,
+		Continue:      "",
 	}
 	for {
 		subnetList, err := h.subnetClient.List(flv1.SubnetNamespace, options)
@@ -74,6 +84,93 @@ func (h *Handler) validateFlatNetworkSubnet(ar *admissionv1.AdmissionReview) (bo
 	if err := common.CheckSubnetConflict(subnet, subnets); err != nil {
 		return false, err
 	}
+	// Ensure the CIDR & ranges changes do not strand the allocated IPs
+	if err := h.validateSubnetResize(ar, subnet); err != nil {
+		return false, err
+	}
 	logrus.Infof("handle flatnetwork subnet validate request [%v]", subnet.Name)
 	return true, nil
 }
+
+// validateSubnetResize ensures the subnet CIDR & ranges can be changed
+// online, the allocated IPs should still inside the new CIDR & ranges.
+func (h *Handler) validateSubnetResize(
+	ar *admissionv1.AdmissionReview, subnet *flv1.FlatNetworkSubnet,
+) error {
+	if ar.Request.Operation != admissionv1.Update || len(ar.Request.OldObject.Raw) == 0 {
+		return nil
+	}
+	old, err := deserializeOldFlatNetworkSubnet(ar)
+	if err != nil {
+		return fmt.Errorf("failed to decode old subnet object: %w", err)
+	}
+	if old.Spec.CIDR == subnet.Spec.CIDR &&
+		equality.Semantic.DeepEqual(old.Spec.Ranges, subnet.Spec.Ranges) {
+		return nil
+	}
+
+	ips, err := h.listSubnetIPs(subnet.Name)
+	if err != nil {
+		return err
+	}
+	merged, err := h.getSubnetAllocation(subnet)
+	if err != nil {
+		return err
+	}
+	if err := common.CheckSubnetResize(old, merged, ips); err != nil {
+		return err
+	}
+	logrus.Infof("subnet [%v] CIDR [%v] ranges %v change to CIDR [%v] ranges %v",
+		subnet.Name, old.Spec.CIDR, utils.Print(old.Spec.Ranges),
+		subnet.Spec.CIDR, utils.Print(subnet.Spec.Ranges))
+	return nil
+}
+
+// validateSubnetDelete ensures the subnet is not in use before deleting.
+func (h *Handler) validateSubnetDelete(ar *admissionv1.AdmissionReview) (bool, error) {
+	subnet, err := deserializeOldFlatNetworkSubnet(ar)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode old subnet object: %w", err)
+	}
+	ips, err := h.listSubnetIPs(subnet.Name)
+	if err != nil {
+		return false, err
+	}
+	subnet, err = h.getSubnetAllocation(subnet)
+	if err != nil {
+		return false, err
+	}
+	if err := common.CheckSubnetDelete(subnet, ips); err != nil {
+		return false, err
+	}
+	logrus.Infof("handle flatnetwork subnet delete request [%v]", subnet.Name)
+	return true, nil
+}
+
+// listSubnetIPs lists the FlatNetworkIPs using the subnet, including the IPs
+// using the subnet as the dual-stack secondary subnet.
+func (h *Handler) listSubnetIPs(subnetName string) ([]*flv1.FlatNetworkIP, error) {
+	var ips []*flv1.FlatNetworkIP
+	for _, label := range []string{"subnet", "secondarySubnet"} {
+		options := metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%v=%v", label, subnetName),
+			Limit:         listLimit,
+		}
+		for {
+			ipList, err := h.ipClient.List("", options)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list FlatNetworkIPs by selector %q: %w",
+					options.LabelSelector, err)
+			}
+			for i := range ipList.Items {
+				ips = append(ips, ipList.Items[i].DeepCopy())
+			}
+			if ipList.Continue == "" {
+				break
+			}
+			options.Continue = ipList.Continue
+			time.Sleep(listInterval)
+		}
+	}
+	return ips, nil
+}
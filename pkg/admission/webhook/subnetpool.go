@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+)
+
+func deserializeFlatNetworkSubnetPool(
+	ar *admissionv1.AdmissionReview,
+) (*flv1.FlatNetworkSubnetPool, error) {
+	/* unmarshal FlatNetworkSubnetPool from AdmissionReview request */
+	pool := &flv1.FlatNetworkSubnetPool{}
+	err := json.Unmarshal(ar.Request.Object.Raw, pool)
+	return pool, err
+}
+
+func (h *Handler) validateFlatNetworkSubnetPool(ar *admissionv1.AdmissionReview) (bool, error) {
+	pool, err := deserializeFlatNetworkSubnetPool(ar)
+	if err != nil {
+		return false, err
+	}
+	if pool == nil || pool.Name == "" || pool.DeletionTimestamp != nil {
+		return true, nil
+	}
+	if err := common.ValidateSubnetPool(pool); err != nil {
+		return false, err
+	}
+	logrus.Infof("handle flatnetwork subnet pool validate request [%v/%v]",
+		ar.Request.Namespace, pool.Name)
+	return true, nil
+}
@@ -23,7 +23,11 @@ import (
 type Handler struct {
 	ipClient          flcontroller.FlatNetworkIPClient
 	subnetClient      flcontroller.FlatNetworkSubnetClient
+	subnetPoolClient  flcontroller.FlatNetworkSubnetPoolClient
+	ipBlockClient     flcontroller.FlatNetworkIPBlockClient
+	reservationClient flcontroller.FlatNetworkIPReservationClient
 	podClient         corecontroller.PodClient
+	namespaceClient   corecontroller.NamespaceClient
 	deploymentClient  appscontroller.DeploymentClient
 	daemonSetClient   appscontroller.DaemonSetClient
 	statefulSetClient appscontroller.StatefulSetClient
@@ -35,7 +39,11 @@ func NewWebhookHandler(wctx *wrangler.Context) *Handler {
 	return &Handler{
 		ipClient:          wctx.FlatNetwork.FlatNetworkIP(),
 		subnetClient:      wctx.FlatNetwork.FlatNetworkSubnet(),
+		subnetPoolClient:  wctx.FlatNetwork.FlatNetworkSubnetPool(),
+		ipBlockClient:     wctx.FlatNetwork.FlatNetworkIPBlock(),
+		reservationClient: wctx.FlatNetwork.FlatNetworkIPReservation(),
 		podClient:         wctx.Core.Pod(),
+		namespaceClient:   wctx.Core.Namespace(),
 		deploymentClient:  wctx.Apps.Deployment(),
 		daemonSetClient:   wctx.Apps.DaemonSet(),
 		statefulSetClient: wctx.Apps.StatefulSet(),
@@ -155,6 +163,7 @@ const (
 	kindStatefulSet = "StatefulSet"
 	kindCronJob     = "CronJob"
 	kindJob         = "Job"
+	kindPod         = "Pod"
 )
 
 func (h *Handler) validateAdmissionReview(ar *admissionv1.AdmissionReview) (bool, error) {
@@ -167,6 +176,12 @@ func (h *Handler) validateAdmissionReview(ar *admissionv1.AdmissionReview) (bool
 		ok, err = h.validateFlatNetworkSubnet(ar)
 	case kindDeployment, kindDaemonSet, kindStatefulSet, kindCronJob, kindJob:
 		ok, err = h.validateWorkload(ar)
+	case kindPod:
+		ok, err = h.validatePod(ar)
+	case "FlatNetworkIPReservation":
+		ok, err = h.validateFlatNetworkIPReservation(ar)
+	case "FlatNetworkSubnetPool":
+		ok, err = h.validateFlatNetworkSubnetPool(ar)
 	default:
 		return true, nil
 	}
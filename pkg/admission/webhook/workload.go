@@ -6,6 +6,7 @@ import (
 	"net"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -13,6 +14,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
 	"github.com/cnrancher/rancher-flat-network/pkg/common"
@@ -31,19 +33,23 @@ type WorkloadReview struct {
 }
 
 func (r *WorkloadReview) PodTemplateAnnotations(key string) string {
+	return r.PodTemplateAnnotationMap()[key]
+}
+
+func (r *WorkloadReview) PodTemplateAnnotationMap() map[string]string {
 	switch r.AdmissionReview.Request.Kind.Kind {
 	case kindDeployment:
-		return r.Deployment.Spec.Template.Annotations[key]
+		return r.Deployment.Spec.Template.Annotations
 	case kindDaemonSet:
-		return r.DaemonSet.Spec.Template.Annotations[key]
+		return r.DaemonSet.Spec.Template.Annotations
 	case kindStatefulSet:
-		return r.StatefulSet.Spec.Template.Annotations[key]
+		return r.StatefulSet.Spec.Template.Annotations
 	case kindCronJob:
-		return r.CronJob.Spec.JobTemplate.Spec.Template.Annotations[key]
+		return r.CronJob.Spec.JobTemplate.Spec.Template.Annotations
 	case kindJob:
-		return r.Job.Spec.Template.Annotations[key]
+		return r.Job.Spec.Template.Annotations
 	default:
-		return ""
+		return nil
 	}
 }
 
@@ -92,38 +98,35 @@ func (h *Handler) validateWorkload(ar *admissionv1.AdmissionReview) (bool, error
 		workload.PodTemplateAnnotations("v1.multus-cni.io/default-network") == "" {
 		return true, nil
 	}
-	subnetName := workload.PodTemplateAnnotations(flv1.AnnotationSubnet)
-	if subnetName == "" {
+	interfaces, err := common.GetPodInterfaces(workload.PodTemplateAnnotationMap())
+	if err != nil {
+		return false, err
+	}
+	if len(interfaces) == 0 {
 		return true, nil
 	}
 	if h.isUpdatingWorkloadSubnetLabel(workload) {
 		return true, nil
 	}
-	// Check the ip is available in subnet CIDR and not gateway
-	subnet, err := h.subnetClient.Get(
-		flv1.SubnetNamespace, subnetName, metav1.GetOptions{})
-	if err != nil {
-		return false, fmt.Errorf("failed to get subnet %v: %w", subnetName, err)
-	}
-	if err := h.validateAnnotationIP(workload, subnet); err != nil {
-		return false, fmt.Errorf("validate annotation IP failed: %w", err)
-	}
-	if err := h.validateAnnotationMac(workload); err != nil {
-		return false, fmt.Errorf("validate annotation mac failed: %w", err)
+	if err := checkInterfacesDuplicate(interfaces); err != nil {
+		return false, err
 	}
-	if err := h.validateIPsInReserved(workload, subnet); err != nil {
-		return false, fmt.Errorf("validate IP reserved failed: %w", err)
+	bw := common.GetPodAnnotationBandwidth(workload.PodTemplateAnnotationMap())
+	if _, _, err := common.GetBandwidthLimits(bw); err != nil {
+		return false, fmt.Errorf("invalid bandwidth annotations: %w", err)
 	}
 
 	flatNetworkIPs, err := h.getWorkloadPodFlatNetworkIPs(workload)
 	if err != nil {
 		return false, err
 	}
-	if err := h.validateIPsInUsed(workload, subnet, flatNetworkIPs); err != nil {
-		return false, fmt.Errorf("validate IP used failed: %w", err)
-	}
-	if err := h.validateMACsInUsed(workload, subnet, flatNetworkIPs); err != nil {
-		return false, fmt.Errorf("validate MAC used failed: %w", err)
+	for i := range interfaces {
+		if err := h.validateInterface(workload, &interfaces[i], flatNetworkIPs); err != nil {
+			if interfaces[i].Interface != "" {
+				return false, fmt.Errorf("interface [%v]: %w", interfaces[i].Interface, err)
+			}
+			return false, err
+		}
 	}
 
 	logrus.Infof("handle workload [%v] validate request [%v/%v]",
@@ -131,30 +134,286 @@ func (h *Handler) validateWorkload(ar *admissionv1.AdmissionReview) (bool, error
 	return true, nil
 }
 
-func (h *Handler) validateAnnotationIP(
-	workload *WorkloadReview, subnet *flv1.FlatNetworkSubnet,
+// validateInterface validates the flat-network attachment of the pod
+// interface.
+func (h *Handler) validateInterface(
+	workload *WorkloadReview,
+	iface *flv1.PodInterface,
+	flatNetworkIPs []flv1.FlatNetworkIP,
+) error {
+	if iface.SubnetPool != "" {
+		return h.validateInterfaceSubnetPool(workload, iface, flatNetworkIPs)
+	}
+	subnetNames, err := common.CheckPodAnnotationSubnets(iface.Subnet)
+	if err != nil {
+		return err
+	}
+	if len(subnetNames) == 0 {
+		return nil
+	}
+	// Check the ip is available in subnet CIDR and not gateway
+	subnets := []*flv1.FlatNetworkSubnet{}
+	for _, subnetName := range subnetNames {
+		subnet, err := h.subnetClient.Get(
+			flv1.SubnetNamespace, subnetName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get subnet %v: %w", subnetName, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	subnet := subnets[0]
+	if len(subnets) > 1 {
+		if err := common.CheckDualStackSubnets(subnet, subnets[1]); err != nil {
+			return err
+		}
+	}
+	if err := h.validateSubnetsNamespace(workload.AdmissionReview.Request.Namespace, subnets); err != nil {
+		return err
+	}
+	if err := validateSubnetsCordoned(workload, subnets, flatNetworkIPs); err != nil {
+		return err
+	}
+	ips, err := common.CheckPodAnnotationIPs(iface.IP)
+	if err != nil {
+		return err
+	}
+	macs, err := common.CheckPodAnnotationMACs(iface.MAC)
+	if err != nil {
+		return err
+	}
+	if err := h.validateAnnotationIP(ips, subnets); err != nil {
+		return fmt.Errorf("validate annotation IP failed: %w", err)
+	}
+	if err := h.validateAnnotationMac(ips, macs, subnets); err != nil {
+		return fmt.Errorf("validate annotation mac failed: %w", err)
+	}
+	if workload.AdmissionReview.Request.Kind.Kind == kindStatefulSet {
+		if err := validateStatefulSetAddrs(&workload.StatefulSet, ips, macs); err != nil {
+			return fmt.Errorf("validate statefulset addresses failed: %w", err)
+		}
+	}
+	for _, subnet := range subnets {
+		if err := h.validateIPsInReserved(workload, ips, subnet); err != nil {
+			return fmt.Errorf("validate IP reserved failed: %w", err)
+		}
+		if err := h.validateIPsInUsed(ips, subnet, flatNetworkIPs); err != nil {
+			return fmt.Errorf("validate IP used failed: %w", err)
+		}
+		if err := h.validateSubnetQuota(workload, subnet, flatNetworkIPs); err != nil {
+			return fmt.Errorf("validate subnet quota failed: %w", err)
+		}
+	}
+	if err := h.validateMACsInUsed(macs, subnet, flatNetworkIPs); err != nil {
+		return fmt.Errorf("validate MAC used failed: %w", err)
+	}
+	return nil
+}
+
+// validateInterfaceSubnetPool validates the flat-network attachment of the
+// pod interface using the subnet pool, at least one subnet of the pool
+// should be available for the workload.
+func (h *Handler) validateInterfaceSubnetPool(
+	workload *WorkloadReview,
+	iface *flv1.PodInterface,
+	flatNetworkIPs []flv1.FlatNetworkIP,
+) error {
+	pool, err := h.subnetPoolClient.Get(flv1.SubnetNamespace, iface.SubnetPool, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get subnet pool %v: %w", iface.SubnetPool, err)
+	}
+	subnetList, err := h.subnetClient.List(flv1.SubnetNamespace, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list subnets: %w", err)
+	}
+	subnets := make([]*flv1.FlatNetworkSubnet, 0, len(subnetList.Items))
+	for i := range subnetList.Items {
+		subnets = append(subnets, &subnetList.Items[i])
+	}
+	subnets, err = common.GetSubnetPoolSubnets(pool, subnets)
+	if err != nil {
+		return err
+	}
+	macs, err := common.CheckPodAnnotationMACs(iface.MAC)
+	if err != nil {
+		return err
+	}
+	if err := h.validateAnnotationMac(nil, macs, subnets); err != nil {
+		return fmt.Errorf("validate annotation mac failed: %w", err)
+	}
+	var errs []string
+	for _, subnet := range subnets {
+		err := h.validateSubnetsNamespace(workload.AdmissionReview.Request.Namespace,
+			[]*flv1.FlatNetworkSubnet{subnet})
+		if err == nil {
+			err = validateSubnetsCordoned(workload, []*flv1.FlatNetworkSubnet{subnet}, flatNetworkIPs)
+		}
+		if err == nil {
+			err = h.validateMACsInUsed(macs, subnet, flatNetworkIPs)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		// The subnet pool is available if any subnet of the pool can be used.
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("subnet pool [%v] does not select any subnet", pool.Name)
+	}
+	return fmt.Errorf("no available subnet in subnet pool [%v]: [%v]",
+		pool.Name, strings.Join(errs, "; "))
+}
+
+// validateSubnetsCordoned rejects the new workloads using the cordoned
+// subnets, the existing workloads already have pods using the cordoned
+// subnet are allowed to update.
+func validateSubnetsCordoned(
+	workload *WorkloadReview,
+	subnets []*flv1.FlatNetworkSubnet,
+	flatNetworkIPs []flv1.FlatNetworkIP,
+) error {
+	for _, subnet := range subnets {
+		if !subnet.Spec.Cordoned {
+			continue
+		}
+		if workload.AdmissionReview.Request.Operation == admissionv1.Update &&
+			slices.ContainsFunc(flatNetworkIPs, func(ip flv1.FlatNetworkIP) bool {
+				return len(common.GetFlatNetworkIPAddrOfSubnet(&ip, subnet.Name)) != 0
+			}) {
+			continue
+		}
+		return common.CheckSubnetCordoned(subnet)
+	}
+	return nil
+}
+
+// validateStatefulSetAddrs ensures the specified IP & MAC addresses are
+// enough for the StatefulSet replicas, as the N-th address is pinned to the
+// N-th StatefulSet pod.
+func validateStatefulSetAddrs(
+	sts *appsv1.StatefulSet, ips []net.IP, macs []string,
 ) error {
-	// Check annotation IP format.
-	ips, err := common.CheckPodAnnotationIPs(workload.PodTemplateAnnotations(flv1.AnnotationIP))
+	replicas := 1
+	if sts.Spec.Replicas != nil {
+		replicas = int(*sts.Spec.Replicas)
+	}
+	if replicas <= 0 {
+		return nil
+	}
+	if _, err := common.GetOrdinalIPs(ips, replicas-1); err != nil {
+		return fmt.Errorf("statefulset replicas [%v] exceeds the number of IPs: %w",
+			replicas, err)
+	}
+	if _, err := common.GetOrdinalMACs(macs, replicas-1); err != nil {
+		return fmt.Errorf("statefulset replicas [%v] exceeds the number of MACs: %w",
+			replicas, err)
+	}
+	return nil
+}
+
+// validateSubnetQuota ensures the workload replicas do not exceed the
+// subnet quotas of the workload namespace.
+func (h *Handler) validateSubnetQuota(
+	workload *WorkloadReview,
+	subnet *flv1.FlatNetworkSubnet,
+	flatNetworkIPs []flv1.FlatNetworkIP,
+) error {
+	if subnet == nil || len(subnet.Spec.Quotas) == 0 {
+		return nil
+	}
+	replicas, ok := workloadReplicas(workload)
+	if !ok {
+		return nil
+	}
+	// The IPs already allocated by the workload pods are counted in the
+	// quota usage.
+	for i := range flatNetworkIPs {
+		if len(common.GetFlatNetworkIPAddrOfSubnet(&flatNetworkIPs[i], subnet.Name)) != 0 {
+			replicas--
+		}
+	}
+	if replicas <= 0 {
+		return nil
+	}
+	namespace := workload.AdmissionReview.Request.Namespace
+	ns, err := h.namespaceClient.Get(namespace, metav1.GetOptions{})
 	if err != nil {
+		return fmt.Errorf("failed to get namespace %v: %w", namespace, err)
+	}
+	return common.CheckSubnetQuota(subnet, subnet.Status.QuotaUsage,
+		namespace, common.GetNamespaceProjectID(ns), replicas)
+}
+
+// workloadReplicas returns the number of pods expected by the workload.
+// The DaemonSet pod number depends on the nodes and is not checked.
+func workloadReplicas(workload *WorkloadReview) (int, bool) {
+	var replicas *int32
+	switch workload.AdmissionReview.Request.Kind.Kind {
+	case kindDeployment:
+		replicas = workload.Deployment.Spec.Replicas
+	case kindStatefulSet:
+		replicas = workload.StatefulSet.Spec.Replicas
+	case kindCronJob:
+		replicas = workload.CronJob.Spec.JobTemplate.Spec.Parallelism
+	case kindJob:
+		replicas = workload.Job.Spec.Parallelism
+	default:
+		return 0, false
+	}
+	if replicas == nil {
+		return 1, true
+	}
+	return int(*replicas), true
+}
+
+// checkInterfacesDuplicate ensures the specified IP and MAC addresses are
+// not duplicated in all pod interfaces.
+func checkInterfacesDuplicate(interfaces []flv1.PodInterface) error {
+	ips := []net.IP{}
+	macs := []string{}
+	for _, iface := range interfaces {
+		i, err := common.CheckPodAnnotationIPs(iface.IP)
+		if err != nil {
+			return err
+		}
+		m, err := common.CheckPodAnnotationMACs(iface.MAC)
+		if err != nil {
+			return err
+		}
+		ips = append(ips, i...)
+		macs = append(macs, m...)
+	}
+	if err := checkIPDuplicate(ips); err != nil {
 		return err
 	}
+	return checkMacDuplicate(macs)
+}
+
+func (h *Handler) validateAnnotationIP(
+	ips []net.IP, subnets []*flv1.FlatNetworkSubnet,
+) error {
 	// IP allocation mode is auto
 	if len(ips) == 0 {
 		return nil
 	}
 
 	// Check the ip is not duplicated
-	err = checkIPDuplicate(ips)
+	err := checkIPDuplicate(ips)
 	if err != nil {
 		return err
 	}
 
-	err = checkIPsInSubnet(ips, subnet)
-	if err != nil {
-		return err
+	if len(subnets) == 1 {
+		return checkIPsInSubnet(ips, subnets[0])
 	}
 
+	// Dual-stack, check the IPs of each IP family in the subnet.
+	for _, subnet := range subnets {
+		err = checkIPsInSubnet(common.GetSubnetFamilyIPs(ips, subnet), subnet)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -200,14 +459,12 @@ func checkIPsInSubnet(ips []net.IP, subnet *flv1.FlatNetworkSubnet) error {
 	return nil
 }
 
-func (h *Handler) validateAnnotationMac(workload *WorkloadReview) error {
-	ips, err := common.CheckPodAnnotationIPs(workload.PodTemplateAnnotations(flv1.AnnotationIP))
-	if err != nil {
-		return err
-	}
-	macs, err := common.CheckPodAnnotationMACs(workload.PodTemplateAnnotations(flv1.AnnotationMac))
-	if err != nil {
-		return err
+func (h *Handler) validateAnnotationMac(
+	ips []net.IP, macs []string, subnets []*flv1.FlatNetworkSubnet,
+) error {
+	if len(subnets) > 1 {
+		// Dual-stack, the MAC addresses are mapped to the primary subnet IPs.
+		ips = common.GetSubnetFamilyIPs(ips, subnets[0])
 	}
 	// MAC allocation mode is auto
 	if len(macs) == 0 {
@@ -239,12 +496,8 @@ func checkMacDuplicate(macs []string) error {
 }
 
 func (h *Handler) validateIPsInReserved(
-	workload *WorkloadReview, subnet *flv1.FlatNetworkSubnet,
+	workload *WorkloadReview, ips []net.IP, subnet *flv1.FlatNetworkSubnet,
 ) error {
-	ips, err := common.CheckPodAnnotationIPs(workload.PodTemplateAnnotations(flv1.AnnotationIP))
-	if err != nil {
-		return err
-	}
 	if subnet == nil || len(ips) == 0 {
 		return nil
 	}
@@ -254,6 +507,10 @@ func (h *Handler) validateIPsInReserved(
 			return nil
 		}
 	}
+	subnet, err := h.getSubnetAllocation(subnet)
+	if err != nil {
+		return err
+	}
 	if len(subnet.Status.ReservedIP) == 0 {
 		// Subnet does not have reserved IPs used by workloads.
 		return nil
@@ -272,7 +529,7 @@ func (h *Handler) validateIPsInReserved(
 		w = &workload.Job
 	}
 	key := common.GetWorkloadReservdIPKey(w)
-	for _, ip := range ips {
+	for _, ip := range common.GetSubnetFamilyIPs(ips, subnet) {
 		for k, ipRange := range subnet.Status.ReservedIP {
 			if k == key {
 				continue
@@ -331,29 +588,64 @@ func (h *Handler) getWorkloadPodFlatNetworkIPs(
 	return result, nil
 }
 
+// getSubnetAllocation returns the subnet with the usedIP, usedMAC & reservedIP
+// status aggregated from the IP blocks and the FlatNetworkIPReservations of
+// the subnet.
+func (h *Handler) getSubnetAllocation(
+	subnet *flv1.FlatNetworkSubnet,
+) (*flv1.FlatNetworkSubnet, error) {
+	list, err := h.ipBlockClient.List(flv1.SubnetNamespace, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{
+			common.LabelIPBlockSubnet: subnet.Name,
+		}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP blocks of subnet %v: %w", subnet.Name, err)
+	}
+	blocks := make([]*flv1.FlatNetworkIPBlock, 0, len(list.Items))
+	for i := range list.Items {
+		blocks = append(blocks, &list.Items[i])
+	}
+	rList, err := h.reservationClient.List("", metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP reservations: %w", err)
+	}
+	reservations := make([]*flv1.FlatNetworkIPReservation, 0, len(rList.Items))
+	for i := range rList.Items {
+		reservations = append(reservations, &rList.Items[i])
+	}
+	result := common.MergeSubnetIPBlocks(subnet, blocks)
+	common.SetSubnetIPReservations(result,
+		common.GetSubnetIPReservations(subnet, reservations, time.Now()))
+	return result, nil
+}
+
 func (h *Handler) validateIPsInUsed(
-	workload *WorkloadReview,
+	ips []net.IP,
 	subnet *flv1.FlatNetworkSubnet,
 	flatnetworkIPs []flv1.FlatNetworkIP,
 ) error {
-	ips, err := common.CheckPodAnnotationIPs(workload.PodTemplateAnnotations(flv1.AnnotationIP))
-	if err != nil {
-		return err
-	}
 	if subnet == nil {
 		return nil
 	}
 	if flatnetworkIPs == nil {
 		flatnetworkIPs = []flv1.FlatNetworkIP{}
 	}
+	subnet, err := h.getSubnetAllocation(subnet)
+	if err != nil {
+		return err
+	}
 	usedIP := subnet.Status.DeepCopy().UsedIP
-	for _, ip := range flatnetworkIPs {
-		if len(ip.Status.Addr) == 0 {
+	for i := range flatnetworkIPs {
+		addr := common.GetFlatNetworkIPAddrOfSubnet(&flatnetworkIPs[i], subnet.Name)
+		if len(addr) == 0 {
 			continue
 		}
-		usedIP = ipcalc.RemoveIPFromRange(ip.Status.Addr, usedIP)
+		usedIP = ipcalc.RemoveIPFromRange(addr, usedIP)
 	}
-	for _, ip := range ips {
+	// The IPs reserved by FlatNetworkIPReservations can not be used by pods.
+	usedIP = append(usedIP, common.GetSubnetReservationRanges(subnet)...)
+	for _, ip := range common.GetSubnetFamilyIPs(ips, subnet) {
 		if ipcalc.IPInRanges(ip, usedIP) {
 			return fmt.Errorf("IP %q already uesd by other pods", ip.String())
 		}
@@ -363,17 +655,17 @@ func (h *Handler) validateIPsInUsed(
 }
 
 func (h *Handler) validateMACsInUsed(
-	workload *WorkloadReview,
+	macs []string,
 	subnet *flv1.FlatNetworkSubnet,
 	flatnetworkIPs []flv1.FlatNetworkIP,
 ) error {
-	macs, err := common.CheckPodAnnotationMACs(workload.PodTemplateAnnotations(flv1.AnnotationMac))
-	if err != nil {
-		return err
-	}
 	if subnet == nil {
 		return nil
 	}
+	subnet, err := h.getSubnetAllocation(subnet)
+	if err != nil {
+		return err
+	}
 	usedMAC := subnet.Status.DeepCopy().UsedMAC
 	slices.Sort(usedMAC)
 	if flatnetworkIPs == nil {
@@ -406,7 +698,8 @@ func (h *Handler) isUpdatingWorkloadSubnetLabel(workload *WorkloadReview) bool {
 			return false
 		}
 		if old.Labels[flv1.LabelFlatNetworkIPType] != workload.Deployment.Labels[flv1.LabelFlatNetworkIPType] ||
-			old.Labels[flv1.LabelSubnet] != workload.Deployment.Labels[flv1.LabelSubnet] {
+			old.Labels[flv1.LabelSubnet] != workload.Deployment.Labels[flv1.LabelSubnet] ||
+			old.Labels[flv1.LabelSubnetPool] != workload.Deployment.Labels[flv1.LabelSubnetPool] {
 			return true
 		}
 	case kindDaemonSet:
@@ -415,7 +708,8 @@ func (h *Handler) isUpdatingWorkloadSubnetLabel(workload *WorkloadReview) bool {
 			return false
 		}
 		if old.Labels[flv1.LabelFlatNetworkIPType] != workload.DaemonSet.Labels[flv1.LabelFlatNetworkIPType] ||
-			old.Labels[flv1.LabelSubnet] != workload.DaemonSet.Labels[flv1.LabelSubnet] {
+			old.Labels[flv1.LabelSubnet] != workload.DaemonSet.Labels[flv1.LabelSubnet] ||
+			old.Labels[flv1.LabelSubnetPool] != workload.DaemonSet.Labels[flv1.LabelSubnetPool] {
 			return true
 		}
 	case kindStatefulSet:
@@ -424,7 +718,8 @@ func (h *Handler) isUpdatingWorkloadSubnetLabel(workload *WorkloadReview) bool {
 			return false
 		}
 		if old.Labels[flv1.LabelFlatNetworkIPType] != workload.StatefulSet.Labels[flv1.LabelFlatNetworkIPType] ||
-			old.Labels[flv1.LabelSubnet] != workload.StatefulSet.Labels[flv1.LabelSubnet] {
+			old.Labels[flv1.LabelSubnet] != workload.StatefulSet.Labels[flv1.LabelSubnet] ||
+			old.Labels[flv1.LabelSubnetPool] != workload.StatefulSet.Labels[flv1.LabelSubnetPool] {
 			return true
 		}
 	case kindCronJob:
@@ -433,7 +728,8 @@ func (h *Handler) isUpdatingWorkloadSubnetLabel(workload *WorkloadReview) bool {
 			return false
 		}
 		if old.Labels[flv1.LabelFlatNetworkIPType] != workload.CronJob.Labels[flv1.LabelFlatNetworkIPType] ||
-			old.Labels[flv1.LabelSubnet] != workload.CronJob.Labels[flv1.LabelSubnet] {
+			old.Labels[flv1.LabelSubnet] != workload.CronJob.Labels[flv1.LabelSubnet] ||
+			old.Labels[flv1.LabelSubnetPool] != workload.CronJob.Labels[flv1.LabelSubnetPool] {
 			return true
 		}
 	case kindJob:
@@ -442,7 +738,8 @@ func (h *Handler) isUpdatingWorkloadSubnetLabel(workload *WorkloadReview) bool {
 			return false
 		}
 		if old.Labels[flv1.LabelFlatNetworkIPType] != workload.Job.Labels[flv1.LabelFlatNetworkIPType] ||
-			old.Labels[flv1.LabelSubnet] != workload.Job.Labels[flv1.LabelSubnet] {
+			old.Labels[flv1.LabelSubnet] != workload.Job.Labels[flv1.LabelSubnet] ||
+			old.Labels[flv1.LabelSubnetPool] != workload.Job.Labels[flv1.LabelSubnetPool] {
 			return true
 		}
 	}
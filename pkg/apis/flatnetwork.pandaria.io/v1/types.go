@@ -5,6 +5,7 @@ import (
 	"net"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -14,14 +15,24 @@ const (
 	AnnotationPrefix             = "flatnetwork.pandaria.io/"
 	AnnotationIP                 = "flatnetwork.pandaria.io/ip"
 	AnnotationSubnet             = "flatnetwork.pandaria.io/subnet"
+	AnnotationSubnetPool         = "flatnetwork.pandaria.io/subnetPool"
 	AnnotationMac                = "flatnetwork.pandaria.io/mac"
 	AnnotationIngress            = "flatnetwork.pandaria.io/ingress"
 	AnnotationFlatNetworkService = "flatnetwork.pandaria.io/flatNetworkService"
 	AnnotationsIPv6to4           = "flatnetwork.pandaria.io/ipv6to4"
+	AnnotationInterfaces         = "flatnetwork.pandaria.io/interfaces"
+	AnnotationForceDelete        = "flatnetwork.pandaria.io/forceDelete"
+	AnnotationIngressRate        = "flatnetwork.pandaria.io/ingressRate"
+	AnnotationIngressBurst       = "flatnetwork.pandaria.io/ingressBurst"
+	AnnotationEgressRate         = "flatnetwork.pandaria.io/egressRate"
+	AnnotationEgressBurst        = "flatnetwork.pandaria.io/egressBurst"
+	AnnotationPod                = "flatnetwork.pandaria.io/pod"
 
 	// Specification for Labels
 	LabelSelectedIP        = "flatnetwork.pandaria.io/selectedIP"
 	LabelSubnet            = "flatnetwork.pandaria.io/subnet"
+	LabelSecondarySubnet   = "flatnetwork.pandaria.io/secondarySubnet"
+	LabelSubnetPool        = "flatnetwork.pandaria.io/subnetPool"
 	LabelFlatMode          = "flatnetwork.pandaria.io/flatMode"
 	LabelFlatNetworkIPType = "flatnetwork.pandaria.io/flatNetworkIPType"
 	LabelSelectedMac       = "flatnetwork.pandaria.io/selectedMac"
@@ -33,9 +44,54 @@ const (
 	AllocateModeAuto     = "auto"
 	AllocateModeSpecific = "specific"
 
+	// Specification for IP allocation strategies
+	AllocationStrategySequential = "sequential"
+	AllocationStrategyRandom     = "random"
+	AllocationStrategyLRU        = "lru"
+
+	// Specification for MAC allocation modes
+	MACAllocationRandom = "random"
+	MACAllocationIP     = "ip"
+
+	// Specification for remediation actions
+	RemediationActionDeletePod  = "deletePod"
+	RemediationActionEvent      = "event"
+	RemediationActionMarkFailed = "markFailed"
+
+	// Specification for duplicated IP tie-breakers
+	DuplicateTieBreakerNone   = "none"
+	DuplicateTieBreakerOldest = "oldest"
+	DuplicateTieBreakerActive = "active"
+
+	// Specification for IP remediation reasons
+	RemediationPendingTimeout = "PendingTimeout"
+	RemediationDuplicatedIP   = "DuplicatedIP"
+
+	// Specification for IP conditions
+	IPConditionAllocated           = "Allocated"
+	IPConditionInterfaceConfigured = "InterfaceConfigured"
+	IPConditionConflict            = "Conflict"
+
+	// IPBlockSize is the number of the addresses of a FlatNetworkIPBlock.
+	IPBlockSize = 64
+
+	// MaxSubnetStatusItems is the max number of the per-address items of
+	// each list stored in the subnet status.
+	MaxSubnetStatusItems = 16
+
+	// Specification for subnet conditions
+	SubnetConditionReady           = "Ready"
+	SubnetConditionNearlyExhausted = "NearlyExhausted"
+	SubnetConditionExhausted       = "Exhausted"
+	SubnetConditionCordoned        = "Cordoned"
+	SubnetConditionConsistent      = "Consistent"
+
 	// Specification for flatModes
 	FlatModeIPvlan  = "ipvlan"
 	FlatModeMacvlan = "macvlan"
+
+	// MTUAuto is the subnet MTU using the MTU of the host interface.
+	MTUAuto = "auto"
 )
 
 // +genclient
@@ -53,8 +109,18 @@ type FlatNetworkIP struct {
 // IPSpec is the spec for a IP resource
 type IPSpec struct {
 	// Subnet is the name of the flat-network subnet resource (required).
+	// The subnet is selected by the operator from the SubnetPool if the
+	// SubnetPool is specified.
 	Subnet string `json:"subnet"`
 
+	// SubnetPool is the name of the flat-network subnet pool to select the
+	// subnet from (optional).
+	SubnetPool string `json:"subnetPool,omitempty"`
+
+	// SecondarySubnet is the name of the flat-network subnet resource
+	// in another IP family for dual-stack (optional).
+	SecondarySubnet string `json:"secondarySubnet,omitempty"`
+
 	// Addrs is the user specified IP addresses (optional).
 	Addrs []net.IP `json:"addrs"`
 
@@ -63,6 +129,38 @@ type IPSpec struct {
 
 	// PodID is the Pod metadata.UID
 	PodID string `json:"podId"`
+
+	// Interface is the pod interface name of the additional flat-network
+	// attachment, empty for the default attachment (optional).
+	Interface string `json:"interface,omitempty"`
+}
+
+// PodInterface is the flat-network attachment of the pod interface.
+//
+// The additional attachments are defined in the
+// 'flatnetwork.pandaria.io/interfaces' pod annotation in JSON format:
+// [{"interface":"eth2","subnet":"subnet-name","ip":"auto","mac":""}]
+type PodInterface struct {
+	// Interface is the pod interface name, should be the same with the
+	// multus network selection element interface.
+	Interface string `json:"interface"`
+
+	// Subnet is the flat-network subnet name(s), same format as the
+	// 'flatnetwork.pandaria.io/subnet' annotation.
+	Subnet string `json:"subnet,omitempty"`
+
+	// SubnetPool is the flat-network subnet pool name, same format as the
+	// 'flatnetwork.pandaria.io/subnetPool' annotation.
+	// Only one of the Subnet and SubnetPool can be specified.
+	SubnetPool string `json:"subnetPool,omitempty"`
+
+	// IP is the IP allocation, same format as the
+	// 'flatnetwork.pandaria.io/ip' annotation.
+	IP string `json:"ip,omitempty"`
+
+	// MAC is the MAC allocation, same format as the
+	// 'flatnetwork.pandaria.io/mac' annotation.
+	MAC string `json:"mac,omitempty"`
 }
 
 type IPStatus struct {
@@ -75,9 +173,55 @@ type IPStatus struct {
 	// Addr is the allocated IP address.
 	Addr net.IP `json:"addr"`
 
+	// SecondaryAddr is the allocated IP address of the secondary subnet
+	// for dual-stack.
+	SecondaryAddr net.IP `json:"secondaryAddr,omitempty"`
+
 	// MAC is actual allocated MAC address by CNI
 	// can be random in auto mode, or specidied by user.
 	MAC string `json:"mac"`
+
+	// Remediation is the reason of the remediation applied to this IP by the
+	// subnet remediation policies, can be 'PendingTimeout', 'DuplicatedIP'.
+	Remediation string `json:"remediation,omitempty"`
+
+	// NodeName is the node where the pod interface configured by CNI.
+	NodeName string `json:"nodeName,omitempty"`
+
+	// ContainerID is the pod sandbox container ID reported by CNI.
+	ContainerID string `json:"containerID,omitempty"`
+
+	// Workload is the workload creating the pod, in 'Kind/Name' format.
+	Workload string `json:"workload,omitempty"`
+
+	// Allocations is the number of times the address allocated by operator.
+	Allocations int `json:"allocations,omitempty"`
+
+	// Conditions is the 'Allocated', 'InterfaceConfigured' and 'Conflict'
+	// conditions of the IP.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// History is the recent allocation records of the IP, the oldest
+	// records are dropped when exceeding the limit.
+	History []IPAllocationRecord `json:"history,omitempty"`
+}
+
+// IPAllocationRecord is the record of the address allocated by operator or
+// the pod interface configured by CNI.
+type IPAllocationRecord struct {
+	// Time is the time (UTC) of the record.
+	Time metav1.Time `json:"time"`
+
+	// Reason is the 'Allocated' or 'InterfaceConfigured'.
+	Reason string `json:"reason"`
+
+	Addr          net.IP `json:"addr,omitempty"`
+	SecondaryAddr net.IP `json:"secondaryAddr,omitempty"`
+	MAC           string `json:"mac,omitempty"`
+
+	// NodeName & ContainerID are reported by CNI.
+	NodeName    string `json:"nodeName,omitempty"`
+	ContainerID string `json:"containerID,omitempty"`
 }
 
 ////////////////////
@@ -101,6 +245,12 @@ type SubnetSpec struct {
 	// Master is the network interface name.
 	Master string `json:"master"`
 
+	// MasterSelectors maps the nodes to the master network interfaces
+	// (optional) for the nodes with different interface names.
+	// The master of the first selector matching the node labels is used,
+	// fallback to the Master if no selector matches.
+	MasterSelectors []MasterSelector `json:"masterSelectors,omitempty"`
+
 	// VLAN is the VLAN ID of this subnet.
 	VLAN int `json:"vlan"`
 
@@ -129,6 +279,221 @@ type SubnetSpec struct {
 
 	// RouteSettings provides some advanced options for custom routes.
 	RouteSettings RouteSettings `json:"routeSettings"`
+
+	// IPReuseDelay is the delay seconds before a released IP address can be
+	// re-allocated in auto mode (optional).
+	// The released IP will not be reused until the upstream ARP caches
+	// of the released address expired. Set to 0 to disable.
+	IPReuseDelay int64 `json:"ipReuseDelay,omitempty"`
+
+	// AllocationStrategy is the strategy to allocate IP address in auto mode
+	// (optional), can be 'sequential', 'random', 'lru' (default 'sequential').
+	//
+	// sequential: allocate the lowest available address;
+	// random: allocate an available address randomly;
+	// lru: allocate the address released longest ago, the addresses never
+	// used are preferred.
+	AllocationStrategy string `json:"allocationStrategy,omitempty"`
+
+	// NearlyExhaustedThreshold is the percentage of the used addresses to
+	// mark the subnet as nearly exhausted (optional), can be 1-100
+	// (default 90).
+	NearlyExhaustedThreshold int `json:"nearlyExhaustedThreshold,omitempty"`
+
+	// MACPrefix is the MAC address prefix (OUI) of the pods using this
+	// subnet (optional), e.g. '02:42:ac', should be a unicast address prefix
+	// of 1-5 bytes. The MAC addresses of the pods are allocated by the
+	// operator within the prefix if specified, otherwise the MAC addresses
+	// are randomly generated by the kernel.
+	MACPrefix string `json:"macPrefix,omitempty"`
+
+	// MACAllocation is the mode to allocate MAC address within the
+	// MACPrefix (optional), can be 'random', 'ip' (default 'random').
+	//
+	// random: allocate an unused MAC address randomly;
+	// ip: derive the MAC address from the low bytes of the allocated IP.
+	MACAllocation string `json:"macAllocation,omitempty"`
+
+	// NamespaceSelector selects the namespaces allowed to use this subnet
+	// (optional). All namespaces are allowed if not specified.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Projects is the Rancher project IDs allowed to use this subnet
+	// (optional), matched with the namespace 'field.cattle.io/projectId'
+	// label. All projects are allowed if not specified.
+	Projects []string `json:"projects,omitempty"`
+
+	// Quotas limits the number of IP addresses allocated by the namespaces
+	// or Rancher projects on this subnet (optional).
+	// The quotas are best-effort, the limit may be exceeded by the concurrent
+	// allocations of multiple operator replicas.
+	Quotas []SubnetQuota `json:"quotas,omitempty"`
+
+	// Cordoned marks the subnet as unschedulable (optional).
+	// No new IP address is allocated from the cordoned subnet and the new
+	// workloads using the cordoned subnet are rejected, the existing pods
+	// keep their IP addresses.
+	Cordoned bool `json:"cordoned,omitempty"`
+
+	// Drain moves the pods out of the cordoned subnet (optional).
+	Drain *SubnetDrain `json:"drain,omitempty"`
+
+	// Remediation is the remediation policies of the pods using this subnet
+	// (optional). The pods are deleted if not specified.
+	Remediation *SubnetRemediation `json:"remediation,omitempty"`
+
+	// Priority is the priority of the subnet in the subnet pools (optional).
+	// The subnets with higher priority are used first, the subnets with
+	// the same priority are sorted by name.
+	Priority int `json:"priority,omitempty"`
+
+	// MTU is the MTU of the pod flat-network interfaces using this subnet
+	// (optional), can be a number or 'auto'.
+	//
+	// auto: use the MTU of the host master (or VLAN) interface;
+	// number: the MTU should not exceed the MTU of the host master interface,
+	// the host VLAN interface MTU is set to match.
+	// The MTU of the NetworkAttachmentDefinition config is used if not
+	// specified.
+	MTU *intstr.IntOrString `json:"mtu,omitempty"`
+
+	// Bandwidth is the default bandwidth limits of the pod flat-network
+	// interfaces using this subnet (optional), overridden by the pod
+	// bandwidth annotations.
+	Bandwidth *Bandwidth `json:"bandwidth,omitempty"`
+}
+
+// Bandwidth is the bandwidth limits of the pod flat-network interface.
+// The rates are in bits per second and the bursts are in bits, in the
+// quantity format, e.g. '100M', '1Gi'. The limit is disabled if the rate is
+// not specified, the burst is the same as the rate if not specified.
+type Bandwidth struct {
+	// IngressRate is the rate limit of the traffic received by the pod.
+	IngressRate string `json:"ingressRate,omitempty"`
+
+	// IngressBurst is the burst of the traffic received by the pod.
+	IngressBurst string `json:"ingressBurst,omitempty"`
+
+	// EgressRate is the rate limit of the traffic sent by the pod.
+	EgressRate string `json:"egressRate,omitempty"`
+
+	// EgressBurst is the burst of the traffic sent by the pod.
+	EgressBurst string `json:"egressBurst,omitempty"`
+}
+
+// MasterSelector is the master network interface of the selected nodes.
+type MasterSelector struct {
+	// NodeSelector selects the nodes by labels.
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+
+	// Master is the network interface name on the selected nodes.
+	Master string `json:"master"`
+}
+
+// SubnetDrain is the drain settings of the cordoned subnet.
+type SubnetDrain struct {
+	// ReplacementSubnet is the subnet to move the workloads to (optional).
+	// The Deployments, DaemonSets, StatefulSets and CronJobs using the
+	// cordoned subnet are rolled to the replacement subnet by updating the
+	// subnet annotations of the pod template.
+	// The other pods using the cordoned subnet are evicted.
+	ReplacementSubnet string `json:"replacementSubnet,omitempty"`
+}
+
+// SubnetRemediation is the remediation policies of the pods when the pod
+// network is not setup by CNI in time, or the IP address is duplicated.
+type SubnetRemediation struct {
+	// PendingTimeout is the seconds to wait for the pod network setup by CNI
+	// after the IP address allocated (optional, default 300).
+	PendingTimeout int64 `json:"pendingTimeout,omitempty"`
+
+	// PendingAction is the action when the PendingTimeout exceeded
+	// (optional), can be 'deletePod', 'event', 'markFailed'
+	// (default 'deletePod').
+	//
+	// deletePod: delete the pod;
+	// event: record a warning event on the pod only;
+	// markFailed: mark the flat-network IP as Failed and record a warning
+	// event, the pod is kept.
+	PendingAction string `json:"pendingAction,omitempty"`
+
+	// DuplicateAction is the action to the pods sharing the same IP address
+	// (optional), can be 'deletePod', 'event', 'markFailed'
+	// (default 'deletePod').
+	DuplicateAction string `json:"duplicateAction,omitempty"`
+
+	// DuplicateTieBreaker decides the pod keeps the duplicated IP address
+	// (optional), can be 'none', 'oldest', 'active' (default 'none').
+	//
+	// none: the DuplicateAction applies to all pods sharing the address;
+	// oldest: keep the pod with the oldest allocation;
+	// active: keep the pod with the Active IP (the oldest allocation if
+	// more than one), or the oldest allocation if no Active IP.
+	DuplicateTieBreaker string `json:"duplicateTieBreaker,omitempty"`
+}
+
+// SubnetQuota is the max number of IP addresses can be allocated on the
+// subnet by a namespace or a Rancher project.
+// Only one of the Namespace and Project can be specified.
+type SubnetQuota struct {
+	// Namespace is the namespace name of the quota.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Project is the Rancher project ID of the quota, can be 'p-xxxxx'
+	// or '<clusterID>:p-xxxxx'.
+	Project string `json:"project,omitempty"`
+
+	// Limit is the max number of IP addresses.
+	Limit int `json:"limit"`
+}
+
+// OutOfRangeIP is the allocated flat-network IP outside of the subnet
+// CIDR or ranges.
+type OutOfRangeIP struct {
+	// Addr is the allocated IP address.
+	Addr net.IP `json:"addr"`
+
+	// FlatNetworkIP is the '<namespace>/<name>' of the flat-network IP.
+	FlatNetworkIP string `json:"flatNetworkIP"`
+}
+
+// SubnetConsistency is the result of the consistency check between the
+// IP blocks and the flat-network IPs of the subnet.
+// Only the numbers of the drift and the first MaxSubnetStatusItems items of
+// each kind are stored, the full drift is logged by operator.
+type SubnetConsistency struct {
+	// LastCheckTime is the time of the last consistency check.
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// LeakedIP is the addresses recorded in the IP blocks but not used by
+	// any flat-network IP, the leaked addresses are released by the check
+	// if also leaked in the previous check.
+	LeakedIP      []IPRange `json:"leakedIP,omitempty"`
+	LeakedIPCount int       `json:"leakedIPCount,omitempty"`
+
+	// UntrackedIP is the addresses used by the flat-network IPs but not
+	// recorded in the IP blocks, the untracked addresses are recorded into
+	// the IP blocks by the check.
+	UntrackedIP      []IPRange `json:"untrackedIP,omitempty"`
+	UntrackedIPCount int       `json:"untrackedIPCount,omitempty"`
+
+	// LeakedMAC is the MAC addresses recorded in the IP blocks but not used
+	// by any flat-network IP, released in the same way as LeakedIP.
+	LeakedMAC      []string `json:"leakedMAC,omitempty"`
+	LeakedMACCount int      `json:"leakedMACCount,omitempty"`
+
+	// UntrackedMAC is the MAC addresses used by the flat-network IPs but
+	// not recorded in the IP blocks.
+	UntrackedMAC      []string `json:"untrackedMAC,omitempty"`
+	UntrackedMACCount int      `json:"untrackedMACCount,omitempty"`
+}
+
+// SubnetQuotaUsage is the number of IP addresses used by the quota.
+type SubnetQuotaUsage struct {
+	Namespace string `json:"namespace,omitempty"`
+	Project   string `json:"project,omitempty"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
 }
 
 type SubnetStatus struct {
@@ -138,15 +503,83 @@ type SubnetStatus struct {
 	// Gateway is the gateway of the subnet.
 	Gateway net.IP `json:"gateway"`
 
-	// ReservedIP is the reserved IPRange of this subnet by workloads.
-	ReservedIP map[string][]IPRange `json:"reservedIP"`
+	// The allocation state is stored in the FlatNetworkIPBlocks of the
+	// subnet instead of the subnet status. The ReservedIP, UsedIP, UsedMAC
+	// and ReleasedIP below are only written by the old versions, operator
+	// migrates them into the IP blocks and clears them on the first
+	// consistency check, then uses these fields to hold the values
+	// aggregated from the IP blocks in memory.
+
+	// ReservedIP is the reserved IPRange of this subnet by workloads
+	// and FlatNetworkIPReservations.
+	// The workload reserved addresses are stored in the IP blocks, the
+	// FlatNetworkIPReservation ranges are read from the reservations.
+	ReservedIP map[string][]IPRange `json:"reservedIP,omitempty"`
 
 	// UsedIP is the used IPRange of this subnet.
-	UsedIP      []IPRange `json:"usedIP"`
-	UsedIPCount int       `json:"usedIPCount"`
+	UsedIP []IPRange `json:"usedIP,omitempty"`
+
+	// UsedIPCount is the number of the used addresses of this subnet,
+	// including the gateway and the reserved addresses.
+	UsedIPCount int `json:"usedIPCount"`
+
+	// UsedMAC is the used MAC address, including the user specified MAC
+	// addresses and the MAC addresses allocated within the MACPrefix.
+	UsedMAC []string `json:"usedMac,omitempty"`
+
+	// ReleasedIP is the released IP addresses waiting for reuse if
+	// IPReuseDelay is enabled, or the released IP addresses history if
+	// the AllocationStrategy is 'lru'.
+	ReleasedIP []ReleasedIP `json:"releasedIP,omitempty"`
+
+	// OutOfRangeIP is the allocated flat-network IPs outside of the subnet
+	// CIDR or ranges after the subnet spec changed, only the first
+	// MaxSubnetStatusItems IPs are stored.
+	OutOfRangeIP      []OutOfRangeIP `json:"outOfRangeIP,omitempty"`
+	OutOfRangeIPCount int            `json:"outOfRangeIPCount,omitempty"`
+
+	// QuotaUsage is the current usage of the subnet quotas.
+	QuotaUsage []SubnetQuotaUsage `json:"quotaUsage,omitempty"`
+
+	// Capacity is the number of the allocatable addresses of the subnet.
+	Capacity SubnetCapacity `json:"capacity,omitempty"`
+
+	// Consistency is the drift found by the last consistency check between
+	// the IP blocks and the flat-network IPs of the subnet.
+	Consistency SubnetConsistency `json:"consistency,omitempty"`
+
+	// Conditions is the 'Ready', 'NearlyExhausted', 'Exhausted',
+	// 'Cordoned' and 'Consistent' conditions of the subnet.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SubnetCapacity is the number of the allocatable addresses of the subnet,
+// the numbers are decimal strings as IPv6 subnets may have more addresses
+// than int64.
+type SubnetCapacity struct {
+	// Total is the number of the allocatable addresses in the subnet
+	// CIDR (or the subnet ranges).
+	Total string `json:"total,omitempty"`
+
+	// Used is the number of the used addresses in the allocatable addresses.
+	Used string `json:"used,omitempty"`
+
+	// Reserved is the number of the allocatable addresses reserved by
+	// workloads and FlatNetworkIPReservations.
+	Reserved string `json:"reserved,omitempty"`
+
+	// Available is the number of the addresses can be allocated in auto
+	// mode, the addresses waiting for reuse are excluded.
+	Available string `json:"available,omitempty"`
 
-	// UsedMAC is the **USER SPECIFIED** used MAC address.
-	UsedMAC []string `json:"usedMac"`
+	// UsedPercent is the percentage of the used addresses.
+	UsedPercent int `json:"usedPercent"`
+}
+
+// ReleasedIP is the released IP address with the timestamp when released.
+type ReleasedIP struct {
+	Addr              net.IP      `json:"addr"`
+	ReleasedTimestamp metav1.Time `json:"releasedTimestamp"`
 }
 
 // Example: ip route add <DST_CIDR> dev <DEV_NAME> via <VIA_GATEWAY_ADDR> src <SRC_ADDR> metrics <PRIORITY>
@@ -185,6 +618,155 @@ type RouteSettings struct {
 	FlatNetworkDefaultGateway bool `json:"flatNetworkDefaultGateway"`
 }
 
+////////////////////
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+
+// FlatNetworkIPReservation reserves the IP addresses of the flat-network
+// subnet used by the hosts outside Kubernetes (physical servers, VIPs, etc).
+type FlatNetworkIPReservation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IPReservationSpec   `json:"spec"`
+	Status IPReservationStatus `json:"status"`
+}
+
+type IPReservationSpec struct {
+	// Subnet is the name of the flat-network subnet (required).
+	Subnet string `json:"subnet"`
+
+	// Addrs is the reserved IP addresses (optional).
+	Addrs []net.IP `json:"addrs,omitempty"`
+
+	// Ranges is the reserved IP ranges (optional).
+	Ranges []IPRange `json:"ranges,omitempty"`
+
+	// Owner is the owner of the reserved addresses (optional).
+	Owner string `json:"owner,omitempty"`
+
+	// Description is the description of the reservation (optional).
+	Description string `json:"description,omitempty"`
+
+	// ExpireTime is the time when the reservation expires (optional).
+	// The reservation never expires if not specified.
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
+}
+
+type IPReservationStatus struct {
+	Phase          string `json:"phase"`
+	FailureMessage string `json:"failureMessage"`
+
+	// ReservedIP is the merged IP ranges of the reserved addresses.
+	ReservedIP []IPRange `json:"reservedIP,omitempty"`
+
+	// Conflicts is the already allocated flat-network IPs conflict with
+	// the reservation.
+	Conflicts []IPReservationConflict `json:"conflicts,omitempty"`
+}
+
+// IPReservationConflict is the allocated flat-network IP conflict with the
+// reservation.
+type IPReservationConflict struct {
+	// Addr is the conflict IP address.
+	Addr net.IP `json:"addr"`
+
+	// FlatNetworkIP is the '<namespace>/<name>' of the flat-network IP.
+	FlatNetworkIP string `json:"flatNetworkIP"`
+}
+
+////////////////////
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+
+// FlatNetworkSubnetPool is a group of flat-network subnets selected by
+// labels, the pods using the subnet pool allocate IP address from the first
+// subnet with free capacity in priority order.
+type FlatNetworkSubnetPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SubnetPoolSpec   `json:"spec"`
+	Status SubnetPoolStatus `json:"status"`
+}
+
+type SubnetPoolSpec struct {
+	// SubnetSelector selects the subnets of the pool by labels (required).
+	SubnetSelector *metav1.LabelSelector `json:"subnetSelector"`
+
+	// Description is the description of the subnet pool (optional).
+	Description string `json:"description,omitempty"`
+}
+
+type SubnetPoolStatus struct {
+	Phase          string `json:"phase"`
+	FailureMessage string `json:"failureMessage"`
+
+	// Subnets is the subnet names of the pool in priority order.
+	Subnets []string `json:"subnets,omitempty"`
+
+	// FlatMode is the flatMode of the subnets in the pool.
+	FlatMode string `json:"flatMode,omitempty"`
+
+	// Available is the total number of the addresses can be allocated in
+	// auto mode of the subnets in the pool.
+	Available string `json:"available,omitempty"`
+}
+
+////////////////////
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+
+// FlatNetworkIPBlock is the allocation state of a block of addresses in the
+// flat-network subnet, managed by operator.
+// The addresses of the subnet are split into the aligned blocks of
+// IPBlockSize addresses, the IP blocks are created on demand and updated
+// independently when allocating and releasing addresses.
+// The MAC addresses of the subnet are recorded in a single MAC block named
+// "<subnet>-mac", so the same MAC address can not be allocated twice.
+type FlatNetworkIPBlock struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IPBlockSpec   `json:"spec"`
+	Status IPBlockStatus `json:"status"`
+}
+
+type IPBlockSpec struct {
+	// Subnet is the name of the subnet of the IP block.
+	Subnet string `json:"subnet"`
+
+	// From and To are the first and last address of the IP block, both are
+	// empty for the MAC block of the subnet.
+	From net.IP `json:"from"`
+	To   net.IP `json:"to"`
+}
+
+type IPBlockStatus struct {
+	// UsedIP is the used IPRange of this IP block.
+	UsedIP      []IPRange `json:"usedIP,omitempty"`
+	UsedIPCount int       `json:"usedIPCount"`
+
+	// UsedMAC is the MAC addresses used in the subnet, only recorded in the
+	// MAC block of the subnet.
+	UsedMAC []string `json:"usedMac,omitempty"`
+
+	// ReleasedIP is the released IP addresses of this IP block.
+	ReleasedIP []ReleasedIP `json:"releasedIP,omitempty"`
+
+	// ReservedIP is the addresses of this IP block reserved by workloads,
+	// keyed by the workload.
+	ReservedIP map[string][]IPRange `json:"reservedIP,omitempty"`
+}
+
+////////////////////
+
 // IPRange defines the closed interval [from, to] of IP ranges.
 type IPRange struct {
 	From net.IP `json:"from"`
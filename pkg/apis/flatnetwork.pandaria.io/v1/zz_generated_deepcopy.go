@@ -24,9 +24,27 @@ package v1
 import (
 	net "net"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Bandwidth) DeepCopyInto(out *Bandwidth) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Bandwidth.
+func (in *Bandwidth) DeepCopy() *Bandwidth {
+	if in == nil {
+		return nil
+	}
+	out := new(Bandwidth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlatNetworkIP) DeepCopyInto(out *FlatNetworkIP) {
 	*out = *in
@@ -55,6 +73,67 @@ func (in *FlatNetworkIP) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlatNetworkIPBlock) DeepCopyInto(out *FlatNetworkIPBlock) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlatNetworkIPBlock.
+func (in *FlatNetworkIPBlock) DeepCopy() *FlatNetworkIPBlock {
+	if in == nil {
+		return nil
+	}
+	out := new(FlatNetworkIPBlock)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlatNetworkIPBlock) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlatNetworkIPBlockList) DeepCopyInto(out *FlatNetworkIPBlockList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FlatNetworkIPBlock, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlatNetworkIPBlockList.
+func (in *FlatNetworkIPBlockList) DeepCopy() *FlatNetworkIPBlockList {
+	if in == nil {
+		return nil
+	}
+	out := new(FlatNetworkIPBlockList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlatNetworkIPBlockList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlatNetworkIPList) DeepCopyInto(out *FlatNetworkIPList) {
 	*out = *in
@@ -88,6 +167,67 @@ func (in *FlatNetworkIPList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlatNetworkIPReservation) DeepCopyInto(out *FlatNetworkIPReservation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlatNetworkIPReservation.
+func (in *FlatNetworkIPReservation) DeepCopy() *FlatNetworkIPReservation {
+	if in == nil {
+		return nil
+	}
+	out := new(FlatNetworkIPReservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlatNetworkIPReservation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlatNetworkIPReservationList) DeepCopyInto(out *FlatNetworkIPReservationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FlatNetworkIPReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlatNetworkIPReservationList.
+func (in *FlatNetworkIPReservationList) DeepCopy() *FlatNetworkIPReservationList {
+	if in == nil {
+		return nil
+	}
+	out := new(FlatNetworkIPReservationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlatNetworkIPReservationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlatNetworkSubnet) DeepCopyInto(out *FlatNetworkSubnet) {
 	*out = *in
@@ -150,168 +290,723 @@ func (in *FlatNetworkSubnetList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IPRange) DeepCopyInto(out *IPRange) {
+func (in *FlatNetworkSubnetPool) DeepCopyInto(out *FlatNetworkSubnetPool) {
 	*out = *in
-	if in.From != nil {
-		in, out := &in.From, &out.From
-		*out = make(net.IP, len(*in))
-		copy(*out, *in)
-	}
-	if in.To != nil {
-		in, out := &in.To, &out.To
-		*out = make(net.IP, len(*in))
-		copy(*out, *in)
-	}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPRange.
-func (in *IPRange) DeepCopy() *IPRange {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlatNetworkSubnetPool.
+func (in *FlatNetworkSubnetPool) DeepCopy() *FlatNetworkSubnetPool {
 	if in == nil {
 		return nil
 	}
-	out := new(IPRange)
+	out := new(FlatNetworkSubnetPool)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlatNetworkSubnetPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IPSpec) DeepCopyInto(out *IPSpec) {
+func (in *FlatNetworkSubnetPoolList) DeepCopyInto(out *FlatNetworkSubnetPoolList) {
 	*out = *in
-	if in.Addrs != nil {
-		in, out := &in.Addrs, &out.Addrs
-		*out = make([]net.IP, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FlatNetworkSubnetPool, len(*in))
 		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = make(net.IP, len(*in))
-				copy(*out, *in)
-			}
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.MACs != nil {
-		in, out := &in.MACs, &out.MACs
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPSpec.
-func (in *IPSpec) DeepCopy() *IPSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlatNetworkSubnetPoolList.
+func (in *FlatNetworkSubnetPoolList) DeepCopy() *FlatNetworkSubnetPoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(IPSpec)
+	out := new(FlatNetworkSubnetPoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlatNetworkSubnetPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IPStatus) DeepCopyInto(out *IPStatus) {
+func (in *IPAllocationRecord) DeepCopyInto(out *IPAllocationRecord) {
 	*out = *in
-	in.AllocatedTimeStamp.DeepCopyInto(&out.AllocatedTimeStamp)
+	in.Time.DeepCopyInto(&out.Time)
 	if in.Addr != nil {
 		in, out := &in.Addr, &out.Addr
 		*out = make(net.IP, len(*in))
 		copy(*out, *in)
 	}
+	if in.SecondaryAddr != nil {
+		in, out := &in.SecondaryAddr, &out.SecondaryAddr
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPStatus.
-func (in *IPStatus) DeepCopy() *IPStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAllocationRecord.
+func (in *IPAllocationRecord) DeepCopy() *IPAllocationRecord {
 	if in == nil {
 		return nil
 	}
-	out := new(IPStatus)
+	out := new(IPAllocationRecord)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Route) DeepCopyInto(out *Route) {
+func (in *IPBlockSpec) DeepCopyInto(out *IPBlockSpec) {
 	*out = *in
-	if in.Src != nil {
-		in, out := &in.Src, &out.Src
+	if in.From != nil {
+		in, out := &in.From, &out.From
 		*out = make(net.IP, len(*in))
 		copy(*out, *in)
 	}
-	if in.Via != nil {
-		in, out := &in.Via, &out.Via
+	if in.To != nil {
+		in, out := &in.To, &out.To
 		*out = make(net.IP, len(*in))
 		copy(*out, *in)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
-func (in *Route) DeepCopy() *Route {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPBlockSpec.
+func (in *IPBlockSpec) DeepCopy() *IPBlockSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Route)
+	out := new(IPBlockSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RouteSettings) DeepCopyInto(out *RouteSettings) {
+func (in *IPBlockStatus) DeepCopyInto(out *IPBlockStatus) {
 	*out = *in
+	if in.UsedIP != nil {
+		in, out := &in.UsedIP, &out.UsedIP
+		*out = make([]IPRange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UsedMAC != nil {
+		in, out := &in.UsedMAC, &out.UsedMAC
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReleasedIP != nil {
+		in, out := &in.ReleasedIP, &out.ReleasedIP
+		*out = make([]ReleasedIP, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReservedIP != nil {
+		in, out := &in.ReservedIP, &out.ReservedIP
+		*out = make(map[string][]IPRange, len(*in))
+		for key, val := range *in {
+			var outVal []IPRange
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]IPRange, len(*in))
+				for i := range *in {
+					(*in)[i].DeepCopyInto(&(*out)[i])
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteSettings.
-func (in *RouteSettings) DeepCopy() *RouteSettings {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPBlockStatus.
+func (in *IPBlockStatus) DeepCopy() *IPBlockStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(RouteSettings)
+	out := new(IPBlockStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SubnetSpec) DeepCopyInto(out *SubnetSpec) {
+func (in *IPRange) DeepCopyInto(out *IPRange) {
 	*out = *in
-	if in.Gateway != nil {
-		in, out := &in.Gateway, &out.Gateway
+	if in.From != nil {
+		in, out := &in.From, &out.From
 		*out = make(net.IP, len(*in))
 		copy(*out, *in)
 	}
-	if in.Ranges != nil {
-		in, out := &in.Ranges, &out.Ranges
-		*out = make([]IPRange, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Routes != nil {
-		in, out := &in.Routes, &out.Routes
-		*out = make([]Route, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
 	}
-	out.RouteSettings = in.RouteSettings
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetSpec.
-func (in *SubnetSpec) DeepCopy() *SubnetSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPRange.
+func (in *IPRange) DeepCopy() *IPRange {
 	if in == nil {
 		return nil
 	}
-	out := new(SubnetSpec)
+	out := new(IPRange)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SubnetStatus) DeepCopyInto(out *SubnetStatus) {
+func (in *IPReservationConflict) DeepCopyInto(out *IPReservationConflict) {
 	*out = *in
-	if in.Gateway != nil {
-		in, out := &in.Gateway, &out.Gateway
+	if in.Addr != nil {
+		in, out := &in.Addr, &out.Addr
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPReservationConflict.
+func (in *IPReservationConflict) DeepCopy() *IPReservationConflict {
+	if in == nil {
+		return nil
+	}
+	out := new(IPReservationConflict)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPReservationSpec) DeepCopyInto(out *IPReservationSpec) {
+	*out = *in
+	if in.Addrs != nil {
+		in, out := &in.Addrs, &out.Addrs
+		*out = make([]net.IP, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make(net.IP, len(*in))
+				copy(*out, *in)
+			}
+		}
+	}
+	if in.Ranges != nil {
+		in, out := &in.Ranges, &out.Ranges
+		*out = make([]IPRange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExpireTime != nil {
+		in, out := &in.ExpireTime, &out.ExpireTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPReservationSpec.
+func (in *IPReservationSpec) DeepCopy() *IPReservationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IPReservationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPReservationStatus) DeepCopyInto(out *IPReservationStatus) {
+	*out = *in
+	if in.ReservedIP != nil {
+		in, out := &in.ReservedIP, &out.ReservedIP
+		*out = make([]IPRange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]IPReservationConflict, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPReservationStatus.
+func (in *IPReservationStatus) DeepCopy() *IPReservationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IPReservationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPSpec) DeepCopyInto(out *IPSpec) {
+	*out = *in
+	if in.Addrs != nil {
+		in, out := &in.Addrs, &out.Addrs
+		*out = make([]net.IP, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make(net.IP, len(*in))
+				copy(*out, *in)
+			}
+		}
+	}
+	if in.MACs != nil {
+		in, out := &in.MACs, &out.MACs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPSpec.
+func (in *IPSpec) DeepCopy() *IPSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IPSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPStatus) DeepCopyInto(out *IPStatus) {
+	*out = *in
+	in.AllocatedTimeStamp.DeepCopyInto(&out.AllocatedTimeStamp)
+	if in.Addr != nil {
+		in, out := &in.Addr, &out.Addr
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecondaryAddr != nil {
+		in, out := &in.SecondaryAddr, &out.SecondaryAddr
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]IPAllocationRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPStatus.
+func (in *IPStatus) DeepCopy() *IPStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IPStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MasterSelector) DeepCopyInto(out *MasterSelector) {
+	*out = *in
+	in.NodeSelector.DeepCopyInto(&out.NodeSelector)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MasterSelector.
+func (in *MasterSelector) DeepCopy() *MasterSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(MasterSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutOfRangeIP) DeepCopyInto(out *OutOfRangeIP) {
+	*out = *in
+	if in.Addr != nil {
+		in, out := &in.Addr, &out.Addr
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutOfRangeIP.
+func (in *OutOfRangeIP) DeepCopy() *OutOfRangeIP {
+	if in == nil {
+		return nil
+	}
+	out := new(OutOfRangeIP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodInterface) DeepCopyInto(out *PodInterface) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodInterface.
+func (in *PodInterface) DeepCopy() *PodInterface {
+	if in == nil {
+		return nil
+	}
+	out := new(PodInterface)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleasedIP) DeepCopyInto(out *ReleasedIP) {
+	*out = *in
+	if in.Addr != nil {
+		in, out := &in.Addr, &out.Addr
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	in.ReleasedTimestamp.DeepCopyInto(&out.ReleasedTimestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleasedIP.
+func (in *ReleasedIP) DeepCopy() *ReleasedIP {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleasedIP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Route) DeepCopyInto(out *Route) {
+	*out = *in
+	if in.Src != nil {
+		in, out := &in.Src, &out.Src
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	if in.Via != nil {
+		in, out := &in.Via, &out.Via
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
+func (in *Route) DeepCopy() *Route {
+	if in == nil {
+		return nil
+	}
+	out := new(Route)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteSettings) DeepCopyInto(out *RouteSettings) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteSettings.
+func (in *RouteSettings) DeepCopy() *RouteSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetCapacity) DeepCopyInto(out *SubnetCapacity) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetCapacity.
+func (in *SubnetCapacity) DeepCopy() *SubnetCapacity {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetCapacity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetConsistency) DeepCopyInto(out *SubnetConsistency) {
+	*out = *in
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+	if in.LeakedIP != nil {
+		in, out := &in.LeakedIP, &out.LeakedIP
+		*out = make([]IPRange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UntrackedIP != nil {
+		in, out := &in.UntrackedIP, &out.UntrackedIP
+		*out = make([]IPRange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LeakedMAC != nil {
+		in, out := &in.LeakedMAC, &out.LeakedMAC
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UntrackedMAC != nil {
+		in, out := &in.UntrackedMAC, &out.UntrackedMAC
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetConsistency.
+func (in *SubnetConsistency) DeepCopy() *SubnetConsistency {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetConsistency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetDrain) DeepCopyInto(out *SubnetDrain) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetDrain.
+func (in *SubnetDrain) DeepCopy() *SubnetDrain {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetDrain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetPoolSpec) DeepCopyInto(out *SubnetPoolSpec) {
+	*out = *in
+	if in.SubnetSelector != nil {
+		in, out := &in.SubnetSelector, &out.SubnetSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetPoolSpec.
+func (in *SubnetPoolSpec) DeepCopy() *SubnetPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetPoolStatus) DeepCopyInto(out *SubnetPoolStatus) {
+	*out = *in
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetPoolStatus.
+func (in *SubnetPoolStatus) DeepCopy() *SubnetPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetQuota) DeepCopyInto(out *SubnetQuota) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetQuota.
+func (in *SubnetQuota) DeepCopy() *SubnetQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetQuotaUsage) DeepCopyInto(out *SubnetQuotaUsage) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetQuotaUsage.
+func (in *SubnetQuotaUsage) DeepCopy() *SubnetQuotaUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetQuotaUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetRemediation) DeepCopyInto(out *SubnetRemediation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetRemediation.
+func (in *SubnetRemediation) DeepCopy() *SubnetRemediation {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetRemediation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetSpec) DeepCopyInto(out *SubnetSpec) {
+	*out = *in
+	if in.MasterSelectors != nil {
+		in, out := &in.MasterSelectors, &out.MasterSelectors
+		*out = make([]MasterSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = make(net.IP, len(*in))
+		copy(*out, *in)
+	}
+	if in.Ranges != nil {
+		in, out := &in.Ranges, &out.Ranges
+		*out = make([]IPRange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]Route, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.RouteSettings = in.RouteSettings
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Projects != nil {
+		in, out := &in.Projects, &out.Projects
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Quotas != nil {
+		in, out := &in.Quotas, &out.Quotas
+		*out = make([]SubnetQuota, len(*in))
+		copy(*out, *in)
+	}
+	if in.Drain != nil {
+		in, out := &in.Drain, &out.Drain
+		*out = new(SubnetDrain)
+		**out = **in
+	}
+	if in.Remediation != nil {
+		in, out := &in.Remediation, &out.Remediation
+		*out = new(SubnetRemediation)
+		**out = **in
+	}
+	if in.MTU != nil {
+		in, out := &in.MTU, &out.MTU
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Bandwidth != nil {
+		in, out := &in.Bandwidth, &out.Bandwidth
+		*out = new(Bandwidth)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetSpec.
+func (in *SubnetSpec) DeepCopy() *SubnetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetStatus) DeepCopyInto(out *SubnetStatus) {
+	*out = *in
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
 		*out = make(net.IP, len(*in))
 		copy(*out, *in)
 	}
@@ -344,6 +1039,34 @@ func (in *SubnetStatus) DeepCopyInto(out *SubnetStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ReleasedIP != nil {
+		in, out := &in.ReleasedIP, &out.ReleasedIP
+		*out = make([]ReleasedIP, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OutOfRangeIP != nil {
+		in, out := &in.OutOfRangeIP, &out.OutOfRangeIP
+		*out = make([]OutOfRangeIP, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.QuotaUsage != nil {
+		in, out := &in.QuotaUsage, &out.QuotaUsage
+		*out = make([]SubnetQuotaUsage, len(*in))
+		copy(*out, *in)
+	}
+	out.Capacity = in.Capacity
+	in.Consistency.DeepCopyInto(&out.Consistency)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -57,3 +57,54 @@ func NewFlatNetworkSubnet(namespace, name string, obj FlatNetworkSubnet) *FlatNe
 	obj.Namespace = namespace
 	return &obj
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FlatNetworkIPReservationList is a list of FlatNetworkIPReservation resources
+type FlatNetworkIPReservationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []FlatNetworkIPReservation `json:"items"`
+}
+
+func NewFlatNetworkIPReservation(namespace, name string, obj FlatNetworkIPReservation) *FlatNetworkIPReservation {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("FlatNetworkIPReservation").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FlatNetworkSubnetPoolList is a list of FlatNetworkSubnetPool resources
+type FlatNetworkSubnetPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []FlatNetworkSubnetPool `json:"items"`
+}
+
+func NewFlatNetworkSubnetPool(namespace, name string, obj FlatNetworkSubnetPool) *FlatNetworkSubnetPool {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("FlatNetworkSubnetPool").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FlatNetworkIPBlockList is a list of FlatNetworkIPBlock resources
+type FlatNetworkIPBlockList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []FlatNetworkIPBlock `json:"items"`
+}
+
+func NewFlatNetworkIPBlock(namespace, name string, obj FlatNetworkIPBlock) *FlatNetworkIPBlock {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("FlatNetworkIPBlock").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
@@ -28,8 +28,11 @@ import (
 )
 
 var (
-	FlatNetworkIPResourceName     = "flatnetworkips"
-	FlatNetworkSubnetResourceName = "flatnetworksubnets"
+	FlatNetworkIPResourceName            = "flatnetworkips"
+	FlatNetworkIPBlockResourceName       = "flatnetworkipblocks"
+	FlatNetworkIPReservationResourceName = "flatnetworkipreservations"
+	FlatNetworkSubnetResourceName        = "flatnetworksubnets"
+	FlatNetworkSubnetPoolResourceName    = "flatnetworksubnetpools"
 )
 
 // SchemeGroupVersion is group version used to register these objects
@@ -55,8 +58,14 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&FlatNetworkIP{},
 		&FlatNetworkIPList{},
+		&FlatNetworkIPBlock{},
+		&FlatNetworkIPBlockList{},
+		&FlatNetworkIPReservation{},
+		&FlatNetworkIPReservationList{},
 		&FlatNetworkSubnet{},
 		&FlatNetworkSubnetList{},
+		&FlatNetworkSubnetPool{},
+		&FlatNetworkSubnetPoolList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,200 @@
+package bandwidth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/containernetworking/plugins/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	flcommon "github.com/cnrancher/rancher-flat-network/pkg/common"
+)
+
+const (
+	maxIfbDeviceLength = 15
+	ifbDevicePrefix    = "bwp"
+
+	latencyInMillis = 25
+)
+
+type Options struct {
+	IfName  string
+	NetNS   ns.NetNS
+	Ingress *flcommon.BandwidthLimit
+	Egress  *flcommon.BandwidthLimit
+}
+
+// Setup limits the bandwidth of the pod flat-network iface in pod NS.
+//
+// The egress traffic is shaped by the TBF qdisc on the pod iface, the
+// ingress traffic is redirected to an IFB device in pod NS and shaped by the
+// TBF qdisc on the IFB device.
+func Setup(o *Options) error {
+	if o.Ingress == nil && o.Egress == nil {
+		return nil
+	}
+	err := o.NetNS.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(o.IfName)
+		if err != nil {
+			return fmt.Errorf("failed to get iface %q: %w", o.IfName, err)
+		}
+		if o.Egress != nil {
+			if err := createTBF(o.Egress, link.Attrs().Index); err != nil {
+				return fmt.Errorf("failed to create egress qdisc on %q: %w", o.IfName, err)
+			}
+		}
+		if o.Ingress != nil {
+			if err := createIngressQdisc(o.Ingress, link); err != nil {
+				teardownIfb(GetIfbName(o.IfName))
+				return fmt.Errorf("failed to create ingress qdisc on %q: %w", o.IfName, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bandwidth.Setup: %w", err)
+	}
+	logrus.Infof("limit iface [%v] bandwidth: ingress %v egress %v",
+		o.IfName, limitString(o.Ingress), limitString(o.Egress))
+	return nil
+}
+
+// Teardown deletes the IFB device of the pod flat-network iface in pod NS,
+// the qdiscs on the pod iface are deleted with the iface.
+func Teardown(netns ns.NetNS, ifName string) error {
+	err := netns.Do(func(_ ns.NetNS) error {
+		return teardownIfb(GetIfbName(ifName))
+	})
+	if err != nil {
+		return fmt.Errorf("bandwidth.Teardown: %w", err)
+	}
+	return nil
+}
+
+// GetIfbName returns the IFB device name of the pod iface.
+func GetIfbName(ifName string) string {
+	return utils.MustFormatHashWithPrefix(maxIfbDeviceLength, ifbDevicePrefix, ifName)
+}
+
+func createIngressQdisc(l *flcommon.BandwidthLimit, link netlink.Link) error {
+	ifbName := GetIfbName(link.Attrs().Name)
+	err := netlink.LinkAdd(&netlink.Ifb{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:  ifbName,
+			Flags: net.FlagUp,
+			MTU:   link.Attrs().MTU,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create ifb device %q: %w", ifbName, err)
+	}
+	ifb, err := netlink.LinkByName(ifbName)
+	if err != nil {
+		return fmt.Errorf("failed to get ifb device %q: %w", ifbName, err)
+	}
+
+	// tc qdisc add dev <iface> handle ffff: ingress
+	ingress := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+	if err := netlink.QdiscAdd(ingress); err != nil {
+		return fmt.Errorf("failed to add ingress qdisc: %w", err)
+	}
+
+	// Redirect the ingress traffic of the iface to the ifb device
+	filter := &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: link.Attrs().Index,
+			Parent:    ingress.QdiscAttrs.Handle,
+			Priority:  1,
+			Protocol:  syscall.ETH_P_ALL,
+		},
+		ClassId:    netlink.MakeHandle(1, 1),
+		RedirIndex: ifb.Attrs().Index,
+		Actions: []netlink.Action{
+			&netlink.MirredAction{
+				MirredAction: netlink.TCA_EGRESS_REDIR,
+				Ifindex:      ifb.Attrs().Index,
+			},
+		},
+	}
+	if err := netlink.FilterAdd(filter); err != nil {
+		return fmt.Errorf("failed to add ifb redirect filter: %w", err)
+	}
+	return createTBF(l, ifb.Attrs().Index)
+}
+
+// createTBF executes
+// 'tc qdisc add dev <link> root tbf rate <rate> burst <burst> latency 25ms'.
+func createTBF(l *flcommon.BandwidthLimit, linkIndex int) error {
+	rateInBytes := l.Rate / 8
+	burstInBytes := l.Burst / 8
+	if rateInBytes == 0 || burstInBytes == 0 {
+		return fmt.Errorf("invalid bandwidth limit %v", limitString(l))
+	}
+	bufferInBytes := buffer(rateInBytes, uint32(burstInBytes))
+	latency := latencyInUsec(latencyInMillis)
+	limitInBytes := limit(rateInBytes, latency, uint32(burstInBytes))
+
+	qdisc := &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: linkIndex,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		Limit:  limitInBytes,
+		Rate:   rateInBytes,
+		Buffer: bufferInBytes,
+	}
+	if err := netlink.QdiscAdd(qdisc); err != nil {
+		return fmt.Errorf("failed to add tbf qdisc: %w", err)
+	}
+	return nil
+}
+
+func teardownIfb(ifbName string) error {
+	link, err := netlink.LinkByName(ifbName)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get ifb device %q: %w", ifbName, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("failed to delete ifb device %q: %w", ifbName, err)
+	}
+	logrus.Infof("delete ifb device [%v]", ifbName)
+	return nil
+}
+
+func time2Tick(time uint32) uint32 {
+	return uint32(float64(time) * netlink.TickInUsec())
+}
+
+func buffer(rate uint64, burst uint32) uint32 {
+	return time2Tick(uint32(float64(burst) * float64(netlink.TIME_UNITS_PER_SEC) / float64(rate)))
+}
+
+func limit(rate uint64, latency float64, buffer uint32) uint32 {
+	return uint32(float64(rate)*latency/float64(netlink.TIME_UNITS_PER_SEC)) + buffer
+}
+
+func latencyInUsec(latencyInMillis float64) float64 {
+	return float64(netlink.TIME_UNITS_PER_SEC) * (latencyInMillis / 1000.0)
+}
+
+func limitString(l *flcommon.BandwidthLimit) string {
+	if l == nil {
+		return "unlimited"
+	}
+	return fmt.Sprintf("[rate %vbps burst %vbit]", l.Rate, l.Burst)
+}
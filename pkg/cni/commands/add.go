@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/bandwidth"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/ipvlan"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/kubeclient"
@@ -28,6 +29,7 @@ import (
 	"k8s.io/client-go/util/retry"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flcommon "github.com/cnrancher/rancher-flat-network/pkg/common"
 	cnitypes "github.com/containernetworking/cni/pkg/types"
 	types100 "github.com/containernetworking/cni/pkg/types/100"
 )
@@ -70,25 +72,33 @@ func Add(args *skel.CmdArgs) error {
 
 	podName := string(k8sArgs.K8S_POD_NAME)
 	podNamespace := string(k8sArgs.K8S_POD_NAMESPACE)
+	ipName, ifName, err := getFlatNetworkIPName(client, podNamespace, podName, args.IfName)
+	if err != nil {
+		return err
+	}
 	// The pod may just created and the IP is not allocated by operator.
 	// Retry to wait a few seconds to let Operator allocate IP for pod.
 	var flatNetworkIP *flv1.FlatNetworkIP
 	if err := retry.OnError(getPodRetry, shouldRetryOnFlatNetworkIP, func() error {
-		flatNetworkIP, err = client.GetIP(context.TODO(), podNamespace, podName)
+		flatNetworkIP, err = client.GetIP(context.TODO(), podNamespace, ipName)
 		if err != nil {
 			logrus.Warnf("failed to get FlatNetworkIP [%v/%v]: %v",
-				podNamespace, podName, err)
+				podNamespace, ipName, err)
 			return err
 		}
+		if flatNetworkIP.Spec.Interface != ifName {
+			return fmt.Errorf("FlatNetworkIP [%v/%v] interface [%v] mismatch, expected [%v]",
+				podNamespace, ipName, flatNetworkIP.Spec.Interface, ifName)
+		}
 		if len(flatNetworkIP.Status.Addr) == 0 {
 			logrus.Infof("FlatNetworkIP [%v/%v] address not allocated by operator, will retry...",
-				podNamespace, podName)
+				podNamespace, ipName)
 			return errIPNotAllocated
 		}
 		return nil
 	}); err != nil {
 		return fmt.Errorf("failed to get FlatNetworkIP [%v/%v]: %w",
-			podNamespace, podName, err)
+			podNamespace, ipName, err)
 	}
 
 	if flatNetworkIP == nil || len(flatNetworkIP.Status.Addr) == 0 {
@@ -101,6 +111,33 @@ func Add(args *skel.CmdArgs) error {
 	if err != nil {
 		return fmt.Errorf("failed to get FlatNetworkSubnet: %w", err)
 	}
+	if subnet.Spec.MACPrefix != "" && flatNetworkIP.Status.MAC == "" {
+		// The MAC address should be allocated by operator within the subnet
+		// MAC prefix, do not let the kernel generate a random MAC.
+		return fmt.Errorf("flatNetwork IP [%v/%v] MAC address not allocated in subnet [%v] macPrefix [%v]",
+			podNamespace, ipName, subnet.Name, subnet.Spec.MACPrefix)
+	}
+	pod, err := client.GetPod(context.TODO(), podNamespace, podName)
+	if err != nil {
+		return fmt.Errorf("failed to get pod [%v/%v]: %w", podNamespace, podName, err)
+	}
+	ingress, egress, err := getBandwidthLimits(n, pod.Annotations, subnet)
+	if err != nil {
+		return err
+	}
+	var secondarySubnet *flv1.FlatNetworkSubnet
+	if flatNetworkIP.Spec.SecondarySubnet != "" {
+		secondarySubnet, err = client.GetSubnet(context.TODO(), flatNetworkIP.Spec.SecondarySubnet)
+		if err != nil {
+			return fmt.Errorf("failed to get secondary FlatNetworkSubnet: %w", err)
+		}
+		logrus.Infof("flatNetworkIP [%v/%v] allocated secondary address [%v]",
+			flatNetworkIP.Namespace, flatNetworkIP.Name, flatNetworkIP.Status.SecondaryAddr.String())
+	}
+	nodeName := getPodNodeName(client, podNamespace, podName)
+	if err := resolveSubnetsMaster(client, nodeName, subnet, secondarySubnet); err != nil {
+		return err
+	}
 
 	/**
 	 * FYI: https://github.com/moby/libnetwork/blob/c1865b811b6247cc0a52c4f7a253fc05372b3d89/docs/macvlan.md#macvlan-bridge-mode-example-usage
@@ -125,12 +162,20 @@ func Add(args *skel.CmdArgs) error {
 	// Create/Get vlan interface on host network namespace.
 	// If the vlan ID is not 0, it will create a vlan iface [master].[vlanID]
 	// (eth0.100 for example) to separate broadcast domain.
-	vlanIface, err := common.GetVlanIfaceOnHost(subnet.Spec.Master, 0, subnet.Spec.VLAN)
+	subnetMTU, _, err := flcommon.ParseSubnetMTU(subnet)
+	if err != nil {
+		return err
+	}
+	vlanIface, err := common.GetVlanIfaceOnHost(subnet.Spec.Master, subnetMTU, subnet.Spec.VLAN)
 	if err != nil {
 		return fmt.Errorf("failed to create host vlan of subnet [%v] on iface [%s.%d]: %w",
 			subnet.Name, subnet.Spec.Master, subnet.Spec.VLAN, err)
 	}
 	logrus.Infof("host vlan interface: %v", utils.Print(vlanIface))
+	mtu, err := getPodMTU(n, subnet, vlanIface.Mtu)
+	if err != nil {
+		return err
+	}
 
 	netns, err := ns.GetNS(args.Netns)
 	if err != nil {
@@ -145,7 +190,7 @@ func Add(args *skel.CmdArgs) error {
 		iface, err = macvlan.Create(&macvlan.Options{
 			Mode:   subnet.Spec.Mode,
 			Master: vlanIface.Name,
-			MTU:    n.FlatNetworkConfig.MTU,
+			MTU:    mtu,
 			IfName: args.IfName,
 			NetNS:  netns,
 			MAC:    flatNetworkIP.Status.MAC,
@@ -155,7 +200,7 @@ func Add(args *skel.CmdArgs) error {
 			Mode:   subnet.Spec.Mode,
 			Flag:   subnet.Spec.IPvlanFlag,
 			Master: vlanIface.Name,
-			MTU:    n.FlatNetworkConfig.MTU,
+			MTU:    mtu,
 			IfName: args.IfName,
 			NetNS:  netns,
 			MAC:    flatNetworkIP.Status.MAC,
@@ -186,7 +231,7 @@ func Add(args *skel.CmdArgs) error {
 	}()
 
 	// run the IPAM plugin and get back the config to apply
-	ipamConf, err := mergeIPAMConfig(args.IfName, n, flatNetworkIP, subnet)
+	ipamConf, err := mergeIPAMConfig(args.IfName, n, flatNetworkIP, subnet, secondarySubnet)
 	if err != nil {
 		return fmt.Errorf("failed to merge IPAM config on netConf [%v]: %w",
 			utils.Print(n), err)
@@ -204,9 +249,10 @@ func Add(args *skel.CmdArgs) error {
 			return
 		}
 		if subnet.Spec.RouteSettings.AddPodIPToHost {
-			err = route.DelFlatNetworkRouteFromHost(flatNetworkIP.Status.Addr)
-			if err != nil {
-				logrus.Errorf("DelFlatNetworkRouteFromHost failed: %v", err)
+			for _, addr := range flatNetworkIPAddrs(flatNetworkIP) {
+				if err := route.DelFlatNetworkRouteFromHost(addr); err != nil {
+					logrus.Errorf("DelFlatNetworkRouteFromHost failed: %v", err)
+				}
 			}
 		}
 		if err := ipam.ExecDel(n.IPAM.Type, ipamConf); err != nil {
@@ -272,6 +318,17 @@ func Add(args *skel.CmdArgs) error {
 		return fmt.Errorf("netns do failed, error: %w", err)
 	}
 
+	// Limit the bandwidth of the flat-network iface
+	err = bandwidth.Setup(&bandwidth.Options{
+		IfName:  args.IfName,
+		NetNS:   netns,
+		Ingress: ingress,
+		Egress:  egress,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to setup bandwidth: %w", err)
+	}
+
 	result.DNS = n.DNS
 
 	// Add ClusterCIDR route in Pod NS
@@ -316,9 +373,11 @@ func Add(args *skel.CmdArgs) error {
 
 	// Add FlatNetwork IP route to Pod on Host NS
 	if subnet.Spec.RouteSettings.AddPodIPToHost {
-		err = route.AddFlatNetworkRouteToHost(netns, flatNetworkIP.Status.Addr, vlanIface.Name)
-		if err != nil {
-			return fmt.Errorf("route.AddFlatNetworkRouteToHost: %w", err)
+		for _, addr := range flatNetworkIPAddrs(flatNetworkIP) {
+			err = route.AddFlatNetworkRouteToHost(netns, addr, vlanIface.Name)
+			if err != nil {
+				return fmt.Errorf("route.AddFlatNetworkRouteToHost: %w", err)
+			}
 		}
 	}
 
@@ -329,25 +388,37 @@ func Add(args *skel.CmdArgs) error {
 		if err != nil {
 			return fmt.Errorf("route.UpdatePodDefaultGateway: %w", err)
 		}
+		if secondarySubnet != nil && len(flatNetworkIP.Status.SecondaryAddr) != 0 {
+			err = route.UpdatePodDefaultGateway(
+				netns, args.IfName, flatNetworkIP.Status.SecondaryAddr, secondarySubnet.Status.Gateway)
+			if err != nil {
+				return fmt.Errorf("route.UpdatePodDefaultGateway: %w", err)
+			}
+		}
 	}
 
 	// Add other user-defined custom routes
 	if err := route.AddPodFlatNetworkCustomRoutes(netns, subnet.Spec.Routes); err != nil {
 		return fmt.Errorf("failed to add custom routes: %w", err)
 	}
+	if secondarySubnet != nil {
+		if err := route.AddPodFlatNetworkCustomRoutes(netns, secondarySubnet.Spec.Routes); err != nil {
+			return fmt.Errorf("failed to add custom routes: %w", err)
+		}
+	}
 
 	// Update flatNetworkIP status addr
 	if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		flatNetworkIP, err = client.GetIP(context.TODO(), podNamespace, podName)
+		flatNetworkIP, err = client.GetIP(context.TODO(), podNamespace, ipName)
 		if err != nil {
 			logrus.Warnf("failed to get FlatNetworkIP [%v/%v]: %v",
-				podNamespace, podName, err)
+				podNamespace, ipName, err)
 			return err
 		}
 
 		flatNetworkIP = flatNetworkIP.DeepCopy()
 		flatNetworkIP.Status.MAC = iface.Mac
-		flatNetworkIP.Status.Phase = "Active"
+		setIPInterfaceConfigured(flatNetworkIP, args.IfName, nodeName, args.ContainerID, time.Now())
 		flatNetworkIP, err = client.UpdateIPStatus(context.TODO(), podNamespace, flatNetworkIP)
 		return err
 	}); err != nil {
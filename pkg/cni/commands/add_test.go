@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/api/meta"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flcommon "github.com/cnrancher/rancher-flat-network/pkg/common"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func Test_MergeIPAMConfig(t *testing.T) {
@@ -68,7 +73,7 @@ func Test_MergeIPAMConfig(t *testing.T) {
 	}
 
 	// Merge IPAM Config when using single nic mode (eth0)
-	c, err := mergeIPAMConfig(common.PodIfaceEth0, n, flip, flsubnet)
+	c, err := mergeIPAMConfig(common.PodIfaceEth0, n, flip, flsubnet, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -81,7 +86,7 @@ func Test_MergeIPAMConfig(t *testing.T) {
 	assert.Equal(0, len(result.IPAM.Routes)) // single nic mode should not have routes
 
 	// Merge IPAM Config when using multi-nic mode (eth1)
-	c, err = mergeIPAMConfig(common.PodIfaceEth1, n, flip, flsubnet)
+	c, err = mergeIPAMConfig(common.PodIfaceEth1, n, flip, flsubnet, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -95,4 +100,144 @@ func Test_MergeIPAMConfig(t *testing.T) {
 	assert.Equal("192.168.2.1", result.IPAM.Routes[0].GW.String())
 
 	fmt.Println(string(c))
+
+	// Merge IPAM Config when using dual-stack subnets
+	flip.Status.SecondaryAddr = net.ParseIP("fd00::2")
+	flsubnet6 := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			FlatMode: "macvlan",
+			Master:   "eth0",
+			CIDR:     "fd00::/64",
+			Mode:     "bridge",
+			Gateway:  net.ParseIP("fd00::1"),
+			Routes: []flv1.Route{
+				{
+					Dev: common.PodIfaceEth1,
+					Dst: "fd01::/64",
+					Via: net.ParseIP("fd00::ff"),
+				},
+			},
+		},
+	}
+	result = types.NetConf{}
+	c, err = mergeIPAMConfig(common.PodIfaceEth1, n, flip, flsubnet, flsubnet6)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	json.Unmarshal(c, &result)
+	assert.Equal(2, len(result.IPAM.Addresses))
+	assert.Equal("192.168.1.2/24", result.IPAM.Addresses[0].Address)
+	assert.Equal("fd00::2/64", result.IPAM.Addresses[1].Address)
+	assert.Equal("fd00::1", result.IPAM.Addresses[1].Gateway.String())
+	assert.Equal(2, len(result.IPAM.Routes))
+	assert.Equal("fd01::/64", result.IPAM.Routes[1].Dst.String())
+	assert.Equal(2, len(flsubnet.Spec.Routes)) // subnet routes not modified
+}
+
+func Test_setIPInterfaceConfigured(t *testing.T) {
+	flip := &flv1.FlatNetworkIP{
+		Status: flv1.IPStatus{
+			Phase: "Pending",
+			Addr:  net.ParseIP("192.168.1.2"),
+			MAC:   "02:42:ac:01:02:03",
+		},
+	}
+	setIPInterfaceConfigured(flip, "eth1", "node1", "abcdef", time.Now())
+	assert.Equal(t, "Active", flip.Status.Phase)
+	assert.Equal(t, "node1", flip.Status.NodeName)
+	assert.Equal(t, "abcdef", flip.Status.ContainerID)
+	assert.True(t, meta.IsStatusConditionTrue(
+		flip.Status.Conditions, flv1.IPConditionInterfaceConfigured))
+	assert.Equal(t, 1, len(flip.Status.History))
+	assert.Equal(t, "192.168.1.2", flip.Status.History[0].Addr.String())
+	assert.Equal(t, "node1", flip.Status.History[0].NodeName)
+}
+
+func Test_validateFlatNetworkIPAddrs(t *testing.T) {
+	ip := &flv1.FlatNetworkIP{
+		Status: flv1.IPStatus{
+			Addr:          net.ParseIP("192.168.1.10"),
+			SecondaryAddr: net.ParseIP("fd00::10"),
+			MAC:           "aa:bb:cc:dd:ee:ff",
+		},
+	}
+	newAddr := func(s string) netlink.Addr {
+		addr, _ := netlink.ParseAddr(s)
+		return *addr
+	}
+	addrs := []netlink.Addr{
+		newAddr("192.168.1.10/24"),
+		newAddr("fd00::10/64"),
+	}
+	assert.NoError(t, validateFlatNetworkIPAddrs(ip, "AA:BB:CC:DD:EE:FF", addrs))
+
+	err := validateFlatNetworkIPAddrs(ip, "aa:bb:cc:dd:ee:00", addrs[:1])
+	assert.ErrorContains(t, err, "MAC")
+	assert.ErrorContains(t, err, "fd00::10")
+	assert.NotContains(t, err.Error(), "192.168.1.10")
+}
+
+func Test_getBandwidthLimits(t *testing.T) {
+	n := &types.NetConf{}
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			Bandwidth: &flv1.Bandwidth{
+				IngressRate: "100M",
+				EgressRate:  "100M",
+			},
+		},
+	}
+	ingress, egress, err := getBandwidthLimits(n, nil, subnet)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100_000_000), ingress.Rate)
+	assert.Equal(t, uint64(100_000_000), egress.Rate)
+
+	n.RuntimeConfig.Bandwidth = &types.BandwidthEntry{
+		IngressRate:  50_000_000,
+		IngressBurst: 1_000_000,
+	}
+	ingress, egress, err = getBandwidthLimits(n, map[string]string{
+		flv1.AnnotationEgressRate: "10M",
+	}, subnet)
+	assert.NoError(t, err)
+	assert.Equal(t, &flcommon.BandwidthLimit{Rate: 50_000_000, Burst: 1_000_000}, ingress)
+	assert.Equal(t, &flcommon.BandwidthLimit{Rate: 10_000_000, Burst: 10_000_000}, egress)
+
+	ingress, egress, err = getBandwidthLimits(&types.NetConf{}, nil, &flv1.FlatNetworkSubnet{})
+	assert.NoError(t, err)
+	assert.Nil(t, ingress)
+	assert.Nil(t, egress)
+
+	_, _, err = getBandwidthLimits(n, map[string]string{
+		flv1.AnnotationIngressBurst: "10M",
+	}, subnet)
+	assert.Error(t, err)
+}
+
+func Test_getPodMTU(t *testing.T) {
+	newMTU := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+	n := &types.NetConf{
+		FlatNetworkConfig: types.FlatNetworkConfig{
+			MTU: 1500,
+		},
+	}
+	subnet := &flv1.FlatNetworkSubnet{}
+	mtu, err := getPodMTU(n, subnet, 9000)
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, mtu)
+	_, err = getPodMTU(n, subnet, 1450)
+	assert.Error(t, err)
+
+	subnet.Spec.MTU = newMTU(intstr.FromString(flv1.MTUAuto))
+	mtu, err = getPodMTU(n, subnet, 9000)
+	assert.NoError(t, err)
+	assert.Equal(t, 9000, mtu)
+
+	subnet.Spec.MTU = newMTU(intstr.FromInt32(8950))
+	mtu, err = getPodMTU(n, subnet, 9000)
+	assert.NoError(t, err)
+	assert.Equal(t, 8950, mtu)
+	_, err = getPodMTU(n, subnet, 1500)
+	assert.Error(t, err)
 }
@@ -2,13 +2,18 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/ipvlan"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/kubeclient"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/logger"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/macvlan"
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/route"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/types"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 	"github.com/containernetworking/cni/pkg/skel"
@@ -19,7 +24,7 @@ import (
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/util/retry"
 )
 
@@ -54,25 +59,41 @@ func Check(args *skel.CmdArgs) error {
 	podName := string(k8sArgs.K8S_POD_NAME)
 	podNamespace := string(k8sArgs.K8S_POD_NAMESPACE)
 
+	ipName, _, err := getFlatNetworkIPName(client, podNamespace, podName, args.IfName)
+	if err != nil {
+		return err
+	}
+
 	// The pod may just created and the IP is not allocated by operator.
 	var flatNetworkIP *flv1.FlatNetworkIP
-	if err := retry.OnError(retry.DefaultBackoff, errors.IsNotFound, func() error {
-		flatNetworkIP, err = client.GetIP(context.TODO(), podNamespace, podName)
+	if err := retry.OnError(retry.DefaultBackoff, apierrors.IsNotFound, func() error {
+		flatNetworkIP, err = client.GetIP(context.TODO(), podNamespace, ipName)
 		if err != nil {
 			logrus.Warnf("failed to get FlatNetworkIP [%v/%v]: %v",
-				podNamespace, podName, err)
+				podNamespace, ipName, err)
 			return err
 		}
 		return nil
 	}); err != nil {
 		return fmt.Errorf("failed to get FlatNetworkIP [%v/%v]: %w",
-			podNamespace, podName, err)
+			podNamespace, ipName, err)
 	}
 
 	subnet, err := client.GetSubnet(context.TODO(), flatNetworkIP.Spec.Subnet)
 	if err != nil {
 		return fmt.Errorf("failed to get FlatNetworkSubnet: %w", err)
 	}
+	var secondarySubnet *flv1.FlatNetworkSubnet
+	if flatNetworkIP.Spec.SecondarySubnet != "" {
+		secondarySubnet, err = client.GetSubnet(context.TODO(), flatNetworkIP.Spec.SecondarySubnet)
+		if err != nil {
+			return fmt.Errorf("failed to get secondary FlatNetworkSubnet: %w", err)
+		}
+	}
+	nodeName := getPodNodeName(client, podNamespace, podName)
+	if err := resolveSubnetsMaster(client, nodeName, subnet, secondarySubnet); err != nil {
+		return err
+	}
 
 	// run the IPAM plugin and get back the config to apply
 	err = ipam.ExecCheck(n.IPAM.Type, args.StdinData)
@@ -144,9 +165,111 @@ func Check(args *skel.CmdArgs) error {
 		return err
 	}
 
+	// Check the host side state and the pod routes configured by ADD are
+	// consistent with the FlatNetworkIP and subnets.
+	if err := checkFlatNetworkDrift(
+		args.IfName, n, netns, flatNetworkIP, subnet, secondarySubnet,
+	); err != nil {
+		err = fmt.Errorf("flatNetwork drift found on pod [%v/%v] iface [%v]: %w",
+			podNamespace, podName, args.IfName, err)
+		logrus.Error(err)
+		return err
+	}
+	logrus.Infof("CHECK: Done")
+
 	return nil
 }
 
+// checkFlatNetworkDrift checks the pod interface address & MAC, the host
+// route, the host VLAN interface and the pod routes configured by ADD still
+// exist, all of the drift found are returned in the error.
+func checkFlatNetworkDrift(
+	ifName string,
+	n *types.NetConf,
+	netns ns.NetNS,
+	flatNetworkIP *flv1.FlatNetworkIP,
+	subnet *flv1.FlatNetworkSubnet,
+	secondarySubnet *flv1.FlatNetworkSubnet,
+) error {
+	var errs []error
+	if err := common.CheckVlanIfaceOnHost(subnet.Spec.Master, subnet.Spec.VLAN); err != nil {
+		errs = append(errs, err)
+	}
+	if subnet.Spec.RouteSettings.AddPodIPToHost {
+		for _, addr := range flatNetworkIPAddrs(flatNetworkIP) {
+			if err := route.CheckFlatNetworkRouteOnHost(addr); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if err := netns.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(ifName)
+		if err != nil {
+			return fmt.Errorf("failed to lookup iface %q in pod: %w", ifName, err)
+		}
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return fmt.Errorf("failed to list addrs of iface %q in pod: %w", ifName, err)
+		}
+		return validateFlatNetworkIPAddrs(
+			flatNetworkIP, link.Attrs().HardwareAddr.String(), addrs)
+	}); err != nil {
+		errs = append(errs, err)
+	}
+
+	var cidrs []string
+	if subnet.Spec.RouteSettings.AddClusterCIDR {
+		cidrs = append(cidrs, strings.Split(n.FlatNetworkConfig.ClusterCIDR, ",")...)
+	}
+	if subnet.Spec.RouteSettings.AddServiceCIDR {
+		cidrs = append(cidrs, strings.Split(n.FlatNetworkConfig.ServiceCIDR, ",")...)
+	}
+	for _, cidr := range cidrs {
+		if cidr == "" {
+			continue
+		}
+		if err := route.CheckPodKubeCIDRRoutes(netns, cidr); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := route.CheckPodFlatNetworkCustomRoutes(netns, subnet.Spec.Routes); err != nil {
+		errs = append(errs, err)
+	}
+	if secondarySubnet != nil {
+		if err := route.CheckPodFlatNetworkCustomRoutes(netns, secondarySubnet.Spec.Routes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateFlatNetworkIPAddrs ensures the addresses and MAC allocated in the
+// FlatNetworkIP status are configured on the pod interface.
+func validateFlatNetworkIPAddrs(
+	flatNetworkIP *flv1.FlatNetworkIP, mac string, addrs []netlink.Addr,
+) error {
+	var errs []error
+	if flatNetworkIP.Status.MAC != "" && !strings.EqualFold(flatNetworkIP.Status.MAC, mac) {
+		errs = append(errs, fmt.Errorf("iface MAC %q does not match FlatNetworkIP MAC %q",
+			mac, flatNetworkIP.Status.MAC))
+	}
+	for _, addr := range flatNetworkIPAddrs(flatNetworkIP) {
+		if len(addr) == 0 {
+			continue
+		}
+		found := slices.ContainsFunc(addrs, func(a netlink.Addr) bool {
+			return a.IPNet != nil && a.IP.Equal(addr)
+		})
+		if !found {
+			errs = append(errs, fmt.Errorf("FlatNetworkIP address %q not found on iface",
+				addr.String()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func validateCniContainerInterface(
 	intf types100.Interface, flatMode string, expectedMode string, expectedFlag string,
 ) error {
@@ -1,16 +1,26 @@
 package commands
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/kubeclient"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/types"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flcommon "github.com/cnrancher/rancher-flat-network/pkg/common"
 	cnitypes "github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/types/create"
 )
@@ -23,9 +33,13 @@ func loadCNINetConf(bytes []byte) (*types.NetConf, error) {
 	return n, nil
 }
 
+// mergeIPAMConfig merges the flat-network IP addresses and routes into
+// the static IPAM config.
+// The secondarySubnet is nil if the pod is not using dual-stack subnets.
 func mergeIPAMConfig(
 	ifName string, netConf *types.NetConf,
 	flatNetworkIP *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+	secondarySubnet *flv1.FlatNetworkSubnet,
 ) ([]byte, error) {
 	address := flatNetworkIP.Status.Addr
 	_, n, err := net.ParseCIDR(subnet.Spec.CIDR)
@@ -58,6 +72,21 @@ func mergeIPAMConfig(
 		}
 	}
 
+	// add secondary address for dual-stack
+	if secondarySubnet != nil && len(flatNetworkIP.Status.SecondaryAddr) != 0 {
+		_, n, err := net.ParseCIDR(secondarySubnet.Spec.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse secondary subnet CIDR [%v]: %w",
+				secondarySubnet.Spec.CIDR, err)
+		}
+		ones, _ := n.Mask.Size()
+		netConf.IPAM.Addresses = append(netConf.IPAM.Addresses, types.Address{
+			Address: fmt.Sprintf("%v/%v", flatNetworkIP.Status.SecondaryAddr.String(), ones),
+			Gateway: secondarySubnet.Spec.Gateway,
+		})
+		routes = append(slices.Clone(routes), secondarySubnet.Spec.Routes...)
+	}
+
 	if len(routes) != 0 && ifName != common.PodIfaceEth0 {
 		rs := []*cnitypes.Route{}
 		for _, v := range routes {
@@ -81,6 +110,53 @@ func mergeIPAMConfig(
 	return json.MarshalIndent(netConf, "", "  ")
 }
 
+// getFlatNetworkIPName returns the FlatNetworkIP name and the expected
+// interface name of the pod interface attachment.
+// The FlatNetworkIP of the default attachment is named after the pod,
+// the additional attachments defined in the pod interfaces annotation
+// are named by '<pod>-<ifName>-<hash>', or '<pod>-<ifName>' if created by
+// the previous versions for the pod.
+func getFlatNetworkIPName(
+	client kubeclient.KubeClient, namespace, podName, ifName string,
+) (string, string, error) {
+	pod, err := client.GetPod(context.TODO(), namespace, podName)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get pod [%v/%v]: %w",
+			namespace, podName, err)
+	}
+	interfaces, err := flcommon.CheckPodAnnotationInterfaces(
+		pod.Annotations[flv1.AnnotationInterfaces])
+	if err != nil {
+		return "", "", err
+	}
+	for _, iface := range interfaces {
+		if iface.Interface != ifName {
+			continue
+		}
+		name := flcommon.GetLegacyFlatNetworkIPName(podName, ifName)
+		ip, err := client.GetIP(context.TODO(), namespace, name)
+		if err == nil && ip.Spec.Interface == ifName && ip.Spec.PodID == string(pod.UID) {
+			return name, ifName, nil
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			return "", "", fmt.Errorf("failed to get FlatNetworkIP [%v/%v]: %w",
+				namespace, name, err)
+		}
+		return flcommon.GetFlatNetworkIPName(podName, ifName), ifName, nil
+	}
+	return flcommon.GetFlatNetworkIPName(podName, ""), "", nil
+}
+
+// flatNetworkIPAddrs returns the allocated addresses of the flat-network IP,
+// including the secondary address of the dual-stack subnet.
+func flatNetworkIPAddrs(flatNetworkIP *flv1.FlatNetworkIP) []net.IP {
+	addrs := []net.IP{flatNetworkIP.Status.Addr}
+	if len(flatNetworkIP.Status.SecondaryAddr) != 0 {
+		addrs = append(addrs, flatNetworkIP.Status.SecondaryAddr)
+	}
+	return addrs
+}
+
 func get6to4CIDR(ip net.IP, size int) string {
 	if ip = ip.To4(); ip == nil {
 		return ""
@@ -127,3 +203,181 @@ func parsePrevResult(conf *types.NetConf) error {
 
 	return nil
 }
+
+// getPodNodeName returns the node name of the pod, fallback to the node name
+// of this node if failed to get the pod.
+func getPodNodeName(client kubeclient.KubeClient, namespace, podName string) string {
+	pod, err := client.GetPod(context.TODO(), namespace, podName)
+	if err == nil && pod.Spec.NodeName != "" {
+		return pod.Spec.NodeName
+	}
+	return getNodeName()
+}
+
+// nodeNameFile is the file on host written by the flat-network CNI daemonset
+// recording the node name.
+const nodeNameFile = "/etc/rancher/flat-network/node-name"
+
+// getNodeName returns the Kubernetes node name of this node, which may differ
+// from the hostname if the kubelet hostname is overridden.
+// The node name is read from the NODE_NAME env or the file written by the
+// flat-network CNI daemonset, fallback to the hostname.
+func getNodeName() string {
+	if name := os.Getenv("NODE_NAME"); name != "" {
+		return name
+	}
+	if b, err := os.ReadFile(nodeNameFile); err == nil {
+		if name := strings.TrimSpace(string(b)); name != "" {
+			return name
+		}
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// setIPInterfaceConfigured updates the flat-network IP status after the pod
+// interface configured by CNI.
+func setIPInterfaceConfigured(
+	flatNetworkIP *flv1.FlatNetworkIP, ifName, nodeName, containerID string, now time.Time,
+) {
+	flatNetworkIP.Status.Phase = "Active"
+	flatNetworkIP.Status.NodeName = nodeName
+	flatNetworkIP.Status.ContainerID = containerID
+	flcommon.SetIPCondition(flatNetworkIP, flv1.IPConditionInterfaceConfigured,
+		metav1.ConditionTrue, flv1.IPConditionInterfaceConfigured,
+		fmt.Sprintf("interface [%v] configured on node [%v]", ifName, nodeName))
+	flcommon.AddIPAllocationRecord(flatNetworkIP, flv1.IPAllocationRecord{
+		Time:          metav1.NewTime(now.UTC()),
+		Reason:        flv1.IPConditionInterfaceConfigured,
+		Addr:          flatNetworkIP.Status.Addr,
+		SecondaryAddr: flatNetworkIP.Status.SecondaryAddr,
+		MAC:           flatNetworkIP.Status.MAC,
+		NodeName:      nodeName,
+		ContainerID:   containerID,
+	})
+}
+
+// getBandwidthLimits returns the ingress and egress bandwidth limits of the
+// pod flat-network iface, nil if the direction is not limited.
+// The pod bandwidth annotations take precedence over the runtime 'bandwidth'
+// capability, then the subnet default bandwidth.
+func getBandwidthLimits(
+	n *types.NetConf, annotations map[string]string, subnet *flv1.FlatNetworkSubnet,
+) (*flcommon.BandwidthLimit, *flcommon.BandwidthLimit, error) {
+	ingress, egress, err := flcommon.GetBandwidthLimits(
+		flcommon.GetPodAnnotationBandwidth(annotations))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid pod bandwidth annotations: %w", err)
+	}
+	if bw := n.RuntimeConfig.Bandwidth; bw != nil {
+		if ingress == nil {
+			ingress = newBandwidthLimit(bw.IngressRate, bw.IngressBurst)
+		}
+		if egress == nil {
+			egress = newBandwidthLimit(bw.EgressRate, bw.EgressBurst)
+		}
+	}
+	subnetIngress, subnetEgress, err := flcommon.GetBandwidthLimits(subnet.Spec.Bandwidth)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid subnet [%v] bandwidth: %w", subnet.Name, err)
+	}
+	if ingress == nil {
+		ingress = subnetIngress
+	}
+	if egress == nil {
+		egress = subnetEgress
+	}
+	return ingress, egress, nil
+}
+
+func newBandwidthLimit(rate, burst uint64) *flcommon.BandwidthLimit {
+	if rate == 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = rate
+	}
+	return &flcommon.BandwidthLimit{
+		Rate:  rate,
+		Burst: burst,
+	}
+}
+
+// getPodMTU returns the MTU of the pod flat-network iface by the subnet MTU,
+// fallback to the MTU of the NetworkAttachmentDefinition config.
+// The MTU should not exceed the MTU of the parent iface on host.
+func getPodMTU(
+	n *types.NetConf, subnet *flv1.FlatNetworkSubnet, parentMTU int,
+) (int, error) {
+	mtu, auto, err := flcommon.ParseSubnetMTU(subnet)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case auto:
+		mtu = parentMTU
+	case mtu == 0:
+		mtu = n.FlatNetworkConfig.MTU
+	}
+	if parentMTU != 0 && mtu > parentMTU {
+		return 0, fmt.Errorf("pod MTU %v of subnet [%v] exceeds the parent iface MTU %v",
+			mtu, subnet.Name, parentMTU)
+	}
+	return mtu, nil
+}
+
+// resolveSubnetsMaster sets the master of the subnets to the master
+// interface of the node resolved by the subnet masterSelectors.
+func resolveSubnetsMaster(
+	client kubeclient.KubeClient, nodeName string, subnets ...*flv1.FlatNetworkSubnet,
+) error {
+	nodeLabels := nodeLabelsGetter(client, nodeName)
+	for _, subnet := range subnets {
+		if err := resolveSubnetMaster(subnet, nodeName, nodeLabels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeLabelsGetter returns the function to get the labels of the node,
+// the node is only read once.
+func nodeLabelsGetter(
+	client kubeclient.KubeClient, nodeName string,
+) func() (map[string]string, error) {
+	return sync.OnceValues(func() (map[string]string, error) {
+		node, err := client.GetNode(context.TODO(), nodeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node [%v]: %w", nodeName, err)
+		}
+		if node.Labels == nil {
+			return map[string]string{}, nil
+		}
+		return node.Labels, nil
+	})
+}
+
+// resolveSubnetMaster sets the master of the subnet to the master interface
+// of the node resolved by the subnet masterSelectors.
+func resolveSubnetMaster(
+	subnet *flv1.FlatNetworkSubnet, nodeName string,
+	nodeLabels func() (map[string]string, error),
+) error {
+	if subnet == nil || len(subnet.Spec.MasterSelectors) == 0 {
+		return nil
+	}
+	l, err := nodeLabels()
+	if err != nil {
+		return err
+	}
+	master := flcommon.GetSubnetNodeMaster(subnet, l)
+	if master == "" {
+		logrus.Warnf("master of subnet [%v] not found for node [%v]",
+			subnet.Name, nodeName)
+	} else if master != subnet.Spec.Master {
+		logrus.Infof("use master [%v] of subnet [%v] on node [%v]",
+			master, subnet.Name, nodeName)
+	}
+	subnet.Spec.Master = master
+	return nil
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+func Test_resolveSubnetMaster(t *testing.T) {
+	newSubnet := func() *flv1.FlatNetworkSubnet {
+		return &flv1.FlatNetworkSubnet{
+			Spec: flv1.SubnetSpec{
+				Master: "eth1",
+				MasterSelectors: []flv1.MasterSelector{
+					{
+						NodeSelector: metav1.LabelSelector{
+							MatchLabels: map[string]string{"nic": "ens"},
+						},
+						Master: "ens1",
+					},
+				},
+			},
+		}
+	}
+	nodeLabels := func() (map[string]string, error) {
+		return map[string]string{"nic": "ens"}, nil
+	}
+	subnet := newSubnet()
+	assert.NoError(t, resolveSubnetMaster(subnet, "node", nodeLabels))
+	assert.Equal(t, "ens1", subnet.Spec.Master)
+
+	// The node is not read for the subnet without master selectors.
+	failed := func() (map[string]string, error) {
+		return nil, fmt.Errorf("node not found")
+	}
+	subnet = newSubnet()
+	subnet.Spec.MasterSelectors = nil
+	assert.NoError(t, resolveSubnetMaster(subnet, "node", failed))
+	assert.Equal(t, "eth1", subnet.Spec.Master)
+
+	subnet = newSubnet()
+	assert.Error(t, resolveSubnetMaster(subnet, "node", failed))
+	assert.Equal(t, "eth1", subnet.Spec.Master)
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/bandwidth"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/logger"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/route"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
@@ -37,7 +38,11 @@ func Del(args *skel.CmdArgs) error {
 	// There is a netns so try to clean up. Delete can be called multiple times
 	// so don't return an error if the device is already removed.
 	var addrs []netlink.Addr
-	if err := ns.WithNetNSPath(args.Netns, func(_ ns.NetNS) error {
+	if err := ns.WithNetNSPath(args.Netns, func(netns ns.NetNS) error {
+		if err := bandwidth.Teardown(netns, args.IfName); err != nil {
+			return err
+		}
+
 		iface, err := netlink.LinkByName(args.IfName)
 		if err != nil {
 			if _, ok := err.(netlink.LinkNotFoundError); ok {
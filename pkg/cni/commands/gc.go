@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/kubeclient"
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/logger"
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/route"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
+	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flcommon "github.com/cnrancher/rancher-flat-network/pkg/common"
+)
+
+// GC cleans up the host routes, VLAN interfaces and FlatNetworkIPs of the
+// attachments on this node not in the valid attachments list (CNI 1.1).
+func GC(args *skel.CmdArgs) error {
+	if err := logger.Setup(); err != nil {
+		return err
+	}
+	logrus.Debugf("cmdGC args: %v", utils.Print(args))
+
+	n, err := loadCNINetConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("cniNetConf: %v", utils.Print(n))
+
+	client, err := kubeclient.GetK8sClient(args.Path)
+	if err != nil {
+		return fmt.Errorf("failed to get kube client: %w", err)
+	}
+	ctx := context.TODO()
+	subnetList, err := client.ListSubnets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list FlatNetworkSubnets: %w", err)
+	}
+	subnets := make(map[string]*flv1.FlatNetworkSubnet, len(subnetList))
+	subnetPtrs := make([]*flv1.FlatNetworkSubnet, 0, len(subnetList))
+	for i := range subnetList {
+		subnets[subnetList[i].Name] = &subnetList[i]
+		subnetPtrs = append(subnetPtrs, &subnetList[i])
+	}
+	nodeName := getNodeName()
+	if err := resolveSubnetsMaster(client, nodeName, subnetPtrs...); err != nil {
+		return err
+	}
+	ips, err := client.ListIPs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list FlatNetworkIPs: %w", err)
+	}
+
+	keep, stale := getNodeAttachmentIPs(ips, n.ValidAttachments, nodeName)
+	var errs []error
+	for _, ip := range stale {
+		deleted, err := gcFlatNetworkIP(client, ip)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if !deleted {
+			// The pod still exists, keep the host route & VLAN iface.
+			keep = append(keep, ip)
+		}
+	}
+	if err := gcHostRoutes(subnetList, keep); err != nil {
+		errs = append(errs, err)
+	}
+	if err := gcVlanIfaces(subnets, keep); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// getNodeAttachmentIPs returns the FlatNetworkIPs should be kept and the
+// stale FlatNetworkIPs configured on the node whose container is not in
+// the valid attachments.
+// The IPs configured by the old versions without the container ID recorded
+// are kept.
+func getNodeAttachmentIPs(
+	ips []flv1.FlatNetworkIP, attachments []cnitypes.GCAttachment, nodeName string,
+) ([]*flv1.FlatNetworkIP, []*flv1.FlatNetworkIP) {
+	containers := make(map[string]bool, len(attachments))
+	for _, a := range attachments {
+		containers[a.ContainerID] = true
+	}
+	var keep, stale []*flv1.FlatNetworkIP
+	for i := range ips {
+		ip := &ips[i]
+		switch {
+		case ip.Status.ContainerID == "" || containers[ip.Status.ContainerID]:
+			keep = append(keep, ip)
+		case !strings.EqualFold(ip.Status.NodeName, nodeName):
+		case ip.DeletionTimestamp == nil:
+			stale = append(stale, ip)
+		}
+	}
+	return keep, stale
+}
+
+// gcFlatNetworkIP deletes the FlatNetworkIP of the stale attachment if the
+// pod is already deleted, the IP of the existing pod is updated by the next
+// CNI ADD instead.
+// Returns true if the FlatNetworkIP is deleted.
+func gcFlatNetworkIP(client kubeclient.KubeClient, ip *flv1.FlatNetworkIP) (bool, error) {
+	podName := flcommon.GetFlatNetworkIPPodName(ip)
+	_, err := client.GetPod(context.TODO(), ip.Namespace, podName)
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get pod [%v/%v]: %w", ip.Namespace, podName, err)
+	}
+	err = client.DeleteIP(context.TODO(), ip.Namespace, ip.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to delete FlatNetworkIP [%v/%v]: %w",
+			ip.Namespace, ip.Name, err)
+	}
+	logrus.Infof("GC: delete FlatNetworkIP [%v/%v] of stale container [%v]",
+		ip.Namespace, ip.Name, ip.Status.ContainerID)
+	return true, nil
+}
+
+// gcHostRoutes deletes the flat-network IP routes on host not used by the
+// kept FlatNetworkIPs, see route.AddFlatNetworkRouteToHost.
+func gcHostRoutes(subnets []flv1.FlatNetworkSubnet, keep []*flv1.FlatNetworkIP) error {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list routes on host: %w", err)
+	}
+	used := map[string]bool{}
+	for _, ip := range keep {
+		for _, addr := range flatNetworkIPAddrs(ip) {
+			if len(addr) != 0 {
+				used[addr.String()] = true
+			}
+		}
+	}
+	var errs []error
+	for _, r := range routes {
+		if !isFlatNetworkHostRoute(&r, subnets) || used[r.Dst.IP.String()] {
+			continue
+		}
+		if err := route.DelFlatNetworkRouteFromHost(r.Dst.IP); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logrus.Infof("GC: delete stale flatNetwork route [%v] on host", r.Dst)
+	}
+	return errors.Join(errs...)
+}
+
+// isFlatNetworkHostRoute checks whether the host route is the single address
+// route to the IP inside of the flat-network subnets.
+func isFlatNetworkHostRoute(r *netlink.Route, subnets []flv1.FlatNetworkSubnet) bool {
+	if r.Dst == nil {
+		return false
+	}
+	if ones, bits := r.Dst.Mask.Size(); bits == 0 || ones != bits {
+		return false
+	}
+	for i := range subnets {
+		_, network, err := net.ParseCIDR(subnets[i].Spec.CIDR)
+		if err != nil {
+			continue
+		}
+		if network.Contains(r.Dst.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// gcVlanIfaces deletes the VLAN interfaces created for the flat-network
+// subnets not used by the kept FlatNetworkIPs.
+// The VLAN interfaces configured with addresses are used by host and kept.
+func gcVlanIfaces(
+	subnets map[string]*flv1.FlatNetworkSubnet, keep []*flv1.FlatNetworkIP,
+) error {
+	used := map[string]bool{}
+	for _, ip := range keep {
+		for _, name := range []string{ip.Spec.Subnet, ip.Spec.SecondarySubnet} {
+			if s := subnets[name]; s != nil && s.Spec.VLAN != 0 {
+				used[getSubnetVlanIfaceName(s)] = true
+			}
+		}
+	}
+	expected := map[string]bool{}
+	for _, s := range subnets {
+		if s.Spec.VLAN != 0 {
+			expected[getSubnetVlanIfaceName(s)] = true
+		}
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list links on host: %w", err)
+	}
+	var errs []error
+	for _, l := range links {
+		if _, ok := l.(*netlink.Vlan); !ok {
+			continue
+		}
+		name := l.Attrs().Name
+		if !expected[name] || used[name] {
+			continue
+		}
+		addrs, err := netlink.AddrList(l, netlink.FAMILY_ALL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list addrs on %q: %w", name, err))
+			continue
+		}
+		if hasGlobalUnicastAddr(addrs) {
+			continue
+		}
+		if err := netlink.LinkDel(l); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete vlan iface %q: %w", name, err))
+			continue
+		}
+		logrus.Infof("GC: delete stale vlan interface [%v] on host", name)
+	}
+	return errors.Join(errs...)
+}
+
+// getSubnetVlanIfaceName returns the VLAN interface name <master>.<vlanID>
+// of the subnet, see common.GetVlanIfaceOnHost.
+func getSubnetVlanIfaceName(subnet *flv1.FlatNetworkSubnet) string {
+	return fmt.Sprintf("%v.%v", subnet.Spec.Master, subnet.Spec.VLAN)
+}
+
+func hasGlobalUnicastAddr(addrs []netlink.Addr) bool {
+	for _, a := range addrs {
+		if a.IP != nil && a.IP.IsGlobalUnicast() {
+			return true
+		}
+	}
+	return false
+}
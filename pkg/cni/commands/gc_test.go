@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"net"
+	"testing"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+func Test_getNodeAttachmentIPs(t *testing.T) {
+	newIP := func(name, node, containerID string) flv1.FlatNetworkIP {
+		ip := flv1.FlatNetworkIP{}
+		ip.Name = name
+		ip.Status.NodeName = node
+		ip.Status.ContainerID = containerID
+		return ip
+	}
+	ips := []flv1.FlatNetworkIP{
+		newIP("ip1", "node1", "c1"),
+		newIP("ip2", "node1", "c2"),
+		newIP("ip3", "node2", "c3"),
+		newIP("ip4", "", ""),
+	}
+	keep, stale := getNodeAttachmentIPs(ips, []cnitypes.GCAttachment{
+		{ContainerID: "c1", IfName: "eth1"},
+	}, "Node1")
+	names := func(ips []*flv1.FlatNetworkIP) []string {
+		var result []string
+		for _, ip := range ips {
+			result = append(result, ip.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"ip1", "ip4"}, names(keep))
+	assert.Equal(t, []string{"ip2"}, names(stale))
+}
+
+func Test_isFlatNetworkHostRoute(t *testing.T) {
+	subnets := []flv1.FlatNetworkSubnet{
+		{Spec: flv1.SubnetSpec{CIDR: "192.168.1.0/24"}},
+	}
+	_, dst, _ := net.ParseCIDR("192.168.1.10/32")
+	assert.True(t, isFlatNetworkHostRoute(&netlink.Route{Dst: dst}, subnets))
+	_, dst, _ = net.ParseCIDR("192.168.1.0/24")
+	assert.False(t, isFlatNetworkHostRoute(&netlink.Route{Dst: dst}, subnets))
+	_, dst, _ = net.ParseCIDR("10.0.0.1/32")
+	assert.False(t, isFlatNetworkHostRoute(&netlink.Route{Dst: dst}, subnets))
+	assert.False(t, isFlatNetworkHostRoute(&netlink.Route{}, subnets))
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/kubeclient"
+	"github.com/cnrancher/rancher-flat-network/pkg/cni/logger"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
+	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	// Error codes of the CNI STATUS verb defined in CNI spec 1.1.
+	errPluginNotAvailable  uint = 50
+	errLimitedConnectivity uint = 51
+
+	statusTimeout = time.Second * 10
+)
+
+// Status reports the plugin is not ready if the API server is unreachable
+// or the master interface of the subnet is missing on this node (CNI 1.1).
+func Status(args *skel.CmdArgs) error {
+	if err := logger.Setup(); err != nil {
+		return err
+	}
+	logrus.Debugf("cmdStatus args: %v", utils.Print(args))
+
+	if _, err := loadCNINetConf(args.StdinData); err != nil {
+		return err
+	}
+	client, err := kubeclient.GetK8sClient(args.Path)
+	if err != nil {
+		return cnitypes.NewError(errPluginNotAvailable,
+			"failed to get kube client", err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), statusTimeout)
+	defer cancel()
+	subnets, err := client.ListSubnets(ctx)
+	if err != nil {
+		logrus.Errorf("STATUS: failed to list FlatNetworkSubnets: %v", err)
+		return cnitypes.NewError(errPluginNotAvailable,
+			"kube-apiserver is unreachable", err.Error())
+	}
+	// Resolve the master per subnet and skip the subnets whose master can not
+	// be resolved by the master selectors rather than reporting the node
+	// not ready.
+	nodeName := getNodeName()
+	nodeLabels := nodeLabelsGetter(client, nodeName)
+	resolved := make([]flv1.FlatNetworkSubnet, 0, len(subnets))
+	for i := range subnets {
+		if err := resolveSubnetMaster(&subnets[i], nodeName, nodeLabels); err != nil {
+			logrus.Warnf("STATUS: skip subnet [%v]: %v", subnets[i].Name, err)
+			continue
+		}
+		resolved = append(resolved, subnets[i])
+	}
+	if err := checkSubnetMasters(resolved, linkExists); err != nil {
+		logrus.Errorf("STATUS: %v", err)
+		return cnitypes.NewError(errLimitedConnectivity,
+			"subnet master interface not found", err.Error())
+	}
+	return nil
+}
+
+// checkSubnetMasters ensures the master interfaces of the active subnets
+// exist on this node.
+func checkSubnetMasters(
+	subnets []flv1.FlatNetworkSubnet, exists func(string) bool,
+) error {
+	for i := range subnets {
+		subnet := &subnets[i]
+		if subnet.Status.Phase != "Active" || subnet.Spec.Master == "" {
+			continue
+		}
+		if !exists(subnet.Spec.Master) {
+			return fmt.Errorf("master interface %q of subnet [%v] not found",
+				subnet.Spec.Master, subnet.Name)
+		}
+	}
+	return nil
+}
+
+func linkExists(name string) bool {
+	_, err := netlink.LinkByName(name)
+	return err == nil
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+func Test_checkSubnetMasters(t *testing.T) {
+	subnets := []flv1.FlatNetworkSubnet{
+		{
+			Spec:   flv1.SubnetSpec{Master: "eth1"},
+			Status: flv1.SubnetStatus{Phase: "Active"},
+		},
+		{
+			Spec:   flv1.SubnetSpec{Master: "eth2"},
+			Status: flv1.SubnetStatus{Phase: "Failed"},
+		},
+	}
+	exists := func(name string) bool { return name == "eth1" }
+	assert.NoError(t, checkSubnetMasters(subnets, exists))
+	subnets[1].Status.Phase = "Active"
+	assert.Error(t, checkSubnetMasters(subnets, exists))
+}
@@ -83,9 +83,19 @@ func SetPromiscOn(iface string) error {
 
 // GetVlanIfaceOnHost gets the VLAN interface <ifname>.<vlanID> (eth0.100) on host
 // and create if not exists.
+// The MTU should not exceed the MTU of the master, the VLAN interface MTU is
+// set to the MTU if not 0.
 func GetVlanIfaceOnHost(
 	master string, mtu int, vlanID int,
 ) (*types100.Interface, error) {
+	m, err := netlink.LinkByName(master)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup master %q: %w", master, err)
+	}
+	if mtu > m.Attrs().MTU {
+		return nil, fmt.Errorf("MTU %v exceeds the MTU %v of master %q",
+			mtu, m.Attrs().MTU, master)
+	}
 	links, err := netlink.LinkList()
 	if err != nil {
 		return nil, fmt.Errorf("netlink.LinkList: failed to list links: %w", err)
@@ -96,16 +106,44 @@ func GetVlanIfaceOnHost(
 		ifName = fmt.Sprintf("%v.%v", master, vlanID)
 	}
 	for _, l := range links {
-		if l.Attrs().Name == ifName {
-			iface := &types100.Interface{}
-			iface.Name = ifName
-			iface.Mac = l.Attrs().HardwareAddr.String()
-			return iface, nil
+		if l.Attrs().Name != ifName {
+			continue
 		}
+		if vlanID != 0 && mtu != 0 && l.Attrs().MTU != mtu {
+			if err := netlink.LinkSetMTU(l, mtu); err != nil {
+				return nil, fmt.Errorf("failed to set vlan iface [%v] MTU %v: %w",
+					ifName, mtu, err)
+			}
+			logrus.Infof("set vlan iface [%v] MTU from %v to %v",
+				ifName, l.Attrs().MTU, mtu)
+			l.Attrs().MTU = mtu
+		}
+		iface := &types100.Interface{}
+		iface.Name = ifName
+		iface.Mac = l.Attrs().HardwareAddr.String()
+		iface.Mtu = l.Attrs().MTU
+		return iface, nil
 	}
 	return createVLANOnHost(master, mtu, ifName, vlanID)
 }
 
+// CheckVlanIfaceOnHost checks the VLAN interface <ifname>.<vlanID> (eth0.100)
+// exists on host and the link is up.
+func CheckVlanIfaceOnHost(master string, vlanID int) error {
+	ifName := master
+	if vlanID != 0 {
+		ifName = fmt.Sprintf("%v.%v", master, vlanID)
+	}
+	link, err := netlink.LinkByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to lookup vlan iface %q on host: %w", ifName, err)
+	}
+	if link.Attrs().Flags&net.FlagUp == 0 {
+		return fmt.Errorf("vlan iface %q on host is not up", ifName)
+	}
+	return nil
+}
+
 // createVLANOnHost creates a VLAN interface <ifname>.<vlanID> (eth0.1) on root
 // network namespace.
 func createVLANOnHost(
@@ -156,6 +194,7 @@ func createVLANOnHost(
 	iface := &types100.Interface{
 		Name: ifName,
 		Mac:  contVlan.Attrs().HardwareAddr.String(),
+		Mtu:  contVlan.Attrs().MTU,
 	}
 	return iface, nil
 }
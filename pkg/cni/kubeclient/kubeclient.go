@@ -32,16 +32,24 @@ var _ KubeClient = &defaultKubeClient{}
 
 type KubeClient interface {
 	GetPod(context.Context, string, string) (*corev1.Pod, error)
+	GetNode(context.Context, string) (*corev1.Node, error)
 	GetIP(context.Context, string, string) (*flv1.FlatNetworkIP, error)
 	GetSubnet(context.Context, string) (*flv1.FlatNetworkSubnet, error)
 	UpdateIP(context.Context, string, *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error)
 	UpdateIPStatus(context.Context, string, *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error)
+	DeleteIP(context.Context, string, string) error
+	ListIPs(context.Context) ([]flv1.FlatNetworkIP, error)
+	ListSubnets(context.Context) ([]flv1.FlatNetworkSubnet, error)
 }
 
 func (d *defaultKubeClient) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
 	return d.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
+func (d *defaultKubeClient) GetNode(ctx context.Context, name string) (*corev1.Node, error) {
+	return d.client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+}
+
 func (d *defaultKubeClient) GetIP(ctx context.Context, namespace, name string) (*flv1.FlatNetworkIP, error) {
 	return d.macvlanclientset.FlatnetworkV1().FlatNetworkIPs(namespace).Get(ctx, name, metav1.GetOptions{})
 }
@@ -54,6 +62,26 @@ func (d *defaultKubeClient) UpdateIPStatus(ctx context.Context, namespace string
 	return d.macvlanclientset.FlatnetworkV1().FlatNetworkIPs(namespace).UpdateStatus(ctx, macvlanip, metav1.UpdateOptions{})
 }
 
+func (d *defaultKubeClient) DeleteIP(ctx context.Context, namespace, name string) error {
+	return d.macvlanclientset.FlatnetworkV1().FlatNetworkIPs(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (d *defaultKubeClient) ListIPs(ctx context.Context) ([]flv1.FlatNetworkIP, error) {
+	list, err := d.macvlanclientset.FlatnetworkV1().FlatNetworkIPs("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (d *defaultKubeClient) ListSubnets(ctx context.Context) ([]flv1.FlatNetworkSubnet, error) {
+	list, err := d.macvlanclientset.FlatnetworkV1().FlatNetworkSubnets(subnetNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
 func (d *defaultKubeClient) GetSubnet(ctx context.Context, name string) (*flv1.FlatNetworkSubnet, error) {
 	return d.macvlanclientset.FlatnetworkV1().FlatNetworkSubnets(subnetNamespace).Get(ctx, name, metav1.GetOptions{})
 }
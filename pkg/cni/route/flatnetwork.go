@@ -138,3 +138,35 @@ func DelFlatNetworkRouteFromHost(flatNetworkIP net.IP) error {
 
 	return nil
 }
+
+// CheckFlatNetworkRouteOnHost checks the flatNetworkIP route added by
+// AddFlatNetworkRouteToHost exists on host NS.
+func CheckFlatNetworkRouteOnHost(flatNetworkIP net.IP) error {
+	if len(flatNetworkIP) == 0 || flatNetworkIP.To16() == nil {
+		return nil
+	}
+
+	dst := &net.IPNet{
+		IP:   flatNetworkIP.To16(),
+		Mask: net.CIDRMask(net.IPv6len*8, net.IPv6len*8),
+	}
+	if v4 := flatNetworkIP.To4(); v4 != nil {
+		dst = &net.IPNet{
+			IP:   v4,
+			Mask: net.CIDRMask(net.IPv4len*8, net.IPv4len*8),
+		}
+	}
+	ok, err := CheckRouteExists(&netlink.Route{
+		Dst:    dst,
+		Family: nl.GetIPFamily(flatNetworkIP),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check flatNetwork IP %q route on host: %w",
+			flatNetworkIP.String(), err)
+	}
+	if !ok {
+		return fmt.Errorf("flatNetwork IP %q route not found on host",
+			flatNetworkIP.String())
+	}
+	return nil
+}
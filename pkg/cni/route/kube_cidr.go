@@ -60,3 +60,31 @@ func AddPodKubeCIDRRoutes(podNS ns.NetNS, cidr string) error {
 	}
 	return nil
 }
+
+// CheckPodKubeCIDRRoutes checks the route of the kube CIDR added by
+// AddPodKubeCIDRRoutes exists in pod NS.
+func CheckPodKubeCIDRRoutes(podNS ns.NetNS, cidr string) error {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("failed to parse CIDR %q: %w", cidr, err)
+	}
+	r := netlink.Route{
+		Dst:    network,
+		Family: netlink.FAMILY_V4,
+	}
+	if ip.To16() != nil && len(ip.To4()) == 0 {
+		r.Family = netlink.FAMILY_V6
+	}
+	var ok bool
+	err = podNS.Do(func(_ ns.NetNS) error {
+		ok, err = CheckRouteExists(&r)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("checkPodKubeCIDRRoutes: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("route of CIDR %q not found in pod", cidr)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"errors"
 	"fmt"
 	"net"
 
@@ -86,7 +87,7 @@ func CheckRouteExists(
 	for _, r := range rs {
 		// TODO: Change here if needed
 		// Simple logic to avoid conflict when add routes
-		if r.Dst.String() != route.Dst.String() {
+		if getRouteDst(&r) != getRouteDst(route) {
 			continue
 		}
 		if r.Family != route.Family {
@@ -149,31 +150,9 @@ func AddPodFlatNetworkCustomRoutes(podNS ns.NetNS, customRoutes []flv1.Route) er
 				continue
 			}
 
-			link, err := netlink.LinkByName(r.Dev)
+			route, err := newPodCustomRoute(&r)
 			if err != nil {
-				return fmt.Errorf("failed to get link %q in pod: %w",
-					r.Dev, err)
-			}
-			ip, network, err := net.ParseCIDR(r.Dst)
-			if err != nil {
-				return fmt.Errorf("failed to parse CIDR %q: %w", r.Dst, err)
-			}
-
-			route := &netlink.Route{
-				LinkIndex: link.Attrs().Index,
-				Src:       r.Src,
-				Gw:        nil,
-				Dst:       network,
-				Priority:  r.Priority,
-				Family:    nl.GetIPFamily(ip),
-			}
-			if r.Via != nil {
-				route.Gw = r.Via
-			}
-
-			switch network.IP.String() {
-			case "0.0.0.0", "::0":
-				route.Dst = nil
+				return err
 			}
 			logrus.Debugf("add custom route: %v", utils.Print(route))
 			if err := EnsureRouteExists(route); err != nil {
@@ -190,6 +169,75 @@ func AddPodFlatNetworkCustomRoutes(podNS ns.NetNS, customRoutes []flv1.Route) er
 	return nil
 }
 
+// CheckPodFlatNetworkCustomRoutes checks the user defined custom routes added
+// by AddPodFlatNetworkCustomRoutes exist in pod NS, the missing routes are
+// returned in the error.
+func CheckPodFlatNetworkCustomRoutes(podNS ns.NetNS, customRoutes []flv1.Route) error {
+	if podNS == nil || len(customRoutes) == 0 {
+		return nil
+	}
+	var errs []error
+	err := podNS.Do(func(_ ns.NetNS) error {
+		for _, r := range customRoutes {
+			if r.Dev == common.PodIfaceEth1 {
+				// eth1 route is managed by ipam
+				continue
+			}
+
+			route, err := newPodCustomRoute(&r)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ok, err := CheckRouteExists(route)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				errs = append(errs, fmt.Errorf("custom route [%v dev %v] not found in pod",
+					r.Dst, r.Dev))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("checkPodFlatNetworkCustomRoutes: %w", err)
+	}
+	return errors.Join(errs...)
+}
+
+// newPodCustomRoute converts the user defined custom route to the netlink
+// route, should be called in pod NS.
+// The destination of the default route is nil, shared by adding and checking
+// the custom routes.
+func newPodCustomRoute(r *flv1.Route) (*netlink.Route, error) {
+	link, err := netlink.LinkByName(r.Dev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link %q in pod: %w",
+			r.Dev, err)
+	}
+	ip, network, err := net.ParseCIDR(r.Dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CIDR %q: %w", r.Dst, err)
+	}
+
+	route := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Src:       r.Src,
+		Gw:        nil,
+		Dst:       network,
+		Priority:  r.Priority,
+		Family:    nl.GetIPFamily(ip),
+	}
+	if r.Via != nil {
+		route.Gw = r.Via
+	}
+	if isDefaultRoute(route) {
+		route.Dst = nil
+	}
+	return route, nil
+}
+
 func UpdatePodDefaultGateway(
 	podNS ns.NetNS, ifName string, flatNetworkIP net.IP, gateway net.IP,
 ) error {
@@ -296,6 +344,19 @@ func isDefaultRoute(r *netlink.Route) bool {
 	return false
 }
 
+// getRouteDst returns the route destination in CIDR format, the nil
+// destination of the default route is returned as '0.0.0.0/0' or '::/0'
+// by the route family, as the routes listed by netlink.
+func getRouteDst(r *netlink.Route) string {
+	if r.Dst != nil {
+		return r.Dst.String()
+	}
+	if r.Family == netlink.FAMILY_V6 {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
 func sameAddressFamily(r *netlink.Route, ip net.IP) bool {
 	switch r.Family {
 	case netlink.FAMILY_V4:
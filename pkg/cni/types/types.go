@@ -35,6 +35,9 @@ type NetConf struct {
 	RawPrevResult map[string]interface{} `json:"prevResult,omitempty"`
 	PrevResult    types.Result           `json:"-"`
 
+	// RuntimeConfig is supplied by the runtime for the enabled capabilities
+	RuntimeConfig RuntimeConfig `json:"runtimeConfig,omitempty"`
+
 	// ValidAttachments is only supplied when executing a GC operation
 	ValidAttachments []types.GCAttachment `json:"cni.dev/valid-attachments,omitempty"`
 
@@ -45,6 +48,21 @@ type NetConf struct {
 	FlatNetworkConfig FlatNetworkConfig `json:"flatNetwork,omitempty"`
 }
 
+// RuntimeConfig is the runtime config of the capabilities.
+type RuntimeConfig struct {
+	Bandwidth *BandwidthEntry `json:"bandwidth,omitempty"`
+}
+
+// BandwidthEntry is the 'bandwidth' capability, the rates are in bits per
+// second and the bursts are in bits, 0 for no limit.
+// See https://github.com/containernetworking/cni/blob/main/CONVENTIONS.md
+type BandwidthEntry struct {
+	IngressRate  uint64 `json:"ingressRate"`
+	IngressBurst uint64 `json:"ingressBurst"`
+	EgressRate   uint64 `json:"egressRate"`
+	EgressBurst  uint64 `json:"egressBurst"`
+}
+
 type FlatNetworkConfig struct {
 	MTU         int    `json:"mtu"`
 	ClusterCIDR string `json:"clusterCIDR"`
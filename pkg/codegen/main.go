@@ -32,6 +32,9 @@ func main() {
 				Types: []any{
 					flatnetworkv1.FlatNetworkIP{},
 					flatnetworkv1.FlatNetworkSubnet{},
+					flatnetworkv1.FlatNetworkIPReservation{},
+					flatnetworkv1.FlatNetworkSubnetPool{},
+					flatnetworkv1.FlatNetworkIPBlock{},
 				},
 				GenerateTypes:     true,
 				GenerateClients:   true,
@@ -99,7 +102,40 @@ func main() {
 		}
 		return c
 	})
-	crds = append(crds, ipConfig, subnetConfig)
+	reservationConfig := newCRD(&flatnetworkv1.FlatNetworkIPReservation{}, func(c crd.CRD) crd.CRD {
+		if c.Schema == nil {
+			c.Schema = &apiextensionsv1.JSONSchemaProps{}
+		}
+		c.ShortNames = []string{
+			"flatnetworkipreservation",
+			"flipr",
+			"fliprs",
+		}
+		return c
+	})
+	subnetPoolConfig := newCRD(&flatnetworkv1.FlatNetworkSubnetPool{}, func(c crd.CRD) crd.CRD {
+		if c.Schema == nil {
+			c.Schema = &apiextensionsv1.JSONSchemaProps{}
+		}
+		c.ShortNames = []string{
+			"flatnetworksubnetpool",
+			"flsubnetpool",
+			"flsubnetpools",
+		}
+		return c
+	})
+	ipBlockConfig := newCRD(&flatnetworkv1.FlatNetworkIPBlock{}, func(c crd.CRD) crd.CRD {
+		if c.Schema == nil {
+			c.Schema = &apiextensionsv1.JSONSchemaProps{}
+		}
+		c.ShortNames = []string{
+			"flatnetworkipblock",
+			"flipblock",
+			"flipblocks",
+		}
+		return c
+	})
+	crds = append(crds, ipConfig, subnetConfig, reservationConfig, subnetPoolConfig, ipBlockConfig)
 
 	var data []byte
 	for _, crd := range crds {
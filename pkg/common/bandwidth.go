@@ -0,0 +1,87 @@
+package common
+
+import (
+	"fmt"
+	"math"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// BandwidthLimit is the rate limit in bits per second and the burst in bits.
+type BandwidthLimit struct {
+	Rate  uint64
+	Burst uint64
+}
+
+// ParseBandwidthLimit parses the rate and burst in quantity format, returns
+// nil if the rate and burst are not specified.
+// The burst is the same as the rate if not specified.
+func ParseBandwidthLimit(rate, burst string) (*BandwidthLimit, error) {
+	if rate == "" && burst == "" {
+		return nil, nil
+	}
+	if rate == "" {
+		return nil, fmt.Errorf("burst [%v] specified without rate", burst)
+	}
+	l := &BandwidthLimit{}
+	var err error
+	if l.Rate, err = parseBandwidthQuantity(rate); err != nil {
+		return nil, fmt.Errorf("invalid rate [%v]: %w", rate, err)
+	}
+	l.Burst = l.Rate
+	if burst != "" {
+		if l.Burst, err = parseBandwidthQuantity(burst); err != nil {
+			return nil, fmt.Errorf("invalid burst [%v]: %w", burst, err)
+		}
+	}
+	if l.Burst/8 >= math.MaxUint32 {
+		return nil, fmt.Errorf("invalid burst [%v]: should be less than 4GB", burst)
+	}
+	return l, nil
+}
+
+func parseBandwidthQuantity(s string) (uint64, error) {
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := q.AsInt64()
+	if !ok || v <= 0 {
+		return 0, fmt.Errorf("should be a positive integer")
+	}
+	return uint64(v), nil
+}
+
+// GetBandwidthLimits returns the ingress and egress limits of the bandwidth,
+// nil is returned if the direction is not limited.
+func GetBandwidthLimits(bw *flv1.Bandwidth) (*BandwidthLimit, *BandwidthLimit, error) {
+	if bw == nil {
+		return nil, nil, nil
+	}
+	ingress, err := ParseBandwidthLimit(bw.IngressRate, bw.IngressBurst)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ingress: %w", err)
+	}
+	egress, err := ParseBandwidthLimit(bw.EgressRate, bw.EgressBurst)
+	if err != nil {
+		return nil, nil, fmt.Errorf("egress: %w", err)
+	}
+	return ingress, egress, nil
+}
+
+// GetPodAnnotationBandwidth returns the bandwidth specified in the pod
+// annotations, nil if not specified.
+func GetPodAnnotationBandwidth(annotations map[string]string) *flv1.Bandwidth {
+	bw := &flv1.Bandwidth{
+		IngressRate:  annotations[flv1.AnnotationIngressRate],
+		IngressBurst: annotations[flv1.AnnotationIngressBurst],
+		EgressRate:   annotations[flv1.AnnotationEgressRate],
+		EgressBurst:  annotations[flv1.AnnotationEgressBurst],
+	}
+	if *bw == (flv1.Bandwidth{}) {
+		return nil
+	}
+	return bw
+}
@@ -2,14 +2,24 @@ package common
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
 	"net"
+	"slices"
+	"strconv"
 	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
 	"github.com/cnrancher/rancher-flat-network/pkg/cni/ipvlan"
@@ -18,6 +28,23 @@ import (
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 )
 
+const (
+	// The MTU range of the pod flat-network interface.
+	minMTU = 68
+	maxMTU = 65535
+
+	// podDefaultInterface is the pod default network interface name
+	// which can not be used by flat-network.
+	podDefaultInterface = "eth0"
+
+	// maxInterfaceNameLength is the max length of the Linux interface name.
+	maxInterfaceNameLength = 15
+
+	// flatNetworkIPNameHashLength is the length of the hash suffix of the
+	// additional attachment FlatNetworkIP name.
+	flatNetworkIPNameHashLength = 8
+)
+
 const (
 	KindDeployment  = "Deployment"
 	KindDaemonSet   = "DaemonSet"
@@ -53,10 +80,143 @@ func ValidateSubnet(subnet *flv1.FlatNetworkSubnet) error {
 		return fmt.Errorf("invalid subnet routes %v: %w",
 			utils.Print(r), err)
 	}
+	if subnet.Spec.IPReuseDelay < 0 {
+		return fmt.Errorf("invalid subnet ipReuseDelay [%v]: should not be negative",
+			subnet.Spec.IPReuseDelay)
+	}
+	if t := subnet.Spec.NearlyExhaustedThreshold; t < 0 || t > 100 {
+		return fmt.Errorf("invalid subnet nearlyExhaustedThreshold [%v]: should be 1-100", t)
+	}
+	if _, err := ipcalc.GetStrategy(subnet.Spec.AllocationStrategy); err != nil {
+		return fmt.Errorf("invalid subnet allocationStrategy: %w", err)
+	}
+	if err := checkSubnetMACAllocation(&subnet.Spec); err != nil {
+		return err
+	}
+	if subnet.Spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(subnet.Spec.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid subnet namespaceSelector: %w", err)
+		}
+	}
+	for _, p := range subnet.Spec.Projects {
+		if p == "" {
+			return fmt.Errorf("invalid subnet projects %v: empty project ID", subnet.Spec.Projects)
+		}
+	}
+	if err := checkSubnetQuotas(subnet.Spec.Quotas); err != nil {
+		return fmt.Errorf("invalid subnet quotas: %w", err)
+	}
+	if err := checkSubnetRemediation(subnet.Spec.Remediation); err != nil {
+		return err
+	}
+	if err := checkSubnetMasterSelectors(subnet.Spec.MasterSelectors); err != nil {
+		return err
+	}
+	if _, _, err := ParseSubnetMTU(subnet); err != nil {
+		return err
+	}
+	if _, _, err := GetBandwidthLimits(subnet.Spec.Bandwidth); err != nil {
+		return fmt.Errorf("invalid subnet bandwidth: %w", err)
+	}
+	if subnet.Spec.Drain != nil {
+		if !subnet.Spec.Cordoned {
+			return fmt.Errorf("invalid subnet drain: subnet should be cordoned before drain")
+		}
+		if subnet.Spec.Drain.ReplacementSubnet == subnet.Name {
+			return fmt.Errorf("invalid subnet drain: replacementSubnet should not be the subnet itself")
+		}
+	}
 
 	return nil
 }
 
+func checkSubnetMACAllocation(spec *flv1.SubnetSpec) error {
+	switch spec.MACAllocation {
+	case "", flv1.MACAllocationRandom, flv1.MACAllocationIP:
+	default:
+		return fmt.Errorf("invalid subnet macAllocation [%v]: should be one of [%v, %v]",
+			spec.MACAllocation, flv1.MACAllocationRandom, flv1.MACAllocationIP)
+	}
+	if spec.MACPrefix == "" {
+		if spec.MACAllocation != "" {
+			return fmt.Errorf("invalid subnet macAllocation [%v]: macPrefix not specified",
+				spec.MACAllocation)
+		}
+		return nil
+	}
+	if spec.FlatMode != flv1.FlatModeMacvlan {
+		return fmt.Errorf("invalid subnet macPrefix: only supported in flatMode [%v]",
+			flv1.FlatModeMacvlan)
+	}
+	if _, err := ipcalc.ParseMACPrefix(spec.MACPrefix); err != nil {
+		return fmt.Errorf("invalid subnet macPrefix: %w", err)
+	}
+	return nil
+}
+
+// ParseSubnetMTU returns the MTU of the subnet and whether the MTU is
+// 'auto', the MTU is 0 if not specified or 'auto'.
+func ParseSubnetMTU(subnet *flv1.FlatNetworkSubnet) (int, bool, error) {
+	mtu := subnet.Spec.MTU
+	if mtu == nil {
+		return 0, false, nil
+	}
+	if mtu.Type == intstr.String {
+		if mtu.StrVal != flv1.MTUAuto {
+			return 0, false, fmt.Errorf("invalid subnet mtu [%v]: should be a number or [%v]",
+				mtu.StrVal, flv1.MTUAuto)
+		}
+		return 0, true, nil
+	}
+	if mtu.IntVal < minMTU || mtu.IntVal > maxMTU {
+		return 0, false, fmt.Errorf("invalid subnet mtu [%v]: should be %v-%v",
+			mtu.IntVal, minMTU, maxMTU)
+	}
+	return int(mtu.IntVal), false, nil
+}
+
+// CheckSubnetNamespace ensures the namespace is allowed to use the subnet
+// by the subnet namespaceSelector and projects.
+func CheckSubnetNamespace(subnet *flv1.FlatNetworkSubnet, ns *corev1.Namespace) error {
+	if subnet == nil || ns == nil {
+		return nil
+	}
+	if subnet.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(subnet.Spec.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid subnet [%v] namespaceSelector: %w", subnet.Name, err)
+		}
+		if !selector.Matches(labels.Set(ns.Labels)) {
+			return fmt.Errorf("namespace [%v] is not allowed to use subnet [%v]: namespaceSelector mismatch",
+				ns.Name, subnet.Name)
+		}
+	}
+	if len(subnet.Spec.Projects) != 0 {
+		projectID := ns.Labels[flv1.LabelProjectID]
+		allowed := false
+		for _, p := range subnet.Spec.Projects {
+			if projectID != "" && getProjectID(p) == projectID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("namespace [%v] in project [%v] is not allowed to use subnet [%v]",
+				ns.Name, projectID, subnet.Name)
+		}
+	}
+	return nil
+}
+
+// getProjectID returns the Rancher project ID without the cluster ID prefix,
+// the project ID can be 'p-xxxxx' or '<clusterID>:p-xxxxx'.
+func getProjectID(p string) string {
+	if _, id, ok := strings.Cut(p, ":"); ok {
+		return id
+	}
+	return p
+}
+
 func isValidRanges(ranges []flv1.IPRange, network *net.IPNet) (*flv1.IPRange, error) {
 	if len(ranges) == 0 {
 		return nil, nil
@@ -127,12 +287,23 @@ func CheckSubnetConflict(
 		if s.Name == subnet.Name {
 			continue
 		}
+		if !IsSubnetsSharingMaster(s, subnet) {
+			continue // skip using different master
+		}
+		if err := checkSubnetMTUConflict(subnet, s); err != nil {
+			return err
+		}
 		if s.Spec.FlatMode != subnet.Spec.FlatMode {
 			continue // skip using different flatMode
 		}
 		if s.Spec.VLAN != subnet.Spec.VLAN {
 			continue // skip using different VLAN
 		}
+		// The MAC addresses are only checked unique inside the subnet,
+		// subnets on the same VLAN should not use overlapping MAC prefixes.
+		if err := ipcalc.CheckMACPrefixConflict(s.Spec.MACPrefix, subnet.Spec.MACPrefix); err != nil {
+			return fmt.Errorf("MAC prefix conflict with subnet [%v]: %w", s.Name, err)
+		}
 		if err := ipcalc.CheckNetworkConflict(s.Spec.CIDR, subnet.Spec.CIDR); err != nil {
 			if len(s.Spec.Ranges) != 0 && len(subnet.Spec.Ranges) != 0 {
 				err := ipcalc.CheckIPRangesConflict(subnet.Spec.Ranges, s.Spec.Ranges)
@@ -155,6 +326,22 @@ func CheckSubnetConflict(
 	return nil
 }
 
+// checkSubnetMTUConflict ensures the subnets sharing the same host VLAN
+// interface do not specify different MTU.
+func checkSubnetMTUConflict(subnet, s *flv1.FlatNetworkSubnet) error {
+	if subnet.Spec.VLAN == 0 || s.Spec.VLAN != subnet.Spec.VLAN ||
+		!IsSubnetsSharingMaster(s, subnet) {
+		return nil
+	}
+	mtu1, _, _ := ParseSubnetMTU(subnet)
+	mtu2, _, _ := ParseSubnetMTU(s)
+	if mtu1 != 0 && mtu2 != 0 && mtu1 != mtu2 {
+		return fmt.Errorf("subnet mtu [%v] conflicts with subnet [%v] mtu [%v] on VLAN iface [%v.%v]",
+			mtu1, s.Name, mtu2, s.Spec.Master, s.Spec.VLAN)
+	}
+	return nil
+}
+
 func CheckSubnetFlatMode(
 	subnet *flv1.FlatNetworkSubnet, subnets []*flv1.FlatNetworkSubnet,
 ) error {
@@ -201,6 +388,9 @@ func CheckSubnetFlatMode(
 		if s.Spec.VLAN != subnet.Spec.VLAN {
 			continue
 		}
+		if !IsSubnetsSharingMaster(s, subnet) {
+			continue
+		}
 		master := s.Spec.Master
 		if s.Spec.VLAN != 0 {
 			master = fmt.Sprintf("%v.%v", s.Spec.Master, s.Spec.VLAN)
@@ -242,6 +432,125 @@ func CheckPodAnnotationIPs(s string) ([]net.IP, error) {
 	return ret, nil
 }
 
+// CheckPodAnnotationSubnets parses the subnet names of the pod annotation,
+// the dual-stack subnets are separated by comma and the first one is the
+// primary subnet.
+func CheckPodAnnotationSubnets(s string) ([]string, error) {
+	ret := []string{}
+	if s == "" {
+		return ret, nil
+	}
+	spec := strings.Split(strings.TrimSpace(s), ",")
+	if len(spec) > 2 {
+		return nil, fmt.Errorf("invalid annotation subnet [%v]: at most 2 subnets (IPv4 & IPv6) can be specified", s)
+	}
+	for _, v := range spec {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("invalid annotation subnet [%v]: empty subnet name", s)
+		}
+		if slices.Contains(ret, v) {
+			return nil, fmt.Errorf("invalid annotation subnet [%v]: subnet [%v] is duplicated", s, v)
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// GetPodAnnotationSubnet returns the primary subnet name of the pod annotation.
+func GetPodAnnotationSubnet(s string) string {
+	subnet, _, _ := strings.Cut(s, ",")
+	return strings.TrimSpace(subnet)
+}
+
+// CheckDualStackSubnets ensures the secondary subnet is in another IP family
+// and using the same master iface, VLAN and flatMode with the primary subnet,
+// since the addresses of both subnets are configured on the same iface.
+func CheckDualStackSubnets(primary, secondary *flv1.FlatNetworkSubnet) error {
+	if primary == nil || secondary == nil {
+		return nil
+	}
+	v4, err := IsIPv4Subnet(primary)
+	if err != nil {
+		return err
+	}
+	secondaryV4, err := IsIPv4Subnet(secondary)
+	if err != nil {
+		return err
+	}
+	if v4 == secondaryV4 {
+		return fmt.Errorf("dual-stack subnets [%v] and [%v] are in the same IP family",
+			primary.Name, secondary.Name)
+	}
+	if primary.Spec.Master != secondary.Spec.Master ||
+		!equality.Semantic.DeepEqual(primary.Spec.MasterSelectors, secondary.Spec.MasterSelectors) ||
+		primary.Spec.VLAN != secondary.Spec.VLAN ||
+		primary.Spec.FlatMode != secondary.Spec.FlatMode {
+		return fmt.Errorf("dual-stack subnets [%v] and [%v] should use the same master, vlan and flatMode",
+			primary.Name, secondary.Name)
+	}
+	return nil
+}
+
+// IsIPv4Subnet returns true if the subnet CIDR is IPv4.
+func IsIPv4Subnet(subnet *flv1.FlatNetworkSubnet) (bool, error) {
+	_, network, err := net.ParseCIDR(subnet.Spec.CIDR)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse subnet [%v] CIDR [%v]: %w",
+			subnet.Name, subnet.Spec.CIDR, err)
+	}
+	return network.IP.To4() != nil, nil
+}
+
+// GetSubnetFamilyIPs returns the IPs in the same IP family of the subnet.
+func GetSubnetFamilyIPs(ips []net.IP, subnet *flv1.FlatNetworkSubnet) []net.IP {
+	ret := []net.IP{}
+	v4, err := IsIPv4Subnet(subnet)
+	if err != nil {
+		return ret
+	}
+	for _, ip := range ips {
+		if len(ip) == 0 {
+			continue
+		}
+		if (ip.To4() != nil) == v4 {
+			ret = append(ret, ip)
+		}
+	}
+	return ret
+}
+
+// GetFlatNetworkIPAddrs returns the allocated addresses of the
+// flat-network IP, including the secondary address of dual-stack.
+func GetFlatNetworkIPAddrs(ip *flv1.FlatNetworkIP) []net.IP {
+	ret := []net.IP{}
+	if ip == nil {
+		return ret
+	}
+	if len(ip.Status.Addr) != 0 {
+		ret = append(ret, ip.Status.Addr)
+	}
+	if len(ip.Status.SecondaryAddr) != 0 {
+		ret = append(ret, ip.Status.SecondaryAddr)
+	}
+	return ret
+}
+
+// GetFlatNetworkIPAddrOfSubnet returns the allocated address of the
+// flat-network IP in the subnet.
+func GetFlatNetworkIPAddrOfSubnet(ip *flv1.FlatNetworkIP, subnet string) net.IP {
+	if ip == nil {
+		return nil
+	}
+	switch subnet {
+	case ip.Spec.Subnet:
+		return ip.Status.Addr
+	case ip.Spec.SecondarySubnet:
+		return ip.Status.SecondaryAddr
+	}
+	return nil
+}
+
 func CheckPodAnnotationMACs(s string) ([]string, error) {
 	ret := []string{}
 	if s == "" || s == flv1.AllocateModeAuto {
@@ -260,6 +569,190 @@ func CheckPodAnnotationMACs(s string) ([]string, error) {
 	return ret, nil
 }
 
+// GetPodInterfaces returns the flat-network interface attachments of the pod
+// annotations.
+// The first one is the default attachment defined by the ip, subnet (or
+// subnetPool) and mac annotations with empty interface name, followed by the additional
+// attachments defined in the interfaces annotation.
+func GetPodInterfaces(annotations map[string]string) ([]flv1.PodInterface, error) {
+	ret := []flv1.PodInterface{}
+	if annotations[flv1.AnnotationSubnet] == "" && annotations[flv1.AnnotationSubnetPool] == "" {
+		return ret, nil
+	}
+	defaultInterface := flv1.PodInterface{
+		Subnet:     annotations[flv1.AnnotationSubnet],
+		SubnetPool: annotations[flv1.AnnotationSubnetPool],
+		IP:         annotations[flv1.AnnotationIP],
+		MAC:        annotations[flv1.AnnotationMac],
+	}
+	if err := checkPodInterfaceSubnetPool(&defaultInterface); err != nil {
+		return nil, fmt.Errorf("invalid annotation [%v]: %w", flv1.AnnotationSubnetPool, err)
+	}
+	ret = append(ret, defaultInterface)
+	interfaces, err := CheckPodAnnotationInterfaces(annotations[flv1.AnnotationInterfaces])
+	if err != nil {
+		return nil, err
+	}
+	return append(ret, interfaces...), nil
+}
+
+// CheckPodAnnotationInterfaces parses the additional flat-network interface
+// attachments of the pod annotation.
+func CheckPodAnnotationInterfaces(s string) ([]flv1.PodInterface, error) {
+	ret := []flv1.PodInterface{}
+	if strings.TrimSpace(s) == "" {
+		return ret, nil
+	}
+	if err := json.Unmarshal([]byte(s), &ret); err != nil {
+		return nil, fmt.Errorf("invalid annotation interfaces [%v]: %w", s, err)
+	}
+	set := map[string]bool{}
+	for _, i := range ret {
+		switch {
+		case i.Interface == "":
+			return nil, fmt.Errorf("invalid annotation interfaces [%v]: empty interface name", s)
+		case len(i.Interface) > maxInterfaceNameLength,
+			strings.ContainsAny(i.Interface, "/: \t\n"):
+			return nil, fmt.Errorf("invalid annotation interfaces [%v]: invalid interface name [%v]",
+				s, i.Interface)
+		case i.Interface == podDefaultInterface:
+			return nil, fmt.Errorf("invalid annotation interfaces [%v]: interface [%v] is reserved",
+				s, i.Interface)
+		case set[i.Interface]:
+			return nil, fmt.Errorf("invalid annotation interfaces [%v]: interface [%v] is duplicated",
+				s, i.Interface)
+		}
+		set[i.Interface] = true
+
+		subnets, err := CheckPodAnnotationSubnets(i.Subnet)
+		if err != nil {
+			return nil, err
+		}
+		if len(subnets) == 0 && i.SubnetPool == "" {
+			return nil, fmt.Errorf("invalid annotation interfaces [%v]: subnet of interface [%v] not specified",
+				s, i.Interface)
+		}
+		if err := checkPodInterfaceSubnetPool(&i); err != nil {
+			return nil, fmt.Errorf("invalid annotation interfaces [%v]: interface [%v]: %w",
+				s, i.Interface, err)
+		}
+		if _, err := CheckPodAnnotationIPs(i.IP); err != nil {
+			return nil, err
+		}
+		if _, err := CheckPodAnnotationMACs(i.MAC); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// GetFlatNetworkIPName returns the FlatNetworkIP resource name of the pod
+// interface attachment.
+// The FlatNetworkIP of the default attachment is named after the pod,
+// and the additional attachment is named by '<pod>-<interface>-<hash>',
+// the hash of the pod & interface name avoids the conflict with the
+// FlatNetworkIP of another pod, the '<pod>-<interface>' prefix is
+// truncated to keep the name within the object name length limit.
+func GetFlatNetworkIPName(podName, iface string) string {
+	if iface == "" {
+		return podName
+	}
+	sum := sha256.Sum256([]byte(podName + "/" + iface))
+	hash := hex.EncodeToString(sum[:])[:flatNetworkIPNameHashLength]
+	prefix := fmt.Sprintf("%v-%v", podName, iface)
+	if len(prefix) > validation.DNS1123SubdomainMaxLength-len(hash)-1 {
+		prefix = prefix[:validation.DNS1123SubdomainMaxLength-len(hash)-1]
+	}
+	return fmt.Sprintf("%v-%v", strings.TrimRight(prefix, "-."), hash)
+}
+
+// GetLegacyFlatNetworkIPName returns the '<pod>-<interface>' name of the
+// additional attachment FlatNetworkIP created by the previous versions.
+func GetLegacyFlatNetworkIPName(podName, iface string) string {
+	if iface == "" {
+		return podName
+	}
+	return fmt.Sprintf("%v-%v", podName, iface)
+}
+
+// GetFlatNetworkIPPodName returns the pod name of the FlatNetworkIP.
+// The pod name is read from the pod annotation of the FlatNetworkIP,
+// the FlatNetworkIP not annotated is created by the previous versions
+// and named by '<pod>-<interface>'.
+func GetFlatNetworkIPPodName(ip *flv1.FlatNetworkIP) string {
+	if name := ip.Annotations[flv1.AnnotationPod]; name != "" {
+		return name
+	}
+	if ip.Spec.Interface == "" {
+		return ip.Name
+	}
+	return strings.TrimSuffix(ip.Name, "-"+ip.Spec.Interface)
+}
+
+// GetStatefulSetPodOrdinal returns the ordinal index of the StatefulSet pod.
+func GetStatefulSetPodOrdinal(pod *corev1.Pod) (string, int, bool) {
+	var owner string
+	for _, o := range pod.OwnerReferences {
+		if o.Kind == KindStatefulSet {
+			owner = o.Name
+			break
+		}
+	}
+	if owner == "" {
+		return "", 0, false
+	}
+	s, ok := pod.Labels[appsv1.PodIndexLabel]
+	if !ok {
+		s = strings.TrimPrefix(pod.Name, owner+"-")
+	}
+	ordinal, err := strconv.Atoi(s)
+	if err != nil || ordinal < 0 {
+		return "", 0, false
+	}
+	return owner, ordinal, true
+}
+
+// GetOrdinalIPs returns the N-th IP address of each IP family in the list,
+// used for pinning the IP address to the StatefulSet pod ordinal.
+func GetOrdinalIPs(ips []net.IP, ordinal int) ([]net.IP, error) {
+	ret := []net.IP{}
+	if len(ips) == 0 {
+		return ret, nil
+	}
+	v4, v6 := []net.IP{}, []net.IP{}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	for _, l := range [][]net.IP{v4, v6} {
+		if len(l) == 0 {
+			continue
+		}
+		if ordinal >= len(l) {
+			return nil, fmt.Errorf("ordinal [%v] out of range: only %v IP addresses %v specified",
+				ordinal, len(l), l)
+		}
+		ret = append(ret, l[ordinal])
+	}
+	return ret, nil
+}
+
+// GetOrdinalMACs returns the N-th MAC address in the list, used for pinning
+// the MAC address to the StatefulSet pod ordinal.
+func GetOrdinalMACs(macs []string, ordinal int) ([]string, error) {
+	if len(macs) == 0 {
+		return []string{}, nil
+	}
+	if ordinal >= len(macs) {
+		return nil, fmt.Errorf("ordinal [%v] out of range: only %v MAC addresses %v specified",
+			ordinal, len(macs), macs)
+	}
+	return []string{macs[ordinal]}, nil
+}
+
 func GetWorkloadKind(w metav1.Object) string {
 	switch w.(type) {
 	case *appsv1.Deployment:
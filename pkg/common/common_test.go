@@ -2,13 +2,17 @@ package common
 
 import (
 	"net"
+	"strings"
 	"testing"
+	"time"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
 	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func Test_checkSubnetFlatMode(t *testing.T) {
@@ -122,6 +126,199 @@ func Test_CheckPodAnnotationMACs(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func Test_CheckPodAnnotationSubnets(t *testing.T) {
+	subnets, err := CheckPodAnnotationSubnets("")
+	assert.Empty(t, subnets)
+	assert.Nil(t, err)
+
+	subnets, err = CheckPodAnnotationSubnets("subnet-v4")
+	assert.Equal(t, []string{"subnet-v4"}, subnets)
+	assert.Nil(t, err)
+
+	subnets, err = CheckPodAnnotationSubnets("subnet-v4, subnet-v6")
+	assert.Equal(t, []string{"subnet-v4", "subnet-v6"}, subnets)
+	assert.Nil(t, err)
+	assert.Equal(t, "subnet-v4", GetPodAnnotationSubnet("subnet-v4, subnet-v6"))
+
+	subnets, err = CheckPodAnnotationSubnets("subnet-v4,subnet-v4")
+	assert.Empty(t, subnets)
+	assert.NotNil(t, err)
+
+	subnets, err = CheckPodAnnotationSubnets("subnet-v4,")
+	assert.Empty(t, subnets)
+	assert.NotNil(t, err)
+
+	subnets, err = CheckPodAnnotationSubnets("a,b,c")
+	assert.Empty(t, subnets)
+	assert.NotNil(t, err)
+}
+
+func Test_CheckDualStackSubnets(t *testing.T) {
+	v4 := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet-v4",
+		},
+		Spec: flv1.SubnetSpec{
+			FlatMode: "macvlan",
+			Master:   "eth0",
+			VLAN:     10,
+			CIDR:     "10.128.0.0/16",
+		},
+	}
+	v6 := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet-v6",
+		},
+		Spec: flv1.SubnetSpec{
+			FlatMode: "macvlan",
+			Master:   "eth0",
+			VLAN:     10,
+			CIDR:     "fd00::/64",
+		},
+	}
+	assert.Nil(t, CheckDualStackSubnets(v4, v6))
+	assert.Nil(t, CheckDualStackSubnets(v6, v4))
+	assert.NotNil(t, CheckDualStackSubnets(v4, v4))
+
+	v6.Spec.VLAN = 20
+	assert.NotNil(t, CheckDualStackSubnets(v4, v6))
+
+	ips := []net.IP{
+		net.ParseIP("10.128.0.2"),
+		net.ParseIP("fd00::2"),
+		net.ParseIP("10.128.0.3"),
+	}
+	assert.Equal(t, []net.IP{ips[0], ips[2]}, GetSubnetFamilyIPs(ips, v4))
+	assert.Equal(t, []net.IP{ips[1]}, GetSubnetFamilyIPs(ips, v6))
+}
+
+func Test_GetPodInterfaces(t *testing.T) {
+	interfaces, err := GetPodInterfaces(map[string]string{})
+	assert.Empty(t, interfaces)
+	assert.Nil(t, err)
+
+	interfaces, err = GetPodInterfaces(map[string]string{
+		flv1.AnnotationIP:     "auto",
+		flv1.AnnotationSubnet: "subnet-mgmt",
+		flv1.AnnotationInterfaces: `[
+			{"interface":"eth2","subnet":"subnet-data","ip":"10.128.0.10"},
+			{"interface":"eth3","subnet":"subnet-v4,subnet-v6"}
+		]`,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []flv1.PodInterface{
+		{Subnet: "subnet-mgmt", IP: "auto"},
+		{Interface: "eth2", Subnet: "subnet-data", IP: "10.128.0.10"},
+		{Interface: "eth3", Subnet: "subnet-v4,subnet-v6"},
+	}, interfaces)
+
+	for _, s := range []string{
+		`{"interface":"eth2","subnet":"subnet-data"}`,
+		`[{"interface":"","subnet":"subnet-data"}]`,
+		`[{"interface":"eth0","subnet":"subnet-data"}]`,
+		`[{"interface":"eth2"}]`,
+		`[{"interface":"eth2","subnet":"a"},{"interface":"eth2","subnet":"b"}]`,
+		`[{"interface":"eth2","subnet":"a","ip":"10.0.0.a"}]`,
+		`[{"interface":"eth2","subnet":"a","mac":"aa:bb"}]`,
+		`[{"interface":"a-very-long-interface","subnet":"a"}]`,
+	} {
+		interfaces, err = CheckPodAnnotationInterfaces(s)
+		assert.Empty(t, interfaces)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func Test_GetFlatNetworkIPName(t *testing.T) {
+	assert.Equal(t, "pod", GetFlatNetworkIPName("pod", ""))
+	name := GetFlatNetworkIPName("pod", "eth2")
+	assert.Regexp(t, "^pod-eth2-[0-9a-f]{8}$", name)
+	assert.Equal(t, name, GetFlatNetworkIPName("pod", "eth2"))
+	// The additional attachment does not conflict with the pod named by
+	// '<pod>-<interface>' or the pod & interface names containing '-'.
+	assert.NotEqual(t, GetFlatNetworkIPName("pod-eth2", ""), name)
+	assert.NotEqual(t, GetFlatNetworkIPName("a-b", "c"), GetFlatNetworkIPName("a", "b-c"))
+	assert.Equal(t, "pod-eth2", GetLegacyFlatNetworkIPName("pod", "eth2"))
+
+	long := strings.Repeat("a", 250) + ".b"
+	name = GetFlatNetworkIPName(long, "eth2")
+	assert.Len(t, name, 253)
+	name = GetFlatNetworkIPName(strings.Repeat("a", 243)+".b", "eth2")
+	assert.Regexp(t, "^a{243}-[0-9a-f]{8}$", name)
+
+	ip := &flv1.FlatNetworkIP{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pod-eth2",
+		},
+	}
+	assert.Equal(t, "pod-eth2", GetFlatNetworkIPPodName(ip))
+	ip.Spec.Interface = "eth2"
+	assert.Equal(t, "pod", GetFlatNetworkIPPodName(ip))
+	ip.Name = GetFlatNetworkIPName("web-eth2", "eth2")
+	ip.Annotations = map[string]string{
+		flv1.AnnotationPod: "web-eth2",
+	}
+	assert.Equal(t, "web-eth2", GetFlatNetworkIPPodName(ip))
+}
+
+func Test_GetStatefulSetPodOrdinal(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "db-2",
+		},
+	}
+	_, _, ok := GetStatefulSetPodOrdinal(pod)
+	assert.False(t, ok)
+
+	pod.OwnerReferences = []metav1.OwnerReference{
+		{
+			Kind: "StatefulSet",
+			Name: "db",
+		},
+	}
+	owner, ordinal, ok := GetStatefulSetPodOrdinal(pod)
+	assert.True(t, ok)
+	assert.Equal(t, "db", owner)
+	assert.Equal(t, 2, ordinal)
+
+	pod.Labels = map[string]string{
+		"apps.kubernetes.io/pod-index": "3",
+	}
+	_, ordinal, ok = GetStatefulSetPodOrdinal(pod)
+	assert.True(t, ok)
+	assert.Equal(t, 3, ordinal)
+}
+
+func Test_GetOrdinalIPs(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("10.128.0.10"),
+		net.ParseIP("fd00::10"),
+		net.ParseIP("10.128.0.11"),
+		net.ParseIP("fd00::11"),
+	}
+	r, err := GetOrdinalIPs(ips, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []net.IP{ips[0], ips[1]}, r)
+
+	r, err = GetOrdinalIPs(ips, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []net.IP{ips[2], ips[3]}, r)
+
+	_, err = GetOrdinalIPs(ips, 2)
+	assert.NotNil(t, err)
+
+	r, err = GetOrdinalIPs(nil, 2)
+	assert.Nil(t, err)
+	assert.Empty(t, r)
+
+	macs := []string{"aa:bb:cc:dd:ef:01", "aa:bb:cc:dd:ef:02"}
+	m, err := GetOrdinalMACs(macs, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"aa:bb:cc:dd:ef:02"}, m)
+
+	_, err = GetOrdinalMACs(macs, 2)
+	assert.NotNil(t, err)
+}
+
 func Test_CheckSubnetConflict(t *testing.T) {
 	assert := assert.New(t)
 	var err error
@@ -249,4 +446,815 @@ func Test_CheckSubnetConflict(t *testing.T) {
 	// should return CIDR conflict.
 	assert.ErrorIs(err, ipcalc.ErrNetworkConflict)
 	t.Log(err)
+
+	// Subnets on the same VLAN with overlapping MAC prefixes.
+	s1.Spec.CIDR = "192.168.2.0/24"
+	s1.Spec.MACPrefix = "02:42:ac"
+	subnets[1].Spec.MACPrefix = "02:42"
+	err = CheckSubnetConflict(s1, subnets)
+	assert.ErrorIs(err, ipcalc.ErrMACPrefixConflict)
+	t.Log(err)
+
+	subnets[1].Spec.MACPrefix = "02:42:ad"
+	err = CheckSubnetConflict(s1, subnets)
+	assert.Nil(err)
+}
+
+func Test_CheckSubnetNamespace(t *testing.T) {
+	assert := assert.New(t)
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subnet1",
+			Namespace: flv1.SubnetNamespace,
+		},
+	}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "ns1",
+			Labels: map[string]string{
+				"env":               "prod",
+				flv1.LabelProjectID: "p-abcde",
+			},
+		},
+	}
+	// No restriction.
+	assert.Nil(CheckSubnetNamespace(subnet, ns))
+
+	subnet.Spec.NamespaceSelector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"env": "prod"},
+	}
+	assert.Nil(CheckSubnetNamespace(subnet, ns))
+	subnet.Spec.NamespaceSelector.MatchLabels["env"] = "dev"
+	assert.NotNil(CheckSubnetNamespace(subnet, ns))
+	subnet.Spec.NamespaceSelector = nil
+
+	subnet.Spec.Projects = []string{"p-xxxxx", "c-12345:p-abcde"}
+	assert.Nil(CheckSubnetNamespace(subnet, ns))
+	subnet.Spec.Projects = []string{"p-abcde"}
+	assert.Nil(CheckSubnetNamespace(subnet, ns))
+	subnet.Spec.Projects = []string{"p-xxxxx"}
+	assert.NotNil(CheckSubnetNamespace(subnet, ns))
+
+	// Both the namespaceSelector and projects should match.
+	subnet.Spec.Projects = []string{"p-abcde"}
+	subnet.Spec.NamespaceSelector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"env": "dev"},
+	}
+	assert.NotNil(CheckSubnetNamespace(subnet, ns))
+
+	// Namespace not in project.
+	subnet.Spec.NamespaceSelector = nil
+	delete(ns.Labels, flv1.LabelProjectID)
+	assert.NotNil(CheckSubnetNamespace(subnet, ns))
+}
+
+func Test_CheckSubnetQuota(t *testing.T) {
+	assert := assert.New(t)
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subnet1",
+			Namespace: flv1.SubnetNamespace,
+		},
+		Spec: flv1.SubnetSpec{
+			Quotas: []flv1.SubnetQuota{
+				{Namespace: "ns1", Limit: 2},
+				{Project: "c-12345:p-abcde", Limit: 3},
+			},
+		},
+	}
+	assert.Nil(checkSubnetQuotas(subnet.Spec.Quotas))
+	assert.NotNil(checkSubnetQuotas([]flv1.SubnetQuota{{Namespace: "ns1", Project: "p-abcde"}}))
+	assert.NotNil(checkSubnetQuotas([]flv1.SubnetQuota{{Limit: 1}}))
+	assert.NotNil(checkSubnetQuotas([]flv1.SubnetQuota{{Namespace: "ns1", Limit: -1}}))
+	assert.NotNil(checkSubnetQuotas([]flv1.SubnetQuota{
+		{Project: "p-abcde", Limit: 1}, {Project: "c-12345:p-abcde", Limit: 2}}))
+
+	newIP := func(namespace, name string, addr string) *flv1.FlatNetworkIP {
+		return &flv1.FlatNetworkIP{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: flv1.IPSpec{
+				Subnet: "subnet1",
+			},
+			Status: flv1.IPStatus{
+				Addr: net.ParseIP(addr),
+			},
+		}
+	}
+	ips := []*flv1.FlatNetworkIP{
+		newIP("ns1", "pod1", "192.168.1.2"),
+		newIP("ns2", "pod2", "192.168.1.3"),
+		newIP("ns3", "pod3", ""), // not allocated
+	}
+	nsProjects := map[string]string{
+		"ns1": "p-abcde",
+		"ns2": "p-abcde",
+		"ns3": "p-abcde",
+	}
+	usage := GetSubnetQuotaUsage(subnet, ips, nsProjects)
+	assert.Equal([]flv1.SubnetQuotaUsage{
+		{Namespace: "ns1", Limit: 2, Used: 1},
+		{Project: "c-12345:p-abcde", Limit: 3, Used: 2},
+	}, usage)
+
+	assert.Nil(CheckSubnetQuota(subnet, usage, "ns1", "p-abcde", 1))
+	// Exceeds the project quota.
+	assert.ErrorIs(CheckSubnetQuota(subnet, usage, "ns3", "p-abcde", 2), ErrSubnetQuotaExceeded)
+	// Exceeds the namespace quota.
+	assert.ErrorIs(CheckSubnetQuota(subnet, usage, "ns1", "", 2), ErrSubnetQuotaExceeded)
+	// No quota for the namespace.
+	assert.Nil(CheckSubnetQuota(subnet, usage, "ns4", "p-xxxxx", 10))
+}
+
+func Test_ValidateIPReservation(t *testing.T) {
+	assert := assert.New(t)
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subnet1",
+			Namespace: flv1.SubnetNamespace,
+		},
+		Spec: flv1.SubnetSpec{
+			CIDR: "192.168.1.0/24",
+		},
+	}
+	r := &flv1.FlatNetworkIPReservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "r1",
+			Namespace: flv1.SubnetNamespace,
+		},
+		Spec: flv1.IPReservationSpec{
+			Subnet: "subnet1",
+		},
+	}
+	assert.NotNil(ValidateIPReservation(r, subnet)) // no addresses
+	r.Spec.Addrs = []net.IP{net.ParseIP("192.168.1.5"), net.ParseIP("192.168.1.6")}
+	r.Spec.Ranges = []flv1.IPRange{
+		{From: net.ParseIP("192.168.1.10"), To: net.ParseIP("192.168.1.20")},
+	}
+	assert.Nil(ValidateIPReservation(r, subnet))
+	assert.Equal([]flv1.IPRange{
+		{From: net.ParseIP("192.168.1.5"), To: net.ParseIP("192.168.1.6")},
+		{From: net.ParseIP("192.168.1.10"), To: net.ParseIP("192.168.1.20")},
+	}, GetIPReservationRanges(r))
+
+	r.Spec.Addrs = []net.IP{net.ParseIP("192.168.2.5")} // not in subnet
+	assert.NotNil(ValidateIPReservation(r, subnet))
+	r.Spec.Addrs = nil
+	r.Spec.Ranges[0].To = net.ParseIP("192.168.2.20") // not in subnet
+	assert.NotNil(ValidateIPReservation(r, subnet))
+
+	key := GetIPReservationKey(r)
+	assert.Equal("FlatNetworkIPReservation/cattle-flat-network/r1", key)
+	assert.True(IsIPReservationKey(key))
+	assert.False(IsIPReservationKey("Deployment/default/test"))
+
+	now := time.Now()
+	assert.False(IsIPReservationExpired(r, now))
+	r.Spec.ExpireTime = &metav1.Time{Time: now.Add(time.Hour)}
+	assert.False(IsIPReservationExpired(r, now))
+	r.Spec.ExpireTime = &metav1.Time{Time: now.Add(-time.Hour)}
+	assert.True(IsIPReservationExpired(r, now))
+
+	subnet.Status.ReservedIP = map[string][]flv1.IPRange{
+		"Deployment/default/test": {
+			{From: net.ParseIP("192.168.1.100"), To: net.ParseIP("192.168.1.100")},
+		},
+		key: {
+			{From: net.ParseIP("192.168.1.10"), To: net.ParseIP("192.168.1.20")},
+		},
+	}
+	assert.Equal([]flv1.IPRange{
+		{From: net.ParseIP("192.168.1.10"), To: net.ParseIP("192.168.1.20")},
+	}, GetSubnetReservationRanges(subnet))
+}
+
+func Test_SetSubnetIPReservations(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{}
+	SetSubnetIPReservations(subnet, nil)
+	assert.Nil(t, subnet.Status.ReservedIP)
+
+	workloadRange := []flv1.IPRange{
+		{From: net.ParseIP("10.1.2.3"), To: net.ParseIP("10.1.2.3")},
+	}
+	reservationRange := []flv1.IPRange{
+		{From: net.ParseIP("10.1.2.10"), To: net.ParseIP("10.1.2.20")},
+	}
+	subnet.Status.ReservedIP = map[string][]flv1.IPRange{
+		"Deployment/default/test":                  workloadRange,
+		"FlatNetworkIPReservation/default/removed": reservationRange,
+	}
+	SetSubnetIPReservations(subnet, map[string][]flv1.IPRange{
+		"FlatNetworkIPReservation/default/r1": reservationRange,
+	})
+	assert.Equal(t, map[string][]flv1.IPRange{
+		"Deployment/default/test":             workloadRange,
+		"FlatNetworkIPReservation/default/r1": reservationRange,
+	}, subnet.Status.ReservedIP)
+}
+
+func Test_CheckSubnetResize(t *testing.T) {
+	assert := assert.New(t)
+	old := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
+		},
+		Spec: flv1.SubnetSpec{
+			CIDR: "192.168.1.0/24",
+		},
+	}
+	newIP := func(name string, addr string) *flv1.FlatNetworkIP {
+		return &flv1.FlatNetworkIP{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: flv1.IPSpec{
+				Subnet: "subnet1",
+			},
+			Status: flv1.IPStatus{
+				Addr: net.ParseIP(addr),
+			},
+		}
+	}
+	ips := []*flv1.FlatNetworkIP{
+		newIP("ip1", "192.168.1.10"),
+		newIP("ip2", "192.168.1.200"),
+	}
+	assert.Nil(CheckSubnetResize(old, old, ips))
+	assert.Nil(GetSubnetOutOfRangeIPs(old, ips))
+
+	// Grow CIDR
+	subnet := old.DeepCopy()
+	subnet.Spec.CIDR = "192.168.0.0/23"
+	assert.Nil(CheckSubnetResize(old, subnet, ips))
+
+	// Shrink CIDR strands ip2
+	subnet.Spec.CIDR = "192.168.1.0/25"
+	err := CheckSubnetResize(old, subnet, ips)
+	assert.ErrorContains(err, "default/ip2(192.168.1.200)")
+	assert.NotContains(err.Error(), "ip1")
+	assert.Equal([]flv1.OutOfRangeIP{
+		{Addr: net.ParseIP("192.168.1.200"), FlatNetworkIP: "default/ip2"},
+	}, GetSubnetOutOfRangeIPs(subnet, ips))
+
+	// Shrink ranges strands ip1
+	subnet.Spec.CIDR = old.Spec.CIDR
+	subnet.Spec.Ranges = []flv1.IPRange{
+		{From: net.ParseIP("192.168.1.100"), To: net.ParseIP("192.168.1.254")},
+	}
+	assert.ErrorContains(CheckSubnetResize(old, subnet, ips), "default/ip1(192.168.1.10)")
+
+	// The IPs already out of range are ignored
+	old.Spec.Ranges = subnet.Spec.Ranges
+	subnet.Spec.Ranges = []flv1.IPRange{
+		{From: net.ParseIP("192.168.1.150"), To: net.ParseIP("192.168.1.254")},
+	}
+	assert.Nil(CheckSubnetResize(old, subnet, ips))
+
+	// Shrink CIDR strands the gateway
+	old = old.DeepCopy()
+	old.Spec.Ranges = nil
+	old.Spec.Gateway = net.ParseIP("192.168.1.254")
+	subnet = old.DeepCopy()
+	subnet.Spec.CIDR = "192.168.1.0/25"
+	ips = ips[:1]
+	assert.ErrorContains(CheckSubnetResize(old, subnet, ips), "gateway(192.168.1.254)")
+	subnet.Spec.Gateway = nil
+	subnet.Status.Gateway = net.ParseIP("192.168.1.254")
+	assert.ErrorContains(CheckSubnetResize(old, subnet, ips), "gateway(192.168.1.254)")
+	subnet.Status.Gateway = net.ParseIP("192.168.1.1")
+	assert.Nil(CheckSubnetResize(old, subnet, ips))
+
+	// Shrink ranges strands the reserved IPs inside the old ranges only
+	subnet.Spec.CIDR = old.Spec.CIDR
+	subnet.Spec.Ranges = []flv1.IPRange{
+		{From: net.ParseIP("192.168.1.1"), To: net.ParseIP("192.168.1.100")},
+	}
+	subnet.Status.ReservedIP = map[string][]flv1.IPRange{
+		"Deployment/default/test": {
+			{From: net.ParseIP("192.168.1.90"), To: net.ParseIP("192.168.1.110")},
+		},
+		"FlatNetworkIPReservation/default/r1": {
+			{From: net.ParseIP("192.168.1.20"), To: net.ParseIP("192.168.1.30")},
+		},
+	}
+	err = CheckSubnetResize(old, subnet, ips)
+	assert.ErrorContains(err, "Deployment/default/test('192.168.1.101'-'192.168.1.110')")
+	assert.NotContains(err.Error(), "r1")
+	old.Spec.Ranges = subnet.Spec.Ranges
+	subnet.Spec.Ranges = []flv1.IPRange{
+		{From: net.ParseIP("192.168.1.1"), To: net.ParseIP("192.168.1.25")},
+	}
+	err = CheckSubnetResize(old, subnet, ips)
+	assert.ErrorContains(err, "Deployment/default/test('192.168.1.90'-'192.168.1.100')")
+	assert.ErrorContains(err, "FlatNetworkIPReservation/default/r1('192.168.1.26'-'192.168.1.30')")
+}
+
+func Test_CheckSubnetCordoned(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
+		},
+	}
+	assert.Nil(t, CheckSubnetCordoned(nil))
+	assert.Nil(t, CheckSubnetCordoned(subnet))
+	subnet.Spec.Cordoned = true
+	assert.ErrorIs(t, CheckSubnetCordoned(subnet), ErrSubnetCordoned)
+	subnet.Spec.Drain = &flv1.SubnetDrain{ReplacementSubnet: "subnet2"}
+	err := CheckSubnetCordoned(subnet)
+	assert.ErrorIs(t, err, ErrSubnetCordoned)
+	assert.ErrorContains(t, err, "subnet2")
+}
+
+func Test_ReplacePodAnnotationSubnet(t *testing.T) {
+	annotations := map[string]string{
+		flv1.AnnotationIP:     "auto",
+		flv1.AnnotationSubnet: "subnet1,subnet-v6",
+		flv1.AnnotationInterfaces: `[{"interface":"eth2","subnet":"subnet2","ip":"auto"},` +
+			`{"interface":"eth3","subnet":"subnet1","ip":"auto"}]`,
+	}
+	changed, err := ReplacePodAnnotationSubnet(annotations, "subnet1", "subnet3")
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "subnet3,subnet-v6", annotations[flv1.AnnotationSubnet])
+	interfaces, err := CheckPodAnnotationInterfaces(annotations[flv1.AnnotationInterfaces])
+	assert.Nil(t, err)
+	assert.Equal(t, "subnet2", interfaces[0].Subnet)
+	assert.Equal(t, "subnet3", interfaces[1].Subnet)
+
+	// Already replaced
+	changed, err = ReplacePodAnnotationSubnet(annotations, "subnet1", "subnet3")
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	// Specific IP
+	annotations = map[string]string{
+		flv1.AnnotationIP:     "192.168.1.10",
+		flv1.AnnotationSubnet: "subnet1",
+	}
+	_, err = ReplacePodAnnotationSubnet(annotations, "subnet1", "subnet3")
+	assert.NotNil(t, err)
+	assert.Equal(t, "subnet1", annotations[flv1.AnnotationSubnet])
+}
+
+func Test_CheckSubnetDelete(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
+		},
+	}
+	ips := []*flv1.FlatNetworkIP{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ip1", Namespace: "default"},
+			Spec:       flv1.IPSpec{Subnet: "subnet1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ip2", Namespace: "default"},
+			Spec:       flv1.IPSpec{Subnet: "subnet2", SecondarySubnet: "subnet1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ip3", Namespace: "default"},
+			Spec:       flv1.IPSpec{Subnet: "subnet2"},
+		},
+	}
+	assert.Nil(t, CheckSubnetDelete(subnet, nil))
+
+	subnet.Status.ReservedIP = map[string][]flv1.IPRange{
+		"FlatNetworkIPReservation/default/r1": {},
+		"Deployment/default/test":             {},
+	}
+	assert.Equal(t, []string{
+		"Deployment/default/test",
+		"FlatNetworkIP/default/ip1",
+		"FlatNetworkIP/default/ip2",
+	}, GetSubnetReferences(subnet, ips))
+	err := CheckSubnetDelete(subnet, ips)
+	assert.ErrorContains(t, err, "FlatNetworkIP/default/ip1")
+	assert.ErrorContains(t, err, flv1.AnnotationForceDelete)
+
+	subnet.Annotations = map[string]string{
+		flv1.AnnotationForceDelete: "true",
+	}
+	assert.True(t, IsSubnetForceDelete(subnet))
+	assert.Nil(t, CheckSubnetDelete(subnet, ips))
+}
+
+func Test_GetSubnetPoolSubnets(t *testing.T) {
+	pool := &flv1.FlatNetworkSubnetPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pool1",
+		},
+	}
+	_, err := GetSubnetPoolSubnets(pool, nil)
+	assert.NotNil(t, err)
+
+	pool.Spec.SubnetSelector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"pool": "vlan10"},
+	}
+	assert.Nil(t, ValidateSubnetPool(pool))
+	newSubnet := func(name, pool string, priority int) *flv1.FlatNetworkSubnet {
+		return &flv1.FlatNetworkSubnet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"pool": pool},
+			},
+			Spec: flv1.SubnetSpec{Priority: priority},
+		}
+	}
+	deleting := newSubnet("subnet5", "vlan10", 100)
+	deleting.DeletionTimestamp = &metav1.Time{}
+	subnets, err := GetSubnetPoolSubnets(pool, []*flv1.FlatNetworkSubnet{
+		newSubnet("subnet3", "vlan10", 0),
+		newSubnet("subnet1", "vlan10", 0),
+		newSubnet("subnet2", "vlan10", 10),
+		newSubnet("subnet4", "vlan20", 10),
+		deleting,
+	})
+	assert.Nil(t, err)
+	names := []string{}
+	for _, s := range subnets {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"subnet2", "subnet1", "subnet3"}, names)
+
+	pool.Spec.SubnetSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "pool", Operator: "invalid"},
+		},
+	}
+	assert.NotNil(t, ValidateSubnetPool(pool))
+}
+
+func Test_GetPodInterfacesSubnetPool(t *testing.T) {
+	interfaces, err := GetPodInterfaces(map[string]string{
+		flv1.AnnotationIP:         "auto",
+		flv1.AnnotationSubnetPool: "pool1",
+		flv1.AnnotationInterfaces: `[{"interface":"eth2","subnetPool":"pool2"}]`,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []flv1.PodInterface{
+		{SubnetPool: "pool1", IP: "auto"},
+		{Interface: "eth2", SubnetPool: "pool2"},
+	}, interfaces)
+
+	_, err = GetPodInterfaces(map[string]string{
+		flv1.AnnotationIP:         "auto",
+		flv1.AnnotationSubnet:     "subnet1",
+		flv1.AnnotationSubnetPool: "pool1",
+	})
+	assert.NotNil(t, err)
+	_, err = GetPodInterfaces(map[string]string{
+		flv1.AnnotationIP:         "10.0.0.2",
+		flv1.AnnotationSubnetPool: "pool1",
+	})
+	assert.NotNil(t, err)
+	for _, s := range []string{
+		`[{"interface":"eth2","subnet":"subnet1","subnetPool":"pool1"}]`,
+		`[{"interface":"eth2","subnetPool":"pool1","ip":"10.0.0.2"}]`,
+	} {
+		_, err = CheckPodAnnotationInterfaces(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func Test_GetSubnetRemediation(t *testing.T) {
+	r := GetSubnetRemediation(nil)
+	assert.Equal(t, flv1.SubnetRemediation{
+		PendingTimeout:      300,
+		PendingAction:       flv1.RemediationActionDeletePod,
+		DuplicateAction:     flv1.RemediationActionDeletePod,
+		DuplicateTieBreaker: flv1.DuplicateTieBreakerNone,
+	}, r)
+
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			Remediation: &flv1.SubnetRemediation{
+				PendingTimeout:      60,
+				PendingAction:       flv1.RemediationActionMarkFailed,
+				DuplicateTieBreaker: flv1.DuplicateTieBreakerActive,
+			},
+		},
+	}
+	r = GetSubnetRemediation(subnet)
+	assert.Equal(t, int64(60), r.PendingTimeout)
+	assert.Equal(t, flv1.RemediationActionMarkFailed, r.PendingAction)
+	assert.Equal(t, flv1.RemediationActionDeletePod, r.DuplicateAction)
+	assert.Equal(t, flv1.DuplicateTieBreakerActive, r.DuplicateTieBreaker)
+
+	assert.Nil(t, checkSubnetRemediation(nil))
+	assert.Nil(t, checkSubnetRemediation(subnet.Spec.Remediation))
+	assert.NotNil(t, checkSubnetRemediation(&flv1.SubnetRemediation{
+		PendingTimeout: -1,
+	}))
+	assert.NotNil(t, checkSubnetRemediation(&flv1.SubnetRemediation{
+		DuplicateAction: "restart",
+	}))
+	assert.NotNil(t, checkSubnetRemediation(&flv1.SubnetRemediation{
+		DuplicateTieBreaker: "newest",
+	}))
+}
+
+func Test_GetDuplicatedIPsToRemediate(t *testing.T) {
+	now := time.Now()
+	newIP := func(name, phase string, allocated time.Duration) *flv1.FlatNetworkIP {
+		return &flv1.FlatNetworkIP{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Status: flv1.IPStatus{
+				Phase:              phase,
+				AllocatedTimeStamp: metav1.NewTime(now.Add(allocated)),
+			},
+		}
+	}
+	names := func(ips []*flv1.FlatNetworkIP) []string {
+		s := []string{}
+		for _, ip := range ips {
+			s = append(s, ip.Name)
+		}
+		return s
+	}
+	ips := []*flv1.FlatNetworkIP{
+		newIP("ip1", "Pending", time.Minute),
+		newIP("ip2", "Active", time.Second),
+		newIP("ip3", "Pending", 0),
+	}
+	assert.Nil(t, GetDuplicatedIPsToRemediate(ips[:1], flv1.DuplicateTieBreakerNone))
+	assert.Equal(t, []string{"ip3", "ip2", "ip1"},
+		names(GetDuplicatedIPsToRemediate(ips, flv1.DuplicateTieBreakerNone)))
+	assert.Equal(t, []string{"ip2", "ip1"},
+		names(GetDuplicatedIPsToRemediate(ips, flv1.DuplicateTieBreakerOldest)))
+	assert.Equal(t, []string{"ip3", "ip1"},
+		names(GetDuplicatedIPsToRemediate(ips, flv1.DuplicateTieBreakerActive)))
+	assert.Equal(t, []string{"ip1"},
+		names(GetDuplicatedIPsToRemediate([]*flv1.FlatNetworkIP{ips[0], ips[2]},
+			flv1.DuplicateTieBreakerActive)))
+	// The input IPs are not modified.
+	assert.Equal(t, []string{"ip1", "ip2", "ip3"}, names(ips))
+}
+
+func Test_AddIPAllocationRecord(t *testing.T) {
+	ip := &flv1.FlatNetworkIP{}
+	for i := 0; i < maxIPAllocationHistory+3; i++ {
+		AddIPAllocationRecord(ip, flv1.IPAllocationRecord{
+			Reason: flv1.IPConditionAllocated,
+			Addr:   net.IPv4(10, 0, 0, byte(i)),
+		})
+	}
+	assert.Equal(t, maxIPAllocationHistory, len(ip.Status.History))
+	assert.Equal(t, "10.0.0.3", ip.Status.History[0].Addr.String())
+	assert.Equal(t, "10.0.0.12", ip.Status.History[maxIPAllocationHistory-1].Addr.String())
+}
+
+func Test_SetIPCondition(t *testing.T) {
+	ip := &flv1.FlatNetworkIP{
+		ObjectMeta: metav1.ObjectMeta{
+			Generation: 2,
+		},
+	}
+	assert.True(t, SetIPCondition(ip, flv1.IPConditionAllocated,
+		metav1.ConditionTrue, flv1.IPConditionAllocated, ""))
+	assert.False(t, SetIPCondition(ip, flv1.IPConditionAllocated,
+		metav1.ConditionTrue, flv1.IPConditionAllocated, ""))
+	assert.True(t, SetIPCondition(ip, flv1.IPConditionConflict,
+		metav1.ConditionTrue, flv1.RemediationDuplicatedIP, "duplicated"))
+	assert.Equal(t, 2, len(ip.Status.Conditions))
+	assert.Equal(t, int64(2), ip.Status.Conditions[1].ObservedGeneration)
+}
+
+func Test_GetIPBlockName(t *testing.T) {
+	assert.Equal(t, "subnet1-10-2-3-64", GetIPBlockName("subnet1", net.ParseIP("10.2.3.100")))
+	assert.Equal(t, "subnet1-10-2-3-0", GetIPBlockName("subnet1", net.ParseIP("10.2.3.63")))
+	assert.Equal(t, "subnet1-fd000000000000000000000000000040",
+		GetIPBlockName("subnet1", net.ParseIP("fd00::7f")))
+
+	from, to := GetIPBlockRange(net.ParseIP("10.2.3.200"))
+	assert.Equal(t, "10.2.3.192", from.String())
+	assert.Equal(t, "10.2.3.255", to.String())
+
+	assert.Equal(t, "subnet1-mac", GetMACBlockName("subnet1"))
+}
+
+func Test_MergeSubnetIPBlocks(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
+		},
+		Spec: flv1.SubnetSpec{
+			CIDR:    "10.0.0.0/24",
+			Gateway: net.ParseIP("10.0.0.1"),
+		},
+		Status: flv1.SubnetStatus{
+			// Legacy usedIP recorded in the subnet status.
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("10.0.0.2"), To: net.ParseIP("10.0.0.2")},
+			},
+		},
+	}
+	blocks := []*flv1.FlatNetworkIPBlock{
+		NewIPBlock(subnet, net.ParseIP("10.0.0.3")),
+		NewIPBlock(&flv1.FlatNetworkSubnet{
+			ObjectMeta: metav1.ObjectMeta{Name: "subnet2"},
+		}, net.ParseIP("10.0.0.3")),
+	}
+	blocks[0].Status.UsedIP = []flv1.IPRange{
+		{From: net.ParseIP("10.0.0.3"), To: net.ParseIP("10.0.0.3")},
+	}
+	blocks[0].Status.UsedMAC = []string{"02:00:00:00:00:03"}
+	blocks[0].Status.ReservedIP = map[string][]flv1.IPRange{
+		"Deployment/default/test": {
+			{From: net.ParseIP("10.0.0.5"), To: net.ParseIP("10.0.0.5")},
+		},
+	}
+	blocks[1].Status.UsedIP = []flv1.IPRange{
+		{From: net.ParseIP("10.0.0.10"), To: net.ParseIP("10.0.0.10")},
+	}
+
+	result := MergeSubnetIPBlocks(subnet, blocks)
+	assert.Equal(t, 1, len(result.Status.UsedIP))
+	assert.Equal(t, "10.0.0.1", result.Status.UsedIP[0].From.String())
+	assert.Equal(t, "10.0.0.3", result.Status.UsedIP[0].To.String())
+	assert.Equal(t, []string{"02:00:00:00:00:03"}, result.Status.UsedMAC)
+	assert.Equal(t, 1, len(result.Status.ReservedIP["Deployment/default/test"]))
+	// The input subnet is not modified.
+	assert.Equal(t, 1, len(subnet.Status.UsedIP))
+	assert.Nil(t, subnet.Status.ReservedIP)
+}
+
+func Test_GetIPBlocksReservedIP(t *testing.T) {
+	result := GetIPBlocksReservedIP("subnet1", []flv1.IPRange{
+		{From: net.ParseIP("10.0.0.5"), To: net.ParseIP("10.0.0.5")},
+		{From: net.ParseIP("10.0.0.60"), To: net.ParseIP("10.0.0.130")},
+	})
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, []flv1.IPRange{
+		{From: net.ParseIP("10.0.0.5"), To: net.ParseIP("10.0.0.5")},
+		{From: net.ParseIP("10.0.0.60"), To: net.ParseIP("10.0.0.63")},
+	}, result["subnet1-10-0-0-0"])
+	assert.Equal(t, "10.0.0.64", result["subnet1-10-0-0-64"][0].From.String())
+	assert.Equal(t, "10.0.0.127", result["subnet1-10-0-0-64"][0].To.String())
+	assert.Equal(t, "10.0.0.128", result["subnet1-10-0-0-128"][0].From.String())
+	assert.Equal(t, "10.0.0.130", result["subnet1-10-0-0-128"][0].To.String())
+
+	assert.Empty(t, GetIPBlocksReservedIP("subnet1", nil))
+}
+
+func Test_ParseBandwidthLimit(t *testing.T) {
+	l, err := ParseBandwidthLimit("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+
+	l, err = ParseBandwidthLimit("100M", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &BandwidthLimit{Rate: 100_000_000, Burst: 100_000_000}, l)
+
+	l, err = ParseBandwidthLimit("1Gi", "10Mi")
+	assert.NoError(t, err)
+	assert.Equal(t, &BandwidthLimit{Rate: 1 << 30, Burst: 10 << 20}, l)
+
+	_, err = ParseBandwidthLimit("", "10M")
+	assert.Error(t, err)
+	_, err = ParseBandwidthLimit("abc", "")
+	assert.Error(t, err)
+	_, err = ParseBandwidthLimit("-1M", "")
+	assert.Error(t, err)
+	_, err = ParseBandwidthLimit("100M", "40G")
+	assert.Error(t, err)
+}
+
+func Test_GetPodAnnotationBandwidth(t *testing.T) {
+	assert.Nil(t, GetPodAnnotationBandwidth(nil))
+	bw := GetPodAnnotationBandwidth(map[string]string{
+		flv1.AnnotationIngressRate: "10M",
+		flv1.AnnotationEgressRate:  "20M",
+		flv1.AnnotationEgressBurst: "1M",
+	})
+	ingress, egress, err := GetBandwidthLimits(bw)
+	assert.NoError(t, err)
+	assert.Equal(t, &BandwidthLimit{Rate: 10_000_000, Burst: 10_000_000}, ingress)
+	assert.Equal(t, &BandwidthLimit{Rate: 20_000_000, Burst: 1_000_000}, egress)
+}
+
+func Test_ParseSubnetMTU(t *testing.T) {
+	newMTU := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+	subnet := &flv1.FlatNetworkSubnet{}
+	mtu, auto, err := ParseSubnetMTU(subnet)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mtu)
+	assert.False(t, auto)
+
+	subnet.Spec.MTU = newMTU(intstr.FromString("auto"))
+	mtu, auto, err = ParseSubnetMTU(subnet)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mtu)
+	assert.True(t, auto)
+
+	subnet.Spec.MTU = newMTU(intstr.FromInt32(9000))
+	mtu, auto, err = ParseSubnetMTU(subnet)
+	assert.NoError(t, err)
+	assert.Equal(t, 9000, mtu)
+	assert.False(t, auto)
+
+	subnet.Spec.MTU = newMTU(intstr.FromString("9000"))
+	_, _, err = ParseSubnetMTU(subnet)
+	assert.Error(t, err)
+	subnet.Spec.MTU = newMTU(intstr.FromInt32(10))
+	_, _, err = ParseSubnetMTU(subnet)
+	assert.Error(t, err)
+}
+
+func Test_checkSubnetMTUConflict(t *testing.T) {
+	newMTU := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+	newSubnet := func(name string, vlan int, mtu *intstr.IntOrString) *flv1.FlatNetworkSubnet {
+		s := &flv1.FlatNetworkSubnet{}
+		s.Name = name
+		s.Spec.Master = "eth1"
+		s.Spec.VLAN = vlan
+		s.Spec.MTU = mtu
+		return s
+	}
+	mtu9000 := newMTU(intstr.FromInt32(9000))
+	mtu1500 := newMTU(intstr.FromInt32(1500))
+	auto := newMTU(intstr.FromString(flv1.MTUAuto))
+	assert.NoError(t, checkSubnetMTUConflict(
+		newSubnet("s1", 100, mtu9000), newSubnet("s2", 100, mtu9000)))
+	assert.NoError(t, checkSubnetMTUConflict(
+		newSubnet("s1", 100, mtu9000), newSubnet("s2", 100, auto)))
+	assert.NoError(t, checkSubnetMTUConflict(
+		newSubnet("s1", 100, mtu9000), newSubnet("s2", 200, mtu1500)))
+	assert.NoError(t, checkSubnetMTUConflict(
+		newSubnet("s1", 0, mtu9000), newSubnet("s2", 0, mtu1500)))
+	assert.Error(t, checkSubnetMTUConflict(
+		newSubnet("s1", 100, mtu9000), newSubnet("s2", 100, mtu1500)))
+}
+
+func Test_GetSubnetNodeMaster(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			Master: "eth0",
+			MasterSelectors: []flv1.MasterSelector{
+				{
+					NodeSelector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"hw": "vmware"},
+					},
+					Master: "ens192",
+				},
+				{
+					NodeSelector: metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      "hw",
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   []string{"bare-metal", "vmware"},
+							},
+						},
+					},
+					Master: "bond0",
+				},
+			},
+		},
+	}
+	assert.Equal(t, "ens192", GetSubnetNodeMaster(subnet, map[string]string{"hw": "vmware"}))
+	assert.Equal(t, "bond0", GetSubnetNodeMaster(subnet, map[string]string{"hw": "bare-metal"}))
+	assert.Equal(t, "eth0", GetSubnetNodeMaster(subnet, map[string]string{"hw": "kvm"}))
+	assert.Equal(t, "eth0", GetSubnetNodeMaster(subnet, nil))
+	assert.Equal(t, []string{"bond0", "ens192", "eth0"}, GetSubnetMasters(subnet))
+
+	assert.NoError(t, checkSubnetMasterSelectors(subnet.Spec.MasterSelectors))
+	assert.Error(t, checkSubnetMasterSelectors([]flv1.MasterSelector{{}}))
+}
+
+func Test_IsSubnetsSharingMaster(t *testing.T) {
+	a := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			Master: "eth0",
+			MasterSelectors: []flv1.MasterSelector{
+				{Master: "bond0"},
+			},
+		},
+	}
+	b := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			Master: "bond0",
+		},
+	}
+	c := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			Master: "ens192",
+		},
+	}
+	assert.True(t, IsSubnetsSharingMaster(a, b))
+	assert.True(t, IsSubnetsSharingMaster(b, a))
+	assert.False(t, IsSubnetsSharingMaster(a, c))
+	assert.False(t, IsSubnetsSharingMaster(b, c))
+
+	// Subnets using different flatMode on the same master are conflicts.
+	a.Name, a.Spec.FlatMode, a.Spec.Mode = "a", flv1.FlatModeMacvlan, "bridge"
+	b.Name, b.Spec.FlatMode, b.Spec.Mode = "b", flv1.FlatModeIPvlan, "l2"
+	c.Name, c.Spec.FlatMode, c.Spec.Mode = "c", flv1.FlatModeIPvlan, "l2"
+	assert.Error(t, CheckSubnetFlatMode(a, []*flv1.FlatNetworkSubnet{b}))
+	assert.NoError(t, CheckSubnetFlatMode(a, []*flv1.FlatNetworkSubnet{c}))
 }
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+var (
+	ErrSubnetCordoned = errors.New("subnet is cordoned")
+)
+
+// CheckSubnetCordoned ensures the subnet is not cordoned before allocating
+// new IP address from the subnet.
+func CheckSubnetCordoned(subnet *flv1.FlatNetworkSubnet) error {
+	if subnet == nil || !subnet.Spec.Cordoned {
+		return nil
+	}
+	if subnet.Spec.Drain != nil && subnet.Spec.Drain.ReplacementSubnet != "" {
+		return fmt.Errorf("subnet [%v] is cordoned, use the replacement subnet [%v] instead: %w",
+			subnet.Name, subnet.Spec.Drain.ReplacementSubnet, ErrSubnetCordoned)
+	}
+	return fmt.Errorf("subnet [%v] is cordoned, use another subnet instead: %w",
+		subnet.Name, ErrSubnetCordoned)
+}
+
+// ReplacePodAnnotationSubnet replaces the subnet with the replacement subnet
+// in the flat-network subnet and interfaces annotations, returns true if the
+// annotations changed.
+// The interface attachments using the specific IP addresses can not be
+// moved to the replacement subnet and an error is returned.
+func ReplacePodAnnotationSubnet(
+	annotations map[string]string, subnet, replacement string,
+) (bool, error) {
+	interfaces, err := GetPodInterfaces(annotations)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for i := range interfaces {
+		subnets, err := CheckPodAnnotationSubnets(interfaces[i].Subnet)
+		if err != nil {
+			return false, err
+		}
+		index := slices.Index(subnets, subnet)
+		if index < 0 {
+			continue
+		}
+		switch interfaces[i].IP {
+		case "", flv1.AllocateModeAuto:
+		default:
+			return false, fmt.Errorf("interface [%v] using the specific IP [%v] of subnet [%v] can not be moved to subnet [%v]",
+				interfaces[i].Interface, interfaces[i].IP, subnet, replacement)
+		}
+		subnets[index] = replacement
+		interfaces[i].Subnet = strings.Join(slices.Compact(subnets), ",")
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	// The first one is the default attachment defined by the subnet
+	// annotation, followed by the interfaces annotation.
+	annotations[flv1.AnnotationSubnet] = interfaces[0].Subnet
+	if len(interfaces) > 1 {
+		b, err := json.Marshal(interfaces[1:])
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal interfaces: %w", err)
+		}
+		annotations[flv1.AnnotationInterfaces] = string(b)
+	}
+	return true, nil
+}
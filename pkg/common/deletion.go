@@ -0,0 +1,71 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// maxSubnetReferencesMessage is the max number of the subnet references
+// shown in the error message.
+const maxSubnetReferencesMessage = 10
+
+// IsSubnetForceDelete returns true if the subnet is annotated to be deleted
+// even if it is still in use.
+func IsSubnetForceDelete(subnet *flv1.FlatNetworkSubnet) bool {
+	if subnet == nil {
+		return false
+	}
+	force, _ := strconv.ParseBool(subnet.Annotations[flv1.AnnotationForceDelete])
+	return force
+}
+
+// GetSubnetReferences returns the flat-network IPs and the workloads
+// reserved IPs still referencing the subnet, sorted by name.
+func GetSubnetReferences(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) []string {
+	var result []string
+	for _, ip := range ips {
+		if ip == nil || ip.DeletionTimestamp != nil {
+			continue
+		}
+		if ip.Spec.Subnet != subnet.Name && ip.Spec.SecondarySubnet != subnet.Name {
+			continue
+		}
+		result = append(result, fmt.Sprintf("FlatNetworkIP/%s/%s", ip.Namespace, ip.Name))
+	}
+	for key := range subnet.Status.ReservedIP {
+		// The FlatNetworkIPReservations do not block the subnet deletion.
+		if IsIPReservationKey(key) {
+			continue
+		}
+		result = append(result, key)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// CheckSubnetDelete ensures the subnet is not referenced by the flat-network
+// IPs and workloads before deleting, unless the subnet is annotated to be
+// force deleted.
+func CheckSubnetDelete(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) error {
+	if IsSubnetForceDelete(subnet) {
+		return nil
+	}
+	references := GetSubnetReferences(subnet, ips)
+	if len(references) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("%v", references)
+	if len(references) > maxSubnetReferencesMessage {
+		message = fmt.Sprintf("%v and %d more",
+			references[:maxSubnetReferencesMessage], len(references)-maxSubnetReferencesMessage)
+	}
+	return fmt.Errorf("subnet [%v] is still in use by %v, add annotation [%v: \"true\"] to force delete",
+		subnet.Name, message, flv1.AnnotationForceDelete)
+}
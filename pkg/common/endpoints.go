@@ -62,6 +62,36 @@ func (r *EndpointReource) GetEndpointSliceAddressType() (discoveryv1.AddressType
 	return t, nil
 }
 
+// GetEndpointsByAddressType returns the endpoints matching the EndpointSlice
+// address type, used for the dual-stack service with both IPv4 and IPv6
+// EndpointSlices.
+func (r *EndpointReource) GetEndpointsByAddressType(
+	t discoveryv1.AddressType,
+) []discoveryv1.Endpoint {
+	endpoints := []discoveryv1.Endpoint{}
+	for _, e := range r.Endpoints {
+		addresses := []string{}
+		for _, a := range e.Addresses {
+			addr := net.ParseIP(a)
+			if len(addr) == 0 {
+				continue
+			}
+			switch {
+			case addr.To4() != nil && t == discoveryv1.AddressTypeIPv4,
+				addr.To4() == nil && t == discoveryv1.AddressTypeIPv6:
+				addresses = append(addresses, a)
+			}
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+		e := *e.DeepCopy()
+		e.Addresses = addresses
+		endpoints = append(endpoints, e)
+	}
+	return endpoints
+}
+
 var (
 	ErrPodNetworkStatusNotUpdated = fmt.Errorf("pod network status not updated by multus")
 )
@@ -201,10 +231,11 @@ func parseServiceNetworkSelections(
 }
 
 func isInNetworkSelectionElementsArray(
-	statusName, namespace string, networks []*types.NetworkSelectionElement,
+	status *nettypes.NetworkStatus, namespace string, networks []*types.NetworkSelectionElement,
 ) bool {
 	// https://github.com/k8snetworkplumbingwg/multus-cni/blob/v4.0.2/pkg/types/conf.go#L117
 	var netName, netNamespace string
+	statusName := status.Name
 	units := strings.SplitN(statusName, "/", 2)
 	switch len(units) {
 	case 1:
@@ -218,9 +249,15 @@ func isInNetworkSelectionElementsArray(
 		return false
 	}
 	for i := range networks {
-		if netName == networks[i].Name && netNamespace == networks[i].Namespace {
-			return true
+		if netName != networks[i].Name || netNamespace != networks[i].Namespace {
+			continue
+		}
+		// Only match the specified interface if the pod has multiple
+		// flat-network interfaces in the same network.
+		if networks[i].InterfaceRequest != "" && networks[i].InterfaceRequest != status.Interface {
+			continue
 		}
+		return true
 	}
 	return false
 }
@@ -248,7 +285,7 @@ func getPodEndpointSubset(
 
 	// Find networks used by pod and match network annotation of this service
 	for _, status := range networksStatus {
-		if !isInNetworkSelectionElementsArray(status.Name, pod.Namespace, svcNetworks) {
+		if !isInNetworkSelectionElementsArray(&status, pod.Namespace, svcNetworks) {
 			continue
 		}
 		// All IPs of matching network are added as endpoints
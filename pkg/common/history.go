@@ -0,0 +1,41 @@
+package common
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	// maxIPAllocationHistory is the max number of the allocation records
+	// kept in the IP status.
+	maxIPAllocationHistory = 10
+)
+
+// SetIPCondition sets the condition of the flat-network IP status,
+// returns true if the condition changed.
+func SetIPCondition(
+	ip *flv1.FlatNetworkIP, conditionType string,
+	status metav1.ConditionStatus, reason, message string,
+) bool {
+	return meta.SetStatusCondition(&ip.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: ip.Generation,
+	})
+}
+
+// AddIPAllocationRecord appends the record to the flat-network IP history,
+// the oldest records are dropped when exceeding the limit.
+func AddIPAllocationRecord(ip *flv1.FlatNetworkIP, record flv1.IPAllocationRecord) {
+	history := append(ip.Status.History, record)
+	if n := len(history) - maxIPAllocationHistory; n > 0 {
+		history = slices.Clone(history[n:])
+	}
+	ip.Status.History = history
+}
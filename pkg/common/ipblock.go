@@ -0,0 +1,166 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	// LabelIPBlockSubnet is the label of the subnet name of the IP block.
+	LabelIPBlockSubnet = "subnet"
+)
+
+// GetIPBlockRange returns the first and last address of the IP block
+// containing the IP address, the IP blocks are aligned by IPBlockSize.
+func GetIPBlockRange(ip net.IP) (net.IP, net.IP) {
+	from := slices.Clone(ip.To16())
+	if len(from) == 0 {
+		return nil, nil
+	}
+	if v4 := from.To4(); v4 != nil {
+		from = v4
+	}
+	to := slices.Clone(from)
+	from[len(from)-1] &^= flv1.IPBlockSize - 1
+	to[len(to)-1] |= flv1.IPBlockSize - 1
+	return from, to
+}
+
+// GetIPBlockName returns the name of the IP block of the subnet containing
+// the IP address.
+// e.g. subnet1-10-2-3-64, subnet1-fd000000000000000000000000000040
+func GetIPBlockName(subnet string, ip net.IP) string {
+	from, _ := GetIPBlockRange(ip)
+	if len(from) == net.IPv4len {
+		return fmt.Sprintf("%v-%v", subnet, strings.ReplaceAll(from.String(), ".", "-"))
+	}
+	return fmt.Sprintf("%v-%v", subnet, hex.EncodeToString(from))
+}
+
+// GetMACBlockName returns the name of the MAC block of the subnet.
+// e.g. subnet1-mac
+func GetMACBlockName(subnet string) string {
+	return subnet + "-mac"
+}
+
+// NewIPBlock returns the IP block of the subnet containing the IP address.
+func NewIPBlock(subnet *flv1.FlatNetworkSubnet, ip net.IP) *flv1.FlatNetworkIPBlock {
+	from, to := GetIPBlockRange(ip)
+	block := newIPBlock(subnet, GetIPBlockName(subnet.Name, ip))
+	block.Spec.From = from
+	block.Spec.To = to
+	return block
+}
+
+// NewMACBlock returns the MAC block of the subnet, which is the IP block
+// without address range recording all the MAC addresses used in the subnet.
+func NewMACBlock(subnet *flv1.FlatNetworkSubnet) *flv1.FlatNetworkIPBlock {
+	return newIPBlock(subnet, GetMACBlockName(subnet.Name))
+}
+
+func newIPBlock(subnet *flv1.FlatNetworkSubnet, name string) *flv1.FlatNetworkIPBlock {
+	return &flv1.FlatNetworkIPBlock{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: flv1.SubnetNamespace,
+			Labels: map[string]string{
+				LabelIPBlockSubnet: subnet.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: flv1.SchemeGroupVersion.String(),
+					Kind:       "FlatNetworkSubnet",
+					Name:       subnet.Name,
+					UID:        subnet.UID,
+				},
+			},
+		},
+		Spec: flv1.IPBlockSpec{
+			Subnet: subnet.Name,
+		},
+	}
+}
+
+// GetIPBlocksReservedIP splits the reserved IP ranges of the subnet by the IP
+// blocks, returns the map of the IP block name to the reserved IP ranges
+// inside the IP block.
+func GetIPBlocksReservedIP(subnet string, ranges []flv1.IPRange) map[string][]flv1.IPRange {
+	result := map[string][]flv1.IPRange{}
+	for _, r := range ipcalc.NewIPSet(ranges).Ranges() {
+		from := slices.Clone(r.From.To16())
+		for {
+			_, to := GetIPBlockRange(from)
+			to = to.To16()
+			if bytes.Compare(to, r.To.To16()) > 0 {
+				to = r.To.To16()
+			}
+			name := GetIPBlockName(subnet, from)
+			result[name] = append(result[name], flv1.IPRange{From: from, To: to})
+			if to.Equal(r.To) {
+				break
+			}
+			from = slices.Clone(to)
+			ipcalc.IPIncrease(from)
+		}
+	}
+	return result
+}
+
+// MergeSubnetIPBlocks returns a copy of the subnet with the UsedIP, UsedMAC,
+// ReleasedIP and ReservedIP status aggregated from the IP blocks of the
+// subnet, the subnet gateway is included in the UsedIP.
+// The values stored in the subnet status by the old versions are merged as
+// well until migrated to the IP blocks.
+func MergeSubnetIPBlocks(
+	subnet *flv1.FlatNetworkSubnet, blocks []*flv1.FlatNetworkIPBlock,
+) *flv1.FlatNetworkSubnet {
+	result := subnet.DeepCopy()
+	usedIP := slices.Clone(subnet.Status.UsedIP)
+	usedMAC := slices.Clone(subnet.Status.UsedMAC)
+	releasedIP := slices.Clone(subnet.Status.ReleasedIP)
+	reservedIP := map[string][]flv1.IPRange{}
+	for k, r := range subnet.Status.ReservedIP {
+		reservedIP[k] = slices.Clone(r)
+	}
+	for _, b := range blocks {
+		if b == nil || b.Spec.Subnet != subnet.Name {
+			continue
+		}
+		usedIP = append(usedIP, b.Status.UsedIP...)
+		usedMAC = append(usedMAC, b.Status.UsedMAC...)
+		releasedIP = append(releasedIP, b.Status.ReleasedIP...)
+		for k, r := range b.Status.ReservedIP {
+			reservedIP[k] = append(reservedIP[k], r...)
+		}
+	}
+	if len(subnet.Spec.Gateway) != 0 {
+		usedIP = append(usedIP, flv1.IPRange{
+			From: subnet.Spec.Gateway,
+			To:   subnet.Spec.Gateway,
+		})
+	}
+	result.Status.UsedIP = ipcalc.NewIPSet(usedIP).Ranges()
+	slices.Sort(usedMAC)
+	result.Status.UsedMAC = slices.Compact(usedMAC)
+	slices.SortStableFunc(releasedIP, func(a, b flv1.ReleasedIP) int {
+		return a.ReleasedTimestamp.Compare(b.ReleasedTimestamp.Time)
+	})
+	result.Status.ReleasedIP = releasedIP
+	result.Status.ReservedIP = nil
+	if len(reservedIP) != 0 {
+		result.Status.ReservedIP = make(map[string][]flv1.IPRange, len(reservedIP))
+		for k, r := range reservedIP {
+			result.Status.ReservedIP[k] = ipcalc.NewIPSet(r).Ranges()
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// checkSubnetMasterSelectors validates the subnet masterSelectors.
+func checkSubnetMasterSelectors(selectors []flv1.MasterSelector) error {
+	for i := range selectors {
+		s := &selectors[i]
+		if s.Master == "" {
+			return fmt.Errorf("invalid subnet masterSelectors: master of selector [%v] is empty", i)
+		}
+		if _, err := metav1.LabelSelectorAsSelector(&s.NodeSelector); err != nil {
+			return fmt.Errorf("invalid subnet masterSelectors: nodeSelector of selector [%v]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// GetSubnetNodeMaster returns the master interface of the subnet on the node
+// by the subnet masterSelectors, fallback to the subnet master if no selector
+// matches the node labels.
+func GetSubnetNodeMaster(subnet *flv1.FlatNetworkSubnet, nodeLabels map[string]string) string {
+	for i := range subnet.Spec.MasterSelectors {
+		s := &subnet.Spec.MasterSelectors[i]
+		selector, err := metav1.LabelSelectorAsSelector(&s.NodeSelector)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(nodeLabels)) {
+			return s.Master
+		}
+	}
+	return subnet.Spec.Master
+}
+
+// GetSubnetMasters returns all the master interfaces may be used by the
+// subnet on the nodes.
+func GetSubnetMasters(subnet *flv1.FlatNetworkSubnet) []string {
+	masters := []string{}
+	if subnet.Spec.Master != "" {
+		masters = append(masters, subnet.Spec.Master)
+	}
+	for _, s := range subnet.Spec.MasterSelectors {
+		masters = append(masters, s.Master)
+	}
+	slices.Sort(masters)
+	return slices.Compact(masters)
+}
+
+// IsSubnetsSharingMaster returns true if the subnets may use the same master
+// interface on a node, the subnet master and the masters of all the master
+// selectors are compared.
+// The node selectors are not evaluated, the subnets using the same interface
+// name on any node are considered sharing the master.
+func IsSubnetsSharingMaster(a, b *flv1.FlatNetworkSubnet) bool {
+	masters := GetSubnetMasters(b)
+	for _, m := range GetSubnetMasters(a) {
+		if _, ok := slices.BinarySearch(masters, m); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// ValidateSubnetPool validates the subnet selector of the subnet pool.
+func ValidateSubnetPool(pool *flv1.FlatNetworkSubnetPool) error {
+	if pool.Spec.SubnetSelector == nil {
+		return fmt.Errorf("subnet pool [%v] subnetSelector not specified", pool.Name)
+	}
+	if _, err := metav1.LabelSelectorAsSelector(pool.Spec.SubnetSelector); err != nil {
+		return fmt.Errorf("invalid subnet pool [%v] subnetSelector: %w", pool.Name, err)
+	}
+	return nil
+}
+
+// GetSubnetPoolSubnets returns the subnets selected by the subnet pool in
+// priority order, the subnets being deleted are excluded.
+// The subnets with higher priority come first, the subnets with the same
+// priority are sorted by name.
+func GetSubnetPoolSubnets(
+	pool *flv1.FlatNetworkSubnetPool, subnets []*flv1.FlatNetworkSubnet,
+) ([]*flv1.FlatNetworkSubnet, error) {
+	if err := ValidateSubnetPool(pool); err != nil {
+		return nil, err
+	}
+	selector, _ := metav1.LabelSelectorAsSelector(pool.Spec.SubnetSelector)
+	var result []*flv1.FlatNetworkSubnet
+	for _, subnet := range subnets {
+		if subnet == nil || subnet.DeletionTimestamp != nil {
+			continue
+		}
+		if !selector.Matches(labels.Set(subnet.Labels)) {
+			continue
+		}
+		result = append(result, subnet)
+	}
+	slices.SortFunc(result, func(a, b *flv1.FlatNetworkSubnet) int {
+		if c := cmp.Compare(b.Spec.Priority, a.Spec.Priority); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, nil
+}
+
+// checkPodInterfaceSubnetPool ensures only one of the subnet and subnet pool
+// of the pod interface is specified, and the subnet pool is only used in
+// IP auto mode.
+func checkPodInterfaceSubnetPool(iface *flv1.PodInterface) error {
+	if iface.SubnetPool == "" {
+		return nil
+	}
+	if iface.Subnet != "" {
+		return fmt.Errorf("subnet [%v] and subnet pool [%v] can not be specified at the same time",
+			iface.Subnet, iface.SubnetPool)
+	}
+	switch iface.IP {
+	case "", flv1.AllocateModeAuto:
+	default:
+		return fmt.Errorf("subnet pool [%v] does not support specific IP [%v]",
+			iface.SubnetPool, iface.IP)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+var (
+	ErrSubnetQuotaExceeded = errors.New("subnet quota exceeded")
+)
+
+func checkSubnetQuotas(quotas []flv1.SubnetQuota) error {
+	set := map[string]bool{}
+	for _, q := range quotas {
+		if (q.Namespace == "") == (q.Project == "") {
+			return fmt.Errorf("only one of the namespace and project should be specified in quota")
+		}
+		if q.Limit < 0 {
+			return fmt.Errorf("quota limit [%v] should not be negative", q.Limit)
+		}
+		key := subnetQuotaKey(q.Namespace, q.Project)
+		if set[key] {
+			return fmt.Errorf("quota [%v] is duplicated", key)
+		}
+		set[key] = true
+	}
+	return nil
+}
+
+func subnetQuotaKey(namespace, project string) string {
+	if namespace != "" {
+		return "namespace/" + namespace
+	}
+	return "project/" + getProjectID(project)
+}
+
+// GetNamespaceProjectID returns the Rancher project ID of the namespace.
+func GetNamespaceProjectID(ns *corev1.Namespace) string {
+	if ns == nil {
+		return ""
+	}
+	return ns.Labels[flv1.LabelProjectID]
+}
+
+// IsSubnetQuotaMatch returns true if the quota applies to the namespace.
+func IsSubnetQuotaMatch(quota *flv1.SubnetQuota, namespace, projectID string) bool {
+	if quota.Namespace != "" {
+		return quota.Namespace == namespace
+	}
+	return projectID != "" && getProjectID(quota.Project) == projectID
+}
+
+// GetSubnetQuotaUsage counts the IP addresses allocated on the subnet by
+// each subnet quota.
+// The nsProjects is the map of namespace name to its Rancher project ID.
+func GetSubnetQuotaUsage(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP, nsProjects map[string]string,
+) []flv1.SubnetQuotaUsage {
+	if len(subnet.Spec.Quotas) == 0 {
+		return nil
+	}
+	usage := make([]flv1.SubnetQuotaUsage, 0, len(subnet.Spec.Quotas))
+	for i := range subnet.Spec.Quotas {
+		q := &subnet.Spec.Quotas[i]
+		u := flv1.SubnetQuotaUsage{
+			Namespace: q.Namespace,
+			Project:   q.Project,
+			Limit:     q.Limit,
+		}
+		for _, ip := range ips {
+			if ip == nil || ip.DeletionTimestamp != nil {
+				continue
+			}
+			if len(GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)) == 0 {
+				continue
+			}
+			if IsSubnetQuotaMatch(q, ip.Namespace, nsProjects[ip.Namespace]) {
+				u.Used++
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// CheckSubnetQuota ensures the namespace does not exceed the subnet quotas
+// after allocating count more IP addresses.
+// The usage is the current quota usage of the subnet.
+func CheckSubnetQuota(
+	subnet *flv1.FlatNetworkSubnet, usage []flv1.SubnetQuotaUsage,
+	namespace, projectID string, count int,
+) error {
+	if count <= 0 {
+		return nil
+	}
+	for i := range subnet.Spec.Quotas {
+		q := &subnet.Spec.Quotas[i]
+		if !IsSubnetQuotaMatch(q, namespace, projectID) {
+			continue
+		}
+		used := 0
+		key := subnetQuotaKey(q.Namespace, q.Project)
+		for _, u := range usage {
+			if subnetQuotaKey(u.Namespace, u.Project) == key {
+				used = u.Used
+				break
+			}
+		}
+		if used+count > q.Limit {
+			return fmt.Errorf("%w: [%v] of subnet [%v] used %v, limit %v, requested %v",
+				ErrSubnetQuotaExceeded, key, subnet.Name, used, q.Limit, count)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	// defaultPendingTimeout is the default seconds to wait for the pod
+	// network setup by CNI.
+	defaultPendingTimeout = 300
+)
+
+// GetSubnetRemediation returns the remediation policies of the subnet with
+// the default values filled.
+func GetSubnetRemediation(subnet *flv1.FlatNetworkSubnet) flv1.SubnetRemediation {
+	r := flv1.SubnetRemediation{}
+	if subnet != nil && subnet.Spec.Remediation != nil {
+		r = *subnet.Spec.Remediation
+	}
+	if r.PendingTimeout == 0 {
+		r.PendingTimeout = defaultPendingTimeout
+	}
+	if r.PendingAction == "" {
+		r.PendingAction = flv1.RemediationActionDeletePod
+	}
+	if r.DuplicateAction == "" {
+		r.DuplicateAction = flv1.RemediationActionDeletePod
+	}
+	if r.DuplicateTieBreaker == "" {
+		r.DuplicateTieBreaker = flv1.DuplicateTieBreakerNone
+	}
+	return r
+}
+
+func checkSubnetRemediation(r *flv1.SubnetRemediation) error {
+	if r == nil {
+		return nil
+	}
+	if r.PendingTimeout < 0 {
+		return fmt.Errorf("invalid subnet remediation pendingTimeout [%v]: should not be negative",
+			r.PendingTimeout)
+	}
+	for _, action := range []string{r.PendingAction, r.DuplicateAction} {
+		switch action {
+		case "", flv1.RemediationActionDeletePod, flv1.RemediationActionEvent,
+			flv1.RemediationActionMarkFailed:
+		default:
+			return fmt.Errorf("invalid subnet remediation action [%v]: should be one of [%v, %v, %v]",
+				action, flv1.RemediationActionDeletePod, flv1.RemediationActionEvent,
+				flv1.RemediationActionMarkFailed)
+		}
+	}
+	switch r.DuplicateTieBreaker {
+	case "", flv1.DuplicateTieBreakerNone, flv1.DuplicateTieBreakerOldest,
+		flv1.DuplicateTieBreakerActive:
+	default:
+		return fmt.Errorf("invalid subnet remediation duplicateTieBreaker [%v]: should be one of [%v, %v, %v]",
+			r.DuplicateTieBreaker, flv1.DuplicateTieBreakerNone, flv1.DuplicateTieBreakerOldest,
+			flv1.DuplicateTieBreakerActive)
+	}
+	return nil
+}
+
+// GetDuplicatedIPsToRemediate returns the flat-network IPs sharing the same
+// address should be remediated by the tie-breaker, the IP keeps the address
+// is excluded.
+func GetDuplicatedIPsToRemediate(
+	ips []*flv1.FlatNetworkIP, tieBreaker string,
+) []*flv1.FlatNetworkIP {
+	if len(ips) < 2 {
+		return nil
+	}
+	ips = slices.Clone(ips)
+	// Sort by the allocation time, the oldest allocation first.
+	slices.SortStableFunc(ips, func(a, b *flv1.FlatNetworkIP) int {
+		if c := a.Status.AllocatedTimeStamp.Compare(b.Status.AllocatedTimeStamp.Time); c != 0 {
+			return c
+		}
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+	keep := -1
+	switch tieBreaker {
+	case flv1.DuplicateTieBreakerOldest:
+		keep = 0
+	case flv1.DuplicateTieBreakerActive:
+		keep = slices.IndexFunc(ips, func(ip *flv1.FlatNetworkIP) bool {
+			return ip.Status.Phase == "Active"
+		})
+		if keep < 0 {
+			keep = 0
+		}
+	}
+	if keep < 0 {
+		return ips
+	}
+	return slices.Delete(ips, keep, keep+1)
+}
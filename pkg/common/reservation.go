@@ -0,0 +1,131 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
+)
+
+const (
+	KindFlatNetworkIPReservation = "FlatNetworkIPReservation"
+)
+
+// ValidateIPReservation validates the reserved addresses of the
+// FlatNetworkIPReservation are inside the subnet.
+func ValidateIPReservation(
+	r *flv1.FlatNetworkIPReservation, subnet *flv1.FlatNetworkSubnet,
+) error {
+	if r.Spec.Subnet == "" {
+		return fmt.Errorf("subnet not specified")
+	}
+	if len(r.Spec.Addrs) == 0 && len(r.Spec.Ranges) == 0 {
+		return fmt.Errorf("neither addrs nor ranges specified")
+	}
+	_, network, err := net.ParseCIDR(subnet.Spec.CIDR)
+	if err != nil {
+		return fmt.Errorf("failed to parse subnet CIDR [%v]: %w",
+			subnet.Spec.CIDR, err)
+	}
+	for _, a := range r.Spec.Addrs {
+		if !ipcalc.IPInNetwork(a, network) {
+			return fmt.Errorf("reserved address [%v] is not in subnet [%v] CIDR [%v]",
+				a, subnet.Name, subnet.Spec.CIDR)
+		}
+	}
+	if rg, err := isValidRanges(r.Spec.Ranges, network); err != nil {
+		return fmt.Errorf("invalid reserved ranges %v: %w", utils.Print(rg), err)
+	}
+	return nil
+}
+
+// GetIPReservationKey returns the key of the reservation in the subnet
+// status reservedIP.
+func GetIPReservationKey(r *flv1.FlatNetworkIPReservation) string {
+	// FlatNetworkIPReservation/<Namespace>/<Name>
+	return fmt.Sprintf("%s/%s/%s",
+		KindFlatNetworkIPReservation, r.Namespace, r.Name)
+}
+
+// IsIPReservationKey returns true if the subnet status reservedIP key is
+// owned by FlatNetworkIPReservation.
+func IsIPReservationKey(key string) bool {
+	return strings.HasPrefix(key, KindFlatNetworkIPReservation+"/")
+}
+
+// GetIPReservationRanges returns the merged IP ranges of the reserved
+// addresses and ranges.
+func GetIPReservationRanges(r *flv1.FlatNetworkIPReservation) []flv1.IPRange {
+	s := ipcalc.NewIPSet(r.Spec.Ranges)
+	for _, a := range r.Spec.Addrs {
+		s.Add(a)
+	}
+	return s.Ranges()
+}
+
+// IsIPReservationExpired returns true if the reservation is expired.
+func IsIPReservationExpired(r *flv1.FlatNetworkIPReservation, now time.Time) bool {
+	if r.Spec.ExpireTime == nil {
+		return false
+	}
+	return !now.Before(r.Spec.ExpireTime.Time)
+}
+
+// GetSubnetIPReservations returns the IP ranges of the active
+// FlatNetworkIPReservations of the subnet, keyed by the reservation key.
+func GetSubnetIPReservations(
+	subnet *flv1.FlatNetworkSubnet, reservations []*flv1.FlatNetworkIPReservation, now time.Time,
+) map[string][]flv1.IPRange {
+	result := map[string][]flv1.IPRange{}
+	for _, r := range reservations {
+		if r == nil || r.Spec.Subnet != subnet.Name || r.DeletionTimestamp != nil {
+			continue
+		}
+		if IsIPReservationExpired(r, now) {
+			continue
+		}
+		if err := ValidateIPReservation(r, subnet); err != nil {
+			continue
+		}
+		result[GetIPReservationKey(r)] = GetIPReservationRanges(r)
+	}
+	return result
+}
+
+// SetSubnetIPReservations replaces the IP ranges of the FlatNetworkIPReservations
+// in the subnet status reservedIP, the removed reservations are pruned.
+func SetSubnetIPReservations(
+	subnet *flv1.FlatNetworkSubnet, reservations map[string][]flv1.IPRange,
+) {
+	result := make(map[string][]flv1.IPRange, len(subnet.Status.ReservedIP)+len(reservations))
+	for k, v := range subnet.Status.ReservedIP {
+		if IsIPReservationKey(k) {
+			continue
+		}
+		result[k] = v
+	}
+	for k, v := range reservations {
+		result[k] = v
+	}
+	if len(result) == 0 {
+		result = nil
+	}
+	subnet.Status.ReservedIP = result
+}
+
+// GetSubnetReservationRanges returns the IP ranges reserved by the
+// FlatNetworkIPReservations in the subnet status.
+func GetSubnetReservationRanges(subnet *flv1.FlatNetworkSubnet) []flv1.IPRange {
+	var ranges []flv1.IPRange
+	for k, r := range subnet.Status.ReservedIP {
+		if !IsIPReservationKey(k) {
+			continue
+		}
+		ranges = append(ranges, r...)
+	}
+	return ranges
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+)
+
+// maxStrandedIPsMessage is the max number of the stranded IPs shown in the
+// error message.
+const maxStrandedIPsMessage = 10
+
+// IsIPInSubnetRange returns true if the IP address is available in the
+// subnet CIDR and inside the subnet ranges (if specified).
+func IsIPInSubnetRange(addr net.IP, subnet *flv1.FlatNetworkSubnet) bool {
+	_, network, err := net.ParseCIDR(subnet.Spec.CIDR)
+	if err != nil {
+		return false
+	}
+	if !ipcalc.IsAvailableIP(addr, network) {
+		return false
+	}
+	if len(subnet.Spec.Ranges) != 0 && !ipcalc.IPInRanges(addr, subnet.Spec.Ranges) {
+		return false
+	}
+	return true
+}
+
+// GetSubnetOutOfRangeIPs returns the flat-network IPs whose allocated
+// address of the subnet is outside of the subnet CIDR or ranges,
+// sorted by the '<namespace>/<name>' of the flat-network IP.
+func GetSubnetOutOfRangeIPs(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) []flv1.OutOfRangeIP {
+	var result []flv1.OutOfRangeIP
+	for _, ip := range ips {
+		addr := GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)
+		if ip == nil || ip.DeletionTimestamp != nil || len(addr) == 0 {
+			continue
+		}
+		if IsIPInSubnetRange(addr, subnet) {
+			continue
+		}
+		result = append(result, flv1.OutOfRangeIP{
+			Addr:          addr,
+			FlatNetworkIP: fmt.Sprintf("%s/%s", ip.Namespace, ip.Name),
+		})
+	}
+	slices.SortFunc(result, func(a, b flv1.OutOfRangeIP) int {
+		return strings.Compare(a.FlatNetworkIP, b.FlatNetworkIP)
+	})
+	return result
+}
+
+// getSubnetPoolSet returns the addresses available in the subnet CIDR and
+// inside the subnet ranges (if specified), see IsIPInSubnetRange.
+func getSubnetPoolSet(subnet *flv1.FlatNetworkSubnet) *ipcalc.IPSet {
+	_, network, err := net.ParseCIDR(subnet.Spec.CIDR)
+	if err != nil {
+		return ipcalc.NewIPSet(nil)
+	}
+	from := slices.Clone(network.IP.Mask(network.Mask))
+	to := slices.Clone(from)
+	mask := ipcalc.MaskXOR(network.Mask)
+	for i := 0; i < len(to) && i < len(mask); i++ {
+		to[i] |= mask[i]
+	}
+	// Exclude the network and broadcast addresses.
+	ipcalc.IPIncrease(from)
+	ipcalc.IPDecrease(to)
+	pool := ipcalc.NewIPSet([]flv1.IPRange{{From: from, To: to}})
+	if len(subnet.Spec.Ranges) == 0 {
+		return pool
+	}
+	// The intersection of the network and the ranges.
+	return pool.Difference(pool.Difference(ipcalc.NewIPSet(subnet.Spec.Ranges)))
+}
+
+// CheckSubnetResize ensures the changes of the subnet CIDR and ranges do not
+// strand the allocated flat-network IPs, the gateway and the reserved IPs of
+// the FlatNetworkIPReservations and workloads, the addresses already outside
+// of the old subnet CIDR or ranges are ignored.
+// The subnet should be the subnet with the allocation status aggregated from
+// the IP blocks and the FlatNetworkIPReservations.
+func CheckSubnetResize(
+	old, subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) error {
+	if old == nil {
+		return nil
+	}
+	if old.Spec.CIDR == subnet.Spec.CIDR &&
+		slices.EqualFunc(old.Spec.Ranges, subnet.Spec.Ranges, func(a, b flv1.IPRange) bool {
+			return a.From.Equal(b.From) && a.To.Equal(b.To)
+		}) {
+		return nil
+	}
+	var stranded []string
+	for _, r := range GetSubnetOutOfRangeIPs(subnet, ips) {
+		if !IsIPInSubnetRange(r.Addr, old) {
+			continue
+		}
+		stranded = append(stranded, fmt.Sprintf("%v(%v)", r.FlatNetworkIP, r.Addr))
+	}
+
+	// The gateway is not required to be inside the subnet ranges.
+	gateway := subnet.Spec.Gateway
+	if len(gateway) == 0 {
+		gateway = subnet.Status.Gateway
+	}
+	_, oldNetwork, err1 := net.ParseCIDR(old.Spec.CIDR)
+	_, network, err2 := net.ParseCIDR(subnet.Spec.CIDR)
+	if len(gateway) != 0 && err1 == nil && err2 == nil &&
+		oldNetwork.Contains(gateway) && !network.Contains(gateway) {
+		stranded = append(stranded, fmt.Sprintf("gateway(%v)", gateway))
+	}
+
+	// The reserved IPs inside the old pool but outside the new pool.
+	removed := getSubnetPoolSet(old).Difference(getSubnetPoolSet(subnet))
+	keys := slices.Sorted(maps.Keys(subnet.Status.ReservedIP))
+	for _, k := range keys {
+		reserved := ipcalc.NewIPSet(subnet.Status.ReservedIP[k])
+		for _, r := range reserved.Difference(reserved.Difference(removed)).Ranges() {
+			stranded = append(stranded, fmt.Sprintf("%v(%v)", k, r.String()))
+		}
+	}
+	if len(stranded) == 0 {
+		return nil
+	}
+	message := strings.Join(stranded, ", ")
+	if len(stranded) > maxStrandedIPsMessage {
+		message = fmt.Sprintf("%v and %d more",
+			strings.Join(stranded[:maxStrandedIPsMessage], ", "),
+			len(stranded)-maxStrandedIPsMessage)
+	}
+	return fmt.Errorf("subnet [%v] CIDR [%v] ranges change would strand the allocated IPs: [%v]",
+		subnet.Name, subnet.Spec.CIDR, message)
+}
@@ -147,16 +147,25 @@ func (h *handler) syncDiscoveryV1EndpointSlice(
 			sort.Slice(ports, func(i, j int) bool {
 				return strings.Compare(*ports[i].Name, *ports[j].Name) > 0
 			})
-			sort.Slice(resource.Endpoints, func(i, j int) bool {
+			// Only the endpoints matching the address type are added to the
+			// EndpointSlice for the dual-stack service.
+			resourceEndpoints := resource.GetEndpointsByAddressType(endpointSlice.AddressType)
+			if len(resourceEndpoints) == 0 && len(resource.Endpoints) != 0 {
+				logrus.WithFields(fieldsEPS(epSlice)).
+					Debugf("skip to update endpointSlice [%v]: no %v address found",
+						endpointSlice.Name, endpointSlice.AddressType)
+				return nil
+			}
+			sort.Slice(resourceEndpoints, func(i, j int) bool {
 				return strings.Compare(
-					resource.Endpoints[i].TargetRef.Name, resource.Endpoints[j].TargetRef.Name) > 0
+					resourceEndpoints[i].TargetRef.Name, resourceEndpoints[j].TargetRef.Name) > 0
 			})
 			sort.Slice(resource.EndpointPorts, func(i, j int) bool {
 				return strings.Compare(
 					*resource.EndpointPorts[i].Name, *resource.EndpointPorts[j].Name) > 0
 			})
-			if len(endpoints) == len(resource.Endpoints) &&
-				apiequality.Semantic.DeepDerivative(resource.Endpoints, endpoints) &&
+			if len(endpoints) == len(resourceEndpoints) &&
+				apiequality.Semantic.DeepDerivative(resourceEndpoints, endpoints) &&
 				apiequality.Semantic.DeepDerivative(resource.EndpointPorts, ports) {
 				logrus.WithFields(fieldsEPS(epSlice)).
 					Debugf("discoveryv1.EndpointSlice [%v] already updated, skip",
@@ -164,20 +173,8 @@ func (h *handler) syncDiscoveryV1EndpointSlice(
 				return nil
 			}
 			endpointSlice.Labels[discoveryv1.LabelManagedBy] = "rancher-flat-network-controller"
-			endpointSlice.Endpoints = resource.Endpoints
+			endpointSlice.Endpoints = resourceEndpoints
 			endpointSlice.Ports = resource.EndpointPorts
-			addressType, err := resource.GetEndpointSliceAddressType()
-			if err != nil {
-				logrus.WithFields(fieldsEPS(epSlice)).
-					Errorf("failed to update endpointSlice: %v", err)
-				return nil
-			}
-			if addressType != endpointSlice.AddressType {
-				logrus.WithFields(fieldsEPS(epSlice)).
-					Infof("skip to update endpointSlice [%v]: address type changed to IPv6, skip",
-						endpointSlice.Name)
-				return nil
-			}
 			endpointSlice, err = h.endpointSliceClient.Update(endpointSlice)
 			if err != nil {
 				return fmt.Errorf("failed to update endpointSlice: %w", err)
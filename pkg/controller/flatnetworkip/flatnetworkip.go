@@ -2,10 +2,12 @@ package flatnetworkip
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"slices"
+	"net"
 	"time"
 
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/wrangler"
 	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
@@ -18,6 +20,7 @@ import (
 	"k8s.io/client-go/util/retry"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	appscontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/apps/v1"
 	corecontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/core/v1"
 	flcontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/flatnetwork.pandaria.io/v1"
 )
@@ -27,6 +30,7 @@ const (
 	handlerRemoveName = "rancher-flat-network-ip-remove"
 
 	eventFlatNetworkIPError = "FlatNetworkIPError"
+	eventSubnetExhausted    = "SubnetExhausted"
 )
 
 const (
@@ -43,6 +47,14 @@ type handler struct {
 	subnetCache  flcontroller.FlatNetworkSubnetCache
 	podClient    corecontroller.PodClient
 	podCache     corecontroller.PodCache
+	nsCache      corecontroller.NamespaceCache
+
+	subnetPoolCache flcontroller.FlatNetworkSubnetPoolCache
+	replicaSetCache appscontroller.ReplicaSetCache
+	ipBlockClient   flcontroller.FlatNetworkIPBlockClient
+	ipBlockCache    flcontroller.FlatNetworkIPBlockCache
+
+	reservationCache flcontroller.FlatNetworkIPReservationCache
 
 	recorder record.EventRecorder
 
@@ -67,6 +79,14 @@ func Register(
 		subnetCache:  wctx.FlatNetwork.FlatNetworkSubnet().Cache(),
 		podClient:    wctx.Core.Pod(),
 		podCache:     wctx.Core.Pod().Cache(),
+		nsCache:      wctx.Core.Namespace().Cache(),
+
+		subnetPoolCache: wctx.FlatNetwork.FlatNetworkSubnetPool().Cache(),
+		replicaSetCache: wctx.Apps.ReplicaSet().Cache(),
+		ipBlockClient:   wctx.FlatNetwork.FlatNetworkIPBlock(),
+		ipBlockCache:    wctx.FlatNetwork.FlatNetworkIPBlock().Cache(),
+
+		reservationCache: wctx.FlatNetwork.FlatNetworkIPReservation().Cache(),
 
 		recorder: wctx.Recorder,
 
@@ -106,6 +126,11 @@ func (h *handler) handleError(
 		if ip.Status.FailureMessage == message {
 			return ip, err
 		}
+		if message == "" && ip.Status.Phase == flatNetworkIPFailedPhase &&
+			ip.Status.Remediation != "" {
+			// Keep the failure message of the remediation.
+			return ip, err
+		}
 
 		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			ip, err := h.ipCache.Get(ip.Namespace, ip.Name)
@@ -115,6 +140,10 @@ func (h *handler) handleError(
 			ip = ip.DeepCopy()
 			if message != "" {
 				ip.Status.Phase = flatNetworkIPFailedPhase
+				if len(ip.Status.Addr) == 0 {
+					common.SetIPCondition(ip, flv1.IPConditionAllocated,
+						metav1.ConditionFalse, "AllocateFailed", message)
+				}
 			}
 			ip.Status.FailureMessage = message
 
@@ -139,6 +168,13 @@ func (h *handler) handleIP(_ string, ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkI
 		return h.onIPUpdate(ip)
 	case flatNetworkIPPendingPhase:
 		return h.onIPPending(ip)
+	case flatNetworkIPFailedPhase:
+		if ip.Status.Remediation != "" {
+			// The IP is marked as Failed by the remediation policies,
+			// waiting for the CNI setup or the pod deleted.
+			return ip, nil
+		}
+		return h.onIPCreate(ip)
 	default:
 		return h.onIPCreate(ip)
 	}
@@ -146,13 +182,14 @@ func (h *handler) handleIP(_ string, ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkI
 
 func (h *handler) onIPCreate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error) {
 	// Ensure the pod exists.
-	pod, err := h.podCache.Get(ip.Namespace, ip.Name)
+	podName := common.GetFlatNetworkIPPodName(ip)
+	pod, err := h.podCache.Get(ip.Namespace, podName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return ip, h.ipClient.Delete(ip.Namespace, ip.Name, &metav1.DeleteOptions{})
 		}
 		return ip, fmt.Errorf("onIPCreate: failed to get pod [%v/%v]: %w",
-			ip.Namespace, ip.Name, err)
+			ip.Namespace, podName, err)
 	}
 	if pod.UID != types.UID(ip.Spec.PodID) {
 		logrus.WithFields(fieldsIP(ip)).
@@ -160,7 +197,21 @@ func (h *handler) onIPCreate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 				ip.Spec.PodID, pod.UID)
 	}
 
-	unlock := wrangler.IPAllocateLock(ip.Spec.Subnet)
+	if ip.Spec.SubnetPool != "" && len(ip.Status.Addr) == 0 {
+		// Select the subnet from the subnet pool before allocating the
+		// address, the IP is re-synced after the subnet updated.
+		subnet, err := h.selectPoolSubnet(ip)
+		if err != nil {
+			err = fmt.Errorf("onIPCreate: %w", err)
+			h.eventFlatNetworkIPError(pod, err)
+			return ip, err
+		}
+		if subnet.Name != ip.Spec.Subnet {
+			return h.updateIPPoolSubnet(ip, subnet)
+		}
+	}
+
+	unlock := wrangler.IPAllocateLocks(ip.Spec.Subnet, ip.Spec.SecondarySubnet)
 	defer unlock()
 
 	// Ensure the flat-network subnet resource exists.
@@ -171,64 +222,101 @@ func (h *handler) onIPCreate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 		h.eventFlatNetworkIPError(pod, err)
 		return ip, err
 	}
-	switch subnet.Status.Phase {
-	case "Active":
-	default:
-		// Do not allocate IP if subnet is not active
-		logrus.Infof("waiting for subnet %q status %q",
-			subnet.Name, subnet.Status.Phase)
-		h.ipEnqueueAfter(ip.Namespace, ip.Name, time.Second*5)
-		return ip, nil
+	var secondarySubnet *flv1.FlatNetworkSubnet
+	if ip.Spec.SecondarySubnet != "" {
+		secondarySubnet, err = h.subnetCache.Get(flv1.SubnetNamespace, ip.Spec.SecondarySubnet)
+		if err != nil {
+			err = fmt.Errorf("onIPCreate: failed to get secondary subnet [%v] of ip [%v/%v]: %w",
+				ip.Spec.SecondarySubnet, ip.Namespace, ip.Name, err)
+			h.eventFlatNetworkIPError(pod, err)
+			return ip, err
+		}
 	}
-
-	allocatedIP, err := allocateIP(ip, subnet)
-	if err != nil {
-		logrus.WithFields(fieldsIP(ip)).
-			Errorf("failed to allocate IP address: %v", err)
-		h.eventFlatNetworkIPError(pod, err)
-		return ip, err
+	for _, s := range []*flv1.FlatNetworkSubnet{subnet, secondarySubnet} {
+		if s == nil {
+			continue
+		}
+		if s.DeletionTimestamp != nil {
+			// Do not allocate IP if subnet is being deleted
+			err = fmt.Errorf("onIPCreate: subnet [%v] is being deleted", s.Name)
+			h.eventFlatNetworkIPError(pod, err)
+			return ip, err
+		}
+		switch s.Status.Phase {
+		case "Active":
+		default:
+			// Do not allocate IP if subnet is not active
+			logrus.Infof("waiting for subnet %q status %q",
+				s.Name, s.Status.Phase)
+			h.ipEnqueueAfter(ip.Namespace, ip.Name, time.Second*5)
+			return ip, nil
+		}
 	}
-	allocatedMAC, err := allocateMAC(ip, subnet)
-	if err != nil {
-		logrus.WithFields(fieldsIP(ip)).
-			Errorf("failed to allocate MAC address: %v", err)
+	if err := h.checkSubnetsNamespace(ip, subnet, secondarySubnet); err != nil {
+		err = fmt.Errorf("onIPCreate: %w", err)
 		h.eventFlatNetworkIPError(pod, err)
 		return ip, err
 	}
-
-	// Update subnet status.
-	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		result, err := h.subnetCache.Get(flv1.SubnetNamespace, ip.Spec.Subnet)
-		if err != nil {
-			logrus.WithFields(fieldsIP(ip)).
-				Errorf("failed to get subnet from cache: %v", err)
-			return err
+	if !alreadyAllocateIP(ip, subnet) {
+		if err := common.CheckSubnetCordoned(subnet); err != nil {
+			err = fmt.Errorf("onIPCreate: %w", err)
+			h.eventFlatNetworkIPError(pod, err)
+			return ip, err
 		}
-		result = result.DeepCopy()
-		if !ipcalc.IPInRanges(allocatedIP, result.Status.UsedIP) {
-			result.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, result.Status.UsedIP)
-			result.Status.UsedIPCount++
+		if err := h.checkSubnetQuota(ip, subnet); err != nil {
+			err = fmt.Errorf("onIPCreate: %w", err)
+			h.eventFlatNetworkIPError(pod, err)
+			return ip, err
 		}
-		if allocatedMAC != "" {
-			result.Status.UsedMAC = append(result.Status.UsedMAC, allocatedMAC)
-			slices.Sort(result.Status.UsedMAC)
+	}
+	if secondarySubnet != nil && !alreadyAllocateSecondaryIP(ip, secondarySubnet) {
+		if err := common.CheckSubnetCordoned(secondarySubnet); err != nil {
+			err = fmt.Errorf("onIPCreate: %w", err)
+			h.eventFlatNetworkIPError(pod, err)
+			return ip, err
 		}
-		result, err = h.subnetClient.UpdateStatus(result)
-		if err != nil {
-			return err
+		if err := h.checkSubnetQuota(ip, secondarySubnet); err != nil {
+			err = fmt.Errorf("onIPCreate: %w", err)
+			h.eventFlatNetworkIPError(pod, err)
+			return ip, err
 		}
-		subnet = result
-		return err
-	})
+	}
+
+	// Allocate the addresses and update the IP blocks of the subnet.
+	allocatedIP, allocatedMAC, err := h.allocateSubnetAddr(
+		subnet, ip.Status.Addr, ip.Status.MAC,
+		func(a *subnetAllocation) (net.IP, error) {
+			return allocateIP(ip, a)
+		},
+		func(s *flv1.FlatNetworkSubnet, a net.IP) (string, error) {
+			return allocateMAC(ip, s, a)
+		})
 	if err != nil {
-		err = fmt.Errorf("failed to update subnet [%v] usedIP status: %w",
-			subnet.Name, err)
-		logrus.Errorf("%v", err)
+		logrus.WithFields(fieldsIP(ip)).
+			Errorf("failed to allocate IP address: %v", err)
 		h.eventFlatNetworkIPError(pod, err)
+		h.eventSubnetExhausted(subnet, ip, err)
 		return ip, err
 	}
+	var allocatedSecondaryIP net.IP
+	if secondarySubnet != nil {
+		allocatedSecondaryIP, _, err = h.allocateSubnetAddr(
+			secondarySubnet, ip.Status.SecondaryAddr, "",
+			func(a *subnetAllocation) (net.IP, error) {
+				return allocateSecondaryIP(ip, a)
+			}, nil)
+		if err != nil {
+			logrus.WithFields(fieldsIP(ip)).
+				Errorf("failed to allocate secondary IP address: %v", err)
+			h.removeSubnetUsedIP(ip, subnet, allocatedIP, allocatedMAC)
+			h.eventFlatNetworkIPError(pod, err)
+			h.eventSubnetExhausted(secondarySubnet, ip, err)
+			return ip, err
+		}
+	}
 
 	// Update IP status to pending and wait for CNI.
+	workload := h.getPodWorkload(pod)
 	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		result, err := h.ipCache.Get(ip.Namespace, ip.Name)
 		if err != nil {
@@ -238,9 +326,25 @@ func (h *handler) onIPCreate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 
 		result = result.DeepCopy()
 		result.Status.Addr = allocatedIP
+		result.Status.SecondaryAddr = allocatedSecondaryIP
 		result.Status.MAC = allocatedMAC
 		result.Status.Phase = flatNetworkIPPendingPhase
+		result.Status.Remediation = ""
 		result.Status.AllocatedTimeStamp = metav1.NewTime(time.Now().UTC())
+		result.Status.Workload = workload
+		result.Status.Allocations++
+		common.SetIPCondition(result, flv1.IPConditionAllocated, metav1.ConditionTrue,
+			flv1.IPConditionAllocated, fmt.Sprintf("allocated address from subnet [%v]",
+				result.Spec.Subnet))
+		common.SetIPCondition(result, flv1.IPConditionInterfaceConfigured, metav1.ConditionFalse,
+			"WaitingForCNI", "waiting for pod network setup by CNI")
+		common.AddIPAllocationRecord(result, flv1.IPAllocationRecord{
+			Time:          result.Status.AllocatedTimeStamp,
+			Reason:        flv1.IPConditionAllocated,
+			Addr:          allocatedIP,
+			SecondaryAddr: allocatedSecondaryIP,
+			MAC:           allocatedMAC,
+		})
 		result, err = h.ipClient.UpdateStatus(result)
 		if err != nil {
 			return err
@@ -251,21 +355,9 @@ func (h *handler) onIPCreate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 	})
 	if err != nil {
 		// Fallback subnet status.
-		subnet = subnet.DeepCopy()
-		if ipcalc.IPInRanges(allocatedIP, subnet.Status.UsedIP) {
-			subnet.Status.UsedIP = ipcalc.RemoveIPFromRange(allocatedIP, subnet.Status.UsedIP)
-			subnet.Status.UsedIPCount--
-		}
-		if len(allocatedMAC) != 0 && len(subnet.Status.UsedMAC) != 0 {
-			subnet.Status.UsedMAC = slices.DeleteFunc(subnet.Status.UsedMAC, func(s string) bool {
-				return s == allocatedMAC
-			})
-		}
-		_, err = h.subnetClient.UpdateStatus(subnet)
-		if err != nil {
-			logrus.WithFields(fieldsIP(ip)).
-				Warnf("failed to update (fallback) subnet [%v] status: %v",
-					subnet.Name, err)
+		h.removeSubnetUsedIP(ip, subnet, allocatedIP, allocatedMAC)
+		if secondarySubnet != nil {
+			h.removeSubnetUsedIP(ip, secondarySubnet, allocatedSecondaryIP, "")
 		}
 		h.eventFlatNetworkIPError(pod, err)
 		return ip, fmt.Errorf("failed to update IP [%v/%v] addr status: %w",
@@ -276,6 +368,13 @@ func (h *handler) onIPCreate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 	if macString == "" {
 		macString = flv1.AllocateModeAuto
 	}
+	if len(ip.Status.SecondaryAddr) != 0 {
+		logrus.WithFields(fieldsIP(ip)).
+			Infof("allocated IP subnet [%v, %v] MAC [%v] address [%v, %v]",
+				ip.Spec.Subnet, ip.Spec.SecondarySubnet, macString,
+				ip.Status.Addr.String(), ip.Status.SecondaryAddr.String())
+		return ip, nil
+	}
 	logrus.WithFields(fieldsIP(ip)).
 		Infof("allocated IP subnet [%v] MAC [%v] address [%v]",
 			ip.Spec.Subnet, macString, ip.Status.Addr.String())
@@ -283,16 +382,34 @@ func (h *handler) onIPCreate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 	return ip, nil
 }
 
+// removeSubnetUsedIP fallbacks the allocated IP & MAC address from
+// the IP block of the subnet.
+func (h *handler) removeSubnetUsedIP(
+	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+	allocatedIP net.IP, allocatedMAC string,
+) {
+	err := h.removeSubnetUsedAddr(subnet.Name, allocatedIP, allocatedMAC, false)
+	if err != nil {
+		logrus.WithFields(fieldsIP(ip)).
+			Warnf("failed to update (fallback) subnet [%v] IP block status: %v",
+				subnet.Name, err)
+	}
+}
+
 func (h *handler) onIPPending(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error) {
-	if ip.Status.AllocatedTimeStamp.Add(time.Minute*5).Compare(time.Now().UTC()) < 0 {
-		err := h.podClient.Delete(ip.Namespace, ip.Name, &metav1.DeleteOptions{})
+	// Use the default remediation policies if failed to get the subnet.
+	subnet, _ := h.subnetCache.Get(flv1.SubnetNamespace, ip.Spec.Subnet)
+	r := common.GetSubnetRemediation(subnet)
+	timeout := time.Duration(r.PendingTimeout) * time.Second
+	if ip.Status.AllocatedTimeStamp.Add(timeout).Compare(time.Now().UTC()) < 0 {
+		result, err := h.remediatePendingIP(ip, &r)
 		if err != nil {
-			return ip, fmt.Errorf("failed to delete pod [%v/%v]: %w",
-				ip.Namespace, ip.Name, err)
+			return ip, err
+		}
+		ip = result
+		if ip.Status.Phase == flatNetworkIPFailedPhase {
+			return ip, nil
 		}
-		logrus.WithFields(fieldsIP(ip)).
-			Warnf("timeout wait for pod network setup by CNI, delete pod %v/%v",
-				ip.Namespace, ip.Name)
 	}
 	// IP status will be updated to Active by CNI after Pod network setup
 	logrus.WithFields(fieldsIP(ip)).
@@ -302,6 +419,14 @@ func (h *handler) onIPPending(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, erro
 }
 
 func (h *handler) onIPUpdate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error) {
+	if ip.Status.Remediation == flv1.RemediationPendingTimeout {
+		// The pod network setup by CNI after the pending timeout.
+		result, err := h.setIPRemediation(ip, "", ip.Status.Phase, "")
+		if err != nil {
+			return ip, err
+		}
+		ip = result
+	}
 	// Ensure the subnet resource exists.
 	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, ip.Spec.Subnet)
 	if err != nil {
@@ -314,23 +439,52 @@ func (h *handler) onIPUpdate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 		return ip, fmt.Errorf("onIPUpdate: failed to get subnet [%v] of ip [%v/%v]: %w",
 			ip.Spec.Subnet, ip.Namespace, ip.Name, err)
 	}
+	var secondarySubnet *flv1.FlatNetworkSubnet
+	if ip.Spec.SecondarySubnet != "" {
+		secondarySubnet, err = h.subnetCache.Get(flv1.SubnetNamespace, ip.Spec.SecondarySubnet)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				logrus.WithFields(fieldsIP(ip)).
+					Warnf("delete IP as the secondary subnet %q not exists", ip.Spec.SecondarySubnet)
+				err = h.ipClient.Delete(ip.Namespace, ip.Name, &metav1.DeleteOptions{})
+				return ip, err
+			}
+			return ip, fmt.Errorf("onIPUpdate: failed to get secondary subnet [%v] of ip [%v/%v]: %w",
+				ip.Spec.SecondarySubnet, ip.Namespace, ip.Name, err)
+		}
+	}
 
 	// Ensure the pod exists and UID matches
-	pod, err := h.podCache.Get(ip.Namespace, ip.Name)
+	podName := common.GetFlatNetworkIPPodName(ip)
+	pod, err := h.podCache.Get(ip.Namespace, podName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			err = h.ipClient.Delete(ip.Namespace, ip.Name, &metav1.DeleteOptions{})
 			return ip, err
 		}
 		return ip, fmt.Errorf("onIPUpdate: failed to get pod [%v/%v] from cache: %w",
-			ip.Namespace, ip.Name, err)
+			ip.Namespace, podName, err)
 	}
 	if pod.UID != types.UID(ip.Spec.PodID) {
 		err = h.ipClient.Delete(ip.Namespace, ip.Name, &metav1.DeleteOptions{})
 		return ip, err
 	}
 
-	if alreadyAllocateIP(ip, subnet) && alreadyAllocatedMAC(ip) {
+	// Do not re-allocate the address outside of the subnet CIDR after the
+	// subnet CIDR changed, the running pod keeps using the address and it is
+	// reported in the subnet status.
+	outOfSubnet := allocatedOutOfSubnet(ip.Spec.Addrs, ip.Status.Addr, subnet)
+	secondaryOutOfSubnet := secondarySubnet != nil && allocatedOutOfSubnet(
+		common.GetSubnetFamilyIPs(ip.Spec.Addrs, secondarySubnet), ip.Status.SecondaryAddr, secondarySubnet)
+	if (outOfSubnet || alreadyAllocateIP(ip, subnet)) &&
+		(secondaryOutOfSubnet || alreadyAllocateSecondaryIP(ip, secondarySubnet)) &&
+		alreadyAllocatedMAC(ip) {
+		if outOfSubnet || secondaryOutOfSubnet {
+			logrus.WithFields(fieldsIP(ip)).
+				Warnf("allocated IP [%v, %v] outside of the subnet CIDR, skip re-allocate",
+					ip.Status.Addr, ip.Status.SecondaryAddr)
+			return ip, nil
+		}
 		logrus.WithFields(fieldsIP(ip)).
 			Debugf("IP already updated")
 		return ip, nil
@@ -361,10 +515,49 @@ func (h *handler) onIPUpdate(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkIP, error
 	return ip, nil
 }
 
+// checkSubnetsNamespace ensures the IP namespace is allowed to use the
+// flat-network subnets.
+func (h *handler) checkSubnetsNamespace(
+	ip *flv1.FlatNetworkIP, subnets ...*flv1.FlatNetworkSubnet,
+) error {
+	var ns *corev1.Namespace
+	for _, subnet := range subnets {
+		if subnet == nil {
+			continue
+		}
+		if subnet.Spec.NamespaceSelector == nil && len(subnet.Spec.Projects) == 0 {
+			continue
+		}
+		if ns == nil {
+			var err error
+			ns, err = h.nsCache.Get(ip.Namespace)
+			if err != nil {
+				return fmt.Errorf("failed to get namespace [%v]: %w", ip.Namespace, err)
+			}
+		}
+		if err := common.CheckSubnetNamespace(subnet, ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *handler) eventFlatNetworkIPError(pod *corev1.Pod, err error) {
 	h.recorder.Event(pod, corev1.EventTypeWarning, eventFlatNetworkIPError, err.Error())
 }
 
+// eventSubnetExhausted records the event on the subnet if failed to
+// allocate IP address as no available IP address in the subnet.
+func (h *handler) eventSubnetExhausted(
+	subnet *flv1.FlatNetworkSubnet, ip *flv1.FlatNetworkIP, err error,
+) {
+	if subnet == nil || !errors.Is(err, ipcalc.ErrNoAvailableIP) {
+		return
+	}
+	h.recorder.Eventf(subnet, corev1.EventTypeWarning, eventSubnetExhausted,
+		"failed to allocate IP address for [%v/%v]: %v", ip.Namespace, ip.Name, err)
+}
+
 func fieldsIP(ip *flv1.FlatNetworkIP) logrus.Fields {
 	if ip == nil {
 		return logrus.Fields{}
@@ -374,3 +567,21 @@ func fieldsIP(ip *flv1.FlatNetworkIP) logrus.Fields {
 		"IP":  fmt.Sprintf("%v/%v", ip.Namespace, ip.Name),
 	}
 }
+
+// getPodWorkload returns the workload creating the pod in 'Kind/Name'
+// format, the Deployment is returned if the pod created by ReplicaSet.
+func (h *handler) getPodWorkload(pod *corev1.Pod) string {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return ""
+	}
+	if owner.Kind == "ReplicaSet" {
+		rs, err := h.replicaSetCache.Get(pod.Namespace, owner.Name)
+		if err == nil {
+			if o := metav1.GetControllerOf(rs); o != nil {
+				owner = o
+			}
+		}
+	}
+	return fmt.Sprintf("%v/%v", owner.Kind, owner.Name)
+}
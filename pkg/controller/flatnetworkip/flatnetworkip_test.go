@@ -3,10 +3,12 @@ package flatnetworkip
 import (
 	"net"
 	"testing"
+	"time"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
 	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func Test_alreadyAllocateIP(t *testing.T) {
@@ -72,13 +74,13 @@ func Test_allocateIP(t *testing.T) {
 		},
 	}
 	// Allocate IP in auto mode
-	allocatedIP, err := allocateIP(ip, subnet)
+	allocatedIP, err := allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.Nil(t, err)
 	assert.Equal(t, allocatedIP, net.ParseIP("10.128.0.2"))
 	subnet.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, subnet.Status.UsedIP)
 
 	// Re-allocate IP in auto mode
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.Nil(t, err)
 	assert.Equal(t, allocatedIP, net.ParseIP("10.128.0.3"))
 
@@ -89,7 +91,7 @@ func Test_allocateIP(t *testing.T) {
 			To:   net.IPv4(10, 128, 1, 102),
 		},
 	}
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.Nil(t, err)
 	assert.Equal(t, allocatedIP, net.ParseIP("10.128.1.101"))
 	subnet.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, subnet.Status.UsedIP)
@@ -101,7 +103,7 @@ func Test_allocateIP(t *testing.T) {
 			To:   net.IPv4(10, 128, 1, 102),
 		},
 	}
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.Nil(t, err)
 	assert.Equal(t, allocatedIP, net.ParseIP("10.128.1.102"))
 	subnet.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, subnet.Status.UsedIP)
@@ -113,21 +115,21 @@ func Test_allocateIP(t *testing.T) {
 			To:   net.IPv4(10, 128, 1, 102),
 		},
 	}
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableIP)
 	assert.Nil(t, allocatedIP)
 
 	// Allocate IP in single specific mode
 	subnet.Spec.Ranges = nil
 	ip.Spec.Addrs = append(ip.Spec.Addrs, net.ParseIP("10.128.1.200"))
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.Nil(t, err)
 	assert.Equal(t, allocatedIP, net.ParseIP("10.128.1.200"))
 	subnet.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, subnet.Status.UsedIP)
 
 	// Re-alloc IP in single specific mode
 	subnet.Spec.Ranges = nil
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableIP)
 	assert.Nil(t, allocatedIP)
 
@@ -141,7 +143,7 @@ func Test_allocateIP(t *testing.T) {
 	ip.Spec.Addrs = []net.IP{
 		net.ParseIP("10.128.1.200"),
 	}
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableIP)
 	assert.Nil(t, allocatedIP)
 
@@ -152,19 +154,19 @@ func Test_allocateIP(t *testing.T) {
 		net.ParseIP("10.128.1.200"),
 		net.ParseIP("10.128.1.201"),
 	}
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.Nil(t, err)
 	assert.Equal(t, allocatedIP, net.ParseIP("10.128.1.200"))
 	subnet.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, subnet.Status.UsedIP)
 
 	// Re-allocate IP in multi specific mode
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.Nil(t, err)
 	assert.Equal(t, allocatedIP, net.ParseIP("10.128.1.201"))
 	subnet.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, subnet.Status.UsedIP)
 
 	// Re-allocate IP in multi specific mode, but no available IP
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableIP)
 	assert.Nil(t, allocatedIP)
 
@@ -176,11 +178,148 @@ func Test_allocateIP(t *testing.T) {
 		},
 	}
 	subnet.Status.UsedIP = nil
-	allocatedIP, err = allocateIP(ip, subnet)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
 	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableIP)
 	assert.Nil(t, allocatedIP)
 }
 
+func Test_allocateSecondaryIP(t *testing.T) {
+	ip := &flv1.FlatNetworkIP{
+		Spec: flv1.IPSpec{
+			Subnet:          "subnet-v4",
+			SecondarySubnet: "subnet-v6",
+		},
+	}
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			CIDR: "10.128.0.0/16",
+		},
+	}
+	secondarySubnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			CIDR: "fd00::/64",
+		},
+		Status: flv1.SubnetStatus{
+			UsedIP: []flv1.IPRange{
+				{
+					// Gateway IP address
+					From: net.ParseIP("fd00::1"),
+					To:   net.ParseIP("fd00::1"),
+				},
+			},
+		},
+	}
+
+	// Not using dual-stack
+	allocatedIP, err := allocateSecondaryIP(ip, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, allocatedIP)
+
+	// Allocate IP in auto mode
+	allocatedIP, err = allocateSecondaryIP(ip, newSubnetAllocation(secondarySubnet, time.Now()))
+	assert.Nil(t, err)
+	assert.Equal(t, net.ParseIP("fd00::2"), allocatedIP)
+
+	// Allocate IP in specific mode, only the IPv6 address is used
+	ip.Spec.Addrs = []net.IP{
+		net.ParseIP("10.128.0.10"),
+		net.ParseIP("fd00::10"),
+	}
+	allocatedIP, err = allocateSecondaryIP(ip, newSubnetAllocation(secondarySubnet, time.Now()))
+	assert.Nil(t, err)
+	assert.Equal(t, net.ParseIP("fd00::10"), allocatedIP)
+	allocatedIP, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
+	assert.Nil(t, err)
+	assert.Equal(t, net.ParseIP("10.128.0.10"), allocatedIP)
+
+	// Already allocated
+	ip.Status.SecondaryAddr = net.ParseIP("fd00::10")
+	assert.True(t, alreadyAllocateSecondaryIP(ip, secondarySubnet))
+	assert.False(t, alreadyAllocateSecondaryIP(ip, nil))
+
+	// No IPv4 address specified for the primary subnet
+	ip.Spec.Addrs = []net.IP{
+		net.ParseIP("fd00::10"),
+	}
+	_, err = allocateIP(ip, newSubnetAllocation(subnet, time.Now()))
+	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableIP)
+}
+
+func Test_newSubnetAllocation(t *testing.T) {
+	now := time.Now()
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			CIDR:         "10.128.0.0/30",
+			IPReuseDelay: 60,
+		},
+		Status: flv1.SubnetStatus{
+			UsedIP: []flv1.IPRange{
+				{
+					From: net.ParseIP("10.128.0.1"),
+					To:   net.ParseIP("10.128.0.1"),
+				},
+			},
+			ReleasedIP: []flv1.ReleasedIP{
+				{
+					Addr:              net.ParseIP("10.128.0.2"),
+					ReleasedTimestamp: metav1.NewTime(now.Add(-time.Second * 30)),
+				},
+			},
+		},
+	}
+	a := newSubnetAllocation(subnet, now)
+	assert.Equal(t, now.Add(time.Second*30).Unix(), a.expires.Unix())
+	assert.ErrorIs(t, a.available(), ipcalc.ErrNoAvailableIP)
+	// The released IP is not reused in auto mode until the delay expired.
+	_, err := allocateAddr(nil, a)
+	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableIP)
+	addr, err := allocateAddr([]net.IP{net.ParseIP("10.128.0.2")}, a)
+	assert.Nil(t, err)
+	assert.Equal(t, net.ParseIP("10.128.0.2"), addr)
+
+	a = newSubnetAllocation(subnet, now.Add(time.Minute))
+	assert.True(t, a.expires.IsZero())
+	assert.Nil(t, a.available())
+	// The shared allocator is not changed by the allocation.
+	for i := 0; i < 2; i++ {
+		addr, err = allocateAddr(nil, a)
+		assert.Nil(t, err)
+		assert.Equal(t, net.ParseIP("10.128.0.2"), addr)
+	}
+
+	subnet.Spec.CIDR = "invalid"
+	a = newSubnetAllocation(subnet, now)
+	assert.NotNil(t, a.available())
+	_, err = allocateAddr(nil, a)
+	assert.NotNil(t, err)
+}
+
+func Test_getSubnetAllocationKey(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{}
+	subnet.Name = "subnet"
+	subnet.ResourceVersion = "1"
+	blocks := []*flv1.FlatNetworkIPBlock{{}, {}}
+	blocks[0].Name = "subnet-10-0-0-0"
+	blocks[0].ResourceVersion = "2"
+	blocks[1].Name = "subnet-10-0-0-64"
+	blocks[1].ResourceVersion = "3"
+	key := getSubnetAllocationKey(subnet, blocks, nil)
+	assert.Equal(t, key, getSubnetAllocationKey(subnet,
+		[]*flv1.FlatNetworkIPBlock{blocks[1], blocks[0]}, nil))
+
+	updated := blocks[1].DeepCopy()
+	updated.ResourceVersion = "4"
+	assert.NotEqual(t, key, getSubnetAllocationKey(subnet,
+		[]*flv1.FlatNetworkIPBlock{blocks[0], updated}, nil))
+	reservation := &flv1.FlatNetworkIPReservation{}
+	reservation.Name = "reservation"
+	reservation.ResourceVersion = "5"
+	assert.NotEqual(t, key, getSubnetAllocationKey(subnet, blocks,
+		[]*flv1.FlatNetworkIPReservation{reservation}))
+	subnet.ResourceVersion = "6"
+	assert.NotEqual(t, key, getSubnetAllocationKey(subnet, blocks, nil))
+}
+
 func Test_alreadyAllocatedMAC(t *testing.T) {
 	ip := &flv1.FlatNetworkIP{
 		Spec: flv1.IPSpec{
@@ -192,5 +331,128 @@ func Test_alreadyAllocatedMAC(t *testing.T) {
 }
 
 func Test_allocateMAC(t *testing.T) {
+	ip := &flv1.FlatNetworkIP{}
+	subnet := &flv1.FlatNetworkSubnet{}
+	allocatedIP := net.ParseIP("192.168.1.10")
+
+	// MAC generated by kernel
+	mac, err := allocateMAC(ip, subnet, allocatedIP)
+	assert.Nil(t, err)
+	assert.Equal(t, "", mac)
+
+	// User specified MACs
+	ip.Spec.MACs = []string{"aa:bb:cc:dd:ee:01", "aa:bb:cc:dd:ee:02"}
+	subnet.Status.UsedMAC = []string{"aa:bb:cc:dd:ee:01"}
+	mac, err = allocateMAC(ip, subnet, allocatedIP)
+	assert.Nil(t, err)
+	assert.Equal(t, "aa:bb:cc:dd:ee:02", mac)
+	subnet.Status.UsedMAC = []string{"aa:bb:cc:dd:ee:01", "aa:bb:cc:dd:ee:02"}
+	_, err = allocateMAC(ip, subnet, allocatedIP)
+	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableMac)
+
+	// Random MAC within prefix
+	ip.Spec.MACs = nil
+	subnet.Spec.MACPrefix = "02:42:ac"
+	mac, err = allocateMAC(ip, subnet, allocatedIP)
+	assert.Nil(t, err)
+	assert.Regexp(t, "^02:42:ac:[0-9a-f]{2}:[0-9a-f]{2}:[0-9a-f]{2}$", mac)
+
+	// Reuse the allocated MAC
+	ip.Status.MAC = "02:42:ac:00:00:01"
+	subnet.Status.UsedMAC = []string{"02:42:ac:00:00:01"}
+	mac, err = allocateMAC(ip, subnet, allocatedIP)
+	assert.Nil(t, err)
+	assert.Equal(t, "02:42:ac:00:00:01", mac)
+
+	// MAC derived from IP
+	ip.Status.MAC = ""
+	subnet.Spec.MACAllocation = flv1.MACAllocationIP
+	mac, err = allocateMAC(ip, subnet, allocatedIP)
+	assert.Nil(t, err)
+	assert.Equal(t, "02:42:ac:a8:01:0a", mac)
+
+	// Re-derive MAC if IP changed
+	ip.Status.Addr = net.ParseIP("192.168.1.11")
+	ip.Status.MAC = "02:42:ac:a8:01:0b"
+	subnet.Status.UsedMAC = []string{"02:42:ac:a8:01:0b"}
+	mac, err = allocateMAC(ip, subnet, allocatedIP)
+	assert.Nil(t, err)
+	assert.Equal(t, "02:42:ac:a8:01:0a", mac)
+
+	subnet.Status.UsedMAC = []string{"02:42:ac:a8:01:0a", "02:42:ac:a8:01:0b"}
+	_, err = allocateMAC(ip, subnet, allocatedIP)
+	assert.ErrorIs(t, err, ipcalc.ErrNoAvailableMac)
+}
+
+func Test_allocatedOutOfSubnet(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			CIDR: "192.168.1.0/25",
+		},
+	}
+	assert.False(t, allocatedOutOfSubnet(nil, nil, subnet))
+	assert.False(t, allocatedOutOfSubnet(nil, net.ParseIP("192.168.1.10"), subnet))
+	assert.True(t, allocatedOutOfSubnet(nil, net.ParseIP("192.168.1.200"), subnet))
+	// Specific mode
+	assert.False(t, allocatedOutOfSubnet([]net.IP{net.ParseIP("192.168.1.200")},
+		net.ParseIP("192.168.1.200"), subnet))
+}
+
+func Test_addIPBlockUsedAddr(t *testing.T) {
+	block := &flv1.FlatNetworkIPBlock{
+		Status: flv1.IPBlockStatus{
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("10.0.0.1"), To: net.ParseIP("10.0.0.1")},
+			},
+			UsedIPCount: 1,
+		},
+	}
+	spec := &flv1.SubnetSpec{
+		CIDR: "10.0.0.0/24",
+	}
+	addIPBlockUsedAddr(block, net.ParseIP("10.0.0.2"), spec, time.Now())
+	assert.Equal(t, 2, block.Status.UsedIPCount)
+	assert.True(t, ipcalc.IPInRanges(net.ParseIP("10.0.0.2"), block.Status.UsedIP))
+
+	// Adding the address already used does not change the block status.
+	status := block.Status.DeepCopy()
+	addIPBlockUsedAddr(block, net.ParseIP("10.0.0.2"), spec, time.Now())
+	assert.Equal(t, *status, block.Status)
+}
+
+func Test_removeIPBlockUsedAddr(t *testing.T) {
+	block := &flv1.FlatNetworkIPBlock{
+		Status: flv1.IPBlockStatus{
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("10.0.0.1"), To: net.ParseIP("10.0.0.2")},
+			},
+			UsedIPCount: 2,
+		},
+	}
+	spec := &flv1.SubnetSpec{
+		CIDR:         "10.0.0.0/24",
+		IPReuseDelay: 60,
+	}
+	removeIPBlockUsedAddr(block, net.ParseIP("10.0.0.2"), spec, time.Now())
+	assert.Equal(t, 1, block.Status.UsedIPCount)
+	assert.False(t, ipcalc.IPInRanges(net.ParseIP("10.0.0.2"), block.Status.UsedIP))
+	assert.Len(t, block.Status.ReleasedIP, 1)
+
+	// Removing without the subnet spec does not record the released IP.
+	removeIPBlockUsedAddr(block, net.ParseIP("10.0.0.1"), nil, time.Now())
+	assert.Equal(t, 0, block.Status.UsedIPCount)
+	assert.Len(t, block.Status.ReleasedIP, 1)
+}
+
+func Test_addMACBlockUsedMAC(t *testing.T) {
+	block := &flv1.FlatNetworkIPBlock{}
+	addMACBlockUsedMAC(block, "02:00:00:00:00:03")
+	addMACBlockUsedMAC(block, "02:00:00:00:00:02")
+	addMACBlockUsedMAC(block, "02:00:00:00:00:03")
+	assert.Equal(t, []string{"02:00:00:00:00:02", "02:00:00:00:00:03"}, block.Status.UsedMAC)
 
+	removeMACBlockUsedMAC(block, "02:00:00:00:00:02")
+	assert.Equal(t, []string{"02:00:00:00:00:03"}, block.Status.UsedMAC)
+	removeMACBlockUsedMAC(block, "02:00:00:00:00:04")
+	assert.Equal(t, []string{"02:00:00:00:00:03"}, block.Status.UsedMAC)
 }
@@ -0,0 +1,295 @@
+package flatnetworkip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+var (
+	errIPBlockStale = errors.New("IP block in cache is out of date")
+)
+
+// subnetAllocations caches the allocation state of each subnet, so the
+// allocator is not rebuilt by each allocation & subnet pool check if the
+// subnet, IP blocks and reservations are not changed.
+var subnetAllocations = sync.Map{}
+
+// getSubnetAllocation returns the allocation state of the subnet aggregated
+// from the IP blocks and the FlatNetworkIPReservations in cache.
+func (h *handler) getSubnetAllocation(
+	subnet *flv1.FlatNetworkSubnet,
+) (*subnetAllocation, error) {
+	return h.getLatestSubnetAllocation(subnet, nil)
+}
+
+// allocateSubnetAddr allocates the address from the subnet and adds it into
+// the IP block of the address, then allocates the MAC address and adds it
+// into the MAC block of the subnet if allocateMAC is specified.
+// The IP & MAC blocks are read from the API server instead of the cache and
+// updated with their resourceVersion, the allocation is retried with the
+// latest blocks on conflict, so the same address or MAC can not be allocated
+// twice even with the stale cache or multiple operator replicas.
+// The current address & MAC of the flat-network IP are kept if still valid.
+func (h *handler) allocateSubnetAddr(
+	subnet *flv1.FlatNetworkSubnet, current net.IP, currentMAC string,
+	allocateIP func(*subnetAllocation) (net.IP, error),
+	allocateMAC func(*flv1.FlatNetworkSubnet, net.IP) (string, error),
+) (net.IP, string, error) {
+	// latest is the IP blocks read from the API server.
+	latest := map[string]*flv1.FlatNetworkIPBlock{}
+	var allocatedIP net.IP
+	err := retry.OnError(retry.DefaultBackoff, isIPBlockConflict, func() error {
+		s, err := h.getLatestSubnetAllocation(subnet, latest)
+		if err != nil {
+			return err
+		}
+		a, err := allocateIP(s)
+		if err != nil {
+			return err
+		}
+		block, err := h.getOrCreateIPBlock(common.GetIPBlockName(subnet.Name, a),
+			func() *flv1.FlatNetworkIPBlock { return common.NewIPBlock(subnet, a) })
+		if err != nil {
+			return err
+		}
+		latest[block.Name] = block
+		if !a.Equal(current) && ipcalc.IPInRanges(a, block.Status.UsedIP) {
+			// Retry with the latest IP block.
+			return fmt.Errorf("address [%v] already used in IP block [%v]: %w",
+				a, block.Name, errIPBlockStale)
+		}
+		updated := block.DeepCopy()
+		addIPBlockUsedAddr(updated, a, &subnet.Spec, time.Now())
+		if !equality.Semantic.DeepEqual(updated.Status, block.Status) {
+			result, err := h.ipBlockClient.UpdateStatus(updated)
+			if err != nil {
+				return err
+			}
+			latest[block.Name] = result
+		}
+		allocatedIP = a
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if allocateMAC == nil {
+		return allocatedIP, "", nil
+	}
+
+	var allocatedMAC string
+	err = retry.OnError(retry.DefaultBackoff, isIPBlockConflict, func() error {
+		s, err := h.getLatestSubnetAllocation(subnet, latest)
+		if err != nil {
+			return err
+		}
+		m, err := allocateMAC(s.subnet, allocatedIP)
+		if err != nil || m == "" {
+			allocatedMAC = m
+			return err
+		}
+		block, err := h.getOrCreateIPBlock(common.GetMACBlockName(subnet.Name),
+			func() *flv1.FlatNetworkIPBlock { return common.NewMACBlock(subnet) })
+		if err != nil {
+			return err
+		}
+		latest[block.Name] = block
+		if m != currentMAC && slices.Contains(block.Status.UsedMAC, m) {
+			// Retry with the latest MAC block.
+			return fmt.Errorf("MAC [%v] already used in MAC block [%v]: %w",
+				m, block.Name, errIPBlockStale)
+		}
+		updated := block.DeepCopy()
+		addMACBlockUsedMAC(updated, m)
+		if !equality.Semantic.DeepEqual(updated.Status, block.Status) {
+			result, err := h.ipBlockClient.UpdateStatus(updated)
+			if err != nil {
+				return err
+			}
+			latest[block.Name] = result
+		}
+		allocatedMAC = m
+		return nil
+	})
+	if err != nil {
+		if !allocatedIP.Equal(current) {
+			// Fallback the address allocated above.
+			if err := h.removeSubnetUsedAddr(subnet.Name, allocatedIP, "", false); err != nil {
+				logrus.Warnf("failed to fallback address [%v] of subnet [%v]: %v",
+					allocatedIP, subnet.Name, err)
+			}
+		}
+		return nil, "", err
+	}
+	return allocatedIP, allocatedMAC, nil
+}
+
+// getLatestSubnetAllocation returns the allocation state of the subnet
+// aggregated from the IP blocks and the FlatNetworkIPReservations in cache,
+// the IP blocks in latest read from the API server are used instead of the
+// ones in cache.
+// The cached allocation state is returned if the subnet, IP blocks and
+// reservations are not changed.
+func (h *handler) getLatestSubnetAllocation(
+	subnet *flv1.FlatNetworkSubnet, latest map[string]*flv1.FlatNetworkIPBlock,
+) (*subnetAllocation, error) {
+	blocks, err := h.ipBlockCache.List(flv1.SubnetNamespace, labels.SelectorFromSet(labels.Set{
+		common.LabelIPBlockSubnet: subnet.Name,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP blocks from cache: %w", err)
+	}
+	blocks = slices.DeleteFunc(slices.Clone(blocks), func(b *flv1.FlatNetworkIPBlock) bool {
+		return latest[b.Name] != nil
+	})
+	for _, b := range latest {
+		blocks = append(blocks, b)
+	}
+	reservations, err := h.reservationCache.List("", labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP reservations from cache: %w", err)
+	}
+	reservations = slices.DeleteFunc(slices.Clone(reservations), func(r *flv1.FlatNetworkIPReservation) bool {
+		return r.Spec.Subnet != subnet.Name
+	})
+
+	now := time.Now()
+	key := getSubnetAllocationKey(subnet, blocks, reservations)
+	if v, ok := subnetAllocations.Load(subnet.Name); ok {
+		a := v.(*subnetAllocation)
+		if a.key == key && (a.expires.IsZero() || now.Before(a.expires)) {
+			return a, nil
+		}
+	}
+	result := common.MergeSubnetIPBlocks(subnet, blocks)
+	common.SetSubnetIPReservations(result,
+		common.GetSubnetIPReservations(subnet, reservations, now))
+	a := newSubnetAllocation(result, now)
+	a.key = key
+	for _, r := range reservations {
+		if r.Spec.ExpireTime == nil || !now.Before(r.Spec.ExpireTime.Time) {
+			continue
+		}
+		if a.expires.IsZero() || r.Spec.ExpireTime.Time.Before(a.expires) {
+			a.expires = r.Spec.ExpireTime.Time
+		}
+	}
+	subnetAllocations.Store(subnet.Name, a)
+	return a, nil
+}
+
+// getSubnetAllocationKey returns the resourceVersions of the subnet, IP
+// blocks and reservations, which changes once any of them changed.
+func getSubnetAllocationKey(
+	subnet *flv1.FlatNetworkSubnet,
+	blocks []*flv1.FlatNetworkIPBlock,
+	reservations []*flv1.FlatNetworkIPReservation,
+) string {
+	keys := make([]string, 0, len(blocks)+len(reservations))
+	for _, b := range blocks {
+		keys = append(keys, fmt.Sprintf("%v/%v", b.Name, b.ResourceVersion))
+	}
+	for _, r := range reservations {
+		keys = append(keys, fmt.Sprintf("%v/%v/%v", r.Namespace, r.Name, r.ResourceVersion))
+	}
+	slices.Sort(keys)
+	return fmt.Sprintf("%v/%v,%v", subnet.UID, subnet.ResourceVersion, strings.Join(keys, ","))
+}
+
+// getOrCreateIPBlock gets the IP block by name from the API server, the IP
+// block returned by newBlock is created if not exists.
+func (h *handler) getOrCreateIPBlock(
+	name string, newBlock func() *flv1.FlatNetworkIPBlock,
+) (*flv1.FlatNetworkIPBlock, error) {
+	block, err := h.ipBlockClient.Get(flv1.SubnetNamespace, name, metav1.GetOptions{})
+	if err == nil {
+		return block, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get IP block [%v]: %w", name, err)
+	}
+	b := newBlock()
+	block, err = h.ipBlockClient.Create(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IP block [%v]: %w", name, err)
+	}
+	logrus.Infof("create IP block [%v] of subnet [%v]", name, b.Spec.Subnet)
+	return block, nil
+}
+
+// removeSubnetUsedAddr removes the IP address from the IP block of the
+// address and the MAC address from the MAC block of the subnet, the released
+// IP is recorded if release is true.
+func (h *handler) removeSubnetUsedAddr(
+	subnetName string, addr net.IP, mac string, release bool,
+) error {
+	if len(addr) == 0 {
+		return nil
+	}
+	name := common.GetIPBlockName(subnetName, addr)
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		block, err := h.ipBlockClient.Get(flv1.SubnetNamespace, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				// The IP block or the subnet is deleted, return directly.
+				return nil
+			}
+			return fmt.Errorf("failed to get IP block [%v]: %w", name, err)
+		}
+		var spec *flv1.SubnetSpec
+		if release {
+			subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, subnetName)
+			if err == nil {
+				spec = &subnet.Spec
+			}
+		}
+		updated := block.DeepCopy()
+		removeIPBlockUsedAddr(updated, addr, spec, time.Now())
+		if equality.Semantic.DeepEqual(updated.Status, block.Status) {
+			return nil
+		}
+		_, err = h.ipBlockClient.UpdateStatus(updated)
+		return err
+	})
+	if err != nil || mac == "" {
+		return err
+	}
+	name = common.GetMACBlockName(subnetName)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		block, err := h.ipBlockClient.Get(flv1.SubnetNamespace, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to get MAC block [%v]: %w", name, err)
+		}
+		updated := block.DeepCopy()
+		removeMACBlockUsedMAC(updated, mac)
+		if equality.Semantic.DeepEqual(updated.Status, block.Status) {
+			return nil
+		}
+		_, err = h.ipBlockClient.UpdateStatus(updated)
+		return err
+	})
+}
+
+func isIPBlockConflict(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) ||
+		apierrors.IsNotFound(err) || errors.Is(err, errIPBlockStale)
+}
@@ -3,8 +3,11 @@ package flatnetworkip
 import (
 	"fmt"
 	"net"
+	"slices"
+	"time"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
 )
 
@@ -13,11 +16,29 @@ import (
 func alreadyAllocateIP(
 	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
 ) bool {
-	if len(ip.Status.Addr) == 0 {
+	return alreadyAllocatedAddr(ip.Spec.Addrs, ip.Status.Addr, subnet)
+}
+
+// alreadyAllocateSecondaryIP checks if the flat-network IP already allocated
+// the expected IP address of the dual-stack secondary subnet.
+func alreadyAllocateSecondaryIP(
+	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+) bool {
+	if subnet == nil {
+		return len(ip.Status.SecondaryAddr) == 0
+	}
+	return alreadyAllocatedAddr(
+		common.GetSubnetFamilyIPs(ip.Spec.Addrs, subnet), ip.Status.SecondaryAddr, subnet)
+}
+
+func alreadyAllocatedAddr(
+	addrs []net.IP, allocated net.IP, subnet *flv1.FlatNetworkSubnet,
+) bool {
+	if len(allocated) == 0 {
 		return false
 	}
 
-	switch len(ip.Spec.Addrs) {
+	switch len(addrs) {
 	case 0:
 		// Auto mode
 		// Check if the IP address inside the subnet network.
@@ -25,15 +46,15 @@ func alreadyAllocateIP(
 		if err != nil {
 			return false
 		}
-		return network.Contains(ip.Status.Addr)
+		return network.Contains(allocated)
 	default:
 		// Specific mode.
-		for _, addr := range ip.Spec.Addrs {
+		for _, addr := range addrs {
 			a := addr.To16()
 			if a == nil {
 				continue
 			}
-			if a.Equal(ip.Status.Addr) {
+			if a.Equal(allocated) {
 				return true
 			}
 		}
@@ -41,36 +62,196 @@ func alreadyAllocateIP(
 	}
 }
 
+// allocatedOutOfSubnet returns true if the address allocated in auto mode is
+// outside of the subnet CIDR, which means the subnet CIDR was changed after
+// the address allocated.
+func allocatedOutOfSubnet(
+	addrs []net.IP, allocated net.IP, subnet *flv1.FlatNetworkSubnet,
+) bool {
+	if len(addrs) != 0 || len(allocated) == 0 {
+		return false
+	}
+	_, network, err := net.ParseCIDR(subnet.Spec.CIDR)
+	if err != nil {
+		return false
+	}
+	return !network.Contains(allocated)
+}
+
+// allocateIP allocates the IP address from the primary subnet.
 func allocateIP(
-	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+	ip *flv1.FlatNetworkIP, a *subnetAllocation,
 ) (net.IP, error) {
-	if alreadyAllocateIP(ip, subnet) {
+	if alreadyAllocateIP(ip, a.subnet) {
 		return ip.Status.Addr, nil
 	}
+	addrs := ip.Spec.Addrs
+	if ip.Spec.SecondarySubnet != "" && len(addrs) != 0 {
+		// Dual-stack, only use the addresses in the primary subnet IP family.
+		addrs = common.GetSubnetFamilyIPs(addrs, a.subnet)
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("allocateIP: no IP address of subnet [%v] in addrs %v: %w",
+				a.subnet.Name, ip.Spec.Addrs, ipcalc.ErrNoAvailableIP)
+		}
+	}
+	return allocateAddr(addrs, a)
+}
+
+// allocateSecondaryIP allocates the IP address from the dual-stack
+// secondary subnet.
+// The address is allocated in auto mode if no IP address in the secondary
+// subnet IP family specified.
+func allocateSecondaryIP(
+	ip *flv1.FlatNetworkIP, a *subnetAllocation,
+) (net.IP, error) {
+	if a == nil {
+		return nil, nil
+	}
+	if alreadyAllocateSecondaryIP(ip, a.subnet) {
+		return ip.Status.SecondaryAddr, nil
+	}
+	return allocateAddr(common.GetSubnetFamilyIPs(ip.Spec.Addrs, a.subnet), a)
+}
+
+// subnetAllocation is the allocation state of the subnet aggregated from
+// the IP blocks and the FlatNetworkIPReservations.
+// The used IP set and the allocator are built once and read-only after
+// built, so the subnetAllocation can be cached and shared by allocations
+// until the subnet, IP blocks or reservations changed.
+type subnetAllocation struct {
+	// subnet is the subnet with the aggregated allocation status.
+	subnet *flv1.FlatNetworkSubnet
+	// used is the used and reserved IP addresses of the subnet.
+	used *ipcalc.IPSet
+	// allocator is the auto mode allocator, the released IPs are also
+	// used until the reuse delay expired.
+	allocator *ipcalc.Allocator
+	err       error
+
+	// key is the resourceVersions of the subnet, IP blocks & reservations
+	// the allocation aggregated from.
+	key string
+	// expires is the time the earliest released IP or reservation expires,
+	// the allocation is rebuilt after that.
+	expires time.Time
+}
+
+// newSubnetAllocation builds the allocation state of the subnet with the
+// aggregated allocation status.
+func newSubnetAllocation(subnet *flv1.FlatNetworkSubnet, now time.Time) *subnetAllocation {
+	used := append(slices.Clone(subnet.Status.UsedIP), common.GetSubnetReservationRanges(subnet)...)
+	a := &subnetAllocation{
+		subnet: subnet,
+		used:   ipcalc.NewIPSet(used),
+	}
+	// The released IPs are not reused in auto mode until the reuse delay
+	// expired.
+	released := ipcalc.PruneReleasedIP(
+		subnet.Status.ReleasedIP, subnet.Spec.IPReuseDelay, now)
+	for _, r := range released {
+		used = append(used, flv1.IPRange{From: r.Addr, To: r.Addr})
+		t := r.ReleasedTimestamp.Add(time.Duration(subnet.Spec.IPReuseDelay) * time.Second)
+		if a.expires.IsZero() || t.Before(a.expires) {
+			a.expires = t
+		}
+	}
+	a.allocator, a.err = ipcalc.NewAllocator(subnet.Spec.CIDR, subnet.Spec.Ranges, used)
+	return a
+}
 
-	switch len(ip.Spec.Addrs) {
+// available returns error if no IP address can be allocated from the subnet
+// in auto mode.
+func (a *subnetAllocation) available() error {
+	if a.err != nil {
+		return fmt.Errorf("allocateIP: %w", a.err)
+	}
+	if a.allocator.Free().Sign() <= 0 {
+		return ipcalc.ErrNoAvailableIP
+	}
+	return nil
+}
+
+func allocateAddr(
+	addrs []net.IP, a *subnetAllocation,
+) (net.IP, error) {
+	switch len(addrs) {
 	case 0:
 		// Auto mode.
-		a, err := ipcalc.GetAvailableIP(subnet.Spec.CIDR, subnet.Spec.Ranges, subnet.Status.UsedIP)
+		if a.err != nil {
+			return nil, fmt.Errorf("allocateIP: %w", a.err)
+		}
+		strategy, err := ipcalc.GetStrategy(a.subnet.Spec.AllocationStrategy)
+		if err != nil {
+			return nil, fmt.Errorf("allocateIP: %w", err)
+		}
+		// Allocate from the copy as the allocator is shared.
+		ip, err := strategy.Allocate(a.allocator.Clone(), a.subnet.Status.ReleasedIP)
 		if err != nil {
 			return nil, err
 		}
-		return a, nil
+		return ip, nil
 	default:
 		// Use custom IP from addresses.
-		for _, v := range ip.Spec.Addrs {
-			a := v.To16()
-			if len(a) == 0 {
+		// The user specified IPs are not affected by the reuse delay.
+		for _, v := range addrs {
+			ip := v.To16()
+			if len(ip) == 0 {
 				return nil, fmt.Errorf("allocateIP: invalid IP [%v] in addrs", v)
 			}
-			if len(subnet.Spec.Ranges) != 0 && !ipcalc.IPInRanges(a, subnet.Spec.Ranges) {
+			if len(a.subnet.Spec.Ranges) != 0 && !ipcalc.IPInRanges(ip, a.subnet.Spec.Ranges) {
 				continue
 			}
-			if !ipcalc.IPInRanges(a, subnet.Status.UsedIP) {
-				return a, nil
+			if !a.used.Contains(ip) {
+				return ip, nil
 			}
 		}
 		return nil, fmt.Errorf("allocateIP: no available IP address from addrs %v: %w",
-			ip.Spec.Addrs, ipcalc.ErrNoAvailableIP)
+			addrs, ipcalc.ErrNoAvailableIP)
+	}
+}
+
+// addIPBlockUsedAddr adds the allocated IP address into the IP block status.
+func addIPBlockUsedAddr(
+	block *flv1.FlatNetworkIPBlock, allocatedIP net.IP,
+	spec *flv1.SubnetSpec, now time.Time,
+) {
+	if !ipcalc.IPInRanges(allocatedIP, block.Status.UsedIP) {
+		block.Status.UsedIP = ipcalc.AddIPToRange(allocatedIP, block.Status.UsedIP)
+		block.Status.UsedIPCount++
+	}
+	block.Status.ReleasedIP = ipcalc.RemoveReleasedIP(ipcalc.TrimReleasedIP(
+		block.Status.ReleasedIP, spec, now), allocatedIP)
+}
+
+// removeIPBlockUsedAddr removes the IP address from the IP block status,
+// the released IP is recorded if the subnet spec is specified.
+func removeIPBlockUsedAddr(
+	block *flv1.FlatNetworkIPBlock, addr net.IP,
+	spec *flv1.SubnetSpec, now time.Time,
+) {
+	if !ipcalc.IPInRanges(addr, block.Status.UsedIP) {
+		return
 	}
+	block.Status.UsedIP = ipcalc.RemoveIPFromRange(addr, block.Status.UsedIP)
+	block.Status.UsedIPCount--
+	if spec != nil {
+		block.Status.ReleasedIP = ipcalc.RecordReleasedIP(
+			block.Status.ReleasedIP, addr, spec, now)
+	}
+}
+
+// addMACBlockUsedMAC adds the allocated MAC address into the MAC block status.
+func addMACBlockUsedMAC(block *flv1.FlatNetworkIPBlock, mac string) {
+	if _, ok := slices.BinarySearch(block.Status.UsedMAC, mac); ok {
+		return
+	}
+	block.Status.UsedMAC = append(block.Status.UsedMAC, mac)
+	slices.Sort(block.Status.UsedMAC)
+}
+
+// removeMACBlockUsedMAC removes the MAC address from the MAC block status.
+func removeMACBlockUsedMAC(block *flv1.FlatNetworkIPBlock, mac string) {
+	block.Status.UsedMAC = slices.DeleteFunc(block.Status.UsedMAC, func(m string) bool {
+		return m == mac
+	})
 }
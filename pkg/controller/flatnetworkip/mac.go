@@ -2,6 +2,7 @@ package flatnetworkip
 
 import (
 	"fmt"
+	"net"
 	"slices"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
@@ -28,12 +29,14 @@ func alreadyAllocatedMAC(ip *flv1.FlatNetworkIP) bool {
 	return false
 }
 
+// allocateMAC allocates the MAC address of the IP from the user specified
+// MAC addresses, or within the subnet MAC prefix if user does not specify
+// custom MAC address.
 func allocateMAC(
-	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet, allocatedIP net.IP,
 ) (string, error) {
 	if len(ip.Spec.MACs) == 0 {
-		// User does not specify custom MAC address, return directly.
-		return "", nil
+		return allocatePrefixMAC(ip, subnet, allocatedIP)
 	}
 	if alreadyAllocatedMAC(ip) {
 		return ip.Status.MAC, nil
@@ -50,3 +53,40 @@ func allocateMAC(
 	return "", fmt.Errorf("allocateMAC: no available MAC address from MACs %v: %w",
 		ip.Spec.MACs, ipcalc.ErrNoAvailableMac)
 }
+
+// allocatePrefixMAC allocates the MAC address within the subnet MAC prefix,
+// returns empty string if the subnet MAC prefix is not specified and the MAC
+// address will be generated by the kernel.
+func allocatePrefixMAC(
+	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet, allocatedIP net.IP,
+) (string, error) {
+	if subnet.Spec.MACPrefix == "" {
+		return "", nil
+	}
+	prefix, err := ipcalc.ParseMACPrefix(subnet.Spec.MACPrefix)
+	if err != nil {
+		return "", fmt.Errorf("allocateMAC: %w", err)
+	}
+
+	// Reuse the MAC address already allocated to this IP.
+	if ip.Status.MAC != "" && ipcalc.MACHasPrefix(ip.Status.MAC, prefix) {
+		_, ok := slices.BinarySearch(subnet.Status.UsedMAC, ip.Status.MAC)
+		switch subnet.Spec.MACAllocation {
+		case flv1.MACAllocationIP:
+			if ok && ip.Status.Addr.Equal(allocatedIP) {
+				return ip.Status.MAC, nil
+			}
+		default:
+			if ok {
+				return ip.Status.MAC, nil
+			}
+		}
+	}
+
+	mac, err := ipcalc.GenerateMAC(prefix, subnet.Spec.MACAllocation,
+		allocatedIP, subnet.Status.UsedMAC)
+	if err != nil {
+		return "", fmt.Errorf("allocateMAC: %w", err)
+	}
+	return mac, nil
+}
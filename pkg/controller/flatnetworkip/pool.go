@@ -0,0 +1,116 @@
+package flatnetworkip
+
+import (
+	"fmt"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	eventSubnetPoolExhausted = "SubnetPoolExhausted"
+)
+
+// selectPoolSubnet selects the first subnet with free capacity in priority
+// order from the subnet pool of the flat-network IP.
+func (h *handler) selectPoolSubnet(ip *flv1.FlatNetworkIP) (*flv1.FlatNetworkSubnet, error) {
+	pool, err := h.subnetPoolCache.Get(flv1.SubnetNamespace, ip.Spec.SubnetPool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subnet pool [%v]: %w", ip.Spec.SubnetPool, err)
+	}
+	subnets, err := h.subnetCache.List(flv1.SubnetNamespace, labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subnets from cache: %w", err)
+	}
+	subnets, err = common.GetSubnetPoolSubnets(pool, subnets)
+	if err != nil {
+		return nil, err
+	}
+	for _, subnet := range subnets {
+		if err := h.checkPoolSubnetAvailable(ip, subnet); err != nil {
+			logrus.WithFields(fieldsIP(ip)).
+				Debugf("skip subnet [%v] of subnet pool [%v]: %v",
+					subnet.Name, pool.Name, err)
+			continue
+		}
+		return subnet, nil
+	}
+	err = fmt.Errorf("no available subnet in subnet pool [%v]: %w",
+		pool.Name, ipcalc.ErrNoAvailableIP)
+	h.recorder.Eventf(pool, corev1.EventTypeWarning, eventSubnetPoolExhausted,
+		"failed to allocate IP address for [%v/%v]: %v", ip.Namespace, ip.Name, err)
+	return nil, err
+}
+
+// checkPoolSubnetAvailable ensures the IP address can be allocated from the
+// subnet of the subnet pool.
+// The check is best-effort with the cached IP blocks, the address is
+// allocated later with the fenced IP block update and the allocation fails
+// if the subnet is exhausted by the time.
+func (h *handler) checkPoolSubnetAvailable(
+	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+) error {
+	if subnet.Status.Phase != "Active" {
+		return fmt.Errorf("subnet status is %q", subnet.Status.Phase)
+	}
+	if err := common.CheckSubnetCordoned(subnet); err != nil {
+		return err
+	}
+	if err := h.checkSubnetsNamespace(ip, subnet); err != nil {
+		return err
+	}
+	if err := h.checkSubnetQuota(ip, subnet); err != nil {
+		return err
+	}
+	a, err := h.getSubnetAllocation(subnet)
+	if err != nil {
+		return err
+	}
+	return a.available()
+}
+
+// updateIPPoolSubnet updates the subnet selected from the subnet pool to
+// the flat-network IP spec & labels.
+func (h *handler) updateIPPoolSubnet(
+	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+) (*flv1.FlatNetworkIP, error) {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		result, err := h.ipCache.Get(ip.Namespace, ip.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get IP from cache: %w", err)
+		}
+		result = result.DeepCopy()
+		result.Spec.Subnet = subnet.Name
+		if result.Labels == nil {
+			result.Labels = map[string]string{}
+		}
+		result.Labels["subnet"] = subnet.Name
+		if result.Annotations == nil {
+			result.Annotations = map[string]string{}
+		}
+		delete(result.Annotations, flv1.AnnotationsIPv6to4)
+		if subnet.Annotations[flv1.AnnotationsIPv6to4] != "" {
+			result.Annotations[flv1.AnnotationsIPv6to4] = "true"
+		}
+		result, err = h.ipClient.Update(result)
+		if err != nil {
+			return err
+		}
+		ip = result
+		return nil
+	})
+	if err != nil {
+		return ip, fmt.Errorf("failed to update IP [%v/%v] subnet: %w",
+			ip.Namespace, ip.Name, err)
+	}
+	logrus.WithFields(fieldsIP(ip)).
+		Infof("select subnet [%v] from subnet pool [%v]",
+			subnet.Name, ip.Spec.SubnetPool)
+	return ip, nil
+}
@@ -0,0 +1,77 @@
+package flatnetworkip
+
+import (
+	"fmt"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// checkSubnetQuota ensures the IP namespace does not exceed the subnet
+// quotas before allocating IP address from the subnet.
+// The usage is counted from the IPs read from the API server, the IPs are
+// allocated and updated under the IP allocate lock of the subnet.
+// The quota is best-effort across operator replicas: the lock is held only
+// in this process and the usage is not fenced by the IP block update, so
+// the concurrent allocations of different replicas may exceed the limit.
+func (h *handler) checkSubnetQuota(
+	ip *flv1.FlatNetworkIP, subnet *flv1.FlatNetworkSubnet,
+) error {
+	if subnet == nil || len(subnet.Spec.Quotas) == 0 {
+		return nil
+	}
+	ns, err := h.nsCache.Get(ip.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to get namespace [%v]: %w", ip.Namespace, err)
+	}
+	ips, err := h.listSubnetIPs(subnet.Name)
+	if err != nil {
+		return err
+	}
+	nsProjects, err := h.getNamespaceProjects()
+	if err != nil {
+		return err
+	}
+	usage := common.GetSubnetQuotaUsage(subnet, ips, nsProjects)
+	return common.CheckSubnetQuota(
+		subnet, usage, ip.Namespace, common.GetNamespaceProjectID(ns), 1)
+}
+
+// listSubnetIPs lists the IPs using the subnet from the API server, including
+// the IPs using the subnet as the dual-stack secondary subnet.
+// The IPs are not listed from cache as the address allocated by the previous
+// IP may not be synced into the cache yet when allocating back-to-back.
+func (h *handler) listSubnetIPs(subnetName string) ([]*flv1.FlatNetworkIP, error) {
+	var ips []*flv1.FlatNetworkIP
+	for _, key := range []string{"subnet", "secondarySubnet"} {
+		list, err := h.ipClient.List("", metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(labels.Set{
+				key: subnetName,
+			}).String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list IP: %w", err)
+		}
+		for i := range list.Items {
+			ips = append(ips, &list.Items[i])
+		}
+	}
+	return ips, nil
+}
+
+// getNamespaceProjects returns the map of namespace name to its Rancher
+// project ID.
+func (h *handler) getNamespaceProjects() (map[string]string, error) {
+	namespaces, err := h.nsCache.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespace from cache: %w", err)
+	}
+	nsProjects := make(map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		nsProjects[ns.Name] = common.GetNamespaceProjectID(ns)
+	}
+	return nsProjects, nil
+}
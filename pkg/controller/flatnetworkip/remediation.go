@@ -0,0 +1,92 @@
+package flatnetworkip
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	eventFlatNetworkIPPendingTimeout = "FlatNetworkIPPendingTimeout"
+)
+
+// remediatePendingIP applies the subnet remediation action to the IP
+// after the pod network not setup by CNI in time.
+func (h *handler) remediatePendingIP(
+	ip *flv1.FlatNetworkIP, r *flv1.SubnetRemediation,
+) (*flv1.FlatNetworkIP, error) {
+	podName := common.GetFlatNetworkIPPodName(ip)
+	message := fmt.Sprintf("timeout wait for pod network setup by CNI after [%v]",
+		time.Duration(r.PendingTimeout)*time.Second)
+	switch r.PendingAction {
+	case flv1.RemediationActionEvent, flv1.RemediationActionMarkFailed:
+		if ip.Status.Remediation == flv1.RemediationPendingTimeout {
+			// Already remediated.
+			return ip, nil
+		}
+		phase := ip.Status.Phase
+		if r.PendingAction == flv1.RemediationActionMarkFailed {
+			phase = flatNetworkIPFailedPhase
+		}
+		ip, err := h.setIPRemediation(ip, flv1.RemediationPendingTimeout, phase, message)
+		if err != nil {
+			return ip, err
+		}
+		logrus.WithFields(fieldsIP(ip)).
+			Warnf("%v, remediation action [%v]", message, r.PendingAction)
+		if pod, err := h.podCache.Get(ip.Namespace, podName); err == nil {
+			h.recorder.Event(pod, corev1.EventTypeWarning, eventFlatNetworkIPPendingTimeout, message)
+		}
+		return ip, nil
+	default:
+		err := h.podClient.Delete(ip.Namespace, podName, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return ip, fmt.Errorf("failed to delete pod [%v/%v]: %w",
+				ip.Namespace, podName, err)
+		}
+		logrus.WithFields(fieldsIP(ip)).
+			Warnf("%v, delete pod %v/%v", message, ip.Namespace, podName)
+		return ip, nil
+	}
+}
+
+// setIPRemediation records the remediation reason in the IP status.
+func (h *handler) setIPRemediation(
+	ip *flv1.FlatNetworkIP, remediation, phase, message string,
+) (*flv1.FlatNetworkIP, error) {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		result, err := h.ipCache.Get(ip.Namespace, ip.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get IP from cache: %w", err)
+		}
+		result = result.DeepCopy()
+		result.Status.Remediation = remediation
+		result.Status.Phase = phase
+		if phase == flatNetworkIPFailedPhase {
+			result.Status.FailureMessage = message
+		}
+		if remediation == flv1.RemediationPendingTimeout {
+			common.SetIPCondition(result, flv1.IPConditionInterfaceConfigured,
+				metav1.ConditionFalse, flv1.RemediationPendingTimeout, message)
+		}
+		result, err = h.ipClient.UpdateStatus(result)
+		if err != nil {
+			return err
+		}
+		ip = result
+		return nil
+	})
+	if err != nil {
+		return ip, fmt.Errorf("failed to update IP [%v/%v] remediation status: %w",
+			ip.Namespace, ip.Name, err)
+	}
+	return ip, nil
+}
@@ -2,16 +2,15 @@ package flatnetworkip
 
 import (
 	"fmt"
-	"slices"
+	"net"
 	"time"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/wrangler"
-	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/util/retry"
 )
 
 const (
@@ -26,7 +25,7 @@ func (h *handler) handleIPRemove(_ string, ip *flv1.FlatNetworkIP) (*flv1.FlatNe
 	// Wait until pod deleted
 	i := 0
 	for ; i < maxWaitForPodRemovePeriod; i++ {
-		pod, err := h.podCache.Get(ip.Namespace, ip.Name)
+		pod, err := h.podCache.Get(ip.Namespace, common.GetFlatNetworkIPPodName(ip))
 		if err != nil {
 			if errors.IsNotFound(err) {
 				break
@@ -45,48 +44,40 @@ func (h *handler) handleIPRemove(_ string, ip *flv1.FlatNetworkIP) (*flv1.FlatNe
 	}
 	if i >= maxWaitForPodRemovePeriod {
 		return ip, fmt.Errorf("failed to wait for pod [%v/%v] remove after [%v] times retry",
-			ip.Namespace, ip.Name, maxWaitForPodRemovePeriod)
+			ip.Namespace, common.GetFlatNetworkIPPodName(ip), maxWaitForPodRemovePeriod)
 	}
 
-	unlock := wrangler.IPAllocateLock(ip.Spec.Subnet)
+	if ip.Spec.Subnet == "" {
+		// The subnet is not selected from the subnet pool yet.
+		return ip, nil
+	}
+
+	unlock := wrangler.IPAllocateLocks(ip.Spec.Subnet, ip.Spec.SecondarySubnet)
 	defer unlock()
 
-	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		result, err := h.subnetCache.Get(flv1.SubnetNamespace, ip.Spec.Subnet)
-		if err != nil {
-			if errors.IsNotFound(err) {
-				// The subnet is deleted, return directly.
-				return nil
-			}
-			return fmt.Errorf("failed to get subnet from cache: %w", err)
-		}
+	h.releaseSubnetUsedIP(ip, ip.Spec.Subnet, ip.Status.Addr, ip.Status.MAC)
+	if ip.Spec.SecondarySubnet != "" {
+		h.releaseSubnetUsedIP(ip, ip.Spec.SecondarySubnet, ip.Status.SecondaryAddr, "")
+	}
+	return ip, nil
+}
 
-		result = result.DeepCopy()
-		if ipcalc.IPInRanges(ip.Status.Addr, result.Status.UsedIP) {
-			result.Status.UsedIP = ipcalc.RemoveIPFromRange(ip.Status.Addr, result.Status.UsedIP)
-			result.Status.UsedIPCount--
-		}
-		if len(ip.Status.MAC) != 0 {
-			result.Status.UsedMAC = slices.DeleteFunc(result.Status.UsedMAC, func(m string) bool {
-				return m == ip.Status.MAC
-			})
-			slices.Sort(result.Status.UsedMAC)
-		}
-		_, err = h.subnetClient.UpdateStatus(result)
-		return err
-	})
-	if err != nil {
+// releaseSubnetUsedIP removes the IP & MAC address of the deleted
+// flat-network IP from the IP block of the subnet.
+func (h *handler) releaseSubnetUsedIP(
+	ip *flv1.FlatNetworkIP, subnetName string, addr net.IP, mac string,
+) {
+	if err := h.removeSubnetUsedAddr(subnetName, addr, mac, true); err != nil {
 		logrus.WithFields(fieldsIP(ip)).
 			Errorf("failed to remove usedIP & usedMAC from subnet: %v", err)
 	}
-	if ip.Status.MAC != "" {
+	if mac != "" {
 		logrus.WithFields(fieldsIP(ip)).
 			Infof("remove IP [%v] MAC [%v] from subnet [%v]",
-				ip.Status.Addr, ip.Status.MAC, ip.Spec.Subnet)
+				addr, mac, subnetName)
 	} else {
 		logrus.WithFields(fieldsIP(ip)).
 			Infof("remove IP [%v] from subnet [%v]",
-				ip.Status.Addr, ip.Spec.Subnet)
+				addr, subnetName)
 	}
-	return ip, nil
 }
@@ -0,0 +1,238 @@
+package flatnetworkipreservation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/controller/wrangler"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flcontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	handlerName       = "rancher-flat-network-ipreservation"
+	handlerRemoveName = "rancher-flat-network-ipreservation-remove"
+
+	eventIPReservationConflict = "IPReservationConflict"
+)
+
+const (
+	reservationActivePhase  = "Active"
+	reservationExpiredPhase = "Expired"
+	reservationFailedPhase  = "Failed"
+)
+
+type handler struct {
+	reservationClient flcontroller.FlatNetworkIPReservationClient
+	reservationCache  flcontroller.FlatNetworkIPReservationCache
+	subnetClient      flcontroller.FlatNetworkSubnetClient
+	subnetCache       flcontroller.FlatNetworkSubnetCache
+	ipCache           flcontroller.FlatNetworkIPCache
+
+	recorder record.EventRecorder
+
+	reservationEnqueueAfter func(string, string, time.Duration)
+	subnetEnqueue           func(string, string)
+}
+
+func Register(
+	ctx context.Context,
+	wctx *wrangler.Context,
+) {
+	h := &handler{
+		reservationClient: wctx.FlatNetwork.FlatNetworkIPReservation(),
+		reservationCache:  wctx.FlatNetwork.FlatNetworkIPReservation().Cache(),
+		subnetClient:      wctx.FlatNetwork.FlatNetworkSubnet(),
+		subnetCache:       wctx.FlatNetwork.FlatNetworkSubnet().Cache(),
+		ipCache:           wctx.FlatNetwork.FlatNetworkIP().Cache(),
+
+		recorder: wctx.Recorder,
+
+		reservationEnqueueAfter: wctx.FlatNetwork.FlatNetworkIPReservation().EnqueueAfter,
+		subnetEnqueue:           wctx.FlatNetwork.FlatNetworkSubnet().Enqueue,
+	}
+
+	wctx.FlatNetwork.FlatNetworkIPReservation().OnChange(ctx, handlerName, h.handleError(h.handleReservation))
+	wctx.FlatNetwork.FlatNetworkIPReservation().OnRemove(ctx, handlerRemoveName, h.handleReservationRemove)
+}
+
+func (h *handler) handleError(
+	onChange func(string, *flv1.FlatNetworkIPReservation) (*flv1.FlatNetworkIPReservation, error),
+) func(string, *flv1.FlatNetworkIPReservation) (*flv1.FlatNetworkIPReservation, error) {
+	return func(key string, r *flv1.FlatNetworkIPReservation) (*flv1.FlatNetworkIPReservation, error) {
+		var message string
+		var err error
+		r, err = onChange(key, r)
+		if err != nil {
+			logrus.WithFields(fieldsReservation(r)).
+				Error(err)
+			message = err.Error()
+		}
+		if r == nil || r.Name == "" || r.DeletionTimestamp != nil {
+			return r, err
+		}
+		if r.Status.FailureMessage == message {
+			return r, err
+		}
+
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			r, err := h.reservationCache.Get(r.Namespace, r.Name)
+			if err != nil {
+				return err
+			}
+			r = r.DeepCopy()
+			if message != "" {
+				r.Status.Phase = reservationFailedPhase
+			}
+			r.Status.FailureMessage = message
+
+			_, err = h.reservationClient.UpdateStatus(r)
+			return err
+		})
+		if err != nil {
+			logrus.Errorf("error recording IP reservation [%s] failure message: %v", r.Name, err)
+			return r, err
+		}
+		return r, nil
+	}
+}
+
+func (h *handler) handleReservation(
+	_ string, r *flv1.FlatNetworkIPReservation,
+) (*flv1.FlatNetworkIPReservation, error) {
+	if r == nil || r.Name == "" || r.DeletionTimestamp != nil {
+		return r, nil
+	}
+
+	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, r.Spec.Subnet)
+	if err != nil {
+		return r, fmt.Errorf("failed to get subnet [%v]: %w", r.Spec.Subnet, err)
+	}
+	if err := common.ValidateIPReservation(r, subnet); err != nil {
+		return r, err
+	}
+
+	now := time.Now()
+	if common.IsIPReservationExpired(r, now) {
+		if r.Status.Phase != reservationExpiredPhase {
+			h.subnetEnqueue(flv1.SubnetNamespace, subnet.Name)
+		}
+		return h.updateStatus(r, reservationExpiredPhase, nil, nil)
+	}
+
+	// Sync this reservation in every 10 minutes to refresh the conflicts,
+	// or when the reservation expires.
+	resync := time.Minute * 10
+	if r.Spec.ExpireTime != nil && r.Spec.ExpireTime.Sub(now) < resync {
+		resync = r.Spec.ExpireTime.Sub(now)
+	}
+	defer h.reservationEnqueueAfter(r.Namespace, r.Name, resync)
+
+	// The reserved ranges are read from the reservations when allocating,
+	// refresh the subnet capacity on the reserved ranges changed.
+	ranges := common.GetIPReservationRanges(r)
+	if !equality.Semantic.DeepEqual(ranges, r.Status.ReservedIP) {
+		h.subnetEnqueue(flv1.SubnetNamespace, subnet.Name)
+	}
+	conflicts, err := h.getConflicts(subnet.Name, ranges)
+	if err != nil {
+		return r, err
+	}
+	if len(conflicts) != 0 && !reflect.DeepEqual(conflicts, r.Status.Conflicts) {
+		h.recorder.Eventf(r, corev1.EventTypeWarning, eventIPReservationConflict,
+			"reserved addresses already allocated by flat-network IPs: %v", utils.Print(conflicts))
+	}
+	return h.updateStatus(r, reservationActivePhase, ranges, conflicts)
+}
+
+// getConflicts returns the allocated flat-network IPs in the reserved
+// IP ranges of the subnet.
+func (h *handler) getConflicts(
+	subnetName string, ranges []flv1.IPRange,
+) ([]flv1.IPReservationConflict, error) {
+	ips, err := h.ipCache.List("", labels.SelectorFromSet(labels.Set{
+		"subnet": subnetName,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP from cache: %w", err)
+	}
+	secondaryIPs, err := h.ipCache.List("", labels.SelectorFromSet(labels.Set{
+		"secondarySubnet": subnetName,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP from cache: %w", err)
+	}
+	var conflicts []flv1.IPReservationConflict
+	for _, ip := range append(ips, secondaryIPs...) {
+		if ip.DeletionTimestamp != nil {
+			continue
+		}
+		addr := common.GetFlatNetworkIPAddrOfSubnet(ip, subnetName)
+		if len(addr) == 0 || !ipcalc.IPInRanges(addr, ranges) {
+			continue
+		}
+		conflicts = append(conflicts, flv1.IPReservationConflict{
+			Addr:          addr,
+			FlatNetworkIP: fmt.Sprintf("%v/%v", ip.Namespace, ip.Name),
+		})
+	}
+	return conflicts, nil
+}
+
+func (h *handler) updateStatus(
+	r *flv1.FlatNetworkIPReservation, phase string,
+	ranges []flv1.IPRange, conflicts []flv1.IPReservationConflict,
+) (*flv1.FlatNetworkIPReservation, error) {
+	status := flv1.IPReservationStatus{
+		Phase:      phase,
+		ReservedIP: ranges,
+		Conflicts:  conflicts,
+	}
+	if equality.Semantic.DeepEqual(r.Status, status) {
+		return r, nil
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		result, err := h.reservationCache.Get(r.Namespace, r.Name)
+		if err != nil {
+			return err
+		}
+		result = result.DeepCopy()
+		result.Status = status
+		result, err = h.reservationClient.UpdateStatus(result)
+		if err != nil {
+			return err
+		}
+		r = result
+		return nil
+	})
+	if err != nil {
+		return r, fmt.Errorf("failed to update IP reservation status: %w", err)
+	}
+	logrus.WithFields(fieldsReservation(r)).
+		Infof("update IP reservation status phase [%v] reservedIP %v",
+			phase, utils.Print(ranges))
+	return r, nil
+}
+
+func fieldsReservation(r *flv1.FlatNetworkIPReservation) logrus.Fields {
+	if r == nil {
+		return logrus.Fields{}
+	}
+	return logrus.Fields{
+		"GID":           utils.GID(),
+		"IPReservation": fmt.Sprintf("%v/%v", r.Namespace, r.Name),
+		"Subnet":        r.Spec.Subnet,
+	}
+}
@@ -0,0 +1,20 @@
+package flatnetworkipreservation
+
+import (
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *handler) handleReservationRemove(
+	_ string, r *flv1.FlatNetworkIPReservation,
+) (*flv1.FlatNetworkIPReservation, error) {
+	if r == nil || r.Name == "" {
+		return r, nil
+	}
+
+	// Refresh the subnet capacity as the reserved ranges released.
+	h.subnetEnqueue(flv1.SubnetNamespace, r.Spec.Subnet)
+	logrus.WithFields(fieldsReservation(r)).
+		Infof("IP reservation [%v/%v] removed", r.Namespace, r.Name)
+	return r, nil
+}
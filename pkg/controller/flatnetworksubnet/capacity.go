@@ -0,0 +1,205 @@
+package flatnetworksubnet
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	// defaultNearlyExhaustedThreshold is the default percentage of the used
+	// addresses to mark the subnet as nearly exhausted.
+	defaultNearlyExhaustedThreshold = 90
+
+	eventSubnetNearlyExhausted   = "SubnetNearlyExhausted"
+	eventSubnetExhausted         = "SubnetExhausted"
+	eventSubnetCapacityRecovered = "SubnetCapacityRecovered"
+	eventSubnetOutOfRangeIP      = "SubnetOutOfRangeIP"
+)
+
+// subnetEvent is the event to record on the subnet when the capacity
+// conditions changed.
+type subnetEvent struct {
+	eventType string
+	reason    string
+	message   string
+}
+
+// calcSubnetCapacity calculates the capacity of the subnet by the used IP
+// ranges of the subnet status.
+func calcSubnetCapacity(
+	subnet *flv1.FlatNetworkSubnet, now time.Time,
+) (flv1.SubnetCapacity, error) {
+	alloc, err := ipcalc.NewAllocator(subnet.Spec.CIDR, subnet.Spec.Ranges, subnet.Status.UsedIP)
+	if err != nil {
+		return flv1.SubnetCapacity{}, fmt.Errorf("failed to calculate subnet capacity: %w", err)
+	}
+	var reservedIP []flv1.IPRange
+	for _, r := range subnet.Status.ReservedIP {
+		reservedIP = append(reservedIP, r...)
+	}
+	reservedAlloc, err := ipcalc.NewAllocator(subnet.Spec.CIDR, subnet.Spec.Ranges, reservedIP)
+	if err != nil {
+		return flv1.SubnetCapacity{}, fmt.Errorf("failed to calculate subnet capacity: %w", err)
+	}
+
+	total := alloc.Size()
+	used := new(big.Int).Sub(total, alloc.Free())
+	reserved := new(big.Int).Sub(total, reservedAlloc.Free())
+
+	// The addresses reserved by the FlatNetworkIPReservations are not
+	// available for the other workloads.
+	usedIP := slices.Clone(subnet.Status.UsedIP)
+	usedIP = append(usedIP, common.GetSubnetReservationRanges(subnet)...)
+	alloc, err = ipcalc.NewAllocator(subnet.Spec.CIDR, subnet.Spec.Ranges, usedIP)
+	if err != nil {
+		return flv1.SubnetCapacity{}, fmt.Errorf("failed to calculate subnet capacity: %w", err)
+	}
+	// The released IPs waiting for reuse are not available.
+	for _, r := range ipcalc.PruneReleasedIP(subnet.Status.ReleasedIP, subnet.Spec.IPReuseDelay, now) {
+		if alloc.IsAvailable(r.Addr) {
+			_ = alloc.Reserve(r.Addr)
+		}
+	}
+	percent := 0
+	if total.Sign() > 0 {
+		p := new(big.Int).Mul(used, big.NewInt(100))
+		percent = int(p.Div(p, total).Int64())
+	}
+	return flv1.SubnetCapacity{
+		Total:       total.String(),
+		Used:        used.String(),
+		Reserved:    reserved.String(),
+		Available:   alloc.Free().String(),
+		UsedPercent: percent,
+	}, nil
+}
+
+// setCapacityConditions updates the 'NearlyExhausted' and 'Exhausted'
+// conditions of the subnet status by the capacity, returns the events
+// should be recorded.
+func setCapacityConditions(
+	subnet *flv1.FlatNetworkSubnet, capacity flv1.SubnetCapacity,
+) []subnetEvent {
+	threshold := subnet.Spec.NearlyExhaustedThreshold
+	if threshold <= 0 {
+		threshold = defaultNearlyExhaustedThreshold
+	}
+	exhausted := capacity.Available == "0"
+	nearlyExhausted := exhausted || capacity.UsedPercent >= threshold
+
+	var events []subnetEvent
+	status := &subnet.Status
+	c := metav1.Condition{
+		Type:               flv1.SubnetConditionNearlyExhausted,
+		Status:             metav1.ConditionFalse,
+		Reason:             "CapacitySufficient",
+		Message:            fmt.Sprintf("%v%% addresses used", capacity.UsedPercent),
+		ObservedGeneration: subnet.Generation,
+	}
+	if nearlyExhausted {
+		c.Status = metav1.ConditionTrue
+		c.Reason = "ThresholdExceeded"
+		c.Message = fmt.Sprintf("%v%% addresses used, exceeds the threshold %v%%",
+			capacity.UsedPercent, threshold)
+	}
+	wasNearlyExhausted := meta.IsStatusConditionTrue(status.Conditions, c.Type)
+	meta.SetStatusCondition(&status.Conditions, c)
+	switch {
+	case nearlyExhausted && !wasNearlyExhausted:
+		events = append(events, subnetEvent{
+			eventType: corev1.EventTypeWarning,
+			reason:    eventSubnetNearlyExhausted,
+			message: fmt.Sprintf("subnet [%v] nearly exhausted: %v",
+				subnet.Name, c.Message),
+		})
+	case !nearlyExhausted && wasNearlyExhausted:
+		events = append(events, subnetEvent{
+			eventType: corev1.EventTypeNormal,
+			reason:    eventSubnetCapacityRecovered,
+			message: fmt.Sprintf("subnet [%v] capacity recovered: %v",
+				subnet.Name, c.Message),
+		})
+	}
+
+	c = metav1.Condition{
+		Type:               flv1.SubnetConditionExhausted,
+		Status:             metav1.ConditionFalse,
+		Reason:             "AddressAvailable",
+		Message:            fmt.Sprintf("%v addresses available", capacity.Available),
+		ObservedGeneration: subnet.Generation,
+	}
+	if exhausted {
+		c.Status = metav1.ConditionTrue
+		c.Reason = "NoAvailableAddress"
+		c.Message = "no available address to allocate"
+	}
+	wasExhausted := meta.IsStatusConditionTrue(status.Conditions, c.Type)
+	meta.SetStatusCondition(&status.Conditions, c)
+	if exhausted && !wasExhausted {
+		events = append(events, subnetEvent{
+			eventType: corev1.EventTypeWarning,
+			reason:    eventSubnetExhausted,
+			message:   fmt.Sprintf("subnet [%v] exhausted: %v", subnet.Name, c.Message),
+		})
+	}
+	return events
+}
+
+// setReadyCondition updates the 'Ready' condition of the subnet status.
+func setReadyCondition(subnet *flv1.FlatNetworkSubnet, message string) {
+	c := metav1.Condition{
+		Type:               flv1.SubnetConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             subnetActivePhase,
+		ObservedGeneration: subnet.Generation,
+	}
+	if message != "" {
+		c.Status = metav1.ConditionFalse
+		c.Reason = subnetFailedPhase
+		c.Message = message
+	}
+	meta.SetStatusCondition(&subnet.Status.Conditions, c)
+}
+
+func (h *handler) recordEvents(subnet *flv1.FlatNetworkSubnet, events []subnetEvent) {
+	for _, e := range events {
+		h.recorder.Event(subnet, e.eventType, e.reason, e.message)
+	}
+}
+
+// outOfRangeIPEvent returns the warning event if new allocated IPs outside of
+// the subnet CIDR & ranges found.
+func outOfRangeIPEvent(
+	subnet *flv1.FlatNetworkSubnet, outOfRangeIP []flv1.OutOfRangeIP,
+) *subnetEvent {
+	known := make(map[string]bool, len(subnet.Status.OutOfRangeIP))
+	for _, r := range subnet.Status.OutOfRangeIP {
+		known[r.FlatNetworkIP] = true
+	}
+	var added []string
+	for _, r := range outOfRangeIP {
+		if !known[r.FlatNetworkIP] {
+			added = append(added, fmt.Sprintf("%v(%v)", r.FlatNetworkIP, r.Addr))
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	return &subnetEvent{
+		eventType: corev1.EventTypeWarning,
+		reason:    eventSubnetOutOfRangeIP,
+		message: fmt.Sprintf("%d allocated IPs outside of subnet CIDR [%v] ranges: %v",
+			len(added), subnet.Spec.CIDR, strings.Join(added, ", ")),
+	}
+}
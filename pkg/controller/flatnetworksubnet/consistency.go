@@ -0,0 +1,240 @@
+package flatnetworksubnet
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/controller/wrangler"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/flowcontrol"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	// consistencyCheckInterval is the interval of the consistency check
+	// between the IP blocks and the flat-network IPs of a subnet.
+	consistencyCheckInterval = time.Minute * 10
+
+	// consistencyRetryInterval is the interval to retry the consistency
+	// check postponed by the IP allocation or the rate limit.
+	consistencyRetryInterval = time.Second * 15
+
+	eventSubnetConsistencyDrift = "SubnetConsistencyDrift"
+)
+
+// consistencyCheckLimiter limits the consistency checks of all subnets,
+// as the check lists the IPs & IP blocks from the API server.
+var consistencyCheckLimiter = flowcontrol.NewTokenBucketRateLimiter(0.2, 5)
+
+// lastSubnetDrift is the full drift found by the last consistency check of
+// each subnet, as the drift stored in the subnet status is truncated.
+var lastSubnetDrift = sync.Map{}
+
+// needConsistencyCheck returns true if the last consistency check of the
+// subnet is older than the consistencyCheckInterval.
+func needConsistencyCheck(subnet *flv1.FlatNetworkSubnet, now time.Time) bool {
+	return now.Sub(subnet.Status.Consistency.LastCheckTime.Time) >= consistencyCheckInterval
+}
+
+// checkSubnetConsistency rebuilds the IP blocks of the subnet from the
+// flat-network IPs read from the API server, the drift found is repaired
+// and returned with the IP blocks after repaired.
+// The leaked addresses may be allocated by an in-flight allocation of
+// another replica, they are only released if also leaked in the previous
+// check.
+// Nil is returned if the check is postponed.
+func (h *handler) checkSubnetConsistency(
+	subnet *flv1.FlatNetworkSubnet,
+) (*flv1.SubnetConsistency, []*flv1.FlatNetworkIPBlock, error) {
+	if wrangler.IsIPAllocating(subnet.Name) || !consistencyCheckLimiter.TryAccept() {
+		h.subnetEnqueueAfter(subnet.Namespace, subnet.Name, consistencyRetryInterval)
+		return nil, nil, nil
+	}
+	// Disable IP allocation during the check.
+	unlock := wrangler.IPAllocateLock(subnet.Name)
+	defer unlock()
+
+	ips, err := h.listSubnetIPsFromAPI(subnet)
+	if err != nil {
+		return nil, nil, err
+	}
+	list, err := h.ipBlockClient.List(flv1.SubnetNamespace, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{
+			common.LabelIPBlockSubnet: subnet.Name,
+		}).String(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list IP blocks: %w", err)
+	}
+	blocks := make([]*flv1.FlatNetworkIPBlock, 0, len(list.Items))
+	for i := range list.Items {
+		blocks = append(blocks, &list.Items[i])
+	}
+
+	drift := getSubnetDrift(subnet, blocks, ips)
+	if !isSubnetConsistent(&drift) {
+		logrus.WithFields(fieldsSubnet(subnet)).
+			Warnf("subnet drift found: leakedIP %v untrackedIP %v leakedMAC %v untrackedMAC %v",
+				utils.Print(drift.LeakedIP), utils.Print(drift.UntrackedIP),
+				drift.LeakedMAC, drift.UntrackedMAC)
+	}
+	// The addresses leaked before the operator started are not released
+	// until found by the next check.
+	prev := &flv1.SubnetConsistency{}
+	if v, ok := lastSubnetDrift.Load(subnet.Name); ok {
+		prev = v.(*flv1.SubnetConsistency)
+	}
+	keepIP, keepMAC := getNewLeaks(prev, &drift)
+	blocks, err = h.syncIPBlocks(subnet, blocks, ips, keepIP, keepMAC)
+	if err != nil {
+		return nil, nil, err
+	}
+	lastSubnetDrift.Store(subnet.Name, &drift)
+	consistency := summarizeSubnetDrift(&drift)
+	consistency.LastCheckTime = metav1.Now()
+	return &consistency, blocks, nil
+}
+
+// getSubnetDrift returns the drift between the addresses recorded in the IP
+// blocks and the addresses used by the flat-network IPs of the subnet.
+func getSubnetDrift(
+	subnet *flv1.FlatNetworkSubnet,
+	blocks []*flv1.FlatNetworkIPBlock,
+	ips []*flv1.FlatNetworkIP,
+) flv1.SubnetConsistency {
+	recorded := common.MergeSubnetIPBlocks(subnet, blocks)
+	usedIP := ipcalc.NewIPSet(nil)
+	if len(subnet.Spec.Gateway) != 0 {
+		usedIP.Add(subnet.Spec.Gateway)
+	}
+	var usedMAC []string
+	for _, ip := range ips {
+		addr := common.GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)
+		if ip == nil || ip.DeletionTimestamp != nil || len(addr) == 0 {
+			continue
+		}
+		usedIP.Add(addr)
+		if ip.Spec.Subnet == subnet.Name && ip.Status.MAC != "" {
+			usedMAC = append(usedMAC, ip.Status.MAC)
+		}
+	}
+	slices.Sort(usedMAC)
+	usedMAC = slices.Compact(usedMAC)
+
+	recordedIP := ipcalc.NewIPSet(recorded.Status.UsedIP)
+	c := flv1.SubnetConsistency{}
+	if r := recordedIP.Difference(usedIP).Ranges(); len(r) != 0 {
+		c.LeakedIP = r
+	}
+	if r := usedIP.Difference(recordedIP).Ranges(); len(r) != 0 {
+		c.UntrackedIP = r
+	}
+	for _, m := range recorded.Status.UsedMAC {
+		if _, ok := slices.BinarySearch(usedMAC, m); !ok {
+			c.LeakedMAC = append(c.LeakedMAC, m)
+		}
+	}
+	for _, m := range usedMAC {
+		if _, ok := slices.BinarySearch(recorded.Status.UsedMAC, m); !ok {
+			c.UntrackedMAC = append(c.UntrackedMAC, m)
+		}
+	}
+	return c
+}
+
+// getNewLeaks returns the leaked addresses and MACs (sorted) found by the
+// current check but not by the previous check.
+func getNewLeaks(prev, cur *flv1.SubnetConsistency) (*ipcalc.IPSet, []string) {
+	newIP := ipcalc.NewIPSet(cur.LeakedIP).Difference(ipcalc.NewIPSet(prev.LeakedIP))
+	var newMAC []string
+	for _, m := range cur.LeakedMAC {
+		if !slices.Contains(prev.LeakedMAC, m) {
+			newMAC = append(newMAC, m)
+		}
+	}
+	slices.Sort(newMAC)
+	return newIP, newMAC
+}
+
+// summarizeSubnetDrift returns the drift with the numbers of the leaked &
+// untracked addresses, only the first MaxSubnetStatusItems items of each
+// kind are kept.
+func summarizeSubnetDrift(c *flv1.SubnetConsistency) flv1.SubnetConsistency {
+	return flv1.SubnetConsistency{
+		LeakedIP:          firstStatusItems(c.LeakedIP),
+		LeakedIPCount:     countIPRanges(c.LeakedIP),
+		UntrackedIP:       firstStatusItems(c.UntrackedIP),
+		UntrackedIPCount:  countIPRanges(c.UntrackedIP),
+		LeakedMAC:         firstStatusItems(c.LeakedMAC),
+		LeakedMACCount:    len(c.LeakedMAC),
+		UntrackedMAC:      firstStatusItems(c.UntrackedMAC),
+		UntrackedMACCount: len(c.UntrackedMAC),
+	}
+}
+
+// firstStatusItems returns the first MaxSubnetStatusItems items of the list
+// to store in the subnet status.
+func firstStatusItems[T any](s []T) []T {
+	if len(s) > flv1.MaxSubnetStatusItems {
+		return s[:flv1.MaxSubnetStatusItems]
+	}
+	return s
+}
+
+// countIPRanges returns the number of the addresses in the IP ranges,
+// limited by math.MaxInt32.
+func countIPRanges(ranges []flv1.IPRange) int {
+	n := ipcalc.NewIPSet(ranges).Len()
+	if n.IsInt64() && n.Int64() < int64(math.MaxInt32) {
+		return int(n.Int64())
+	}
+	return math.MaxInt32
+}
+
+func isSubnetConsistent(c *flv1.SubnetConsistency) bool {
+	return len(c.LeakedIP) == 0 && len(c.UntrackedIP) == 0 &&
+		len(c.LeakedMAC) == 0 && len(c.UntrackedMAC) == 0
+}
+
+// setConsistentCondition updates the 'Consistent' condition of the subnet
+// status by the consistency check result, returns the events should be
+// recorded.
+func setConsistentCondition(
+	subnet *flv1.FlatNetworkSubnet, c *flv1.SubnetConsistency,
+) []subnetEvent {
+	condition := metav1.Condition{
+		Type:               flv1.SubnetConditionConsistent,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NoDrift",
+		Message:            "IP blocks are consistent with flat-network IPs",
+		ObservedGeneration: subnet.Generation,
+	}
+	var events []subnetEvent
+	if !isSubnetConsistent(c) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "DriftFound"
+		condition.Message = fmt.Sprintf(
+			"%v leaked IP, %v untracked IP, %v leaked MAC, %v untracked MAC found, "+
+				"leaked addresses are released if still leaked in the next check",
+			c.LeakedIPCount, c.UntrackedIPCount, c.LeakedMACCount, c.UntrackedMACCount)
+		events = append(events, subnetEvent{
+			eventType: corev1.EventTypeWarning,
+			reason:    eventSubnetConsistencyDrift,
+			message: fmt.Sprintf("subnet [%v] usage drift found: %v",
+				subnet.Name, condition.Message),
+		})
+	}
+	meta.SetStatusCondition(&subnet.Status.Conditions, condition)
+	return events
+}
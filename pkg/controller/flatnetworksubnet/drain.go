@@ -0,0 +1,319 @@
+package flatnetworksubnet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/controller/workload"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	// drainInterval is the interval to re-check the draining subnet.
+	drainInterval = time.Second * 30
+
+	eventSubnetDrainWorkload = "SubnetDrainWorkload"
+	eventSubnetDrainEvict    = "SubnetDrainEvict"
+	eventSubnetDrainFailed   = "SubnetDrainFailed"
+
+	reasonSubnetCordoned = "Cordoned"
+	reasonSubnetDraining = "Draining"
+	reasonSubnetDrained  = "Drained"
+)
+
+// drainSubnet moves the pods out of the cordoned subnet, returns the
+// number of the pods still using the subnet and the events should be
+// recorded.
+// The workloads are rolled to the replacement subnet if specified, the other
+// pods are evicted.
+func (h *handler) drainSubnet(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) (int, []subnetEvent) {
+	var events []subnetEvent
+	remaining := 0
+	for _, ip := range ips {
+		if ip.DeletionTimestamp != nil ||
+			len(common.GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)) == 0 {
+			continue
+		}
+		remaining++
+	}
+	if !subnet.Spec.Cordoned || subnet.Spec.Drain == nil || remaining == 0 {
+		return remaining, nil
+	}
+
+	var rolled map[string]bool
+	replacement := subnet.Spec.Drain.ReplacementSubnet
+	if replacement != "" {
+		r, err := h.subnetCache.Get(flv1.SubnetNamespace, replacement)
+		if err == nil && r.Spec.Cordoned {
+			err = fmt.Errorf("subnet is cordoned")
+		}
+		if err != nil {
+			return remaining, []subnetEvent{{
+				eventType: corev1.EventTypeWarning,
+				reason:    eventSubnetDrainFailed,
+				message: fmt.Sprintf("failed to use replacement subnet [%v]: %v",
+					replacement, err),
+			}}
+		}
+		var rollEvents []subnetEvent
+		rolled, rollEvents, err = h.rollWorkloads(subnet, replacement)
+		events = append(events, rollEvents...)
+		if err != nil {
+			// Retry in next drain rather than evicting the pods of the
+			// workloads to be rolled.
+			events = append(events, drainFailedEvents(err)...)
+			h.subnetEnqueueAfter(subnet.Namespace, subnet.Name, drainInterval)
+			return remaining, events
+		}
+	}
+	events = append(events, h.evictPods(subnet, ips, rolled)...)
+
+	// Re-check the draining subnet until all pods moved out.
+	h.subnetEnqueueAfter(subnet.Namespace, subnet.Name, drainInterval)
+	return remaining, events
+}
+
+// rollWorkloads updates the subnet annotations of the workload pod template
+// from the subnet to the replacement subnet.
+// The workloads rolled (or not using the subnet in the pod template) are
+// returned in '<namespace>/<kind>/<name>' format.
+func (h *handler) rollWorkloads(
+	subnet *flv1.FlatNetworkSubnet, replacement string,
+) (map[string]bool, []subnetEvent, error) {
+	var workloads []metav1.Object
+	deployments, err := h.deploymentCache.List("", labels.Everything())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list deployments from cache: %w", err)
+	}
+	for _, o := range deployments {
+		workloads = append(workloads, o)
+	}
+	daemonSets, err := h.daemonSetCache.List("", labels.Everything())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list daemonsets from cache: %w", err)
+	}
+	for _, o := range daemonSets {
+		workloads = append(workloads, o)
+	}
+	statefulSets, err := h.statefulSetCache.List("", labels.Everything())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list statefulsets from cache: %w", err)
+	}
+	for _, o := range statefulSets {
+		workloads = append(workloads, o)
+	}
+	cronJobs, err := h.cronJobCache.List("", labels.Everything())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list cronjobs from cache: %w", err)
+	}
+	for _, o := range cronJobs {
+		workloads = append(workloads, o)
+	}
+
+	var events []subnetEvent
+	rolled := map[string]bool{}
+	for _, w := range workloads {
+		if w.GetDeletionTimestamp() != nil {
+			continue
+		}
+		w = workload.DeepCopy(w)
+		kind := workloadKind(w)
+		key := fmt.Sprintf("%v/%v/%v", w.GetNamespace(), kind, w.GetName())
+		m := workload.GetTemplateObjectMeta(w)
+		if m == nil || len(m.Annotations) == 0 {
+			rolled[key] = true
+			continue
+		}
+		changed, err := common.ReplacePodAnnotationSubnet(m.Annotations, subnet.Name, replacement)
+		if err == nil && changed {
+			err = h.updateWorkload(w)
+		}
+		switch {
+		case err != nil:
+			events = append(events, drainFailedEvents(fmt.Errorf("failed to roll %v [%v/%v]: %w",
+				kind, w.GetNamespace(), w.GetName(), err))...)
+			continue
+		case changed:
+			logrus.WithFields(fieldsSubnet(subnet)).
+				Infof("roll %v [%v/%v] to subnet [%v]",
+					kind, w.GetNamespace(), w.GetName(), replacement)
+			events = append(events, subnetEvent{
+				eventType: corev1.EventTypeNormal,
+				reason:    eventSubnetDrainWorkload,
+				message: fmt.Sprintf("roll %v [%v/%v] to subnet [%v]",
+					kind, w.GetNamespace(), w.GetName(), replacement),
+			})
+		}
+		rolled[key] = true
+	}
+	return rolled, events, nil
+}
+
+func (h *handler) updateWorkload(w metav1.Object) error {
+	var err error
+	switch o := w.(type) {
+	case *appsv1.Deployment:
+		_, err = h.deploymentClient.Update(o)
+	case *appsv1.DaemonSet:
+		_, err = h.daemonSetClient.Update(o)
+	case *appsv1.StatefulSet:
+		_, err = h.statefulSetClient.Update(o)
+	case *batchv1.CronJob:
+		_, err = h.cronJobClient.Update(o)
+	default:
+		err = fmt.Errorf("unrecognized workload %T", w)
+	}
+	return err
+}
+
+func workloadKind(w metav1.Object) string {
+	switch w.(type) {
+	case *appsv1.Deployment:
+		return "Deployment"
+	case *appsv1.DaemonSet:
+		return "DaemonSet"
+	case *appsv1.StatefulSet:
+		return "StatefulSet"
+	case *batchv1.CronJob:
+		return "CronJob"
+	}
+	return fmt.Sprintf("%T", w)
+}
+
+// evictPods evicts the pods using the subnet.
+// The pods of the rolled workloads are replaced by the workload controllers
+// and are not evicted unless using the subnet pool.
+func (h *handler) evictPods(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP, rolled map[string]bool,
+) []subnetEvent {
+	var events []subnetEvent
+	for _, ip := range ips {
+		if ip.DeletionTimestamp != nil ||
+			len(common.GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)) == 0 {
+			continue
+		}
+		pod, err := h.podCache.Get(ip.Namespace, common.GetFlatNetworkIPPodName(ip))
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			events = append(events, drainFailedEvents(fmt.Errorf("failed to get pod [%v/%v]: %w",
+				ip.Namespace, common.GetFlatNetworkIPPodName(ip), err))...)
+			continue
+		}
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		// The pods using the subnet pool are evicted to select another
+		// subnet from the pool.
+		if ip.Spec.SubnetPool == "" &&
+			rolled[fmt.Sprintf("%v/%v", pod.Namespace, h.getPodWorkload(pod))] {
+			continue
+		}
+		err = h.kubeClient.CoreV1().Pods(pod.Namespace).EvictV1(context.TODO(), &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+			},
+		})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			// The eviction may be blocked by the PodDisruptionBudget,
+			// retry in next drain.
+			logrus.WithFields(fieldsSubnet(subnet)).
+				Warnf("failed to evict pod [%v/%v]: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+		logrus.WithFields(fieldsSubnet(subnet)).
+			Infof("evict pod [%v/%v]", pod.Namespace, pod.Name)
+		events = append(events, subnetEvent{
+			eventType: corev1.EventTypeNormal,
+			reason:    eventSubnetDrainEvict,
+			message:   fmt.Sprintf("evict pod [%v/%v]", pod.Namespace, pod.Name),
+		})
+	}
+	return events
+}
+
+// getPodWorkload returns the top-level workload of the pod in 'Kind/Name'
+// format, the Deployment is returned if the pod created by ReplicaSet and the
+// CronJob is returned if the pod created by Job.
+func (h *handler) getPodWorkload(pod *corev1.Pod) string {
+	return getPodWorkload(pod, func(kind, name string) *metav1.OwnerReference {
+		switch kind {
+		case "ReplicaSet":
+			rs, err := h.replicaSetCache.Get(pod.Namespace, name)
+			if err == nil {
+				return metav1.GetControllerOf(rs)
+			}
+		case "Job":
+			job, err := h.jobCache.Get(pod.Namespace, name)
+			if err == nil {
+				return metav1.GetControllerOf(job)
+			}
+		}
+		return nil
+	})
+}
+
+// getPodWorkload returns the top-level workload of the pod by the controller
+// returned by controllerOf, empty string if the pod is not controlled.
+func getPodWorkload(
+	pod *corev1.Pod, controllerOf func(kind, name string) *metav1.OwnerReference,
+) string {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return ""
+	}
+	if o := controllerOf(owner.Kind, owner.Name); o != nil {
+		owner = o
+	}
+	return fmt.Sprintf("%v/%v", owner.Kind, owner.Name)
+}
+
+func drainFailedEvents(err error) []subnetEvent {
+	return []subnetEvent{{
+		eventType: corev1.EventTypeWarning,
+		reason:    eventSubnetDrainFailed,
+		message:   err.Error(),
+	}}
+}
+
+// setCordonedCondition updates the 'Cordoned' condition of the subnet status
+// by the number of the pods still using the subnet.
+func setCordonedCondition(subnet *flv1.FlatNetworkSubnet, remaining int) {
+	if !subnet.Spec.Cordoned {
+		meta.RemoveStatusCondition(&subnet.Status.Conditions, flv1.SubnetConditionCordoned)
+		return
+	}
+	c := metav1.Condition{
+		Type:               flv1.SubnetConditionCordoned,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonSubnetCordoned,
+		Message:            fmt.Sprintf("%d pods using the subnet", remaining),
+		ObservedGeneration: subnet.Generation,
+	}
+	if subnet.Spec.Drain != nil {
+		c.Reason = reasonSubnetDraining
+		if remaining == 0 {
+			c.Reason = reasonSubnetDrained
+		}
+	}
+	meta.SetStatusCondition(&subnet.Status.Conditions, c)
+}
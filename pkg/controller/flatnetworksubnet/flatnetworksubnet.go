@@ -1,12 +1,10 @@
 package flatnetworksubnet
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net"
 	"reflect"
-	"slices"
 	"time"
 
 	"github.com/cnrancher/rancher-flat-network/pkg/common"
@@ -14,19 +12,32 @@ import (
 	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 	"github.com/sirupsen/logrus"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	appscontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/apps/v1"
+	batchcontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/batch/v1"
 	corecontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/core/v1"
 	flcontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/flatnetwork.pandaria.io/v1"
 )
 
 const (
-	handlerName       = "rancher-flat-network-subnet"
-	handlerRemoveName = "rancher-flat-network-subnet-remove"
+	handlerName        = "rancher-flat-network-subnet"
+	handlerRemoveName  = "rancher-flat-network-subnet-remove"
+	handlerIPBlockName = "rancher-flat-network-subnet-ipblock"
+	handlerIPName      = "rancher-flat-network-subnet-ip"
+
+	// usageSyncDelay is the delay to update the subnet usage after the
+	// flat-network IPs or IP blocks changed, to merge the changes in a
+	// short period into one subnet update.
+	usageSyncDelay = time.Second * 5
 )
 
 const (
@@ -43,11 +54,31 @@ const (
 )
 
 type handler struct {
-	subnetClient flcontroller.FlatNetworkSubnetClient
-	subnetCache  flcontroller.FlatNetworkSubnetCache
-	ipClient     flcontroller.FlatNetworkIPClient
-	ipCache      flcontroller.FlatNetworkIPCache
-	podClient    corecontroller.PodClient
+	subnetClient     flcontroller.FlatNetworkSubnetClient
+	subnetCache      flcontroller.FlatNetworkSubnetCache
+	ipClient         flcontroller.FlatNetworkIPClient
+	ipCache          flcontroller.FlatNetworkIPCache
+	reservationCache flcontroller.FlatNetworkIPReservationCache
+	podClient        corecontroller.PodClient
+	podCache         corecontroller.PodCache
+	nsCache          corecontroller.NamespaceCache
+
+	deploymentClient  appscontroller.DeploymentClient
+	deploymentCache   appscontroller.DeploymentCache
+	daemonSetClient   appscontroller.DaemonSetClient
+	daemonSetCache    appscontroller.DaemonSetCache
+	statefulSetClient appscontroller.StatefulSetClient
+	statefulSetCache  appscontroller.StatefulSetCache
+	cronJobClient     batchcontroller.CronJobClient
+	cronJobCache      batchcontroller.CronJobCache
+	replicaSetCache   appscontroller.ReplicaSetCache
+	jobCache          batchcontroller.JobCache
+	kubeClient        kubernetes.Interface
+
+	ipBlockClient flcontroller.FlatNetworkIPBlockClient
+	ipBlockCache  flcontroller.FlatNetworkIPBlockCache
+
+	recorder record.EventRecorder
 
 	subnetEnqueueAfter func(string, string, time.Duration)
 	subnetEnqueue      func(string, string)
@@ -58,11 +89,31 @@ func Register(
 	wctx *wrangler.Context,
 ) {
 	h := &handler{
-		subnetClient: wctx.FlatNetwork.FlatNetworkSubnet(),
-		subnetCache:  wctx.FlatNetwork.FlatNetworkSubnet().Cache(),
-		ipClient:     wctx.FlatNetwork.FlatNetworkIP(),
-		ipCache:      wctx.FlatNetwork.FlatNetworkIP().Cache(),
-		podClient:    wctx.Core.Pod(),
+		subnetClient:     wctx.FlatNetwork.FlatNetworkSubnet(),
+		subnetCache:      wctx.FlatNetwork.FlatNetworkSubnet().Cache(),
+		ipClient:         wctx.FlatNetwork.FlatNetworkIP(),
+		ipCache:          wctx.FlatNetwork.FlatNetworkIP().Cache(),
+		reservationCache: wctx.FlatNetwork.FlatNetworkIPReservation().Cache(),
+		podClient:        wctx.Core.Pod(),
+		podCache:         wctx.Core.Pod().Cache(),
+		nsCache:          wctx.Core.Namespace().Cache(),
+
+		deploymentClient:  wctx.Apps.Deployment(),
+		deploymentCache:   wctx.Apps.Deployment().Cache(),
+		daemonSetClient:   wctx.Apps.DaemonSet(),
+		daemonSetCache:    wctx.Apps.DaemonSet().Cache(),
+		statefulSetClient: wctx.Apps.StatefulSet(),
+		statefulSetCache:  wctx.Apps.StatefulSet().Cache(),
+		cronJobClient:     wctx.Batch.CronJob(),
+		cronJobCache:      wctx.Batch.CronJob().Cache(),
+		replicaSetCache:   wctx.Apps.ReplicaSet().Cache(),
+		jobCache:          wctx.Batch.Job().Cache(),
+		kubeClient:        wctx.Kubernetes,
+
+		ipBlockClient: wctx.FlatNetwork.FlatNetworkIPBlock(),
+		ipBlockCache:  wctx.FlatNetwork.FlatNetworkIPBlock().Cache(),
+
+		recorder: wctx.Recorder,
 
 		subnetEnqueueAfter: wctx.FlatNetwork.FlatNetworkSubnet().EnqueueAfter,
 		subnetEnqueue:      wctx.FlatNetwork.FlatNetworkSubnet().Enqueue,
@@ -70,6 +121,8 @@ func Register(
 
 	wctx.FlatNetwork.FlatNetworkSubnet().OnChange(ctx, handlerName, h.handleError(h.handleSubnet))
 	wctx.FlatNetwork.FlatNetworkSubnet().OnRemove(ctx, handlerRemoveName, h.handleSubnetRemove)
+	wctx.FlatNetwork.FlatNetworkIPBlock().OnChange(ctx, handlerIPBlockName, h.handleIPBlock)
+	wctx.FlatNetwork.FlatNetworkIP().OnChange(ctx, handlerIPName, h.handleIP)
 }
 
 func (h *handler) handleError(
@@ -106,6 +159,7 @@ func (h *handler) handleError(
 				subnet.Status.Phase = subnetFailedPhase
 			}
 			subnet.Status.FailureMessage = message
+			setReadyCondition(subnet, message)
 
 			_, err = h.subnetClient.UpdateStatus(subnet)
 			return err
@@ -143,8 +197,7 @@ func (h *handler) onSubnetCreate(subnet *flv1.FlatNetworkSubnet) (*flv1.FlatNetw
 		return subnet, err
 	}
 	set := map[string]string{
-		labelMaster: subnet.Spec.Master,
-		labelVlan:   fmt.Sprintf("%v", subnet.Spec.VLAN),
+		labelVlan: fmt.Sprintf("%v", subnet.Spec.VLAN),
 	}
 	subnets, err := h.subnetCache.List(subnet.Namespace, labels.SelectorFromSet(set))
 	if err != nil {
@@ -215,8 +268,8 @@ func (h *handler) onSubnetCreate(subnet *flv1.FlatNetworkSubnet) (*flv1.FlatNetw
 	// Update the flat-network subnet status.
 	subnet = subnet.DeepCopy()
 	subnet.Status.Phase = subnetActivePhase
-	subnet.Status.UsedIP = ipcalc.AddIPToRange(subnet.Spec.Gateway, subnet.Status.UsedIP)
 	subnet.Status.Gateway = subnet.Spec.Gateway
+	setReadyCondition(subnet, "")
 	subnetUpdate, err := h.subnetClient.UpdateStatus(subnet)
 	if err != nil {
 		return subnet, fmt.Errorf("failed to update status of subnet: %w", err)
@@ -230,8 +283,7 @@ func (h *handler) onSubnetCreate(subnet *flv1.FlatNetworkSubnet) (*flv1.FlatNetw
 
 func (h *handler) onSubnetUpdate(subnet *flv1.FlatNetworkSubnet) (*flv1.FlatNetworkSubnet, error) {
 	set := map[string]string{
-		labelMaster: subnet.Spec.Master,
-		labelVlan:   fmt.Sprintf("%v", subnet.Spec.VLAN),
+		labelVlan: fmt.Sprintf("%v", subnet.Spec.VLAN),
 	}
 	subnets, err := h.subnetCache.List(subnet.Namespace, labels.SelectorFromSet(set))
 	if err != nil {
@@ -252,8 +304,10 @@ func (h *handler) onSubnetUpdate(subnet *flv1.FlatNetworkSubnet) (*flv1.FlatNetw
 		return subnet, fmt.Errorf("invalid subnet namespace %q", subnet.Namespace)
 	}
 
-	// Sync this subnet in every 10 minutes.
-	defer h.subnetEnqueueAfter(subnet.Namespace, subnet.Name, time.Minute*10)
+	// The subnet usage is updated by the IP & IP block events, re-sync the
+	// subnet periodically for the consistency check.
+	defer h.subnetEnqueueAfter(subnet.Namespace, subnet.Name,
+		wait.Jitter(consistencyCheckInterval, 0.1))
 
 	// Update subnet labels.
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -301,45 +355,38 @@ func (h *handler) onSubnetUpdate(subnet *flv1.FlatNetworkSubnet) (*flv1.FlatNetw
 		return subnet, fmt.Errorf("failed to update label and gateway of subnet: %w", err)
 	}
 
-	if wrangler.IsIPAllocating(subnet.Name) {
-		// Do not self-check when allocating IPs
-		return subnet, nil
-	}
-	// Disable ip allocate when updating subnet usedIPs
-	unlock := wrangler.IPAllocateLock(subnet.Name)
-	defer unlock()
-
-	// The following sections are used to self-recover from unexpected situations:
-	// cleaning up duplicate IPs, checking whether the used IPs is correct.
-
-	// List IPs using this subnet.
-	ips, err := h.ipCache.List("", labels.SelectorFromSet(labels.Set{
-		"subnet": subnet.Name,
-	}))
-	if err != nil {
-		return subnet, fmt.Errorf("failed to list IP from cache: %w", err)
+	// Run the rate-limited consistency check to find and repair the drift
+	// between the IP blocks and the flat-network IPs.
+	var consistency *flv1.SubnetConsistency
+	var blocks []*flv1.FlatNetworkIPBlock
+	if needConsistencyCheck(subnet, time.Now()) {
+		consistency, blocks, err = h.checkSubnetConsistency(subnet)
+		if err != nil {
+			return subnet, fmt.Errorf("failed to check subnet consistency: %w", err)
+		}
 	}
-
-	// Cleanup the duplicated IPs using this subnet.
-	duplicatedIPs := filterDuplicatedIP(subnet, ips)
-	if len(duplicatedIPs) != 0 {
-		return subnet, h.cleanupDuplicatedIPs(subnet, ips)
+	if consistency == nil {
+		blocks, err = h.listSubnetIPBlocks(subnet)
+		if err != nil {
+			return subnet, err
+		}
 	}
-
-	// Ensure the usedIPs are correct.
-	usedIPCount := 0
-	usedIP := []flv1.IPRange{}
-	for _, ip := range ips {
-		if ip == nil || ip.DeletionTimestamp != nil || len(ip.Status.Addr) == 0 {
-			continue
+	var ipsSync *subnetIPsSync
+	if consistency != nil || needSubnetIPsSync(subnet) {
+		ipsSync, err = h.syncSubnetIPs(subnet)
+		if err != nil {
+			return subnet, err
 		}
-		usedIPCount++
-		usedIP = ipcalc.AddIPToRange(ip.Status.Addr, usedIP)
 	}
-	if len(subnet.Spec.Gateway) != 0 {
-		usedIP = ipcalc.AddIPToRange(subnet.Spec.Gateway, usedIP)
-		usedIPCount++
+
+	// The usage of the subnet is aggregated from the IP blocks updated by
+	// the IP allocation & release.
+	reservations, err := h.getSubnetReservations(subnet)
+	if err != nil {
+		return subnet, err
 	}
+	usedIP, usedIPCount := getSubnetUsedIP(subnet, blocks, reservations)
+	var events []subnetEvent
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		result, err := h.subnetCache.Get(subnet.Namespace, subnet.Name)
 		if err != nil {
@@ -348,100 +395,245 @@ func (h *handler) onSubnetUpdate(subnet *flv1.FlatNetworkSubnet) (*flv1.FlatNetw
 			return err
 		}
 		skipUpdate := false
-		if result.Status.UsedIPCount == usedIPCount && reflect.DeepEqual(usedIP, result.Status.UsedIP) {
+		if result.Status.UsedIPCount == usedIPCount {
 			skipUpdate = true
 		}
 		if result.Spec.Gateway.String() == result.Status.Gateway.String() {
 			skipUpdate = true
 		}
+		if consistency != nil {
+			skipUpdate = false
+		}
+		updated := result.DeepCopy()
+		updated.Status.UsedIPCount = usedIPCount
+		updated.Status.Gateway = updated.Spec.Gateway
+		events = nil
+		if consistency != nil {
+			// The reservedIP, usedIP, usedMAC & releasedIP are migrated to
+			// the IP blocks by the consistency check.
+			updated.Status.ReservedIP = nil
+			updated.Status.UsedIP = nil
+			updated.Status.UsedMAC = nil
+			updated.Status.ReleasedIP = nil
+			updated.Status.Consistency = *consistency
+			events = append(events, setConsistentCondition(updated, consistency)...)
+		}
+		if ipsSync != nil {
+			updated.Status.QuotaUsage = ipsSync.quotaUsage
+			updated.Status.OutOfRangeIP = ipsSync.outOfRangeIP
+			updated.Status.OutOfRangeIPCount = ipsSync.outOfRangeIPCount
+			setCordonedCondition(updated, ipsSync.cordonedRemaining)
+			if e := outOfRangeIPEvent(result, ipsSync.outOfRangeIP); e != nil {
+				events = append(events, *e)
+			}
+		} else if !updated.Spec.Cordoned {
+			setCordonedCondition(updated, 0)
+		}
+		// Calculate the capacity by the addresses aggregated from the IP
+		// blocks and the reservations.
+		view := common.MergeSubnetIPBlocks(updated, blocks)
+		view.Status.UsedIP = usedIP
+		common.SetSubnetIPReservations(view, reservations)
+		capacity, err := calcSubnetCapacity(view, time.Now())
+		if err != nil {
+			return err
+		}
+		updated.Status.Capacity = capacity
+		setReadyCondition(updated, "")
+		events = append(events, setCapacityConditions(updated, capacity)...)
+		if !equality.Semantic.DeepEqual(updated.Status, result.Status) {
+			skipUpdate = false
+		}
 		if skipUpdate {
 			subnet = result
 			return nil
 		}
-		result = result.DeepCopy()
-		result.Status.UsedIPCount = usedIPCount
-		result.Status.UsedIP = usedIP
-		result.Status.Gateway = result.Spec.Gateway
-		result, err = h.subnetClient.UpdateStatus(result)
+		result, err = h.subnetClient.UpdateStatus(updated)
 		if err != nil {
 			return err
 		}
 		logrus.WithFields(fieldsSubnet(subnet)).
 			Infof("update subnet usedIP count to %d", result.Status.UsedIPCount)
-		logrus.WithFields(fieldsSubnet(subnet)).
-			Infof("update subnet usedIP to %v", utils.Print(result.Status.UsedIP))
 		subnet = result
 		return nil
 	})
 	if err != nil {
 		return subnet, fmt.Errorf("failed to update subnet usedIP: %w", err)
 	}
+	h.recordEvents(subnet, events)
+	if ipsSync != nil {
+		h.recordEvents(subnet, ipsSync.events)
+	}
 	return subnet, nil
 }
 
-func filterDuplicatedIP(
-	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
-) []*flv1.FlatNetworkIP {
-	duplicatedIPs := []*flv1.FlatNetworkIP{}
-	if len(ips) == 0 {
-		return duplicatedIPs
-	}
-	set := map[string]string{}
-	for _, ip := range ips {
-		if ip == nil || len(ip.Status.Addr.To16()) == 0 || ip.DeletionTimestamp != nil {
-			continue
-		}
-		a := ip.Status.Addr.String()
-		if set[a] == "" {
-			set[a] = ip.Name
-			continue
-		}
-		duplicatedIPs = append(duplicatedIPs, ip)
-		logrus.WithFields(fieldsSubnet(subnet)).
-			Warnf("found duplicated pod IP [%v] using by [%v] and [%v], will delete",
-				ip.Status.Addr.String(), ip.Name, set[a])
+// subnetIPsSync is the result of syncing the flat-network IPs using the
+// subnet.
+type subnetIPsSync struct {
+	quotaUsage        []flv1.SubnetQuotaUsage
+	outOfRangeIP      []flv1.OutOfRangeIP
+	outOfRangeIPCount int
+	cordonedRemaining int
+	events            []subnetEvent
+}
+
+// needSubnetIPsSync returns true if the subnet spec changed since the last
+// status update or the subnet is draining, the IPs of the subnet are synced
+// by the consistency check otherwise.
+func needSubnetIPsSync(subnet *flv1.FlatNetworkSubnet) bool {
+	c := meta.FindStatusCondition(subnet.Status.Conditions, flv1.SubnetConditionReady)
+	if c == nil || c.ObservedGeneration != subnet.Generation {
+		return true
 	}
-	return duplicatedIPs
+	return subnet.Spec.Cordoned && subnet.Spec.Drain != nil
 }
 
-func (h *handler) cleanupDuplicatedIPs(subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP) error {
-	if len(ips) == 0 {
-		return nil
+// syncSubnetIPs lists the flat-network IPs using the subnet to remediate
+// the duplicated IPs, count the quota usage, find the IPs outside of the
+// subnet CIDR & ranges and drain the cordoned subnet.
+// The sync walks all IPs of the subnet, it is skipped on the IP & IP block
+// events and the usage is updated from the IP blocks only.
+func (h *handler) syncSubnetIPs(subnet *flv1.FlatNetworkSubnet) (*subnetIPsSync, error) {
+	// List IPs using this subnet.
+	ips, err := h.listSubnetIPs(subnet)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, ip := range ips {
-		if len(ip.Status.Addr.To16()) == 0 || ip.DeletionTimestamp != nil {
-			continue
+	// Remediate the duplicated IPs using this subnet.
+	duplicatedEvents, err := h.remediateDuplicatedIPs(subnet, ips)
+	if err != nil {
+		h.recordEvents(subnet, duplicatedEvents)
+		return nil, err
+	}
+	quotaUsage, err := h.getSubnetQuotaUsage(subnet, ips)
+	if err != nil {
+		return nil, err
+	}
+	// Report the allocated IPs outside of the subnet CIDR & ranges after
+	// the subnet spec changed, these IPs are kept until the pods deleted.
+	outOfRangeIP := common.GetSubnetOutOfRangeIPs(subnet, ips)
+	// Move the pods out of the cordoned subnet.
+	cordonedRemaining, drainEvents := h.drainSubnet(subnet, ips)
+	return &subnetIPsSync{
+		quotaUsage:        quotaUsage,
+		outOfRangeIP:      firstStatusItems(outOfRangeIP),
+		outOfRangeIPCount: len(outOfRangeIP),
+		cordonedRemaining: cordonedRemaining,
+		events:            append(drainEvents, duplicatedEvents...),
+	}, nil
+}
+
+// handleIP enqueues the subnets of the flat-network IP to update the subnet
+// usage on the IP changes, only the usage, capacity and conditions are
+// updated from cache unless the consistency check is due.
+func (h *handler) handleIP(
+	_ string, ip *flv1.FlatNetworkIP,
+) (*flv1.FlatNetworkIP, error) {
+	if ip == nil {
+		return nil, nil
+	}
+	for _, name := range []string{ip.Spec.Subnet, ip.Spec.SecondarySubnet} {
+		if name != "" {
+			h.subnetEnqueueAfter(flv1.SubnetNamespace, name, usageSyncDelay)
 		}
-		err := h.podClient.Delete(ip.Namespace, ip.Name, &metav1.DeleteOptions{})
+	}
+	return ip, nil
+}
+
+// handleIPBlock enqueues the subnet of the IP block to refresh the subnet
+// usage, capacity and conditions.
+func (h *handler) handleIPBlock(
+	_ string, block *flv1.FlatNetworkIPBlock,
+) (*flv1.FlatNetworkIPBlock, error) {
+	if block == nil || block.Spec.Subnet == "" {
+		return block, nil
+	}
+	h.subnetEnqueueAfter(flv1.SubnetNamespace, block.Spec.Subnet, usageSyncDelay)
+	return block, nil
+}
+
+// listSubnetIPs lists the IPs using this subnet, including the IPs using this
+// subnet as the dual-stack secondary subnet.
+func (h *handler) listSubnetIPs(subnet *flv1.FlatNetworkSubnet) ([]*flv1.FlatNetworkIP, error) {
+	ips, err := h.ipCache.List("", labels.SelectorFromSet(labels.Set{
+		"subnet": subnet.Name,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP from cache: %w", err)
+	}
+	secondaryIPs, err := h.ipCache.List("", labels.SelectorFromSet(labels.Set{
+		"secondarySubnet": subnet.Name,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP from cache: %w", err)
+	}
+	return append(ips, secondaryIPs...), nil
+}
+
+// listSubnetIPsFromAPI lists the IPs using this subnet from the API server.
+func (h *handler) listSubnetIPsFromAPI(subnet *flv1.FlatNetworkSubnet) ([]*flv1.FlatNetworkIP, error) {
+	var ips []*flv1.FlatNetworkIP
+	for _, key := range []string{"subnet", "secondarySubnet"} {
+		list, err := h.ipClient.List("", metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(labels.Set{
+				key: subnet.Name,
+			}).String(),
+		})
 		if err != nil {
-			if !errors.IsNotFound(err) {
-				return fmt.Errorf("failed to delete pod [%v/%v]: %w",
-					ip.Namespace, ip.Name, err)
-			}
+			return nil, fmt.Errorf("failed to list IP: %w", err)
+		}
+		for i := range list.Items {
+			ips = append(ips, &list.Items[i])
 		}
-		logrus.WithFields(fieldsSubnet(subnet)).
-			Warnf("request to delete pod have duplicated IP [%v/%v]: %v",
-				ip.Namespace, ip.Name, ip.Status.Addr.String())
 	}
-	return nil
+	return ips, nil
+}
+
+// getSubnetUsedIP returns the used IPRanges and the number of the used
+// addresses of the subnet aggregated from the IP blocks, including the
+// gateway and the reserved addresses.
+func getSubnetUsedIP(
+	subnet *flv1.FlatNetworkSubnet,
+	blocks []*flv1.FlatNetworkIPBlock,
+	reservations map[string][]flv1.IPRange,
+) ([]flv1.IPRange, int) {
+	usedIP := common.MergeSubnetIPBlocks(subnet, blocks).Status.UsedIP
+	for _, ranges := range reservations {
+		usedIP = append(usedIP, ranges...)
+	}
+	usedIP = ipcalc.NewIPSet(usedIP).Ranges()
+	return usedIP, countIPRanges(usedIP)
 }
 
-func ip2UsedRanges(ips []*flv1.FlatNetworkIP) []flv1.IPRange {
-	var usedIPs []flv1.IPRange
-	if len(ips) == 0 {
-		return usedIPs
+// getSubnetQuotaUsage returns the current usage of the subnet quotas.
+func (h *handler) getSubnetQuotaUsage(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) ([]flv1.SubnetQuotaUsage, error) {
+	if len(subnet.Spec.Quotas) == 0 {
+		return nil, nil
 	}
-	slices.SortFunc(ips, func(a, b *flv1.FlatNetworkIP) int {
-		if a.Status.Addr == nil || b.Status.Addr == nil {
-			return -1
-		}
-		return bytes.Compare(a.Status.Addr, b.Status.Addr)
-	})
-	for _, ip := range ips {
-		usedIPs = ipcalc.AddIPToRange(ip.Status.Addr, usedIPs)
+	namespaces, err := h.nsCache.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespace from cache: %w", err)
+	}
+	nsProjects := make(map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		nsProjects[ns.Name] = common.GetNamespaceProjectID(ns)
+	}
+	return common.GetSubnetQuotaUsage(subnet, ips, nsProjects), nil
+}
+
+// getSubnetReservations returns the IP ranges of the active
+// FlatNetworkIPReservations using this subnet.
+func (h *handler) getSubnetReservations(
+	subnet *flv1.FlatNetworkSubnet,
+) (map[string][]flv1.IPRange, error) {
+	reservations, err := h.reservationCache.List("", labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP reservation from cache: %w", err)
 	}
-	return usedIPs
+	return common.GetSubnetIPReservations(subnet, reservations, time.Now()), nil
 }
 
 func fieldsSubnet(subnet *flv1.FlatNetworkSubnet) logrus.Fields {
@@ -1,80 +1,486 @@
 package flatnetworksubnet
 
 import (
+	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func Test_ip2UsedRanges(t *testing.T) {
-	usedIPs := ip2UsedRanges(nil)
-	assert.Equal(t, len(usedIPs), 0)
-
-	IPs := []*flv1.FlatNetworkIP{
-		{
-			Spec: flv1.IPSpec{},
-			Status: flv1.IPStatus{
-				Addr: net.ParseIP("10.1.2.3"),
+func Test_calcSubnetCapacity(t *testing.T) {
+	now := time.Now()
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			CIDR: "192.168.1.0/24",
+			Ranges: []flv1.IPRange{
+				{From: net.ParseIP("192.168.1.1"), To: net.ParseIP("192.168.1.10")},
+			},
+			IPReuseDelay: 60,
+		},
+		Status: flv1.SubnetStatus{
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("192.168.1.1"), To: net.ParseIP("192.168.1.4")},
+				{From: net.ParseIP("192.168.1.100"), To: net.ParseIP("192.168.1.100")},
+			},
+			ReservedIP: map[string][]flv1.IPRange{
+				"FlatNetworkIPReservation/default/r1": {
+					{From: net.ParseIP("192.168.1.3"), To: net.ParseIP("192.168.1.4")},
+				},
+			},
+			ReleasedIP: []flv1.ReleasedIP{
+				{Addr: net.ParseIP("192.168.1.5"), ReleasedTimestamp: metav1.NewTime(now)},
 			},
 		},
 	}
-	usedIPs = ip2UsedRanges(IPs)
-	assert.Equal(t, usedIPs, []flv1.IPRange{
-		{
-			From: net.ParseIP("10.1.2.3"),
-			To:   net.ParseIP("10.1.2.3"),
+	capacity, err := calcSubnetCapacity(subnet, now)
+	assert.Nil(t, err)
+	assert.Equal(t, flv1.SubnetCapacity{
+		Total:       "10",
+		Used:        "4",
+		Reserved:    "2",
+		Available:   "5",
+		UsedPercent: 40,
+	}, capacity)
+
+	// The reservation not overlapping the used IPs is not available.
+	subnet.Status.ReservedIP["FlatNetworkIPReservation/default/r2"] = []flv1.IPRange{
+		{From: net.ParseIP("192.168.1.8"), To: net.ParseIP("192.168.1.9")},
+	}
+	capacity, err = calcSubnetCapacity(subnet, now)
+	assert.Nil(t, err)
+	assert.Equal(t, flv1.SubnetCapacity{
+		Total:       "10",
+		Used:        "4",
+		Reserved:    "4",
+		Available:   "3",
+		UsedPercent: 40,
+	}, capacity)
+
+	// IPv6 subnet capacity exceeds int64.
+	subnet = &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			CIDR: "fd00::/56",
 		},
+	}
+	capacity, err = calcSubnetCapacity(subnet, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "4722366482869645213694", capacity.Total)
+	assert.Equal(t, "0", capacity.Used)
+}
+
+func Test_setCapacityConditions(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{}
+	subnet.Name = "subnet1"
+
+	events := setCapacityConditions(subnet, flv1.SubnetCapacity{
+		Available: "10", UsedPercent: 50,
 	})
+	assert.Equal(t, 0, len(events))
+	assert.False(t, meta.IsStatusConditionTrue(subnet.Status.Conditions, flv1.SubnetConditionNearlyExhausted))
+	assert.False(t, meta.IsStatusConditionTrue(subnet.Status.Conditions, flv1.SubnetConditionExhausted))
 
-	IPs = append(IPs, &flv1.FlatNetworkIP{
-		Spec: flv1.IPSpec{},
-		Status: flv1.IPStatus{
-			Addr: net.ParseIP("10.1.2.4"),
-		},
+	events = setCapacityConditions(subnet, flv1.SubnetCapacity{
+		Available: "1", UsedPercent: 95,
 	})
-	usedIPs = ip2UsedRanges(IPs)
-	assert.Equal(t, usedIPs, []flv1.IPRange{
-		{
-			From: net.ParseIP("10.1.2.3"),
-			To:   net.ParseIP("10.1.2.4"),
-		},
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, eventSubnetNearlyExhausted, events[0].reason)
+	assert.True(t, meta.IsStatusConditionTrue(subnet.Status.Conditions, flv1.SubnetConditionNearlyExhausted))
+
+	// No duplicated events.
+	events = setCapacityConditions(subnet, flv1.SubnetCapacity{
+		Available: "1", UsedPercent: 96,
 	})
+	assert.Equal(t, 0, len(events))
 
-	IPs = append(IPs, &flv1.FlatNetworkIP{
-		Spec: flv1.IPSpec{},
-		Status: flv1.IPStatus{
-			Addr: net.ParseIP("10.10.1.1"),
-		},
+	events = setCapacityConditions(subnet, flv1.SubnetCapacity{
+		Available: "0", UsedPercent: 100,
 	})
-	usedIPs = ip2UsedRanges(IPs)
-	assert.Equal(t, usedIPs, []flv1.IPRange{
-		{
-			From: net.ParseIP("10.1.2.3"),
-			To:   net.ParseIP("10.1.2.4"),
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, eventSubnetExhausted, events[0].reason)
+	assert.True(t, meta.IsStatusConditionTrue(subnet.Status.Conditions, flv1.SubnetConditionExhausted))
+
+	// Custom threshold.
+	subnet.Spec.NearlyExhaustedThreshold = 99
+	events = setCapacityConditions(subnet, flv1.SubnetCapacity{
+		Available: "5", UsedPercent: 95,
+	})
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, eventSubnetCapacityRecovered, events[0].reason)
+	assert.False(t, meta.IsStatusConditionTrue(subnet.Status.Conditions, flv1.SubnetConditionNearlyExhausted))
+	assert.False(t, meta.IsStatusConditionTrue(subnet.Status.Conditions, flv1.SubnetConditionExhausted))
+}
+
+func Test_outOfRangeIPEvent(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		Spec: flv1.SubnetSpec{
+			CIDR: "192.168.1.0/25",
 		},
-		{
-			From: net.ParseIP("10.10.1.1"),
-			To:   net.ParseIP("10.10.1.1"),
+		Status: flv1.SubnetStatus{
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("192.168.1.10"), To: net.ParseIP("192.168.1.10")},
+				{From: net.ParseIP("192.168.1.200"), To: net.ParseIP("192.168.1.200")},
+			},
 		},
-	})
+	}
+	// The capacity is recomputed by the shrunk CIDR.
+	capacity, err := calcSubnetCapacity(subnet, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "126", capacity.Total)
+	assert.Equal(t, "1", capacity.Used)
 
-	IPs = append(IPs, &flv1.FlatNetworkIP{
-		Spec: flv1.IPSpec{},
-		Status: flv1.IPStatus{
-			Addr: net.ParseIP("10.10.1.2"),
+	outOfRangeIP := []flv1.OutOfRangeIP{
+		{Addr: net.ParseIP("192.168.1.200"), FlatNetworkIP: "default/ip2"},
+	}
+	e := outOfRangeIPEvent(subnet, outOfRangeIP)
+	assert.NotNil(t, e)
+	assert.Equal(t, eventSubnetOutOfRangeIP, e.reason)
+	assert.Contains(t, e.message, "default/ip2(192.168.1.200)")
+
+	// Already reported
+	subnet.Status.OutOfRangeIP = outOfRangeIP
+	assert.Nil(t, outOfRangeIPEvent(subnet, outOfRangeIP))
+	assert.Nil(t, outOfRangeIPEvent(subnet, nil))
+}
+
+func Test_setCordonedCondition(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{}
+	setCordonedCondition(subnet, 2)
+	assert.Nil(t, meta.FindStatusCondition(subnet.Status.Conditions, flv1.SubnetConditionCordoned))
+
+	subnet.Spec.Cordoned = true
+	setCordonedCondition(subnet, 2)
+	c := meta.FindStatusCondition(subnet.Status.Conditions, flv1.SubnetConditionCordoned)
+	assert.Equal(t, reasonSubnetCordoned, c.Reason)
+
+	subnet.Spec.Drain = &flv1.SubnetDrain{}
+	setCordonedCondition(subnet, 2)
+	c = meta.FindStatusCondition(subnet.Status.Conditions, flv1.SubnetConditionCordoned)
+	assert.Equal(t, reasonSubnetDraining, c.Reason)
+	assert.Equal(t, "2 pods using the subnet", c.Message)
+	setCordonedCondition(subnet, 0)
+	c = meta.FindStatusCondition(subnet.Status.Conditions, flv1.SubnetConditionCordoned)
+	assert.Equal(t, reasonSubnetDrained, c.Reason)
+
+	subnet.Spec.Cordoned = false
+	subnet.Spec.Drain = nil
+	setCordonedCondition(subnet, 0)
+	assert.Nil(t, meta.FindStatusCondition(subnet.Status.Conditions, flv1.SubnetConditionCordoned))
+}
+
+func Test_needSubnetIPsSync(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{}
+	subnet.Generation = 1
+	assert.True(t, needSubnetIPsSync(subnet))
+
+	setReadyCondition(subnet, "")
+	assert.False(t, needSubnetIPsSync(subnet))
+
+	subnet.Spec.Cordoned = true
+	assert.False(t, needSubnetIPsSync(subnet))
+	subnet.Spec.Drain = &flv1.SubnetDrain{}
+	assert.True(t, needSubnetIPsSync(subnet))
+
+	subnet.Spec.Cordoned = false
+	subnet.Spec.Drain = nil
+	subnet.Generation = 2
+	assert.True(t, needSubnetIPsSync(subnet))
+}
+
+func Test_getPodWorkload(t *testing.T) {
+	controllers := map[string]*metav1.OwnerReference{
+		"ReplicaSet/rs": {Kind: "Deployment", Name: "deploy", Controller: utils.Ptr(true)},
+		"Job/job":       {Kind: "CronJob", Name: "cronjob", Controller: utils.Ptr(true)},
+	}
+	controllerOf := func(kind, name string) *metav1.OwnerReference {
+		return controllers[kind+"/"+name]
+	}
+	pod := &corev1.Pod{}
+	assert.Equal(t, "", getPodWorkload(pod, controllerOf))
+	pod.OwnerReferences = []metav1.OwnerReference{
+		{Kind: "ReplicaSet", Name: "rs", Controller: utils.Ptr(true)},
+	}
+	assert.Equal(t, "Deployment/deploy", getPodWorkload(pod, controllerOf))
+	pod.OwnerReferences[0].Kind = "Job"
+	pod.OwnerReferences[0].Name = "job"
+	assert.Equal(t, "CronJob/cronjob", getPodWorkload(pod, controllerOf))
+
+	// The bare ReplicaSet & Job are not rolled by the subnet drain.
+	pod.OwnerReferences[0].Kind = "ReplicaSet"
+	pod.OwnerReferences[0].Name = "bare"
+	assert.Equal(t, "ReplicaSet/bare", getPodWorkload(pod, controllerOf))
+	pod.OwnerReferences[0].Kind = "StatefulSet"
+	pod.OwnerReferences[0].Name = "sts"
+	assert.Equal(t, "StatefulSet/sts", getPodWorkload(pod, controllerOf))
+}
+
+func Test_groupDuplicatedIPs(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
 		},
+	}
+	newIP := func(name, addr string) *flv1.FlatNetworkIP {
+		return &flv1.FlatNetworkIP{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: flv1.IPSpec{
+				Subnet: "subnet1",
+			},
+			Status: flv1.IPStatus{
+				Addr: net.ParseIP(addr),
+			},
+		}
+	}
+	deleting := newIP("ip5", "10.0.0.3")
+	deleting.DeletionTimestamp = &metav1.Time{}
+	groups := groupDuplicatedIPs(subnet, []*flv1.FlatNetworkIP{
+		newIP("ip1", "10.0.0.1"),
+		newIP("ip2", "10.0.0.2"),
+		newIP("ip3", "10.0.0.1"),
+		newIP("ip4", "10.0.0.3"),
+		newIP("ip6", ""),
+		newIP("ip7", ""),
+		deleting,
 	})
-	usedIPs = ip2UsedRanges(IPs)
-	assert.Equal(t, usedIPs, []flv1.IPRange{
+	assert.Equal(t, 1, len(groups))
+	assert.Equal(t, 2, len(groups["10.0.0.1"]))
+	assert.Equal(t, "ip1", groups["10.0.0.1"][0].Name)
+	assert.Equal(t, "ip3", groups["10.0.0.1"][1].Name)
+}
+
+func Test_getSubnetDrift(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
+		},
+		Spec: flv1.SubnetSpec{
+			Gateway: net.ParseIP("10.0.0.1"),
+		},
+	}
+	block := &flv1.FlatNetworkIPBlock{
+		Spec: flv1.IPBlockSpec{
+			Subnet: "subnet1",
+		},
+		Status: flv1.IPBlockStatus{
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("10.0.0.2"), To: net.ParseIP("10.0.0.3")},
+			},
+			UsedMAC: []string{"02:00:00:00:00:02"},
+		},
+	}
+	newIP := func(addr, mac string) *flv1.FlatNetworkIP {
+		return &flv1.FlatNetworkIP{
+			Spec:   flv1.IPSpec{Subnet: "subnet1"},
+			Status: flv1.IPStatus{Addr: net.ParseIP(addr), MAC: mac},
+		}
+	}
+	ips := []*flv1.FlatNetworkIP{
+		newIP("10.0.0.2", "02:00:00:00:00:02"), newIP("10.0.0.3", ""),
+	}
+	c := getSubnetDrift(subnet, []*flv1.FlatNetworkIPBlock{block}, ips)
+	assert.True(t, isSubnetConsistent(&c))
+
+	// The address released but the IP block not updated.
+	c = getSubnetDrift(subnet, []*flv1.FlatNetworkIPBlock{block}, ips[:1])
+	assert.False(t, isSubnetConsistent(&c))
+	assert.Equal(t, "10.0.0.3", c.LeakedIP[0].From.String())
+	assert.Empty(t, c.UntrackedIP)
+
+	// The address allocated but not recorded in the IP block.
+	ips = append(ips, newIP("10.0.0.4", "02:00:00:00:00:04"))
+	c = getSubnetDrift(subnet, []*flv1.FlatNetworkIPBlock{block}, ips)
+	assert.Empty(t, c.LeakedIP)
+	assert.Equal(t, "10.0.0.4", c.UntrackedIP[0].From.String())
+	assert.Equal(t, []string{"02:00:00:00:00:04"}, c.UntrackedMAC)
+
+	s := summarizeSubnetDrift(&c)
+	assert.Equal(t, 1, s.UntrackedIPCount)
+	assert.Equal(t, 1, s.UntrackedMACCount)
+	events := setConsistentCondition(subnet, &s)
+	assert.Equal(t, 1, len(events))
+	assert.True(t, meta.IsStatusConditionFalse(
+		subnet.Status.Conditions, flv1.SubnetConditionConsistent))
+}
+
+func Test_summarizeSubnetDrift(t *testing.T) {
+	c := &flv1.SubnetConsistency{
+		LeakedIP: []flv1.IPRange{
+			{From: net.ParseIP("10.0.0.2"), To: net.ParseIP("10.0.0.101")},
+		},
+	}
+	for i := 0; i < flv1.MaxSubnetStatusItems+4; i++ {
+		c.LeakedIP = append(c.LeakedIP, flv1.IPRange{
+			From: net.IPv4(10, 0, 1, byte(i*2)), To: net.IPv4(10, 0, 1, byte(i*2)),
+		})
+		c.LeakedMAC = append(c.LeakedMAC, fmt.Sprintf("02:00:00:00:00:%02x", i))
+	}
+	s := summarizeSubnetDrift(c)
+	assert.Equal(t, 100+flv1.MaxSubnetStatusItems+4, s.LeakedIPCount)
+	assert.Equal(t, flv1.MaxSubnetStatusItems, len(s.LeakedIP))
+	assert.Equal(t, flv1.MaxSubnetStatusItems+4, s.LeakedMACCount)
+	assert.Equal(t, flv1.MaxSubnetStatusItems, len(s.LeakedMAC))
+	assert.Zero(t, s.UntrackedIPCount)
+	assert.Nil(t, s.UntrackedIP)
+}
+
+func Test_getSubnetUsedIP(t *testing.T) {
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
+		},
+		Spec: flv1.SubnetSpec{
+			Gateway: net.ParseIP("10.0.0.1"),
+		},
+	}
+	block := &flv1.FlatNetworkIPBlock{
+		Spec: flv1.IPBlockSpec{
+			Subnet: "subnet1",
+		},
+		Status: flv1.IPBlockStatus{
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("10.0.0.2"), To: net.ParseIP("10.0.0.3")},
+			},
+		},
+	}
+	reservations := map[string][]flv1.IPRange{
+		"reservation": {
+			{From: net.ParseIP("10.0.0.3"), To: net.ParseIP("10.0.0.10")},
+		},
+	}
+	usedIP, count := getSubnetUsedIP(subnet, []*flv1.FlatNetworkIPBlock{block}, reservations)
+	assert.Equal(t, 10, count)
+	assert.Equal(t, 1, len(usedIP))
+
+	_, count = getSubnetUsedIP(subnet, nil, nil)
+	assert.Equal(t, 1, count)
+}
+
+func Test_getExpectedIPBlocks(t *testing.T) {
+	now := time.Now()
+	subnet := &flv1.FlatNetworkSubnet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1",
+		},
+		Spec: flv1.SubnetSpec{
+			CIDR:         "10.0.0.0/24",
+			IPReuseDelay: 60,
+		},
+		Status: flv1.SubnetStatus{
+			// Legacy releasedIP recorded in the subnet status.
+			ReleasedIP: []flv1.ReleasedIP{
+				{Addr: net.ParseIP("10.0.0.70"), ReleasedTimestamp: metav1.NewTime(now)},
+			},
+			// Legacy reservedIP recorded in the subnet status.
+			ReservedIP: map[string][]flv1.IPRange{
+				"Deployment/default/test": {
+					{From: net.ParseIP("10.0.0.5"), To: net.ParseIP("10.0.0.5")},
+				},
+				"FlatNetworkIPReservation/default/r1": {
+					{From: net.ParseIP("10.0.0.200"), To: net.ParseIP("10.0.0.210")},
+				},
+			},
+		},
+	}
+	stale := &flv1.FlatNetworkIPBlock{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subnet1-10-0-0-128",
+		},
+		Spec: flv1.IPBlockSpec{
+			Subnet: "subnet1",
+		},
+		Status: flv1.IPBlockStatus{
+			UsedIP: []flv1.IPRange{
+				{From: net.ParseIP("10.0.0.130"), To: net.ParseIP("10.0.0.130")},
+			},
+			UsedIPCount: 1,
+		},
+	}
+	ips := []*flv1.FlatNetworkIP{
 		{
-			From: net.ParseIP("10.1.2.3"),
-			To:   net.ParseIP("10.1.2.4"),
+			Spec: flv1.IPSpec{Subnet: "subnet1"},
+			Status: flv1.IPStatus{
+				Addr: net.ParseIP("10.0.0.2"),
+				MAC:  "02:00:00:00:00:02",
+			},
 		},
 		{
-			From: net.ParseIP("10.10.1.1"),
-			To:   net.ParseIP("10.10.1.2"),
+			Spec: flv1.IPSpec{Subnet: "subnet1"},
+			Status: flv1.IPStatus{
+				Addr: net.ParseIP("10.0.0.3"),
+			},
 		},
-	})
+	}
+	blocks := getExpectedIPBlocks(subnet, []*flv1.FlatNetworkIPBlock{stale}, ips, nil, nil, now)
+	assert.Equal(t, 4, len(blocks))
+
+	b := blocks["subnet1-10-0-0-0"]
+	assert.NotNil(t, b)
+	assert.Equal(t, 2, b.Status.UsedIPCount)
+	assert.Empty(t, b.Status.UsedMAC)
+	assert.Equal(t, "subnet1", b.Labels["subnet"])
+	// The workload reserved IPs are migrated into the IP blocks.
+	assert.Equal(t, "10.0.0.5", b.Status.ReservedIP["Deployment/default/test"][0].From.String())
+	assert.Nil(t, blocks["subnet1-10-0-0-192"])
+
+	// The MAC addresses are recorded in the MAC block.
+	b = blocks["subnet1-mac"]
+	assert.NotNil(t, b)
+	assert.Equal(t, []string{"02:00:00:00:00:02"}, b.Status.UsedMAC)
+	assert.Empty(t, b.Spec.From)
+
+	b = blocks["subnet1-10-0-0-64"]
+	assert.NotNil(t, b)
+	assert.Equal(t, 0, b.Status.UsedIPCount)
+	assert.Equal(t, 1, len(b.Status.ReleasedIP))
+	assert.False(t, isIPBlockEmpty(b))
+
+	// The stale IP block is empty and should be deleted.
+	assert.True(t, isIPBlockEmpty(blocks["subnet1-10-0-0-128"]))
+	// The input IP block is not modified.
+	assert.Equal(t, 1, stale.Status.UsedIPCount)
+
+	// The address leaked the first time is kept in the IP block.
+	keep := ipcalc.NewIPSet(stale.Status.UsedIP)
+	blocks = getExpectedIPBlocks(subnet, []*flv1.FlatNetworkIPBlock{stale}, ips, keep, nil, now)
+	b = blocks["subnet1-10-0-0-128"]
+	assert.Equal(t, 1, b.Status.UsedIPCount)
+	assert.Equal(t, "10.0.0.130", b.Status.UsedIP[0].From.String())
+
+	// The leaked MAC recorded in the IP block is moved into the MAC block.
+	stale.Status.UsedMAC = []string{"02:00:00:00:00:03", "02:00:00:00:00:04"}
+	blocks = getExpectedIPBlocks(subnet, []*flv1.FlatNetworkIPBlock{stale}, ips,
+		nil, []string{"02:00:00:00:00:03"}, now)
+	assert.Empty(t, blocks["subnet1-10-0-0-128"].Status.UsedMAC)
+	assert.Equal(t, []string{"02:00:00:00:00:02", "02:00:00:00:00:03"},
+		blocks["subnet1-mac"].Status.UsedMAC)
+}
+
+func Test_getNewLeaks(t *testing.T) {
+	prev := &flv1.SubnetConsistency{
+		LeakedIP: []flv1.IPRange{
+			{From: net.ParseIP("10.0.0.2"), To: net.ParseIP("10.0.0.3")},
+		},
+		LeakedMAC: []string{"02:00:00:00:00:02"},
+	}
+	cur := &flv1.SubnetConsistency{
+		LeakedIP: []flv1.IPRange{
+			{From: net.ParseIP("10.0.0.3"), To: net.ParseIP("10.0.0.4")},
+		},
+		LeakedMAC: []string{"02:00:00:00:00:02", "02:00:00:00:00:04"},
+	}
+	keepIP, keepMAC := getNewLeaks(prev, cur)
+	// 10.0.0.3 leaked in both checks and should be released.
+	assert.False(t, keepIP.Contains(net.ParseIP("10.0.0.3")))
+	assert.True(t, keepIP.Contains(net.ParseIP("10.0.0.4")))
+	assert.Equal(t, []string{"02:00:00:00:00:04"}, keepMAC)
 }
@@ -0,0 +1,219 @@
+package flatnetworksubnet
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// listSubnetIPBlocks lists the IP blocks of the subnet from cache.
+func (h *handler) listSubnetIPBlocks(
+	subnet *flv1.FlatNetworkSubnet,
+) ([]*flv1.FlatNetworkIPBlock, error) {
+	blocks, err := h.ipBlockCache.List(flv1.SubnetNamespace, labels.SelectorFromSet(labels.Set{
+		common.LabelIPBlockSubnet: subnet.Name,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP blocks from cache: %w", err)
+	}
+	return blocks, nil
+}
+
+// getExpectedIPBlocks returns the expected IP blocks of the subnet by the
+// addresses used by the flat-network IPs.
+// The released IPs and the workload reserved IPs recorded in the subnet
+// status by the old versions are migrated into the IP blocks, the IP blocks
+// without any used, released or reserved address are returned with the
+// empty status.
+// The MAC addresses are recorded in the MAC block of the subnet.
+// The addresses in keepIP and the MACs in keepMAC (sorted) recorded in the
+// IP blocks are kept even if not used by any flat-network IP.
+func getExpectedIPBlocks(
+	subnet *flv1.FlatNetworkSubnet,
+	blocks []*flv1.FlatNetworkIPBlock,
+	ips []*flv1.FlatNetworkIP,
+	keepIP *ipcalc.IPSet,
+	keepMAC []string,
+	now time.Time,
+) map[string]*flv1.FlatNetworkIPBlock {
+	result := map[string]*flv1.FlatNetworkIPBlock{}
+	getBlock := func(addr net.IP) *flv1.FlatNetworkIPBlock {
+		name := common.GetIPBlockName(subnet.Name, addr)
+		if b, ok := result[name]; ok {
+			return b
+		}
+		b := common.NewIPBlock(subnet, addr)
+		result[name] = b
+		return b
+	}
+	usedIPSets := map[string]*ipcalc.IPSet{}
+	var keptMAC []string
+	for _, b := range blocks {
+		if b == nil || b.Spec.Subnet != subnet.Name {
+			continue
+		}
+		b = b.DeepCopy()
+		if keepIP != nil {
+			// Intersection of the recorded addresses and keepIP.
+			recorded := ipcalc.NewIPSet(b.Status.UsedIP)
+			if kept := recorded.Difference(recorded.Difference(keepIP)); kept.Len().Sign() > 0 {
+				usedIPSets[b.Name] = kept
+			}
+		}
+		b.Status.UsedIP = nil
+		b.Status.UsedIPCount = 0
+		result[b.Name] = b
+		// The leaked MACs in keepMAC are moved into the MAC block.
+		for _, m := range b.Status.UsedMAC {
+			if _, ok := slices.BinarySearch(keepMAC, m); ok {
+				keptMAC = append(keptMAC, m)
+			}
+		}
+		b.Status.UsedMAC = nil
+	}
+	macBlock := result[common.GetMACBlockName(subnet.Name)]
+	if macBlock == nil {
+		macBlock = common.NewMACBlock(subnet)
+		result[macBlock.Name] = macBlock
+	}
+	macBlock.Status.UsedMAC = keptMAC
+	for _, r := range subnet.Status.ReleasedIP {
+		if len(r.Addr) == 0 {
+			continue
+		}
+		b := getBlock(r.Addr)
+		b.Status.ReleasedIP = append(b.Status.ReleasedIP, r)
+	}
+	migratedKeys := map[string]bool{}
+	for _, b := range result {
+		for k := range b.Status.ReservedIP {
+			migratedKeys[k] = true
+		}
+	}
+	for k, ranges := range subnet.Status.ReservedIP {
+		// The FlatNetworkIPReservation ranges are read from the reservations.
+		if common.IsIPReservationKey(k) || migratedKeys[k] {
+			continue
+		}
+		for _, r := range common.GetIPBlocksReservedIP(subnet.Name, ranges) {
+			b := getBlock(r[0].From)
+			if b.Status.ReservedIP == nil {
+				b.Status.ReservedIP = map[string][]flv1.IPRange{}
+			}
+			b.Status.ReservedIP[k] = r
+		}
+	}
+
+	for _, ip := range ips {
+		addr := common.GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)
+		if ip == nil || ip.DeletionTimestamp != nil || len(addr) == 0 {
+			continue
+		}
+		b := getBlock(addr)
+		if usedIPSets[b.Name] == nil {
+			usedIPSets[b.Name] = ipcalc.NewIPSet(nil)
+		}
+		usedIPSets[b.Name].Add(addr)
+		// The MAC address is recorded in the MAC block of the primary subnet.
+		if ip.Spec.Subnet == subnet.Name && ip.Status.MAC != "" {
+			macBlock.Status.UsedMAC = append(macBlock.Status.UsedMAC, ip.Status.MAC)
+		}
+	}
+	for name, b := range result {
+		if s := usedIPSets[name]; s != nil {
+			b.Status.UsedIP = s.Ranges()
+			b.Status.UsedIPCount = int(s.Len().Int64())
+		}
+		slices.Sort(b.Status.UsedMAC)
+		b.Status.UsedMAC = slices.Compact(b.Status.UsedMAC)
+		if len(b.Status.UsedMAC) == 0 {
+			b.Status.UsedMAC = nil
+		}
+		released := ipcalc.TrimReleasedIP(b.Status.ReleasedIP, &subnet.Spec, now)
+		released = slices.DeleteFunc(released, func(r flv1.ReleasedIP) bool {
+			return ipcalc.IPInRanges(r.Addr, b.Status.UsedIP)
+		})
+		if len(released) == 0 {
+			released = nil
+		}
+		b.Status.ReleasedIP = released
+	}
+	return result
+}
+
+// isIPBlockEmpty returns true if the IP block does not have any used,
+// released or reserved address.
+func isIPBlockEmpty(block *flv1.FlatNetworkIPBlock) bool {
+	return len(block.Status.UsedIP) == 0 && len(block.Status.UsedMAC) == 0 &&
+		len(block.Status.ReleasedIP) == 0 && len(block.Status.ReservedIP) == 0
+}
+
+// syncIPBlocks ensures the IP blocks of the subnet are consistent with the
+// addresses used by the flat-network IPs, the empty IP blocks are deleted.
+// The leaked addresses in keepIP and keepMAC are not released.
+// The expected non-empty IP blocks are returned.
+func (h *handler) syncIPBlocks(
+	subnet *flv1.FlatNetworkSubnet,
+	blocks []*flv1.FlatNetworkIPBlock,
+	ips []*flv1.FlatNetworkIP,
+	keepIP *ipcalc.IPSet,
+	keepMAC []string,
+) ([]*flv1.FlatNetworkIPBlock, error) {
+	existing := make(map[string]*flv1.FlatNetworkIPBlock, len(blocks))
+	for _, b := range blocks {
+		existing[b.Name] = b
+	}
+	var result []*flv1.FlatNetworkIPBlock
+	expected := getExpectedIPBlocks(subnet, blocks, ips, keepIP, keepMAC, time.Now())
+	for name, b := range expected {
+		current := existing[name]
+		switch {
+		case isIPBlockEmpty(b):
+			if current == nil {
+				continue
+			}
+			err := h.ipBlockClient.Delete(current.Namespace, current.Name, &metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{
+					ResourceVersion: &current.ResourceVersion,
+				},
+			})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to delete IP block [%v]: %w", name, err)
+			}
+			logrus.WithFields(fieldsSubnet(subnet)).
+				Infof("delete empty IP block [%v]", name)
+			continue
+		case current == nil:
+			created, err := h.ipBlockClient.Create(b)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create IP block [%v]: %w", name, err)
+			}
+			created.Status = b.Status
+			b = created
+		case equality.Semantic.DeepEqual(current.Status, b.Status):
+			result = append(result, current)
+			continue
+		}
+		b, err := h.ipBlockClient.UpdateStatus(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update IP block [%v] status: %w", name, err)
+		}
+		logrus.WithFields(fieldsSubnet(subnet)).
+			Infof("update IP block [%v] usedIP to %v",
+				name, utils.Print(b.Status.UsedIP))
+		result = append(result, b)
+	}
+	return result, nil
+}
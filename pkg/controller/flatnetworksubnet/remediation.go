@@ -0,0 +1,143 @@
+package flatnetworksubnet
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	eventSubnetDuplicatedIP = "SubnetDuplicatedIP"
+
+	ipFailedPhase = "Failed"
+)
+
+// groupDuplicatedIPs returns the IPs sharing the same address of the subnet
+// grouped by the address.
+func groupDuplicatedIPs(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) map[string][]*flv1.FlatNetworkIP {
+	set := map[string][]*flv1.FlatNetworkIP{}
+	for _, ip := range ips {
+		if ip == nil || ip.DeletionTimestamp != nil {
+			continue
+		}
+		addr := common.GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)
+		if len(addr.To16()) == 0 {
+			continue
+		}
+		set[addr.String()] = append(set[addr.String()], ip)
+	}
+	for a, s := range set {
+		if len(s) < 2 {
+			delete(set, a)
+		}
+	}
+	return set
+}
+
+// remediateDuplicatedIPs applies the subnet remediation policies to the
+// pods sharing the same IP address, returns the events should be recorded.
+func (h *handler) remediateDuplicatedIPs(
+	subnet *flv1.FlatNetworkSubnet, ips []*flv1.FlatNetworkIP,
+) ([]subnetEvent, error) {
+	r := common.GetSubnetRemediation(subnet)
+	groups := groupDuplicatedIPs(subnet, ips)
+	addrs := make([]string, 0, len(groups))
+	for a := range groups {
+		addrs = append(addrs, a)
+	}
+	slices.Sort(addrs)
+
+	var events []subnetEvent
+	remediated := map[string]bool{}
+	for _, a := range addrs {
+		for _, ip := range common.GetDuplicatedIPsToRemediate(groups[a], r.DuplicateTieBreaker) {
+			remediated[ip.Namespace+"/"+ip.Name] = true
+			if ip.Status.Remediation == flv1.RemediationDuplicatedIP {
+				// Already remediated.
+				continue
+			}
+			podName := common.GetFlatNetworkIPPodName(ip)
+			message := fmt.Sprintf("pod [%v/%v] have duplicated IP [%v]",
+				ip.Namespace, podName, a)
+			switch r.DuplicateAction {
+			case flv1.RemediationActionEvent, flv1.RemediationActionMarkFailed:
+				phase := ip.Status.Phase
+				if r.DuplicateAction == flv1.RemediationActionMarkFailed {
+					phase = ipFailedPhase
+				}
+				if err := h.setIPRemediation(ip, flv1.RemediationDuplicatedIP, phase, message); err != nil {
+					return events, err
+				}
+			default:
+				err := h.podClient.Delete(ip.Namespace, podName, &metav1.DeleteOptions{})
+				if err != nil && !errors.IsNotFound(err) {
+					return events, fmt.Errorf("failed to delete pod [%v/%v]: %w",
+						ip.Namespace, podName, err)
+				}
+			}
+			logrus.WithFields(fieldsSubnet(subnet)).
+				Warnf("%v, remediation action [%v]", message, r.DuplicateAction)
+			events = append(events, subnetEvent{
+				eventType: corev1.EventTypeWarning,
+				reason:    eventSubnetDuplicatedIP,
+				message:   fmt.Sprintf("%v, remediation action [%v]", message, r.DuplicateAction),
+			})
+		}
+	}
+
+	// Cleanup the remediation reason of the IPs no longer duplicated,
+	// the IPs marked as Failed are kept until the pods deleted.
+	for _, ip := range ips {
+		if ip == nil || ip.DeletionTimestamp != nil ||
+			ip.Status.Remediation != flv1.RemediationDuplicatedIP ||
+			ip.Status.Phase == ipFailedPhase || remediated[ip.Namespace+"/"+ip.Name] {
+			continue
+		}
+		if err := h.setIPRemediation(ip, "", ip.Status.Phase, ""); err != nil {
+			return events, err
+		}
+	}
+	return events, nil
+}
+
+// setIPRemediation records the remediation reason in the IP status.
+func (h *handler) setIPRemediation(
+	ip *flv1.FlatNetworkIP, remediation, phase, message string,
+) error {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		result, err := h.ipCache.Get(ip.Namespace, ip.Name)
+		if err != nil {
+			return err
+		}
+		result = result.DeepCopy()
+		result.Status.Remediation = remediation
+		result.Status.Phase = phase
+		if phase == ipFailedPhase {
+			result.Status.FailureMessage = message
+		}
+		if remediation == flv1.RemediationDuplicatedIP {
+			common.SetIPCondition(result, flv1.IPConditionConflict, metav1.ConditionTrue,
+				flv1.RemediationDuplicatedIP, message)
+		} else {
+			common.SetIPCondition(result, flv1.IPConditionConflict, metav1.ConditionFalse,
+				"NoConflict", "")
+		}
+		_, err = h.ipClient.UpdateStatus(result)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update IP [%v/%v] remediation status: %w",
+			ip.Namespace, ip.Name, err)
+	}
+	return nil
+}
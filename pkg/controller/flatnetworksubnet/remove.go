@@ -5,9 +5,14 @@ import (
 	"net"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 	"github.com/sirupsen/logrus"
-	"k8s.io/apimachinery/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	eventSubnetDeletionBlocked = "SubnetDeletionBlocked"
 )
 
 func (h *handler) handleSubnetRemove(
@@ -18,25 +23,32 @@ func (h *handler) handleSubnetRemove(
 	}
 
 	// List IPs using this subnet.
-	ips, err := h.ipCache.List("", labels.SelectorFromSet(labels.Set{
-		"subnet": subnet.Name,
-	}))
+	ips, err := h.listSubnetIPs(subnet)
 	if err != nil {
-		return subnet, fmt.Errorf("handleSubnetRemove: failed to list IP by subnet [%v] from cache: %w",
+		return subnet, fmt.Errorf("handleSubnetRemove: failed to list IP by subnet [%v]: %w",
 			subnet.Name, err)
 	}
+	// Block the subnet deletion by the finalizer while still in use.
+	blocks, err := h.listSubnetIPBlocks(subnet)
+	if err != nil {
+		return subnet, fmt.Errorf("handleSubnetRemove: %w", err)
+	}
+	if err := common.CheckSubnetDelete(common.MergeSubnetIPBlocks(subnet, blocks), ips); err != nil {
+		h.recorder.Event(subnet, corev1.EventTypeWarning, eventSubnetDeletionBlocked, err.Error())
+		return subnet, fmt.Errorf("handleSubnetRemove: %w", err)
+	}
 	if len(ips) != 0 {
-		// TODO: add more logics here if needed.
-
 		usedMap := map[string]net.IP{}
 		for _, ip := range ips {
-			usedMap[ip.Name] = ip.Status.Addr
+			usedMap[ip.Name] = common.GetFlatNetworkIPAddrOfSubnet(ip, subnet.Name)
 		}
 		logrus.WithFields(fieldsSubnet(subnet)).
-			Warnf("subnet [%v] deleted, but still have following IPs in use: %v",
+			Warnf("subnet [%v] force deleted, but still have following IPs in use: %v",
 				subnet.Name, utils.Print(usedMap))
 	}
 
+	lastSubnetDrift.Delete(subnet.Name)
+
 	subnet = subnet.DeepCopy()
 	subnet.Status.Phase = ""
 	subnetUpdate, err := h.subnetClient.UpdateStatus(subnet)
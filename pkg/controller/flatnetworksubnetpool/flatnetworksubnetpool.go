@@ -0,0 +1,184 @@
+package flatnetworksubnetpool
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
+	"github.com/cnrancher/rancher-flat-network/pkg/controller/wrangler"
+	"github.com/cnrancher/rancher-flat-network/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flcontroller "github.com/cnrancher/rancher-flat-network/pkg/generated/controllers/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	handlerName = "rancher-flat-network-subnetpool"
+
+	// resyncInterval is the interval to refresh the subnet pool status
+	// by the selected subnets.
+	resyncInterval = time.Minute
+)
+
+const (
+	subnetPoolActivePhase = "Active"
+	subnetPoolFailedPhase = "Failed"
+)
+
+type handler struct {
+	poolClient  flcontroller.FlatNetworkSubnetPoolClient
+	poolCache   flcontroller.FlatNetworkSubnetPoolCache
+	subnetCache flcontroller.FlatNetworkSubnetCache
+
+	poolEnqueueAfter func(string, string, time.Duration)
+}
+
+func Register(
+	ctx context.Context,
+	wctx *wrangler.Context,
+) {
+	h := &handler{
+		poolClient:  wctx.FlatNetwork.FlatNetworkSubnetPool(),
+		poolCache:   wctx.FlatNetwork.FlatNetworkSubnetPool().Cache(),
+		subnetCache: wctx.FlatNetwork.FlatNetworkSubnet().Cache(),
+
+		poolEnqueueAfter: wctx.FlatNetwork.FlatNetworkSubnetPool().EnqueueAfter,
+	}
+
+	wctx.FlatNetwork.FlatNetworkSubnetPool().OnChange(ctx, handlerName, h.handleError(h.handlePool))
+}
+
+func (h *handler) handleError(
+	onChange func(string, *flv1.FlatNetworkSubnetPool) (*flv1.FlatNetworkSubnetPool, error),
+) func(string, *flv1.FlatNetworkSubnetPool) (*flv1.FlatNetworkSubnetPool, error) {
+	return func(key string, pool *flv1.FlatNetworkSubnetPool) (*flv1.FlatNetworkSubnetPool, error) {
+		var message string
+		var err error
+		pool, err = onChange(key, pool)
+		if err != nil {
+			logrus.WithFields(fieldsPool(pool)).
+				Error(err)
+			message = err.Error()
+		}
+		if pool == nil || pool.Name == "" || pool.DeletionTimestamp != nil {
+			return pool, err
+		}
+		if pool.Status.FailureMessage == message {
+			return pool, err
+		}
+
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			pool, err := h.poolCache.Get(pool.Namespace, pool.Name)
+			if err != nil {
+				return err
+			}
+			pool = pool.DeepCopy()
+			if message != "" {
+				pool.Status.Phase = subnetPoolFailedPhase
+			}
+			pool.Status.FailureMessage = message
+
+			_, err = h.poolClient.UpdateStatus(pool)
+			return err
+		})
+		if err != nil {
+			logrus.Errorf("error recording subnet pool [%s] failure message: %v", pool.Name, err)
+			return pool, err
+		}
+		return pool, nil
+	}
+}
+
+func (h *handler) handlePool(
+	_ string, pool *flv1.FlatNetworkSubnetPool,
+) (*flv1.FlatNetworkSubnetPool, error) {
+	if pool == nil || pool.Name == "" || pool.DeletionTimestamp != nil {
+		return pool, nil
+	}
+	// Refresh the pool status as the subnets capacity changes.
+	defer h.poolEnqueueAfter(pool.Namespace, pool.Name, resyncInterval)
+
+	subnets, err := h.subnetCache.List(flv1.SubnetNamespace, labels.Everything())
+	if err != nil {
+		return pool, fmt.Errorf("failed to list subnets from cache: %w", err)
+	}
+	subnets, err = common.GetSubnetPoolSubnets(pool, subnets)
+	if err != nil {
+		return pool, err
+	}
+	status, err := getPoolStatus(subnets)
+	if err != nil {
+		return pool, err
+	}
+	return h.updateStatus(pool, status)
+}
+
+// getPoolStatus returns the status of the subnet pool by the subnets of the
+// pool in priority order.
+func getPoolStatus(subnets []*flv1.FlatNetworkSubnet) (flv1.SubnetPoolStatus, error) {
+	status := flv1.SubnetPoolStatus{
+		Phase: subnetPoolActivePhase,
+	}
+	available := new(big.Int)
+	for _, subnet := range subnets {
+		if status.FlatMode != "" && status.FlatMode != subnet.Spec.FlatMode {
+			return status, fmt.Errorf("subnet [%v] flatMode [%v] mismatch with other subnets [%v] in the pool",
+				subnet.Name, subnet.Spec.FlatMode, status.FlatMode)
+		}
+		status.FlatMode = subnet.Spec.FlatMode
+		status.Subnets = append(status.Subnets, subnet.Name)
+		if subnet.Spec.Cordoned {
+			continue
+		}
+		if a, ok := new(big.Int).SetString(subnet.Status.Capacity.Available, 10); ok {
+			available.Add(available, a)
+		}
+	}
+	status.Available = available.String()
+	return status, nil
+}
+
+func (h *handler) updateStatus(
+	pool *flv1.FlatNetworkSubnetPool, status flv1.SubnetPoolStatus,
+) (*flv1.FlatNetworkSubnetPool, error) {
+	if equality.Semantic.DeepEqual(pool.Status, status) {
+		return pool, nil
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		result, err := h.poolCache.Get(pool.Namespace, pool.Name)
+		if err != nil {
+			return err
+		}
+		result = result.DeepCopy()
+		result.Status = status
+		result, err = h.poolClient.UpdateStatus(result)
+		if err != nil {
+			return err
+		}
+		pool = result
+		return nil
+	})
+	if err != nil {
+		return pool, fmt.Errorf("failed to update subnet pool status: %w", err)
+	}
+	logrus.WithFields(fieldsPool(pool)).
+		Infof("update subnet pool status subnets %v available [%v]",
+			utils.Print(status.Subnets), status.Available)
+	return pool, nil
+}
+
+func fieldsPool(pool *flv1.FlatNetworkSubnetPool) logrus.Fields {
+	if pool == nil {
+		return logrus.Fields{}
+	}
+	return logrus.Fields{
+		"GID":        utils.GID(),
+		"SubnetPool": fmt.Sprintf("%v/%v", pool.Namespace, pool.Name),
+	}
+}
@@ -146,6 +146,9 @@ func getNetAttachDefConfig() string {
 	netAttachDefConfig := `{
     "cniVersion": "1.0.0",
     "type": "rancher-flat-network-cni",
+    "capabilities": {
+        "bandwidth": true
+    },
     "dns": {},
     "ipam": {
         "type": "static-ipam"
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cnrancher/rancher-flat-network/pkg/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/wrangler"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 	"github.com/sirupsen/logrus"
@@ -34,6 +35,8 @@ type handler struct {
 	subnetCache  flcontroller.FlatNetworkSubnetCache
 	subnetClient flcontroller.FlatNetworkSubnetController
 
+	subnetPoolCache flcontroller.FlatNetworkSubnetPoolCache
+
 	namespaceCache   corecontroller.NamespaceCache
 	deploymentCache  appscontroller.DeploymentCache
 	daemonSetCache   appscontroller.DaemonSetCache
@@ -60,6 +63,8 @@ func Register(
 		subnetCache:  wctx.FlatNetwork.FlatNetworkSubnet().Cache(),
 		subnetClient: wctx.FlatNetwork.FlatNetworkSubnet(),
 
+		subnetPoolCache: wctx.FlatNetwork.FlatNetworkSubnetPool().Cache(),
+
 		namespaceCache:   wctx.Core.Namespace().Cache(),
 		deploymentCache:  wctx.Apps.Deployment().Cache(),
 		daemonSetCache:   wctx.Apps.DaemonSet().Cache(),
@@ -96,37 +101,57 @@ func (h *handler) sync(_ string, pod *corev1.Pod) (*corev1.Pod, error) {
 	if !utils.IsPodEnabledFlatNetwork(pod) {
 		return pod, nil
 	}
+	interfaces, err := common.GetPodInterfaces(pod.Annotations)
+	if err != nil {
+		h.eventFlatNetworkIPError(pod, err)
+		return pod, fmt.Errorf("failed to get pod flat-network interfaces: %w", err)
+	}
 	if pod.DeletionTimestamp != nil {
 		// The pod is deleting.
-		err := h.ipClient.Delete(pod.Namespace, pod.Name, &metav1.DeleteOptions{})
-		if err != nil {
-			if apierrors.IsNotFound(err) {
-				return pod, nil
+		for _, iface := range interfaces {
+			ip, err := h.getFlatNetworkIP(pod, &iface)
+			if err != nil {
+				return pod, err
+			}
+			if ip == nil || ip.Spec.Interface != iface.Interface {
+				continue
+			}
+			err = h.ipClient.Delete(pod.Namespace, ip.Name, &metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return pod, err
 			}
-			return pod, err
 		}
 		return pod, nil
 	}
 
-	// Ensure FlatNetwork IP resource created.
-	flatnetworkIP, err := h.ensureFlatNetworkIP(pod)
-	if err != nil {
-		h.eventFlatNetworkIPError(pod, err)
-		return pod, fmt.Errorf("ensureFlatNetworkIP: %w", err)
-	}
-	if flatnetworkIP == nil || flatnetworkIP.Status.Phase != "Active" {
-		logrus.WithFields(fieldsPod(pod)).
-			Debugf("waiting for flat-network IP status to active")
+	// Ensure FlatNetwork IP resources created for each pod interface.
+	var defaultIP *flv1.FlatNetworkIP
+	for i, iface := range interfaces {
+		flatnetworkIP, err := h.ensureFlatNetworkIP(pod, &iface)
+		if err != nil {
+			h.eventFlatNetworkIPError(pod, err)
+			return pod, fmt.Errorf("ensureFlatNetworkIP: %w", err)
+		}
+		if flatnetworkIP == nil || flatnetworkIP.Status.Phase != "Active" {
+			logrus.WithFields(fieldsPod(pod)).
+				Debugf("waiting for flat-network IP status to active")
 
-		// Requeue in few seconds to wait for IP status active.
-		// This will not block the pod creation process and just waiting for
-		// a few seconds to update the pod flat-network labels.
-		h.podEnqueueAfter(pod.Namespace, pod.Name, time.Second*5)
+			// Requeue in few seconds to wait for IP status active.
+			// This will not block the pod creation process and just waiting for
+			// a few seconds to update the pod flat-network labels.
+			h.podEnqueueAfter(pod.Namespace, pod.Name, time.Second*5)
+			return pod, nil
+		}
+		if i == 0 {
+			defaultIP = flatnetworkIP
+		}
+	}
+	if defaultIP == nil {
 		return pod, nil
 	}
 
-	// Ensure Pod label updated with the FlatNetworkIP.
-	if err = h.updatePodLabel(pod, flatnetworkIP); err != nil {
+	// Ensure Pod label updated with the default FlatNetworkIP.
+	if err = h.updatePodLabel(pod, defaultIP); err != nil {
 		h.eventFlatNetworkIPError(pod, err)
 		return pod, err
 	}
@@ -134,20 +159,33 @@ func (h *handler) sync(_ string, pod *corev1.Pod) (*corev1.Pod, error) {
 	return pod, nil
 }
 
-// ensureFlatNetworkIP ensure the FlatNetworkIP resource exists.
-func (h *handler) ensureFlatNetworkIP(pod *corev1.Pod) (*flv1.FlatNetworkIP, error) {
-	existFlatNetworkIP, err := h.ipCache.Get(pod.Namespace, pod.Name)
+// ensureFlatNetworkIP ensure the FlatNetworkIP resource of the pod interface
+// exists.
+func (h *handler) ensureFlatNetworkIP(
+	pod *corev1.Pod, iface *flv1.PodInterface,
+) (*flv1.FlatNetworkIP, error) {
+	existFlatNetworkIP, err := h.getFlatNetworkIP(pod, iface)
 	if err != nil {
-		if !apierrors.IsNotFound(err) {
-			logrus.WithFields(fieldsPod(pod)).
-				Errorf("failed to get flat-network IP: %v", err)
-			return nil, err
-		}
+		logrus.WithFields(fieldsPod(pod)).
+			Errorf("failed to get flat-network IP: %v", err)
+		return nil, err
+	}
+	if existFlatNetworkIP != nil && existFlatNetworkIP.Spec.Interface != iface.Interface {
+		// The pod name may conflict with the '<pod>-<interface>' name of
+		// the FlatNetworkIP created by previous versions for another pod.
+		return nil, fmt.Errorf("flat-network IP [%v/%v] already used by interface [%v] of another pod",
+			pod.Namespace, existFlatNetworkIP.Name, existFlatNetworkIP.Spec.Interface)
 	}
-	expectedIP, err := h.newFlatNetworkIP(pod)
+	expectedIP, err := h.newFlatNetworkIP(pod, iface)
 	if err != nil {
 		return expectedIP, err
 	}
+	if existFlatNetworkIP != nil {
+		// Keep the name of the FlatNetworkIP created by previous versions.
+		expectedIP.Name = existFlatNetworkIP.Name
+	}
+	name := expectedIP.Name
+	keepSubnetPoolSelection(expectedIP, existFlatNetworkIP)
 	h.setIfStatefulSetOwnerRef(expectedIP, pod)
 	h.setWorkloadAndProjectLabel(expectedIP, pod)
 	if flatNetworkIPUpdated(existFlatNetworkIP, expectedIP) {
@@ -158,7 +196,7 @@ func (h *handler) ensureFlatNetworkIP(pod *corev1.Pod) (*flv1.FlatNetworkIP, err
 	if existFlatNetworkIP != nil {
 		// FlatNetworkIP already exists, update specs
 		err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-			existFlatNetworkIP, err = h.ipCache.Get(pod.Namespace, pod.Name)
+			existFlatNetworkIP, err = h.ipCache.Get(pod.Namespace, name)
 			if err != nil {
 				return err
 			}
@@ -179,7 +217,7 @@ func (h *handler) ensureFlatNetworkIP(pod *corev1.Pod) (*flv1.FlatNetworkIP, err
 		}
 		logrus.WithFields(fieldsPod(pod)).
 			Infof("request to update flat-network IP [%v/%v]",
-				pod.Namespace, pod.Name)
+				pod.Namespace, name)
 	}
 
 	// FlatNetworkIP not exists, create
@@ -195,36 +233,75 @@ func (h *handler) ensureFlatNetworkIP(pod *corev1.Pod) (*flv1.FlatNetworkIP, err
 	}
 	logrus.WithFields(fieldsPod(pod)).
 		Infof("request to create flat-network IP [%v/%v]",
-			pod.Namespace, pod.Name)
+			pod.Namespace, name)
 
 	return createdFlatNetworkIP, nil
 }
 
+// getFlatNetworkIP returns the existing FlatNetworkIP named for the pod
+// interface from cache, nil is returned if not found.
+// The additional attachment FlatNetworkIP created by previous versions is
+// named by '<pod>-<interface>', which is only used if created for the same
+// interface of the pod.
+func (h *handler) getFlatNetworkIP(
+	pod *corev1.Pod, iface *flv1.PodInterface,
+) (*flv1.FlatNetworkIP, error) {
+	ip, err := h.ipCache.Get(pod.Namespace,
+		common.GetFlatNetworkIPName(pod.Name, iface.Interface))
+	if err == nil || !apierrors.IsNotFound(err) {
+		return ip, err
+	}
+	if iface.Interface == "" {
+		return nil, nil
+	}
+	ip, err = h.ipCache.Get(pod.Namespace,
+		common.GetLegacyFlatNetworkIPName(pod.Name, iface.Interface))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if ip.Spec.Interface != iface.Interface || ip.Spec.PodID != string(pod.UID) {
+		return nil, nil
+	}
+	return ip, nil
+}
+
 func (h *handler) updatePodLabel(pod *corev1.Pod, ip *flv1.FlatNetworkIP) error {
 	if pod.Labels == nil {
 		pod.Labels = make(map[string]string)
 	}
 	annotationIP := pod.Annotations[flv1.AnnotationIP]
-	annotationSubnet := pod.Annotations[flv1.AnnotationSubnet]
 	annotationMac := pod.Annotations[flv1.AnnotationMac]
 
-	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, annotationSubnet)
+	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, ip.Spec.Subnet)
 	if err != nil {
 		return fmt.Errorf("failed to get subnet %q from cache: %w",
-			annotationSubnet, err)
+			ip.Spec.Subnet, err)
 	}
 
 	labels := map[string]string{}
-	labels[flv1.LabelSubnet] = annotationSubnet
+	labels[flv1.LabelSubnet] = ip.Spec.Subnet
 	labels[flv1.LabelSelectedIP] = ""
 	labels[flv1.LabelSelectedMac] = ""
 	labels[flv1.LabelFlatNetworkIPType] = flv1.AllocateModeSpecific
 	labels[flv1.LabelFlatMode] = subnet.Spec.FlatMode
+	if ip.Spec.SecondarySubnet != "" {
+		labels[flv1.LabelSecondarySubnet] = ip.Spec.SecondarySubnet
+	}
+	if ip.Spec.SubnetPool != "" {
+		labels[flv1.LabelSubnetPool] = ip.Spec.SubnetPool
+	}
 
-	if ip.Status.Addr != nil {
-		// IPv6 address contains invalid char ':'
-		s := ip.Status.Addr.String()
-		labels[flv1.LabelSelectedIP] = strings.ReplaceAll(s, ":", ".")
+	if addrs := common.GetFlatNetworkIPAddrs(ip); len(addrs) != 0 {
+		// IPv6 address contains invalid char ':',
+		// dual-stack addresses are separated by '_'.
+		s := []string{}
+		for _, a := range addrs {
+			s = append(s, strings.ReplaceAll(a.String(), ":", "."))
+		}
+		labels[flv1.LabelSelectedIP] = strings.Join(s, "_")
 	}
 	if ip.Status.MAC != "" && annotationMac != "" {
 		labels[flv1.LabelSelectedMac] = strings.ReplaceAll(ip.Status.MAC, ":", "")
@@ -17,12 +17,15 @@ const (
 	eventFlatNetworkIPError = "FlatNetworkIPError"
 )
 
-// newFlatNetworkIP returns a new flat-network IP struct object by Pod.
-func (h *handler) newFlatNetworkIP(pod *corev1.Pod) (*flv1.FlatNetworkIP, error) {
+// newFlatNetworkIP returns a new flat-network IP struct object by Pod
+// interface attachment.
+func (h *handler) newFlatNetworkIP(
+	pod *corev1.Pod, iface *flv1.PodInterface,
+) (*flv1.FlatNetworkIP, error) {
 	// Valid pod annotation
-	annotationIP := pod.Annotations[flv1.AnnotationIP]
-	annotationMAC := pod.Annotations[flv1.AnnotationMac]
-	annotationSubnet := pod.Annotations[flv1.AnnotationSubnet]
+	annotationIP := iface.IP
+	annotationMAC := iface.MAC
+	annotationSubnet := iface.Subnet
 	flatNetworkIPType := flv1.AllocateModeSpecific
 
 	var (
@@ -45,18 +48,46 @@ func (h *handler) newFlatNetworkIP(pod *corev1.Pod) (*flv1.FlatNetworkIP, error)
 		return nil, fmt.Errorf("newFlatNetworkIP: invalid annotation [%v: %v]: %w",
 			flv1.AnnotationMac, annotationMAC, err)
 	}
+	ipAddrs, macAddrs, err = h.pinStatefulSetAddrs(pod, ipAddrs, macAddrs)
+	if err != nil {
+		return nil, fmt.Errorf("newFlatNetworkIP: %w", err)
+	}
+	if iface.SubnetPool != "" {
+		return h.newSubnetPoolFlatNetworkIP(pod, iface, macAddrs)
+	}
 
-	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, annotationSubnet)
+	subnetNames, err := common.CheckPodAnnotationSubnets(annotationSubnet)
+	if err != nil {
+		return nil, fmt.Errorf("newFlatNetworkIP: %w", err)
+	}
+	if len(subnetNames) == 0 {
+		return nil, fmt.Errorf("newFlatNetworkIP: subnet annotation [%v] not specified",
+			flv1.AnnotationSubnet)
+	}
+	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, subnetNames[0])
 	if err != nil {
 		return nil, fmt.Errorf("newFlatNetworkIP: failed to get subnet [%v]: %w",
-			annotationSubnet, err)
+			subnetNames[0], err)
+	}
+	var secondarySubnet *flv1.FlatNetworkSubnet
+	if len(subnetNames) > 1 {
+		secondarySubnet, err = h.subnetCache.Get(flv1.SubnetNamespace, subnetNames[1])
+		if err != nil {
+			return nil, fmt.Errorf("newFlatNetworkIP: failed to get subnet [%v]: %w",
+				subnetNames[1], err)
+		}
+		if err := common.CheckDualStackSubnets(subnet, secondarySubnet); err != nil {
+			return nil, fmt.Errorf("newFlatNetworkIP: %w", err)
+		}
 	}
 
 	flatNetworkIP := &flv1.FlatNetworkIP{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        pod.Name,
-			Namespace:   pod.Namespace,
-			Annotations: map[string]string{},
+			Name:      common.GetFlatNetworkIPName(pod.Name, iface.Interface),
+			Namespace: pod.Namespace,
+			Annotations: map[string]string{
+				flv1.AnnotationPod: pod.Name,
+			},
 			Labels: map[string]string{
 				"subnet":                    subnet.Name,
 				flv1.LabelFlatNetworkIPType: flatNetworkIPType,
@@ -72,18 +103,119 @@ func (h *handler) newFlatNetworkIP(pod *corev1.Pod) (*flv1.FlatNetworkIP, error)
 			},
 		},
 		Spec: flv1.IPSpec{
-			Addrs:  ipAddrs,
-			MACs:   macAddrs,
-			PodID:  string(pod.GetUID()),
-			Subnet: subnet.Name,
+			Addrs:     ipAddrs,
+			MACs:      macAddrs,
+			PodID:     string(pod.GetUID()),
+			Subnet:    subnet.Name,
+			Interface: iface.Interface,
 		},
 	}
+	if secondarySubnet != nil {
+		flatNetworkIP.Labels["secondarySubnet"] = secondarySubnet.Name
+		flatNetworkIP.Spec.SecondarySubnet = secondarySubnet.Name
+	}
 	if subnet.Annotations[flv1.AnnotationsIPv6to4] != "" {
 		flatNetworkIP.Annotations[flv1.AnnotationsIPv6to4] = "true"
 	}
 	return flatNetworkIP, nil
 }
 
+// newSubnetPoolFlatNetworkIP returns a new flat-network IP struct object of
+// the pod interface using the subnet pool.
+// The subnet is selected from the pool by the flat-network IP controller.
+func (h *handler) newSubnetPoolFlatNetworkIP(
+	pod *corev1.Pod, iface *flv1.PodInterface, macAddrs []string,
+) (*flv1.FlatNetworkIP, error) {
+	pool, err := h.subnetPoolCache.Get(flv1.SubnetNamespace, iface.SubnetPool)
+	if err != nil {
+		return nil, fmt.Errorf("newFlatNetworkIP: failed to get subnet pool [%v]: %w",
+			iface.SubnetPool, err)
+	}
+	return &flv1.FlatNetworkIP{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GetFlatNetworkIPName(pod.Name, iface.Interface),
+			Namespace: pod.Namespace,
+			Annotations: map[string]string{
+				flv1.AnnotationPod: pod.Name,
+			},
+			Labels: map[string]string{
+				"subnetPool":                pool.Name,
+				flv1.LabelFlatNetworkIPType: flv1.AllocateModeAuto,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "Pod",
+					UID:        pod.UID,
+					Name:       pod.Name,
+					Controller: utils.Ptr(true),
+				},
+			},
+		},
+		Spec: flv1.IPSpec{
+			MACs:       macAddrs,
+			PodID:      string(pod.GetUID()),
+			SubnetPool: pool.Name,
+			Interface:  iface.Interface,
+		},
+	}, nil
+}
+
+// keepSubnetPoolSelection keeps the subnet already selected from the
+// subnet pool by the flat-network IP controller in the expected IP.
+func keepSubnetPoolSelection(expected, exist *flv1.FlatNetworkIP) {
+	if expected == nil || exist == nil || expected.Spec.SubnetPool == "" {
+		return
+	}
+	if exist.Spec.SubnetPool != expected.Spec.SubnetPool || exist.Spec.Subnet == "" {
+		return
+	}
+	expected.Spec.Subnet = exist.Spec.Subnet
+	expected.Labels["subnet"] = exist.Spec.Subnet
+	if exist.Annotations[flv1.AnnotationsIPv6to4] != "" {
+		expected.Annotations[flv1.AnnotationsIPv6to4] = exist.Annotations[flv1.AnnotationsIPv6to4]
+	}
+}
+
+// pinStatefulSetAddrs pins the N-th IP & MAC address in the list to the
+// N-th StatefulSet pod, to ensure the pod always get the same address after
+// re-scheduled.
+func (h *handler) pinStatefulSetAddrs(
+	pod *corev1.Pod, ipAddrs []net.IP, macAddrs []string,
+) ([]net.IP, []string, error) {
+	if len(ipAddrs) == 0 && len(macAddrs) == 0 {
+		return ipAddrs, macAddrs, nil
+	}
+	owner, ordinal, ok := common.GetStatefulSetPodOrdinal(pod)
+	if !ok {
+		return ipAddrs, macAddrs, nil
+	}
+	sts, err := h.statefulSetCache.Get(pod.Namespace, owner)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get statefulset [%v/%v]: %w",
+			pod.Namespace, owner, err)
+	}
+	if sts.Spec.Ordinals != nil {
+		// The ordinal starts from the spec.ordinals.start.
+		ordinal -= int(sts.Spec.Ordinals.Start)
+	}
+	if ordinal < 0 {
+		return nil, nil, fmt.Errorf("invalid statefulset pod [%v/%v] ordinal [%v]",
+			pod.Namespace, pod.Name, ordinal)
+	}
+	ipAddrs, err = common.GetOrdinalIPs(ipAddrs, ordinal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to pin IP to statefulset pod [%v/%v]: %w",
+			pod.Namespace, pod.Name, err)
+	}
+	macAddrs, err = common.GetOrdinalMACs(macAddrs, ordinal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to pin MAC to statefulset pod [%v/%v]: %w",
+			pod.Namespace, pod.Name, err)
+	}
+	return ipAddrs, macAddrs, nil
+}
+
 func (h *handler) eventFlatNetworkIPError(pod *corev1.Pod, err error) {
 	h.recorder.Event(pod, corev1.EventTypeWarning, eventFlatNetworkIPError, err.Error())
 }
@@ -3,13 +3,15 @@ package workload
 import (
 	"context"
 	"fmt"
-	"maps"
+	"net"
 	"reflect"
 
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/util/retry"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
@@ -46,6 +48,10 @@ type handler struct {
 
 	subnetClient flcontroller.FlatNetworkSubnetClient
 	subnetCache  flcontroller.FlatNetworkSubnetCache
+
+	subnetPoolCache flcontroller.FlatNetworkSubnetPoolCache
+	ipBlockClient   flcontroller.FlatNetworkIPBlockClient
+	ipBlockCache    flcontroller.FlatNetworkIPBlockCache
 }
 
 var workloadHandler *handler
@@ -62,6 +68,9 @@ func Register(
 		jobClient:         wctx.Batch.Job(),
 		subnetClient:      wctx.FlatNetwork.FlatNetworkSubnet(),
 		subnetCache:       wctx.FlatNetwork.FlatNetworkSubnet().Cache(),
+		subnetPoolCache:   wctx.FlatNetwork.FlatNetworkSubnetPool().Cache(),
+		ipBlockClient:     wctx.FlatNetwork.FlatNetworkIPBlock(),
+		ipBlockCache:      wctx.FlatNetwork.FlatNetworkIPBlock().Cache(),
 	}
 	workloadHandler = h
 
@@ -127,6 +136,7 @@ func getTemplateFlatNetworkLabel(
 	var (
 		ipType     string
 		subnetName string
+		poolName   string
 	)
 	switch a[flv1.AnnotationIP] {
 	case flv1.AllocateModeAuto:
@@ -135,8 +145,9 @@ func getTemplateFlatNetworkLabel(
 	default:
 		ipType = flv1.AllocateModeSpecific
 	}
-	subnetName = a[flv1.AnnotationSubnet]
-	isFlatNetworkEnabled = (ipType != "" && subnetName != "")
+	subnetName = common.GetPodAnnotationSubnet(a[flv1.AnnotationSubnet])
+	poolName = a[flv1.AnnotationSubnetPool]
+	isFlatNetworkEnabled = (ipType != "" && (subnetName != "" || poolName != ""))
 
 	labels = map[string]string{
 		flv1.LabelFlatNetworkIPType: ipType,
@@ -145,6 +156,17 @@ func getTemplateFlatNetworkLabel(
 	if !isFlatNetworkEnabled {
 		return isFlatNetworkEnabled, labels, nil
 	}
+	if subnetName == "" {
+		labels[flv1.LabelSubnetPool] = poolName
+		// The subnets of the pool have the same flatMode.
+		pool, err := workloadHandler.subnetPoolCache.Get(flv1.SubnetNamespace, poolName)
+		if err != nil {
+			return isFlatNetworkEnabled, labels, fmt.Errorf(
+				"failed to get subnet pool %q from cache: %w", poolName, err)
+		}
+		labels[flv1.LabelFlatMode] = pool.Status.FlatMode
+		return isFlatNetworkEnabled, labels, nil
+	}
 
 	subnet, err := workloadHandler.subnetCache.Get(flv1.SubnetNamespace, subnetName)
 	if err != nil {
@@ -203,110 +225,173 @@ func (h *handler) updateWorkloadLabel(
 }
 
 func (h *handler) removeWorkloadReservedIP(w metav1.Object) error {
+	subnetIPs, err := getWorkloadSubnetIPs(w)
+	if err != nil {
+		return err
+	}
+	for subnetName := range subnetIPs {
+		if err := h.removeSubnetWorkloadReservedIP(w, subnetName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getWorkloadSubnetIPs returns the user specified IPs of each subnet
+// defined in the workload pod template annotations.
+func getWorkloadSubnetIPs(w metav1.Object) (map[string][]net.IP, error) {
+	subnetIPs := map[string][]net.IP{}
 	m := GetTemplateObjectMeta(w)
 	if m == nil {
-		return nil
+		return subnetIPs, nil
 	}
-	annotationIP := m.Annotations[flv1.AnnotationIP]
-	subnetName := m.Annotations[flv1.AnnotationSubnet]
-	ips, err := common.CheckPodAnnotationIPs(annotationIP)
+	interfaces, err := common.GetPodInterfaces(m.Annotations)
 	if err != nil {
-		return err
-	}
-	if len(ips) == 0 || subnetName == "" {
-		return nil
+		return nil, err
 	}
-	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, subnetName)
+	for _, iface := range interfaces {
+		ips, err := common.CheckPodAnnotationIPs(iface.IP)
 		if err != nil {
-			return fmt.Errorf("failed to get subnet %v from cache: %w",
-				subnetName, err)
-		}
-		key := common.GetWorkloadReservdIPKey(w)
-		if key == "" {
-			return nil
+			return nil, err
 		}
-		reservedIP := maps.Clone(subnet.Status.ReservedIP)
-		if len(reservedIP) == 0 {
-			return nil
+		subnetNames, err := common.CheckPodAnnotationSubnets(iface.Subnet)
+		if err != nil {
+			return nil, err
 		}
-		delete(reservedIP, key)
-		if reflect.DeepEqual(subnet.Status.ReservedIP, reservedIP) {
-			// already updated, skip
-			return nil
+		if len(ips) == 0 {
+			continue
 		}
-		subnet = subnet.DeepCopy()
-		subnet.Status.ReservedIP = reservedIP
-		_, err = h.subnetClient.UpdateStatus(subnet)
-		if err != nil {
-			return err
+		for _, subnetName := range subnetNames {
+			subnetIPs[subnetName] = append(subnetIPs[subnetName], ips...)
 		}
-		logrus.WithFields(fieldsWorkload(w)).
-			Infof("remove subnet workload reservd IP as workload deleted")
-		return nil
-	})
+	}
+	return subnetIPs, nil
+}
+
+func (h *handler) removeSubnetWorkloadReservedIP(w metav1.Object, subnetName string) error {
+	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, subnetName)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get subnet %v from cache: %w",
+			subnetName, err)
+	}
+	if err := h.setSubnetWorkloadReservedIP(w, subnet, nil); err != nil {
 		logrus.WithFields(fieldsWorkload(w)).
 			Errorf("failed to remove subnet workload reserved IP: %v", err)
 		return err
 	}
+	logrus.WithFields(fieldsWorkload(w)).
+		Infof("remove subnet [%v] workload reservd IP as workload deleted", subnetName)
 	return nil
 }
 
 func (h *handler) syncWorkloadReservedIP(w metav1.Object) error {
-	m := GetTemplateObjectMeta(w)
-	if m == nil {
-		return nil
+	subnetIPs, err := getWorkloadSubnetIPs(w)
+	if err != nil {
+		return err
+	}
+	for subnetName, ips := range subnetIPs {
+		if err := h.syncSubnetWorkloadReservedIP(w, subnetName, ips); err != nil {
+			return err
+		}
 	}
-	annotationIP := m.Annotations[flv1.AnnotationIP]
-	subnetName := m.Annotations[flv1.AnnotationSubnet]
-	ips, err := common.CheckPodAnnotationIPs(annotationIP)
+	return nil
+}
+
+func (h *handler) syncSubnetWorkloadReservedIP(
+	w metav1.Object, subnetName string, ips []net.IP,
+) error {
+	subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, subnetName)
 	if err != nil {
+		return fmt.Errorf("failed to get subnet %v from cache: %w",
+			subnetName, err)
+	}
+	// Only reserve the IPs in the same IP family of the subnet
+	// for dual-stack.
+	ipRange := []flv1.IPRange{}
+	for _, ip := range common.GetSubnetFamilyIPs(ips, subnet) {
+		ipRange = ipcalc.AddIPToRange(ip, ipRange)
+	}
+	if err := h.setSubnetWorkloadReservedIP(w, subnet, ipRange); err != nil {
+		logrus.WithFields(fieldsWorkload(w)).
+			Errorf("failed to update subnet workload reserved IP: %v", err)
 		return err
 	}
-	if len(ips) == 0 || subnetName == "" {
+	return nil
+}
+
+// setSubnetWorkloadReservedIP updates the reserved IP ranges of the workload
+// to the IP blocks of the subnet, the ranges are removed from the IP blocks
+// if empty.
+func (h *handler) setSubnetWorkloadReservedIP(
+	w metav1.Object, subnet *flv1.FlatNetworkSubnet, ranges []flv1.IPRange,
+) error {
+	key := common.GetWorkloadReservdIPKey(w)
+	if key == "" {
 		return nil
 	}
-	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		subnet, err := h.subnetCache.Get(flv1.SubnetNamespace, subnetName)
-		if err != nil {
-			return fmt.Errorf("failed to get subnet %v from cache: %w",
-				subnetName, err)
+	blocks, err := h.ipBlockCache.List(flv1.SubnetNamespace, labels.SelectorFromSet(labels.Set{
+		common.LabelIPBlockSubnet: subnet.Name,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to list IP blocks from cache: %w", err)
+	}
+	expected := common.GetIPBlocksReservedIP(subnet.Name, ranges)
+	for _, b := range blocks {
+		if _, ok := b.Status.ReservedIP[key]; ok && expected[b.Name] == nil {
+			expected[b.Name] = nil
 		}
-		key := common.GetWorkloadReservdIPKey(w)
-		if key == "" {
-			return nil
+	}
+	for name, r := range expected {
+		if err := h.setIPBlockReservedIP(subnet, name, key, r); err != nil {
+			return err
 		}
-		reservedIP := maps.Clone(subnet.Status.ReservedIP)
-		if reservedIP == nil {
-			reservedIP = make(map[string][]flv1.IPRange)
+		if len(r) != 0 {
+			logrus.WithFields(fieldsWorkload(w)).
+				Infof("update IP block [%v] workload reserved IP to %v",
+					name, utils.Print(r))
 		}
-		ipRange := []flv1.IPRange{}
-		for _, ip := range ips {
-			ipRange = ipcalc.AddIPToRange(ip, ipRange)
+	}
+	return nil
+}
+
+// setIPBlockReservedIP sets the reserved IP ranges of the key in the IP
+// block, the IP block is created if not exists.
+func (h *handler) setIPBlockReservedIP(
+	subnet *flv1.FlatNetworkSubnet, name, key string, ranges []flv1.IPRange,
+) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		block, err := h.ipBlockCache.Get(flv1.SubnetNamespace, name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get IP block %v from cache: %w", name, err)
+			}
+			if len(ranges) == 0 {
+				return nil
+			}
+			block, err = h.ipBlockClient.Create(common.NewIPBlock(subnet, ranges[0].From))
+			if err != nil {
+				return err
+			}
 		}
-		reservedIP[key] = ipRange
-		if reflect.DeepEqual(subnet.Status.ReservedIP, reservedIP) {
+		if reflect.DeepEqual(block.Status.ReservedIP[key], ranges) {
 			// already updated, skip
 			return nil
 		}
-		subnet = subnet.DeepCopy()
-		subnet.Status.ReservedIP = reservedIP
-		_, err = h.subnetClient.UpdateStatus(subnet)
-		if err != nil {
-			return err
+		block = block.DeepCopy()
+		if len(ranges) != 0 {
+			if block.Status.ReservedIP == nil {
+				block.Status.ReservedIP = map[string][]flv1.IPRange{}
+			}
+			block.Status.ReservedIP[key] = ranges
+		} else {
+			delete(block.Status.ReservedIP, key)
 		}
-		logrus.WithFields(fieldsWorkload(w)).
-			Infof("update subnet workload reservd IP to %v",
-				utils.Print(ipRange))
-		return nil
-	})
-	if err != nil {
-		logrus.WithFields(fieldsWorkload(w)).
-			Errorf("failed to update subnet workload reserved IP: %v", err)
+		_, err = h.ipBlockClient.UpdateStatus(block)
 		return err
-	}
-	return nil
+	})
 }
 
 func fieldsWorkload(obj metav1.Object) logrus.Fields {
@@ -2,8 +2,10 @@ package wrangler
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	discovery "k8s.io/api/discovery/v1"
 	discoveryclient "k8s.io/client-go/discovery"
@@ -41,41 +43,53 @@ func serverSupportsIngressV1(k8sClient kubernetes.Interface) bool {
 
 type ipAllocatingMutex struct {
 	m        sync.Mutex
-	isLocked bool
+	isLocked atomic.Bool
 }
 
 func (m *ipAllocatingMutex) lock() {
-	m.isLocked = true
 	m.m.Lock()
+	m.isLocked.Store(true)
 }
 
 func (m *ipAllocatingMutex) unlock() {
+	m.isLocked.Store(false)
 	m.m.Unlock()
-	m.isLocked = false
 }
 
 var (
-	ipAllocateMap       = sync.Map{}
-	ipAllocateLockMutex = sync.Mutex{}
-	isIPAllocatingMutex = sync.Mutex{}
+	ipAllocateMap = sync.Map{}
 )
 
 // IPAllocateLock locks by subnet name and returns unlock function
 func IPAllocateLock(subnet string) func() {
-	ipAllocateLockMutex.Lock()
-	defer ipAllocateLockMutex.Unlock()
-
 	value, _ := ipAllocateMap.LoadOrStore(subnet, &ipAllocatingMutex{})
 	mtx := value.(*ipAllocatingMutex)
+	// Do not hold any global mutex when waiting for the subnet lock,
+	// otherwise the callers locking multiple subnets may deadlock.
 	mtx.lock()
 	return func() { mtx.unlock() }
 }
 
+// IPAllocateLocks locks multiple subnets in sorted order to avoid deadlock
+// and returns unlock function
+func IPAllocateLocks(subnets ...string) func() {
+	subnets = slices.Compact(slices.Sorted(slices.Values(subnets)))
+	unlocks := make([]func(), 0, len(subnets))
+	for _, subnet := range subnets {
+		if subnet == "" {
+			continue
+		}
+		unlocks = append(unlocks, IPAllocateLock(subnet))
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
 // IsIPAllocating checks whether the subnet is locked
 func IsIPAllocating(subnet string) bool {
-	isIPAllocatingMutex.Lock()
-	defer isIPAllocatingMutex.Unlock()
-
 	o, ok := ipAllocateMap.Load(subnet)
 	if !ok {
 		return false
@@ -84,6 +98,5 @@ func IsIPAllocating(subnet string) bool {
 	if mu == nil || !ok {
 		return false
 	}
-
-	return mu.isLocked
+	return mu.isLocked.Load()
 }
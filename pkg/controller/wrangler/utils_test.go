@@ -0,0 +1,46 @@
+package wrangler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IPAllocateLocks(t *testing.T) {
+	// The dual-stack IPs locking the overlapping subnet pairs.
+	pairs := [][]string{
+		{"subnet-a", "subnet-b"},
+		{"subnet-b", "subnet-c"},
+		{"subnet-c", "subnet-a"},
+		{"subnet-a", ""},
+	}
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(pair []string) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					unlock := IPAllocateLocks(pair...)
+					unlock()
+				}
+			}(pairs[i%len(pairs)])
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 30):
+		t.Fatal("deadlock when locking overlapping subnets")
+	}
+
+	unlock := IPAllocateLock("subnet-a")
+	assert.True(t, IsIPAllocating("subnet-a"))
+	assert.False(t, IsIPAllocating("subnet-b"))
+	unlock()
+	assert.False(t, IsIPAllocating("subnet-a"))
+}
@@ -32,10 +32,22 @@ func (c *FakeFlatnetworkV1) FlatNetworkIPs(namespace string) v1.FlatNetworkIPInt
 	return newFakeFlatNetworkIPs(c, namespace)
 }
 
+func (c *FakeFlatnetworkV1) FlatNetworkIPBlocks(namespace string) v1.FlatNetworkIPBlockInterface {
+	return newFakeFlatNetworkIPBlocks(c, namespace)
+}
+
+func (c *FakeFlatnetworkV1) FlatNetworkIPReservations(namespace string) v1.FlatNetworkIPReservationInterface {
+	return newFakeFlatNetworkIPReservations(c, namespace)
+}
+
 func (c *FakeFlatnetworkV1) FlatNetworkSubnets(namespace string) v1.FlatNetworkSubnetInterface {
 	return newFakeFlatNetworkSubnets(c, namespace)
 }
 
+func (c *FakeFlatnetworkV1) FlatNetworkSubnetPools(namespace string) v1.FlatNetworkSubnetPoolInterface {
+	return newFakeFlatNetworkSubnetPools(c, namespace)
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeFlatnetworkV1) RESTClient() rest.Interface {
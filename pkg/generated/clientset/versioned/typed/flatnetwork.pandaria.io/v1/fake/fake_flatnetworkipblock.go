@@ -0,0 +1,52 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package fake
+
+import (
+	v1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/generated/clientset/versioned/typed/flatnetwork.pandaria.io/v1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeFlatNetworkIPBlocks implements FlatNetworkIPBlockInterface
+type fakeFlatNetworkIPBlocks struct {
+	*gentype.FakeClientWithList[*v1.FlatNetworkIPBlock, *v1.FlatNetworkIPBlockList]
+	Fake *FakeFlatnetworkV1
+}
+
+func newFakeFlatNetworkIPBlocks(fake *FakeFlatnetworkV1, namespace string) flatnetworkpandariaiov1.FlatNetworkIPBlockInterface {
+	return &fakeFlatNetworkIPBlocks{
+		gentype.NewFakeClientWithList[*v1.FlatNetworkIPBlock, *v1.FlatNetworkIPBlockList](
+			fake.Fake,
+			namespace,
+			v1.SchemeGroupVersion.WithResource("flatnetworkipblocks"),
+			v1.SchemeGroupVersion.WithKind("FlatNetworkIPBlock"),
+			func() *v1.FlatNetworkIPBlock { return &v1.FlatNetworkIPBlock{} },
+			func() *v1.FlatNetworkIPBlockList { return &v1.FlatNetworkIPBlockList{} },
+			func(dst, src *v1.FlatNetworkIPBlockList) { dst.ListMeta = src.ListMeta },
+			func(list *v1.FlatNetworkIPBlockList) []*v1.FlatNetworkIPBlock {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1.FlatNetworkIPBlockList, items []*v1.FlatNetworkIPBlock) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
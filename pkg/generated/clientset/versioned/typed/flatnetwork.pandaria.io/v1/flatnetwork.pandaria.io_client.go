@@ -29,7 +29,10 @@ import (
 type FlatnetworkV1Interface interface {
 	RESTClient() rest.Interface
 	FlatNetworkIPsGetter
+	FlatNetworkIPBlocksGetter
+	FlatNetworkIPReservationsGetter
 	FlatNetworkSubnetsGetter
+	FlatNetworkSubnetPoolsGetter
 }
 
 // FlatnetworkV1Client is used to interact with features provided by the flatnetwork.pandaria.io group.
@@ -41,10 +44,22 @@ func (c *FlatnetworkV1Client) FlatNetworkIPs(namespace string) FlatNetworkIPInte
 	return newFlatNetworkIPs(c, namespace)
 }
 
+func (c *FlatnetworkV1Client) FlatNetworkIPBlocks(namespace string) FlatNetworkIPBlockInterface {
+	return newFlatNetworkIPBlocks(c, namespace)
+}
+
+func (c *FlatnetworkV1Client) FlatNetworkIPReservations(namespace string) FlatNetworkIPReservationInterface {
+	return newFlatNetworkIPReservations(c, namespace)
+}
+
 func (c *FlatnetworkV1Client) FlatNetworkSubnets(namespace string) FlatNetworkSubnetInterface {
 	return newFlatNetworkSubnets(c, namespace)
 }
 
+func (c *FlatnetworkV1Client) FlatNetworkSubnetPools(namespace string) FlatNetworkSubnetPoolInterface {
+	return newFlatNetworkSubnetPools(c, namespace)
+}
+
 // NewForConfig creates a new FlatnetworkV1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
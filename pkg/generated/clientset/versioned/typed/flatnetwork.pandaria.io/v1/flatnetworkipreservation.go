@@ -0,0 +1,74 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	context "context"
+
+	flatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	scheme "github.com/cnrancher/rancher-flat-network/pkg/generated/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// FlatNetworkIPReservationsGetter has a method to return a FlatNetworkIPReservationInterface.
+// A group's client should implement this interface.
+type FlatNetworkIPReservationsGetter interface {
+	FlatNetworkIPReservations(namespace string) FlatNetworkIPReservationInterface
+}
+
+// FlatNetworkIPReservationInterface has methods to work with FlatNetworkIPReservation resources.
+type FlatNetworkIPReservationInterface interface {
+	Create(ctx context.Context, flatNetworkIPReservation *flatnetworkpandariaiov1.FlatNetworkIPReservation, opts metav1.CreateOptions) (*flatnetworkpandariaiov1.FlatNetworkIPReservation, error)
+	Update(ctx context.Context, flatNetworkIPReservation *flatnetworkpandariaiov1.FlatNetworkIPReservation, opts metav1.UpdateOptions) (*flatnetworkpandariaiov1.FlatNetworkIPReservation, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, flatNetworkIPReservation *flatnetworkpandariaiov1.FlatNetworkIPReservation, opts metav1.UpdateOptions) (*flatnetworkpandariaiov1.FlatNetworkIPReservation, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*flatnetworkpandariaiov1.FlatNetworkIPReservation, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*flatnetworkpandariaiov1.FlatNetworkIPReservationList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *flatnetworkpandariaiov1.FlatNetworkIPReservation, err error)
+	FlatNetworkIPReservationExpansion
+}
+
+// flatNetworkIPReservations implements FlatNetworkIPReservationInterface
+type flatNetworkIPReservations struct {
+	*gentype.ClientWithList[*flatnetworkpandariaiov1.FlatNetworkIPReservation, *flatnetworkpandariaiov1.FlatNetworkIPReservationList]
+}
+
+// newFlatNetworkIPReservations returns a FlatNetworkIPReservations
+func newFlatNetworkIPReservations(c *FlatnetworkV1Client, namespace string) *flatNetworkIPReservations {
+	return &flatNetworkIPReservations{
+		gentype.NewClientWithList[*flatnetworkpandariaiov1.FlatNetworkIPReservation, *flatnetworkpandariaiov1.FlatNetworkIPReservationList](
+			"flatnetworkipreservations",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *flatnetworkpandariaiov1.FlatNetworkIPReservation {
+				return &flatnetworkpandariaiov1.FlatNetworkIPReservation{}
+			},
+			func() *flatnetworkpandariaiov1.FlatNetworkIPReservationList {
+				return &flatnetworkpandariaiov1.FlatNetworkIPReservationList{}
+			},
+		),
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	context "context"
+
+	flatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	scheme "github.com/cnrancher/rancher-flat-network/pkg/generated/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// FlatNetworkSubnetPoolsGetter has a method to return a FlatNetworkSubnetPoolInterface.
+// A group's client should implement this interface.
+type FlatNetworkSubnetPoolsGetter interface {
+	FlatNetworkSubnetPools(namespace string) FlatNetworkSubnetPoolInterface
+}
+
+// FlatNetworkSubnetPoolInterface has methods to work with FlatNetworkSubnetPool resources.
+type FlatNetworkSubnetPoolInterface interface {
+	Create(ctx context.Context, flatNetworkSubnetPool *flatnetworkpandariaiov1.FlatNetworkSubnetPool, opts metav1.CreateOptions) (*flatnetworkpandariaiov1.FlatNetworkSubnetPool, error)
+	Update(ctx context.Context, flatNetworkSubnetPool *flatnetworkpandariaiov1.FlatNetworkSubnetPool, opts metav1.UpdateOptions) (*flatnetworkpandariaiov1.FlatNetworkSubnetPool, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, flatNetworkSubnetPool *flatnetworkpandariaiov1.FlatNetworkSubnetPool, opts metav1.UpdateOptions) (*flatnetworkpandariaiov1.FlatNetworkSubnetPool, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*flatnetworkpandariaiov1.FlatNetworkSubnetPool, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*flatnetworkpandariaiov1.FlatNetworkSubnetPoolList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *flatnetworkpandariaiov1.FlatNetworkSubnetPool, err error)
+	FlatNetworkSubnetPoolExpansion
+}
+
+// flatNetworkSubnetPools implements FlatNetworkSubnetPoolInterface
+type flatNetworkSubnetPools struct {
+	*gentype.ClientWithList[*flatnetworkpandariaiov1.FlatNetworkSubnetPool, *flatnetworkpandariaiov1.FlatNetworkSubnetPoolList]
+}
+
+// newFlatNetworkSubnetPools returns a FlatNetworkSubnetPools
+func newFlatNetworkSubnetPools(c *FlatnetworkV1Client, namespace string) *flatNetworkSubnetPools {
+	return &flatNetworkSubnetPools{
+		gentype.NewClientWithList[*flatnetworkpandariaiov1.FlatNetworkSubnetPool, *flatnetworkpandariaiov1.FlatNetworkSubnetPoolList](
+			"flatnetworksubnetpools",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *flatnetworkpandariaiov1.FlatNetworkSubnetPool {
+				return &flatnetworkpandariaiov1.FlatNetworkSubnetPool{}
+			},
+			func() *flatnetworkpandariaiov1.FlatNetworkSubnetPoolList {
+				return &flatnetworkpandariaiov1.FlatNetworkSubnetPoolList{}
+			},
+		),
+	}
+}
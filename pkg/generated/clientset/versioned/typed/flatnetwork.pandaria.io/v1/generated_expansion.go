@@ -20,4 +20,10 @@ package v1
 
 type FlatNetworkIPExpansion interface{}
 
+type FlatNetworkIPBlockExpansion interface{}
+
+type FlatNetworkIPReservationExpansion interface{}
+
 type FlatNetworkSubnetExpansion interface{}
+
+type FlatNetworkSubnetPoolExpansion interface{}
@@ -0,0 +1,208 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/rancher/wrangler/v3/pkg/kv"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// FlatNetworkIPReservationController interface for managing FlatNetworkIPReservation resources.
+type FlatNetworkIPReservationController interface {
+	generic.ControllerInterface[*v1.FlatNetworkIPReservation, *v1.FlatNetworkIPReservationList]
+}
+
+// FlatNetworkIPReservationClient interface for managing FlatNetworkIPReservation resources in Kubernetes.
+type FlatNetworkIPReservationClient interface {
+	generic.ClientInterface[*v1.FlatNetworkIPReservation, *v1.FlatNetworkIPReservationList]
+}
+
+// FlatNetworkIPReservationCache interface for retrieving FlatNetworkIPReservation resources in memory.
+type FlatNetworkIPReservationCache interface {
+	generic.CacheInterface[*v1.FlatNetworkIPReservation]
+}
+
+// FlatNetworkIPReservationStatusHandler is executed for every added or modified FlatNetworkIPReservation. Should return the new status to be updated
+type FlatNetworkIPReservationStatusHandler func(obj *v1.FlatNetworkIPReservation, status v1.IPReservationStatus) (v1.IPReservationStatus, error)
+
+// FlatNetworkIPReservationGeneratingHandler is the top-level handler that is executed for every FlatNetworkIPReservation event. It extends FlatNetworkIPReservationStatusHandler by a returning a slice of child objects to be passed to apply.Apply
+type FlatNetworkIPReservationGeneratingHandler func(obj *v1.FlatNetworkIPReservation, status v1.IPReservationStatus) ([]runtime.Object, v1.IPReservationStatus, error)
+
+// RegisterFlatNetworkIPReservationStatusHandler configures a FlatNetworkIPReservationController to execute a FlatNetworkIPReservationStatusHandler for every events observed.
+// If a non-empty condition is provided, it will be updated in the status conditions for every handler execution
+func RegisterFlatNetworkIPReservationStatusHandler(ctx context.Context, controller FlatNetworkIPReservationController, condition condition.Cond, name string, handler FlatNetworkIPReservationStatusHandler) {
+	statusHandler := &flatNetworkIPReservationStatusHandler{
+		client:    controller,
+		condition: condition,
+		handler:   handler,
+	}
+	controller.AddGenericHandler(ctx, name, generic.FromObjectHandlerToHandler(statusHandler.sync))
+}
+
+// RegisterFlatNetworkIPReservationGeneratingHandler configures a FlatNetworkIPReservationController to execute a FlatNetworkIPReservationGeneratingHandler for every events observed, passing the returned objects to the provided apply.Apply.
+// If a non-empty condition is provided, it will be updated in the status conditions for every handler execution
+func RegisterFlatNetworkIPReservationGeneratingHandler(ctx context.Context, controller FlatNetworkIPReservationController, apply apply.Apply,
+	condition condition.Cond, name string, handler FlatNetworkIPReservationGeneratingHandler, opts *generic.GeneratingHandlerOptions) {
+	statusHandler := &flatNetworkIPReservationGeneratingHandler{
+		FlatNetworkIPReservationGeneratingHandler: handler,
+		apply: apply,
+		name:  name,
+		gvk:   controller.GroupVersionKind(),
+	}
+	if opts != nil {
+		statusHandler.opts = *opts
+	}
+	controller.OnChange(ctx, name, statusHandler.Remove)
+	RegisterFlatNetworkIPReservationStatusHandler(ctx, controller, condition, name, statusHandler.Handle)
+}
+
+type flatNetworkIPReservationStatusHandler struct {
+	client    FlatNetworkIPReservationClient
+	condition condition.Cond
+	handler   FlatNetworkIPReservationStatusHandler
+}
+
+// sync is executed on every resource addition or modification. Executes the configured handlers and sends the updated status to the Kubernetes API
+func (a *flatNetworkIPReservationStatusHandler) sync(key string, obj *v1.FlatNetworkIPReservation) (*v1.FlatNetworkIPReservation, error) {
+	if obj == nil {
+		return obj, nil
+	}
+
+	origStatus := obj.Status.DeepCopy()
+	obj = obj.DeepCopy()
+	newStatus, err := a.handler(obj, obj.Status)
+	if err != nil {
+		// Revert to old status on error
+		newStatus = *origStatus.DeepCopy()
+	}
+
+	if a.condition != "" {
+		if errors.IsConflict(err) {
+			a.condition.SetError(&newStatus, "", nil)
+		} else {
+			a.condition.SetError(&newStatus, "", err)
+		}
+	}
+	if !equality.Semantic.DeepEqual(origStatus, &newStatus) {
+		if a.condition != "" {
+			// Since status has changed, update the lastUpdatedTime
+			a.condition.LastUpdated(&newStatus, time.Now().UTC().Format(time.RFC3339))
+		}
+
+		var newErr error
+		obj.Status = newStatus
+		newObj, newErr := a.client.UpdateStatus(obj)
+		if err == nil {
+			err = newErr
+		}
+		if newErr == nil {
+			obj = newObj
+		}
+	}
+	return obj, err
+}
+
+type flatNetworkIPReservationGeneratingHandler struct {
+	FlatNetworkIPReservationGeneratingHandler
+	apply apply.Apply
+	opts  generic.GeneratingHandlerOptions
+	gvk   schema.GroupVersionKind
+	name  string
+	seen  sync.Map
+}
+
+// Remove handles the observed deletion of a resource, cascade deleting every associated resource previously applied
+func (a *flatNetworkIPReservationGeneratingHandler) Remove(key string, obj *v1.FlatNetworkIPReservation) (*v1.FlatNetworkIPReservation, error) {
+	if obj != nil {
+		return obj, nil
+	}
+
+	obj = &v1.FlatNetworkIPReservation{}
+	obj.Namespace, obj.Name = kv.RSplit(key, "/")
+	obj.SetGroupVersionKind(a.gvk)
+
+	if a.opts.UniqueApplyForResourceVersion {
+		a.seen.Delete(key)
+	}
+
+	return nil, generic.ConfigureApplyForObject(a.apply, obj, &a.opts).
+		WithOwner(obj).
+		WithSetID(a.name).
+		ApplyObjects()
+}
+
+// Handle executes the configured FlatNetworkIPReservationGeneratingHandler and pass the resulting objects to apply.Apply, finally returning the new status of the resource
+func (a *flatNetworkIPReservationGeneratingHandler) Handle(obj *v1.FlatNetworkIPReservation, status v1.IPReservationStatus) (v1.IPReservationStatus, error) {
+	if !obj.DeletionTimestamp.IsZero() {
+		return status, nil
+	}
+
+	objs, newStatus, err := a.FlatNetworkIPReservationGeneratingHandler(obj, status)
+	if err != nil {
+		return newStatus, err
+	}
+	if !a.isNewResourceVersion(obj) {
+		return newStatus, nil
+	}
+
+	err = generic.ConfigureApplyForObject(a.apply, obj, &a.opts).
+		WithOwner(obj).
+		WithSetID(a.name).
+		ApplyObjects(objs...)
+	if err != nil {
+		return newStatus, err
+	}
+	a.storeResourceVersion(obj)
+	return newStatus, nil
+}
+
+// isNewResourceVersion detects if a specific resource version was already successfully processed.
+// Only used if UniqueApplyForResourceVersion is set in generic.GeneratingHandlerOptions
+func (a *flatNetworkIPReservationGeneratingHandler) isNewResourceVersion(obj *v1.FlatNetworkIPReservation) bool {
+	if !a.opts.UniqueApplyForResourceVersion {
+		return true
+	}
+
+	// Apply once per resource version
+	key := obj.Namespace + "/" + obj.Name
+	previous, ok := a.seen.Load(key)
+	return !ok || previous != obj.ResourceVersion
+}
+
+// storeResourceVersion keeps track of the latest resource version of an object for which Apply was executed
+// Only used if UniqueApplyForResourceVersion is set in generic.GeneratingHandlerOptions
+func (a *flatNetworkIPReservationGeneratingHandler) storeResourceVersion(obj *v1.FlatNetworkIPReservation) {
+	if !a.opts.UniqueApplyForResourceVersion {
+		return
+	}
+
+	key := obj.Namespace + "/" + obj.Name
+	a.seen.Store(key, obj.ResourceVersion)
+}
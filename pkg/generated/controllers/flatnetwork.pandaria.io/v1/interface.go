@@ -32,7 +32,10 @@ func init() {
 
 type Interface interface {
 	FlatNetworkIP() FlatNetworkIPController
+	FlatNetworkIPBlock() FlatNetworkIPBlockController
+	FlatNetworkIPReservation() FlatNetworkIPReservationController
 	FlatNetworkSubnet() FlatNetworkSubnetController
+	FlatNetworkSubnetPool() FlatNetworkSubnetPoolController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -49,6 +52,18 @@ func (v *version) FlatNetworkIP() FlatNetworkIPController {
 	return generic.NewController[*v1.FlatNetworkIP, *v1.FlatNetworkIPList](schema.GroupVersionKind{Group: "flatnetwork.pandaria.io", Version: "v1", Kind: "FlatNetworkIP"}, "flatnetworkips", true, v.controllerFactory)
 }
 
+func (v *version) FlatNetworkIPBlock() FlatNetworkIPBlockController {
+	return generic.NewController[*v1.FlatNetworkIPBlock, *v1.FlatNetworkIPBlockList](schema.GroupVersionKind{Group: "flatnetwork.pandaria.io", Version: "v1", Kind: "FlatNetworkIPBlock"}, "flatnetworkipblocks", true, v.controllerFactory)
+}
+
+func (v *version) FlatNetworkIPReservation() FlatNetworkIPReservationController {
+	return generic.NewController[*v1.FlatNetworkIPReservation, *v1.FlatNetworkIPReservationList](schema.GroupVersionKind{Group: "flatnetwork.pandaria.io", Version: "v1", Kind: "FlatNetworkIPReservation"}, "flatnetworkipreservations", true, v.controllerFactory)
+}
+
 func (v *version) FlatNetworkSubnet() FlatNetworkSubnetController {
 	return generic.NewController[*v1.FlatNetworkSubnet, *v1.FlatNetworkSubnetList](schema.GroupVersionKind{Group: "flatnetwork.pandaria.io", Version: "v1", Kind: "FlatNetworkSubnet"}, "flatnetworksubnets", true, v.controllerFactory)
 }
+
+func (v *version) FlatNetworkSubnetPool() FlatNetworkSubnetPoolController {
+	return generic.NewController[*v1.FlatNetworkSubnetPool, *v1.FlatNetworkSubnetPoolList](schema.GroupVersionKind{Group: "flatnetwork.pandaria.io", Version: "v1", Kind: "FlatNetworkSubnetPool"}, "flatnetworksubnetpools", true, v.controllerFactory)
+}
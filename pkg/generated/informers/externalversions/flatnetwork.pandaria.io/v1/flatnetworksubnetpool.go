@@ -0,0 +1,102 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	context "context"
+	time "time"
+
+	apisflatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	versioned "github.com/cnrancher/rancher-flat-network/pkg/generated/clientset/versioned"
+	internalinterfaces "github.com/cnrancher/rancher-flat-network/pkg/generated/informers/externalversions/internalinterfaces"
+	flatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/generated/listers/flatnetwork.pandaria.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// FlatNetworkSubnetPoolInformer provides access to a shared informer and lister for
+// FlatNetworkSubnetPools.
+type FlatNetworkSubnetPoolInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() flatnetworkpandariaiov1.FlatNetworkSubnetPoolLister
+}
+
+type flatNetworkSubnetPoolInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewFlatNetworkSubnetPoolInformer constructs a new informer for FlatNetworkSubnetPool type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFlatNetworkSubnetPoolInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredFlatNetworkSubnetPoolInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredFlatNetworkSubnetPoolInformer constructs a new informer for FlatNetworkSubnetPool type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredFlatNetworkSubnetPoolInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.FlatnetworkV1().FlatNetworkSubnetPools(namespace).List(context.Background(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.FlatnetworkV1().FlatNetworkSubnetPools(namespace).Watch(context.Background(), options)
+			},
+			ListWithContextFunc: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.FlatnetworkV1().FlatNetworkSubnetPools(namespace).List(ctx, options)
+			},
+			WatchFuncWithContext: func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.FlatnetworkV1().FlatNetworkSubnetPools(namespace).Watch(ctx, options)
+			},
+		},
+		&apisflatnetworkpandariaiov1.FlatNetworkSubnetPool{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *flatNetworkSubnetPoolInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredFlatNetworkSubnetPoolInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *flatNetworkSubnetPoolInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&apisflatnetworkpandariaiov1.FlatNetworkSubnetPool{}, f.defaultInformer)
+}
+
+func (f *flatNetworkSubnetPoolInformer) Lister() flatnetworkpandariaiov1.FlatNetworkSubnetPoolLister {
+	return flatnetworkpandariaiov1.NewFlatNetworkSubnetPoolLister(f.Informer().GetIndexer())
+}
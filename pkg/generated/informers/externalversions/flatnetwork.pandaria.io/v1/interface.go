@@ -26,8 +26,14 @@ import (
 type Interface interface {
 	// FlatNetworkIPs returns a FlatNetworkIPInformer.
 	FlatNetworkIPs() FlatNetworkIPInformer
+	// FlatNetworkIPBlocks returns a FlatNetworkIPBlockInformer.
+	FlatNetworkIPBlocks() FlatNetworkIPBlockInformer
+	// FlatNetworkIPReservations returns a FlatNetworkIPReservationInformer.
+	FlatNetworkIPReservations() FlatNetworkIPReservationInformer
 	// FlatNetworkSubnets returns a FlatNetworkSubnetInformer.
 	FlatNetworkSubnets() FlatNetworkSubnetInformer
+	// FlatNetworkSubnetPools returns a FlatNetworkSubnetPoolInformer.
+	FlatNetworkSubnetPools() FlatNetworkSubnetPoolInformer
 }
 
 type version struct {
@@ -46,7 +52,22 @@ func (v *version) FlatNetworkIPs() FlatNetworkIPInformer {
 	return &flatNetworkIPInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// FlatNetworkIPBlocks returns a FlatNetworkIPBlockInformer.
+func (v *version) FlatNetworkIPBlocks() FlatNetworkIPBlockInformer {
+	return &flatNetworkIPBlockInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// FlatNetworkIPReservations returns a FlatNetworkIPReservationInformer.
+func (v *version) FlatNetworkIPReservations() FlatNetworkIPReservationInformer {
+	return &flatNetworkIPReservationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // FlatNetworkSubnets returns a FlatNetworkSubnetInformer.
 func (v *version) FlatNetworkSubnets() FlatNetworkSubnetInformer {
 	return &flatNetworkSubnetInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// FlatNetworkSubnetPools returns a FlatNetworkSubnetPoolInformer.
+func (v *version) FlatNetworkSubnetPools() FlatNetworkSubnetPoolInformer {
+	return &flatNetworkSubnetPoolInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -55,8 +55,14 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=flatnetwork.pandaria.io, Version=v1
 	case v1.SchemeGroupVersion.WithResource("flatnetworkips"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Flatnetwork().V1().FlatNetworkIPs().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("flatnetworkipblocks"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Flatnetwork().V1().FlatNetworkIPBlocks().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("flatnetworkipreservations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Flatnetwork().V1().FlatNetworkIPReservations().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("flatnetworksubnets"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Flatnetwork().V1().FlatNetworkSubnets().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("flatnetworksubnetpools"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Flatnetwork().V1().FlatNetworkSubnetPools().Informer()}, nil
 
 	}
 
@@ -26,6 +26,22 @@ type FlatNetworkIPListerExpansion interface{}
 // FlatNetworkIPNamespaceLister.
 type FlatNetworkIPNamespaceListerExpansion interface{}
 
+// FlatNetworkIPBlockListerExpansion allows custom methods to be added to
+// FlatNetworkIPBlockLister.
+type FlatNetworkIPBlockListerExpansion interface{}
+
+// FlatNetworkIPBlockNamespaceListerExpansion allows custom methods to be added to
+// FlatNetworkIPBlockNamespaceLister.
+type FlatNetworkIPBlockNamespaceListerExpansion interface{}
+
+// FlatNetworkIPReservationListerExpansion allows custom methods to be added to
+// FlatNetworkIPReservationLister.
+type FlatNetworkIPReservationListerExpansion interface{}
+
+// FlatNetworkIPReservationNamespaceListerExpansion allows custom methods to be added to
+// FlatNetworkIPReservationNamespaceLister.
+type FlatNetworkIPReservationNamespaceListerExpansion interface{}
+
 // FlatNetworkSubnetListerExpansion allows custom methods to be added to
 // FlatNetworkSubnetLister.
 type FlatNetworkSubnetListerExpansion interface{}
@@ -33,3 +49,11 @@ type FlatNetworkSubnetListerExpansion interface{}
 // FlatNetworkSubnetNamespaceListerExpansion allows custom methods to be added to
 // FlatNetworkSubnetNamespaceLister.
 type FlatNetworkSubnetNamespaceListerExpansion interface{}
+
+// FlatNetworkSubnetPoolListerExpansion allows custom methods to be added to
+// FlatNetworkSubnetPoolLister.
+type FlatNetworkSubnetPoolListerExpansion interface{}
+
+// FlatNetworkSubnetPoolNamespaceListerExpansion allows custom methods to be added to
+// FlatNetworkSubnetPoolNamespaceLister.
+type FlatNetworkSubnetPoolNamespaceListerExpansion interface{}
@@ -0,0 +1,70 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	flatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// FlatNetworkIPBlockLister helps list FlatNetworkIPBlocks.
+// All objects returned here must be treated as read-only.
+type FlatNetworkIPBlockLister interface {
+	// List lists all FlatNetworkIPBlocks in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*flatnetworkpandariaiov1.FlatNetworkIPBlock, err error)
+	// FlatNetworkIPBlocks returns an object that can list and get FlatNetworkIPBlocks.
+	FlatNetworkIPBlocks(namespace string) FlatNetworkIPBlockNamespaceLister
+	FlatNetworkIPBlockListerExpansion
+}
+
+// flatNetworkIPBlockLister implements the FlatNetworkIPBlockLister interface.
+type flatNetworkIPBlockLister struct {
+	listers.ResourceIndexer[*flatnetworkpandariaiov1.FlatNetworkIPBlock]
+}
+
+// NewFlatNetworkIPBlockLister returns a new FlatNetworkIPBlockLister.
+func NewFlatNetworkIPBlockLister(indexer cache.Indexer) FlatNetworkIPBlockLister {
+	return &flatNetworkIPBlockLister{listers.New[*flatnetworkpandariaiov1.FlatNetworkIPBlock](indexer, flatnetworkpandariaiov1.Resource("flatnetworkipblock"))}
+}
+
+// FlatNetworkIPBlocks returns an object that can list and get FlatNetworkIPBlocks.
+func (s *flatNetworkIPBlockLister) FlatNetworkIPBlocks(namespace string) FlatNetworkIPBlockNamespaceLister {
+	return flatNetworkIPBlockNamespaceLister{listers.NewNamespaced[*flatnetworkpandariaiov1.FlatNetworkIPBlock](s.ResourceIndexer, namespace)}
+}
+
+// FlatNetworkIPBlockNamespaceLister helps list and get FlatNetworkIPBlocks.
+// All objects returned here must be treated as read-only.
+type FlatNetworkIPBlockNamespaceLister interface {
+	// List lists all FlatNetworkIPBlocks in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*flatnetworkpandariaiov1.FlatNetworkIPBlock, err error)
+	// Get retrieves the FlatNetworkIPBlock from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*flatnetworkpandariaiov1.FlatNetworkIPBlock, error)
+	FlatNetworkIPBlockNamespaceListerExpansion
+}
+
+// flatNetworkIPBlockNamespaceLister implements the FlatNetworkIPBlockNamespaceLister
+// interface.
+type flatNetworkIPBlockNamespaceLister struct {
+	listers.ResourceIndexer[*flatnetworkpandariaiov1.FlatNetworkIPBlock]
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	flatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// FlatNetworkIPReservationLister helps list FlatNetworkIPReservations.
+// All objects returned here must be treated as read-only.
+type FlatNetworkIPReservationLister interface {
+	// List lists all FlatNetworkIPReservations in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*flatnetworkpandariaiov1.FlatNetworkIPReservation, err error)
+	// FlatNetworkIPReservations returns an object that can list and get FlatNetworkIPReservations.
+	FlatNetworkIPReservations(namespace string) FlatNetworkIPReservationNamespaceLister
+	FlatNetworkIPReservationListerExpansion
+}
+
+// flatNetworkIPReservationLister implements the FlatNetworkIPReservationLister interface.
+type flatNetworkIPReservationLister struct {
+	listers.ResourceIndexer[*flatnetworkpandariaiov1.FlatNetworkIPReservation]
+}
+
+// NewFlatNetworkIPReservationLister returns a new FlatNetworkIPReservationLister.
+func NewFlatNetworkIPReservationLister(indexer cache.Indexer) FlatNetworkIPReservationLister {
+	return &flatNetworkIPReservationLister{listers.New[*flatnetworkpandariaiov1.FlatNetworkIPReservation](indexer, flatnetworkpandariaiov1.Resource("flatnetworkipreservation"))}
+}
+
+// FlatNetworkIPReservations returns an object that can list and get FlatNetworkIPReservations.
+func (s *flatNetworkIPReservationLister) FlatNetworkIPReservations(namespace string) FlatNetworkIPReservationNamespaceLister {
+	return flatNetworkIPReservationNamespaceLister{listers.NewNamespaced[*flatnetworkpandariaiov1.FlatNetworkIPReservation](s.ResourceIndexer, namespace)}
+}
+
+// FlatNetworkIPReservationNamespaceLister helps list and get FlatNetworkIPReservations.
+// All objects returned here must be treated as read-only.
+type FlatNetworkIPReservationNamespaceLister interface {
+	// List lists all FlatNetworkIPReservations in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*flatnetworkpandariaiov1.FlatNetworkIPReservation, err error)
+	// Get retrieves the FlatNetworkIPReservation from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*flatnetworkpandariaiov1.FlatNetworkIPReservation, error)
+	FlatNetworkIPReservationNamespaceListerExpansion
+}
+
+// flatNetworkIPReservationNamespaceLister implements the FlatNetworkIPReservationNamespaceLister
+// interface.
+type flatNetworkIPReservationNamespaceLister struct {
+	listers.ResourceIndexer[*flatnetworkpandariaiov1.FlatNetworkIPReservation]
+}
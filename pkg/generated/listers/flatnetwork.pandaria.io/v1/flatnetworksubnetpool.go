@@ -0,0 +1,70 @@
+/*
+Copyright 2025 SUSE Rancher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package v1
+
+import (
+	flatnetworkpandariaiov1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// FlatNetworkSubnetPoolLister helps list FlatNetworkSubnetPools.
+// All objects returned here must be treated as read-only.
+type FlatNetworkSubnetPoolLister interface {
+	// List lists all FlatNetworkSubnetPools in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*flatnetworkpandariaiov1.FlatNetworkSubnetPool, err error)
+	// FlatNetworkSubnetPools returns an object that can list and get FlatNetworkSubnetPools.
+	FlatNetworkSubnetPools(namespace string) FlatNetworkSubnetPoolNamespaceLister
+	FlatNetworkSubnetPoolListerExpansion
+}
+
+// flatNetworkSubnetPoolLister implements the FlatNetworkSubnetPoolLister interface.
+type flatNetworkSubnetPoolLister struct {
+	listers.ResourceIndexer[*flatnetworkpandariaiov1.FlatNetworkSubnetPool]
+}
+
+// NewFlatNetworkSubnetPoolLister returns a new FlatNetworkSubnetPoolLister.
+func NewFlatNetworkSubnetPoolLister(indexer cache.Indexer) FlatNetworkSubnetPoolLister {
+	return &flatNetworkSubnetPoolLister{listers.New[*flatnetworkpandariaiov1.FlatNetworkSubnetPool](indexer, flatnetworkpandariaiov1.Resource("flatnetworksubnetpool"))}
+}
+
+// FlatNetworkSubnetPools returns an object that can list and get FlatNetworkSubnetPools.
+func (s *flatNetworkSubnetPoolLister) FlatNetworkSubnetPools(namespace string) FlatNetworkSubnetPoolNamespaceLister {
+	return flatNetworkSubnetPoolNamespaceLister{listers.NewNamespaced[*flatnetworkpandariaiov1.FlatNetworkSubnetPool](s.ResourceIndexer, namespace)}
+}
+
+// FlatNetworkSubnetPoolNamespaceLister helps list and get FlatNetworkSubnetPools.
+// All objects returned here must be treated as read-only.
+type FlatNetworkSubnetPoolNamespaceLister interface {
+	// List lists all FlatNetworkSubnetPools in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*flatnetworkpandariaiov1.FlatNetworkSubnetPool, err error)
+	// Get retrieves the FlatNetworkSubnetPool from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*flatnetworkpandariaiov1.FlatNetworkSubnetPool, error)
+	FlatNetworkSubnetPoolNamespaceListerExpansion
+}
+
+// flatNetworkSubnetPoolNamespaceLister implements the FlatNetworkSubnetPoolNamespaceLister
+// interface.
+type flatNetworkSubnetPoolNamespaceLister struct {
+	listers.ResourceIndexer[*flatnetworkpandariaiov1.FlatNetworkSubnetPool]
+}
@@ -0,0 +1,489 @@
+package ipcalc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"sort"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// ipInt is the 128-bit unsigned integer representation of a 16 bytes
+// length IP address.
+type ipInt struct {
+	hi uint64
+	lo uint64
+}
+
+func ipToInt(ip net.IP) (ipInt, bool) {
+	a := ip.To16()
+	if a == nil {
+		return ipInt{}, false
+	}
+	var v ipInt
+	for i := 0; i < 8; i++ {
+		v.hi = v.hi<<8 | uint64(a[i])
+		v.lo = v.lo<<8 | uint64(a[i+8])
+	}
+	return v, true
+}
+
+// ip returns the **16 bytes length** IP address of the integer.
+func (v ipInt) ip() net.IP {
+	a := make(net.IP, net.IPv6len)
+	for i := 7; i >= 0; i-- {
+		a[i] = byte(v.hi >> (8 * (7 - i)))
+		a[i+8] = byte(v.lo >> (8 * (7 - i)))
+	}
+	return a
+}
+
+func (v ipInt) cmp(o ipInt) int {
+	switch {
+	case v.hi < o.hi:
+		return -1
+	case v.hi > o.hi:
+		return 1
+	case v.lo < o.lo:
+		return -1
+	case v.lo > o.lo:
+		return 1
+	}
+	return 0
+}
+
+func (v ipInt) isMax() bool {
+	return v.hi == ^uint64(0) && v.lo == ^uint64(0)
+}
+
+// next returns v+1, the caller should ensure v is not the max value.
+func (v ipInt) next() ipInt {
+	v.lo++
+	if v.lo == 0 {
+		v.hi++
+	}
+	return v
+}
+
+// prev returns v-1, the caller should ensure v is not zero.
+func (v ipInt) prev() ipInt {
+	if v.lo == 0 {
+		v.hi--
+	}
+	v.lo--
+	return v
+}
+
+func (v ipInt) big() *big.Int {
+	b := new(big.Int).SetUint64(v.hi)
+	b.Lsh(b, 64)
+	return b.Or(b, new(big.Int).SetUint64(v.lo))
+}
+
+// interval is the closed interval [start, end] of IP addresses.
+type interval struct {
+	start ipInt
+	end   ipInt
+}
+
+func (r interval) size() *big.Int {
+	s := r.end.big()
+	s.Sub(s, r.start.big())
+	return s.Add(s, big.NewInt(1))
+}
+
+func (r interval) ipRange() flv1.IPRange {
+	return flv1.IPRange{
+		From: r.start.ip(),
+		To:   r.end.ip(),
+	}
+}
+
+// IPSet is a set of IP addresses stored as sorted, non-overlapping and
+// non-adjacent intervals (run-length encoded), the lookup of an address
+// is a binary search over the intervals instead of a scan over addresses.
+type IPSet struct {
+	intervals []interval
+}
+
+// NewIPSet builds the IPSet from IPRanges, the ranges do not need to be
+// sorted and may overlap with each other.
+func NewIPSet(ipRanges []flv1.IPRange) *IPSet {
+	s := &IPSet{
+		intervals: make([]interval, 0, len(ipRanges)),
+	}
+	for _, r := range ipRanges {
+		start, ok1 := ipToInt(r.From)
+		end, ok2 := ipToInt(r.To)
+		if !ok1 || !ok2 || start.cmp(end) > 0 {
+			continue
+		}
+		s.intervals = append(s.intervals, interval{start: start, end: end})
+	}
+	slices.SortFunc(s.intervals, func(a, b interval) int {
+		return a.start.cmp(b.start)
+	})
+	s.intervals = mergeIntervals(s.intervals)
+	return s
+}
+
+func mergeIntervals(intervals []interval) []interval {
+	if len(intervals) < 2 {
+		return intervals
+	}
+	merged := intervals[:1]
+	for _, r := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if last.end.isMax() || r.start.cmp(last.end.next()) <= 0 {
+			if r.end.cmp(last.end) > 0 {
+				last.end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// search returns the index of the first interval with end >= v.
+func (s *IPSet) search(v ipInt) int {
+	return sort.Search(len(s.intervals), func(i int) bool {
+		return s.intervals[i].end.cmp(v) >= 0
+	})
+}
+
+func (s *IPSet) contains(v ipInt) bool {
+	i := s.search(v)
+	return i < len(s.intervals) && s.intervals[i].start.cmp(v) <= 0
+}
+
+// add adds v into the set, returns false if v already in the set.
+func (s *IPSet) add(v ipInt) bool {
+	i := s.search(v)
+	if i < len(s.intervals) && s.intervals[i].start.cmp(v) <= 0 {
+		return false
+	}
+	mergePrev := i > 0 && s.intervals[i-1].end.next() == v
+	mergeNext := i < len(s.intervals) && !v.isMax() && s.intervals[i].start == v.next()
+	switch {
+	case mergePrev && mergeNext:
+		s.intervals[i-1].end = s.intervals[i].end
+		s.intervals = slices.Delete(s.intervals, i, i+1)
+	case mergePrev:
+		s.intervals[i-1].end = v
+	case mergeNext:
+		s.intervals[i].start = v
+	default:
+		s.intervals = slices.Insert(s.intervals, i, interval{start: v, end: v})
+	}
+	return true
+}
+
+// remove removes v from the set, returns false if v not in the set.
+func (s *IPSet) remove(v ipInt) bool {
+	i := s.search(v)
+	if i >= len(s.intervals) || s.intervals[i].start.cmp(v) > 0 {
+		return false
+	}
+	r := s.intervals[i]
+	switch {
+	case r.start == v && r.end == v:
+		s.intervals = slices.Delete(s.intervals, i, i+1)
+	case r.start == v:
+		s.intervals[i].start = v.next()
+	case r.end == v:
+		s.intervals[i].end = v.prev()
+	default:
+		s.intervals[i].end = v.prev()
+		s.intervals = slices.Insert(s.intervals, i+1, interval{start: v.next(), end: r.end})
+	}
+	return true
+}
+
+// firstFree returns the first address in [lo, hi] not in the set.
+func (s *IPSet) firstFree(lo, hi ipInt) (ipInt, bool) {
+	if lo.cmp(hi) > 0 {
+		return ipInt{}, false
+	}
+	i := s.search(lo)
+	if i >= len(s.intervals) || s.intervals[i].start.cmp(lo) > 0 {
+		return lo, true
+	}
+	// The intervals are merged, the address after the interval
+	// containing lo is not in the set.
+	end := s.intervals[i].end
+	if end.isMax() || end.cmp(hi) >= 0 {
+		return ipInt{}, false
+	}
+	return end.next(), true
+}
+
+// countIn returns the number of addresses of the set inside [lo, hi].
+func (s *IPSet) countIn(lo, hi ipInt) *big.Int {
+	count := new(big.Int)
+	for i := s.search(lo); i < len(s.intervals); i++ {
+		r := s.intervals[i]
+		if r.start.cmp(hi) > 0 {
+			break
+		}
+		if r.start.cmp(lo) < 0 {
+			r.start = lo
+		}
+		if r.end.cmp(hi) > 0 {
+			r.end = hi
+		}
+		count.Add(count, r.size())
+	}
+	return count
+}
+
+// Add adds the IP address into the set, returns false if already exists.
+func (s *IPSet) Add(ip net.IP) bool {
+	v, ok := ipToInt(ip)
+	if !ok {
+		return false
+	}
+	return s.add(v)
+}
+
+// Remove removes the IP address from the set, returns false if not exists.
+func (s *IPSet) Remove(ip net.IP) bool {
+	v, ok := ipToInt(ip)
+	if !ok {
+		return false
+	}
+	return s.remove(v)
+}
+
+// Contains checks whether the IP address is in the set.
+func (s *IPSet) Contains(ip net.IP) bool {
+	v, ok := ipToInt(ip)
+	if !ok {
+		return false
+	}
+	return s.contains(v)
+}
+
+// Len returns the number of the IP addresses in the set.
+func (s *IPSet) Len() *big.Int {
+	count := new(big.Int)
+	for _, r := range s.intervals {
+		count.Add(count, r.size())
+	}
+	return count
+}
+
+// Difference returns a new set of the IP addresses in the set but not in
+// the other set.
+func (s *IPSet) Difference(o *IPSet) *IPSet {
+	result := &IPSet{}
+	j := 0
+	for _, r := range s.intervals {
+		// Skip the intervals of the other set before r.
+		for j < len(o.intervals) && o.intervals[j].end.cmp(r.start) < 0 {
+			j++
+		}
+		start, covered := r.start, false
+		for k := j; k < len(o.intervals) && o.intervals[k].start.cmp(r.end) <= 0; k++ {
+			x := o.intervals[k]
+			if x.start.cmp(start) > 0 {
+				result.intervals = append(result.intervals, interval{start: start, end: x.start.prev()})
+			}
+			if x.end.cmp(r.end) >= 0 {
+				covered = true
+				break
+			}
+			start = x.end.next()
+		}
+		if !covered {
+			result.intervals = append(result.intervals, interval{start: start, end: r.end})
+		}
+	}
+	return result
+}
+
+// Ranges returns the sorted IPRanges of the set with **16 bytes** IPs.
+func (s *IPSet) Ranges() []flv1.IPRange {
+	ranges := make([]flv1.IPRange, 0, len(s.intervals))
+	for _, r := range s.intervals {
+		ranges = append(ranges, r.ipRange())
+	}
+	return ranges
+}
+
+// Allocator allocates IP addresses from the subnet CIDR (or the subnet
+// custom IPRanges) excluding the used addresses.
+//
+// The used addresses are stored in an IPSet, allocate, reserve and release
+// an address only need a binary search on the used intervals, rather than
+// iterating the addresses one by one.
+type Allocator struct {
+	// pool is the allocatable intervals in the order of the subnet ranges,
+	// network and broadcast addresses are excluded.
+	pool []interval
+	// poolSet is the merged pool used for counting.
+	poolSet *IPSet
+	used    *IPSet
+
+	usedInPool *big.Int
+}
+
+// NewAllocator builds the Allocator by subnet CIDR, IPRanges (optional)
+// and the used IPRanges.
+func NewAllocator(
+	cidr string, ipRanges []flv1.IPRange, usedIPs []flv1.IPRange,
+) (*Allocator, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	a := &Allocator{
+		used: NewIPSet(usedIPs),
+	}
+	bounds, ok := networkBounds(network)
+	if ok && len(ipRanges) == 0 {
+		a.pool = append(a.pool, bounds)
+	}
+	for _, r := range ipRanges {
+		if !ok {
+			break
+		}
+		start, ok1 := ipToInt(r.From)
+		end, ok2 := ipToInt(r.To)
+		if !ok1 || !ok2 {
+			continue
+		}
+		// Clip the range inside the network.
+		if start.cmp(bounds.start) < 0 {
+			start = bounds.start
+		}
+		if end.cmp(bounds.end) > 0 {
+			end = bounds.end
+		}
+		if start.cmp(end) > 0 {
+			continue
+		}
+		a.pool = append(a.pool, interval{start: start, end: end})
+	}
+	a.poolSet = &IPSet{
+		intervals: mergeIntervals(slices.SortedFunc(slices.Values(a.pool), func(a, b interval) int {
+			return a.start.cmp(b.start)
+		})),
+	}
+	a.usedInPool = new(big.Int)
+	for _, r := range a.poolSet.intervals {
+		a.usedInPool.Add(a.usedInPool, a.used.countIn(r.start, r.end))
+	}
+	return a, nil
+}
+
+// Clone returns a copy of the Allocator, the addresses allocated, reserved
+// or released by the copy do not affect the original one.
+func (a *Allocator) Clone() *Allocator {
+	return &Allocator{
+		pool:       a.pool,
+		poolSet:    a.poolSet,
+		used:       &IPSet{intervals: slices.Clone(a.used.intervals)},
+		usedInPool: new(big.Int).Set(a.usedInPool),
+	}
+}
+
+// networkBounds returns the allocatable interval of the network, the network
+// address and broadcast address are excluded.
+// The returned bool is false if no address is allocatable in the network.
+func networkBounds(network *net.IPNet) (interval, bool) {
+	first, ok := ipToInt(network.IP.Mask(network.Mask))
+	if !ok {
+		return interval{}, false
+	}
+	mask := MaskXOR(network.Mask)
+	last := slices.Clone(network.IP.Mask(network.Mask).To16())
+	for i := 0; i < len(mask); i++ {
+		last[len(last)-len(mask)+i] |= mask[i]
+	}
+	end, _ := ipToInt(last)
+	if end.cmp(first) <= 0 || end.cmp(first.next()) <= 0 {
+		return interval{}, false
+	}
+	return interval{start: first.next(), end: end.prev()}, true
+}
+
+// Allocate allocates the lowest available IP address (**16 bytes**)
+// and marks it as used.
+// ErrNoAvailableIP error will be returned if no IP address resource available.
+func (a *Allocator) Allocate() (net.IP, error) {
+	for _, r := range a.pool {
+		v, ok := a.used.firstFree(r.start, r.end)
+		if !ok {
+			continue
+		}
+		a.reserve(v)
+		return v.ip(), nil
+	}
+	return nil, ErrNoAvailableIP
+}
+
+// Reserve marks the IP address as used.
+// ErrNoAvailableIP error will be returned if the address is not available.
+func (a *Allocator) Reserve(ip net.IP) error {
+	v, ok := ipToInt(ip)
+	if !ok {
+		return fmt.Errorf("invalid IP address %q", ip)
+	}
+	if !a.poolSet.contains(v) || a.used.contains(v) {
+		return fmt.Errorf("IP address %q is not available: %w", ip, ErrNoAvailableIP)
+	}
+	a.reserve(v)
+	return nil
+}
+
+func (a *Allocator) reserve(v ipInt) {
+	if a.used.add(v) && a.poolSet.contains(v) {
+		a.usedInPool.Add(a.usedInPool, big.NewInt(1))
+	}
+}
+
+// Release removes the IP address from the used addresses.
+func (a *Allocator) Release(ip net.IP) {
+	v, ok := ipToInt(ip)
+	if !ok {
+		return
+	}
+	if a.used.remove(v) && a.poolSet.contains(v) {
+		a.usedInPool.Sub(a.usedInPool, big.NewInt(1))
+	}
+}
+
+// InPool checks whether the IP address is allocatable by this allocator,
+// no matter it is used or not.
+func (a *Allocator) InPool(ip net.IP) bool {
+	return a.poolSet.Contains(ip)
+}
+
+// IsUsed checks whether the IP address is already used.
+func (a *Allocator) IsUsed(ip net.IP) bool {
+	return a.used.Contains(ip)
+}
+
+// IsAvailable checks whether the IP address is allocatable and not used.
+func (a *Allocator) IsAvailable(ip net.IP) bool {
+	return a.InPool(ip) && !a.IsUsed(ip)
+}
+
+// Size returns the number of allocatable addresses (used or not).
+func (a *Allocator) Size() *big.Int {
+	return a.poolSet.Len()
+}
+
+// Free returns the number of available addresses.
+func (a *Allocator) Free() *big.Int {
+	free := a.Size()
+	return free.Sub(free, a.usedInPool)
+}
+
+// UsedRanges returns the sorted used IPRanges.
+func (a *Allocator) UsedRanges() []flv1.IPRange {
+	return a.used.Ranges()
+}
@@ -0,0 +1,260 @@
+package ipcalc
+
+import (
+	"math/big"
+	"net"
+	"testing"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewIPSet(t *testing.T) {
+	s := NewIPSet(nil)
+	assert.Equal(t, s.Ranges(), []flv1.IPRange{})
+	assert.Equal(t, s.Len().Int64(), int64(0))
+
+	// Unsorted, overlapping and adjacent ranges are merged.
+	s = NewIPSet([]flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.20"),
+			To:   net.ParseIP("10.0.0.30"),
+		},
+		{
+			From: net.ParseIP("10.0.0.1"),
+			To:   net.ParseIP("10.0.0.10"),
+		},
+		{
+			From: net.ParseIP("10.0.0.11"),
+			To:   net.ParseIP("10.0.0.15"),
+		},
+		{
+			From: net.ParseIP("10.0.0.25"),
+			To:   net.ParseIP("10.0.0.40"),
+		},
+		{
+			// Invalid range is ignored.
+			From: net.ParseIP("10.0.0.100"),
+			To:   net.ParseIP("10.0.0.99"),
+		},
+	})
+	assert.Equal(t, s.Ranges(), []flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.1"),
+			To:   net.ParseIP("10.0.0.15"),
+		},
+		{
+			From: net.ParseIP("10.0.0.20"),
+			To:   net.ParseIP("10.0.0.40"),
+		},
+	})
+	assert.Equal(t, s.Len().Int64(), int64(36))
+	assert.True(t, s.Contains(net.ParseIP("10.0.0.15")))
+	assert.False(t, s.Contains(net.ParseIP("10.0.0.16")))
+	assert.False(t, s.Contains(nil))
+}
+
+func Test_IPSet_AddRemove(t *testing.T) {
+	s := NewIPSet(nil)
+	assert.True(t, s.Add(net.ParseIP("fd00::1")))
+	assert.True(t, s.Add(net.ParseIP("fd00::3")))
+	assert.False(t, s.Add(net.ParseIP("fd00::3")))
+	assert.True(t, s.Add(net.ParseIP("fd00::2")))
+	assert.Equal(t, s.Ranges(), []flv1.IPRange{
+		{
+			From: net.ParseIP("fd00::1"),
+			To:   net.ParseIP("fd00::3"),
+		},
+	})
+
+	assert.True(t, s.Remove(net.ParseIP("fd00::2")))
+	assert.False(t, s.Remove(net.ParseIP("fd00::2")))
+	assert.Equal(t, s.Ranges(), []flv1.IPRange{
+		{
+			From: net.ParseIP("fd00::1"),
+			To:   net.ParseIP("fd00::1"),
+		},
+		{
+			From: net.ParseIP("fd00::3"),
+			To:   net.ParseIP("fd00::3"),
+		},
+	})
+
+	// Boundary of the 128-bit address space.
+	assert.True(t, s.Add(net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")))
+	assert.True(t, s.Add(net.ParseIP("::")))
+	assert.Equal(t, s.Len().Int64(), int64(4))
+	assert.True(t, s.Remove(net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")))
+	assert.True(t, s.Remove(net.ParseIP("::")))
+	assert.Equal(t, s.Len().Int64(), int64(2))
+}
+
+func Test_IPSet_Difference(t *testing.T) {
+	s := NewIPSet([]flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.1"),
+			To:   net.ParseIP("10.0.0.10"),
+		},
+		{
+			From: net.ParseIP("10.0.0.20"),
+			To:   net.ParseIP("10.0.0.30"),
+		},
+	})
+	o := NewIPSet([]flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.3"),
+			To:   net.ParseIP("10.0.0.4"),
+		},
+		{
+			From: net.ParseIP("10.0.0.10"),
+			To:   net.ParseIP("10.0.0.25"),
+		},
+	})
+	assert.Equal(t, s.Difference(o).Ranges(), []flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.1"),
+			To:   net.ParseIP("10.0.0.2"),
+		},
+		{
+			From: net.ParseIP("10.0.0.5"),
+			To:   net.ParseIP("10.0.0.9"),
+		},
+		{
+			From: net.ParseIP("10.0.0.26"),
+			To:   net.ParseIP("10.0.0.30"),
+		},
+	})
+	assert.Equal(t, o.Difference(s).Ranges(), []flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.11"),
+			To:   net.ParseIP("10.0.0.19"),
+		},
+	})
+	assert.Equal(t, s.Difference(s).Len().Int64(), int64(0))
+	assert.Equal(t, s.Difference(NewIPSet(nil)).Len(), s.Len())
+}
+
+func Test_NewAllocator(t *testing.T) {
+	a, err := NewAllocator("invalid data", nil, nil)
+	assert.Nil(t, a)
+	assert.NotNil(t, err)
+
+	a, err = NewAllocator("10.0.0.0/24", nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, a.Size().Int64(), int64(254))
+	assert.Equal(t, a.Free().Int64(), int64(254))
+	assert.False(t, a.InPool(net.ParseIP("10.0.0.0")))
+	assert.False(t, a.InPool(net.ParseIP("10.0.0.255")))
+
+	// Ranges are clipped inside the network.
+	a, err = NewAllocator("10.0.0.0/24", []flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.200"),
+			To:   net.ParseIP("10.0.1.100"),
+		},
+		{
+			From: net.ParseIP("10.1.0.0"),
+			To:   net.ParseIP("10.1.0.100"),
+		},
+	}, []flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.1"),
+			To:   net.ParseIP("10.0.0.201"),
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, a.Size().Int64(), int64(55))
+	assert.Equal(t, a.Free().Int64(), int64(53))
+
+	// No allocatable address in /32 network.
+	a, err = NewAllocator("10.0.0.1/32", nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, a.Size().Int64(), int64(0))
+	ip, err := a.Allocate()
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, ErrNoAvailableIP)
+
+	// IPv6 network size is not limited by int64.
+	a, err = NewAllocator("fd00::/64", nil, nil)
+	assert.Nil(t, err)
+	size := new(big.Int).Lsh(big.NewInt(1), 64)
+	size.Sub(size, big.NewInt(2))
+	assert.Equal(t, a.Size(), size)
+}
+
+func Test_Allocator_Allocate(t *testing.T) {
+	a, err := NewAllocator("192.168.1.0/24", []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.100"),
+			To:   net.ParseIP("192.168.1.102"),
+		},
+		{
+			From: net.ParseIP("192.168.1.10"),
+			To:   net.ParseIP("192.168.1.10"),
+		},
+	}, []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.101"),
+			To:   net.ParseIP("192.168.1.101"),
+		},
+	})
+	assert.Nil(t, err)
+
+	// Allocate in the order of the ranges.
+	for _, expected := range []string{"192.168.1.100", "192.168.1.102", "192.168.1.10"} {
+		ip, err := a.Allocate()
+		assert.Nil(t, err)
+		assert.Equal(t, ip, net.ParseIP(expected))
+	}
+	ip, err := a.Allocate()
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, ErrNoAvailableIP)
+	assert.Equal(t, a.Free().Int64(), int64(0))
+
+	a.Release(net.ParseIP("192.168.1.102"))
+	assert.True(t, a.IsAvailable(net.ParseIP("192.168.1.102")))
+	assert.Equal(t, a.Free().Int64(), int64(1))
+	ip, err = a.Allocate()
+	assert.Nil(t, err)
+	assert.Equal(t, ip, net.ParseIP("192.168.1.102"))
+}
+
+func Test_Allocator_Clone(t *testing.T) {
+	a, err := NewAllocator("10.0.0.0/30", nil, nil)
+	assert.Nil(t, err)
+
+	c := a.Clone()
+	ip, err := c.Allocate()
+	assert.Nil(t, err)
+	assert.Equal(t, ip, net.ParseIP("10.0.0.1"))
+	assert.True(t, c.IsUsed(ip))
+	assert.False(t, a.IsUsed(ip))
+	assert.Equal(t, c.Free().Int64(), int64(1))
+	assert.Equal(t, a.Free().Int64(), int64(2))
+
+	ip, err = a.Clone().Allocate()
+	assert.Nil(t, err)
+	assert.Equal(t, ip, net.ParseIP("10.0.0.1"))
+}
+
+func Test_Allocator_Reserve(t *testing.T) {
+	a, err := NewAllocator("10.0.0.0/24", nil, nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, a.Reserve(net.ParseIP("10.0.0.1")))
+	assert.ErrorIs(t, a.Reserve(net.ParseIP("10.0.0.1")), ErrNoAvailableIP)
+	assert.ErrorIs(t, a.Reserve(net.ParseIP("10.0.0.255")), ErrNoAvailableIP)
+	assert.ErrorIs(t, a.Reserve(net.ParseIP("10.0.1.1")), ErrNoAvailableIP)
+	assert.NotNil(t, a.Reserve(nil))
+	assert.True(t, a.IsUsed(net.ParseIP("10.0.0.1")))
+
+	ip, err := a.Allocate()
+	assert.Nil(t, err)
+	assert.Equal(t, ip, net.ParseIP("10.0.0.2"))
+	assert.Equal(t, a.UsedRanges(), []flv1.IPRange{
+		{
+			From: net.ParseIP("10.0.0.1"),
+			To:   net.ParseIP("10.0.0.2"),
+		},
+	})
+}
@@ -6,16 +6,18 @@ import (
 	"fmt"
 	"net"
 	"slices"
+	"sort"
 
 	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 )
 
 var (
-	ErrNoAvailableIP    = errors.New("no available IP address")
-	ErrNoAvailableMac   = errors.New("no available MAC address")
-	ErrNetworkConflict  = errors.New("network CIDR conflict")
-	ErrIPRangesConflict = errors.New("ip ranges conflict")
+	ErrNoAvailableIP     = errors.New("no available IP address")
+	ErrNoAvailableMac    = errors.New("no available MAC address")
+	ErrNetworkConflict   = errors.New("network CIDR conflict")
+	ErrIPRangesConflict  = errors.New("ip ranges conflict")
+	ErrMACPrefixConflict = errors.New("MAC prefix conflict")
 )
 
 // IPIncrease increases the provided IP address.
@@ -82,176 +84,88 @@ func IPInRanges(ip net.IP, ipRanges []flv1.IPRange) bool {
 func GetAvailableIP(
 	cidr string, ipRanges []flv1.IPRange, usedIPs []flv1.IPRange,
 ) (net.IP, error) {
-	ip, network, err := net.ParseCIDR(cidr)
+	a, err := NewAllocator(cidr, ipRanges, usedIPs)
 	if err != nil {
 		return nil, err
 	}
-
-	// Iterate to get an available IP address from defined IPRanges.
-	if len(ipRanges) > 0 {
-		for _, r := range ipRanges {
-			start := slices.Clone(r.From.To16())
-			end := r.To.To16()
-			// Skip the already used range to increase performance.
-			if len(usedIPs) != 0 {
-				for _, u := range usedIPs {
-					if u.From.Equal(start) {
-						start = slices.Clone(u.To.To16())
-					}
-				}
-			}
-			if len(start) == 0 || len(end) == 0 {
-				continue
-			}
-
-			for ip := start; bytes.Compare(start, end) <= 0; IPIncrease(ip) {
-				ip = ip.To16() // Ensure IP in 16 bytes
-				// Remove network address and broadcast address.
-				if len(ip) == 0 || !IsAvailableIP(ip, network) {
-					continue
-				}
-				if !IPInRanges(ip, usedIPs) {
-					return ip, nil
-				}
-			}
-		}
-		return nil, ErrNoAvailableIP
-	}
-
-	// No custom IPRange defined, iterate to get available IP address.
-	start := net.IP(bytes.Clone(ip.Mask(network.Mask)))
-	IPIncrease(start)
-	// Skip the already used range to increase performance.
-	if len(usedIPs) != 0 {
-		for _, u := range usedIPs {
-			if u.From.Equal(start) {
-				start = slices.Clone(u.To.To16())
-			}
-		}
-	}
-	for ip = start; network.Contains(ip); IPIncrease(ip) {
-		ip = ip.To16() // Ensure IP in 16 bytes
-		// Remove network address and broadcast address.
-		if len(ip) == 0 || !IsAvailableIP(ip, network) {
-			continue
-		}
-		if !IPInRanges(ip, usedIPs) {
-			return ip, nil
-		}
-	}
-
-	return nil, ErrNoAvailableIP
+	return a.Allocate()
 }
 
 // AddIPToRange adds an IP address to IPRange.
+// The ipRanges should be sorted and merged as returned by IPSet.Ranges,
+// the address is inserted by binary search and the input is not modified.
 func AddIPToRange(ip net.IP, ipRanges []flv1.IPRange) []flv1.IPRange {
-	if ip == nil {
+	v, ok := ipToInt(ip)
+	if !ok {
 		return ipRanges
 	}
-	length := len(ipRanges)
-	if length == 0 {
-		ipRanges = []flv1.IPRange{}
-	}
-	if IPInRanges(ip, ipRanges) {
+	i := searchIPRange(ipRanges, v)
+	if i < len(ipRanges) && rangeFrom(ipRanges[i]).cmp(v) <= 0 {
 		// Skip if ip already in ranges.
 		return ipRanges
 	}
-	deleteIndex := -1
-	for i := range ipRanges {
-		var s1 net.IP = bytes.Clone(ipRanges[i].From)
-		var s2 net.IP = bytes.Clone(ipRanges[i].To)
-		IPDecrease(s1)
-		IPIncrease(s2)
-		if ip.Equal(s1) {
-			ipRanges[i].From = s1
-			return ipRanges
-		}
-		if ip.Equal(s2) {
-			if i < length-1 {
-				// Connect two ranges
-				next := ipRanges[i+1]
-				var nextFrom net.IP = bytes.Clone(next.From)
-				IPDecrease(nextFrom)
-				if s2.Equal(nextFrom) {
-					ipRanges[i+1].From = ipRanges[i].From
-					deleteIndex = i
-					break
-				}
-			}
-			ipRanges[i].To = s2
-			return ipRanges
-		}
-	}
-	if deleteIndex >= 0 {
-		ipRanges = append(ipRanges[:deleteIndex], ipRanges[deleteIndex+1:]...)
-		return ipRanges
-	}
-	ipRanges = append(ipRanges, flv1.IPRange{
-		From: bytes.Clone(ip),
-		To:   bytes.Clone(ip),
-	})
-	slices.SortFunc(ipRanges, func(a, b flv1.IPRange) int {
-		return bytes.Compare(a.From, b.From)
-	})
-	return ipRanges
+	mergePrev := i > 0 && rangeTo(ipRanges[i-1]).next() == v
+	mergeNext := i < len(ipRanges) && !v.isMax() && rangeFrom(ipRanges[i]) == v.next()
+	result := slices.Clone(ipRanges)
+	switch {
+	case mergePrev && mergeNext:
+		result[i-1].To = result[i].To
+		result = slices.Delete(result, i, i+1)
+	case mergePrev:
+		result[i-1].To = v.ip()
+	case mergeNext:
+		result[i].From = v.ip()
+	default:
+		result = slices.Insert(result, i, flv1.IPRange{From: v.ip(), To: v.ip()})
+	}
+	return result
 }
 
 // RemoveIPFromRange removes an IP address from IPRange.
+// The ipRanges should be sorted and merged as returned by IPSet.Ranges,
+// the address is removed by binary search and the input is not modified.
 func RemoveIPFromRange(ip net.IP, ipRanges []flv1.IPRange) []flv1.IPRange {
-	ip = ip.To16() // ensure 16 bytes length.
-	if ip == nil {
+	v, ok := ipToInt(ip)
+	if !ok {
 		return ipRanges
 	}
-	if !IPInRanges(ip, ipRanges) {
+	i := searchIPRange(ipRanges, v)
+	if i >= len(ipRanges) || rangeFrom(ipRanges[i]).cmp(v) > 0 {
 		// Skip if ip not in ranges.
 		return ipRanges
 	}
-	newRanges := []flv1.IPRange{}
-	for _, r := range ipRanges {
-		start := r.From.To16()
-		end := r.To.To16()
-		a := bytes.Compare(start, ip)
-		b := bytes.Compare(end, ip)
-		switch {
-		case a < 0 && b > 0:
-			var s1 net.IP = bytes.Clone(ip)
-			var s2 net.IP = bytes.Clone(ip)
-			IPDecrease(s1)
-			IPIncrease(s2)
-			newRanges = append(newRanges, flv1.IPRange{
-				From: start,
-				To:   s1,
-			})
-			newRanges = append(newRanges, flv1.IPRange{
-				From: s2,
-				To:   end,
-			})
-		case a == 0 && b > 0:
-			var s1 net.IP = bytes.Clone(ip)
-			IPIncrease(s1)
-			newRanges = append(newRanges, flv1.IPRange{
-				From: s1,
-				To:   end,
-			})
-		case a < 0 && b == 0:
-			var s2 net.IP = bytes.Clone(ip)
-			IPDecrease(s2)
-			newRanges = append(newRanges, flv1.IPRange{
-				From: start,
-				To:   s2,
-			})
-		case a == 0 && b == 0:
-		default:
-			newRanges = append(newRanges, flv1.IPRange{
-				From: start,
-				To:   end,
-			})
-		}
-	}
-	slices.SortFunc(newRanges, func(a, b flv1.IPRange) int {
-		return bytes.Compare(a.From, b.From)
+	from, to := rangeFrom(ipRanges[i]), rangeTo(ipRanges[i])
+	result := slices.Clone(ipRanges)
+	switch {
+	case from == v && to == v:
+		result = slices.Delete(result, i, i+1)
+	case from == v:
+		result[i].From = v.next().ip()
+	case to == v:
+		result[i].To = v.prev().ip()
+	default:
+		result[i].To = v.prev().ip()
+		result = slices.Insert(result, i+1, flv1.IPRange{From: v.next().ip(), To: ipRanges[i].To})
+	}
+	return result
+}
+
+// searchIPRange returns the index of the first range with To >= v in the
+// sorted ranges.
+func searchIPRange(ipRanges []flv1.IPRange, v ipInt) int {
+	return sort.Search(len(ipRanges), func(i int) bool {
+		return rangeTo(ipRanges[i]).cmp(v) >= 0
 	})
-	return newRanges
+}
+
+func rangeFrom(r flv1.IPRange) ipInt {
+	v, _ := ipToInt(r.From)
+	return v
+}
+
+func rangeTo(r flv1.IPRange) ipInt {
+	v, _ := ipToInt(r.To)
+	return v
 }
 
 // MaskXOR returns the XOR-ed network mask.
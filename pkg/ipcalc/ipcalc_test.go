@@ -564,6 +564,22 @@ func Test_RemoveIPFromRange(t *testing.T) {
 
 	r = RemoveIPFromRange(net.ParseIP("fd00::1"), r)
 	assert.Equal(t, r, []flv1.IPRange{})
+
+	// The input ranges are not modified.
+	r = []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.100"),
+			To:   net.ParseIP("192.168.1.200"),
+		},
+	}
+	_ = RemoveIPFromRange(net.ParseIP("192.168.1.150"), r)
+	_ = AddIPToRange(net.ParseIP("192.168.1.201"), r)
+	assert.Equal(t, r, []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.100"),
+			To:   net.ParseIP("192.168.1.200"),
+		},
+	})
 }
 
 func Test_MaskXOR(t *testing.T) {
@@ -0,0 +1,144 @@
+package ipcalc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strings"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+const (
+	macLength = 6
+
+	// maxRandomMACRetry is the max number of the random MAC addresses
+	// generated before checking the available MAC addresses sequentially.
+	maxRandomMACRetry = 64
+)
+
+// ParseMACPrefix parses the MAC address prefix in '02:42:ac' format,
+// the prefix should be a unicast address prefix of 1-5 bytes.
+func ParseMACPrefix(prefix string) ([]byte, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("empty MAC prefix")
+	}
+	parts := strings.Split(prefix, ":")
+	n := len(parts)
+	if n >= macLength {
+		return nil, fmt.Errorf("MAC prefix %q too long: should be 1-%d bytes",
+			prefix, macLength-1)
+	}
+	// Pad the prefix to a full MAC address to reuse the net.ParseMAC.
+	for len(parts) < macLength {
+		parts = append(parts, "00")
+	}
+	mac, err := net.ParseMAC(strings.Join(parts, ":"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC prefix %q: %w", prefix, err)
+	}
+	if mac[0]&0x01 != 0 {
+		return nil, fmt.Errorf("invalid MAC prefix %q: multicast address", prefix)
+	}
+	return mac[:n], nil
+}
+
+// MACHasPrefix returns true if the MAC address string has the prefix.
+func MACHasPrefix(mac string, prefix []byte) bool {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != macLength {
+		return false
+	}
+	return bytes.HasPrefix(hw, prefix)
+}
+
+// CheckMACPrefixConflict returns error if the MAC addresses generated within
+// the prefixes may overlap, the empty prefix does not conflict with others.
+func CheckMACPrefixConflict(prefix1, prefix2 string) error {
+	if prefix1 == "" || prefix2 == "" {
+		return nil
+	}
+	p1, err := ParseMACPrefix(prefix1)
+	if err != nil {
+		return err
+	}
+	p2, err := ParseMACPrefix(prefix2)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(p1, p2) || bytes.HasPrefix(p2, p1) {
+		return fmt.Errorf("MAC prefix %q overlaps with %q: %w",
+			prefix1, prefix2, ErrMACPrefixConflict)
+	}
+	return nil
+}
+
+// GenerateMAC generates a MAC address within the prefix by the subnet
+// MAC allocation mode, the MAC addresses in used are skipped.
+//
+// random: generate the MAC address randomly;
+// ip: derive the MAC address from the low bytes of the IP address.
+func GenerateMAC(
+	prefix []byte, mode string, ip net.IP, used []string,
+) (string, error) {
+	if len(prefix) == 0 || len(prefix) >= macLength {
+		return "", fmt.Errorf("invalid MAC prefix length %d", len(prefix))
+	}
+	usedSet := make(map[string]bool, len(used))
+	for _, m := range used {
+		usedSet[strings.ToLower(m)] = true
+	}
+	mac := make(net.HardwareAddr, macLength)
+	copy(mac, prefix)
+	suffix := mac[len(prefix):]
+
+	switch mode {
+	case flv1.MACAllocationIP:
+		if len(ip) == 0 {
+			return "", fmt.Errorf("failed to derive MAC from empty IP address")
+		}
+		ip = ip.To16()
+		copy(suffix, ip[len(ip)-len(suffix):])
+		if usedSet[mac.String()] {
+			return "", fmt.Errorf("MAC %q derived from IP %q already in used: %w",
+				mac.String(), ip.String(), ErrNoAvailableMac)
+		}
+		return mac.String(), nil
+	case "", flv1.MACAllocationRandom:
+	default:
+		return "", fmt.Errorf("unrecognized MAC allocation mode %q", mode)
+	}
+
+	for i := 0; i < maxRandomMACRetry; i++ {
+		if _, err := rand.Read(suffix); err != nil {
+			return "", fmt.Errorf("failed to generate random MAC: %w", err)
+		}
+		if !usedSet[mac.String()] {
+			return mac.String(), nil
+		}
+	}
+	// Fallback to find the available MAC sequentially if the prefix is
+	// nearly exhausted.
+	clear(suffix)
+	for {
+		if !usedSet[mac.String()] {
+			return mac.String(), nil
+		}
+		IPIncrease(net.IP(suffix))
+		if allZero(suffix) {
+			break
+		}
+	}
+	return "", fmt.Errorf("no available MAC address in prefix %q: %w",
+		net.HardwareAddr(prefix).String(), ErrNoAvailableMac)
+}
+
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package ipcalc
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseMACPrefix(t *testing.T) {
+	prefix, err := ParseMACPrefix("02:42:ac")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x02, 0x42, 0xac}, prefix)
+
+	prefix, err = ParseMACPrefix("02:42:AC:10:00")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x02, 0x42, 0xac, 0x10, 0x00}, prefix)
+
+	_, err = ParseMACPrefix("")
+	assert.NotNil(t, err)
+	_, err = ParseMACPrefix("02:42:ac:10:00:01")
+	assert.NotNil(t, err)
+	_, err = ParseMACPrefix("02:42:xx")
+	assert.NotNil(t, err)
+	_, err = ParseMACPrefix("01:00:5e")
+	assert.NotNil(t, err)
+}
+
+func Test_MACHasPrefix(t *testing.T) {
+	prefix := []byte{0x02, 0x42, 0xac}
+	assert.True(t, MACHasPrefix("02:42:ac:00:00:01", prefix))
+	assert.True(t, MACHasPrefix("02:42:AC:00:00:01", prefix))
+	assert.False(t, MACHasPrefix("02:42:ad:00:00:01", prefix))
+	assert.False(t, MACHasPrefix("invalid", prefix))
+}
+
+func Test_CheckMACPrefixConflict(t *testing.T) {
+	assert.Nil(t, CheckMACPrefixConflict("", "02:42:ac"))
+	assert.Nil(t, CheckMACPrefixConflict("02:42:ac", "02:42:ad"))
+	assert.ErrorIs(t, CheckMACPrefixConflict("02:42:ac", "02:42:AC"), ErrMACPrefixConflict)
+	assert.ErrorIs(t, CheckMACPrefixConflict("02:42", "02:42:ac:10"), ErrMACPrefixConflict)
+	assert.ErrorIs(t, CheckMACPrefixConflict("02:42:ac:10", "02:42"), ErrMACPrefixConflict)
+}
+
+func Test_GenerateMAC(t *testing.T) {
+	prefix := []byte{0x02, 0x42, 0xac}
+	mac, err := GenerateMAC(prefix, flv1.MACAllocationIP, net.ParseIP("10.1.2.3"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "02:42:ac:01:02:03", mac)
+	mac, err = GenerateMAC(prefix, flv1.MACAllocationIP, net.ParseIP("fd00::1:2:3"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "02:42:ac:02:00:03", mac)
+	_, err = GenerateMAC(prefix, flv1.MACAllocationIP, net.ParseIP("10.1.2.3"),
+		[]string{"02:42:AC:01:02:03"})
+	assert.ErrorIs(t, err, ErrNoAvailableMac)
+	_, err = GenerateMAC(prefix, flv1.MACAllocationIP, nil, nil)
+	assert.NotNil(t, err)
+
+	mac, err = GenerateMAC(prefix, flv1.MACAllocationRandom, nil, nil)
+	assert.Nil(t, err)
+	assert.True(t, MACHasPrefix(mac, prefix))
+	_, err = GenerateMAC(prefix, "unknown", nil, nil)
+	assert.NotNil(t, err)
+
+	// All of the MAC addresses within the prefix are used except one.
+	prefix = []byte{0x02, 0x42, 0xac, 0x10, 0x00}
+	used := []string{}
+	for i := 0; i < 256; i++ {
+		if i == 100 {
+			continue
+		}
+		used = append(used, fmt.Sprintf("02:42:ac:10:00:%02x", i))
+	}
+	mac, err = GenerateMAC(prefix, "", nil, used)
+	assert.Nil(t, err)
+	assert.Equal(t, "02:42:ac:10:00:64", mac)
+	used = append(used, "02:42:ac:10:00:64")
+	_, err = GenerateMAC(prefix, "", nil, used)
+	assert.ErrorIs(t, err, ErrNoAvailableMac)
+}
@@ -0,0 +1,107 @@
+package ipcalc
+
+import (
+	"net"
+	"slices"
+	"time"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PruneReleasedIP returns the released IPs which are still waiting for reuse,
+// the expired released IPs are removed.
+// All of the released IPs are expired if the reuse delay is not positive.
+func PruneReleasedIP(
+	released []flv1.ReleasedIP, delay int64, now time.Time,
+) []flv1.ReleasedIP {
+	if delay <= 0 || len(released) == 0 {
+		return nil
+	}
+	d := time.Duration(delay) * time.Second
+	result := make([]flv1.ReleasedIP, 0, len(released))
+	for _, r := range released {
+		if len(r.Addr) == 0 {
+			continue
+		}
+		if r.ReleasedTimestamp.Add(d).After(now) {
+			result = append(result, r)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// AddReleasedIP records the IP address released at now,
+// the expired released IPs are removed.
+func AddReleasedIP(
+	released []flv1.ReleasedIP, ip net.IP, delay int64, now time.Time,
+) []flv1.ReleasedIP {
+	released = RemoveReleasedIP(PruneReleasedIP(released, delay, now), ip)
+	if delay <= 0 || len(ip) == 0 {
+		return released
+	}
+	return append(released, flv1.ReleasedIP{
+		Addr:              ip.To16(),
+		ReleasedTimestamp: metav1.NewTime(now.UTC()),
+	})
+}
+
+// RemoveReleasedIP removes the IP address from the released IPs.
+func RemoveReleasedIP(released []flv1.ReleasedIP, ip net.IP) []flv1.ReleasedIP {
+	if len(ip) == 0 || len(released) == 0 {
+		return released
+	}
+	released = slices.DeleteFunc(slices.Clone(released), func(r flv1.ReleasedIP) bool {
+		return r.Addr.Equal(ip)
+	})
+	if len(released) == 0 {
+		return nil
+	}
+	return released
+}
+
+// maxReleasedHistory is the max number of the released IPs recorded as the
+// history of the 'lru' allocation strategy.
+const maxReleasedHistory = 1024
+
+// TrimReleasedIP returns the released IPs should be recorded in the subnet
+// status.
+// The released IPs are kept as the history (the newest maxReleasedHistory
+// addresses) if the subnet allocation strategy is 'lru', otherwise the
+// expired released IPs are removed.
+func TrimReleasedIP(
+	released []flv1.ReleasedIP, spec *flv1.SubnetSpec, now time.Time,
+) []flv1.ReleasedIP {
+	if spec.AllocationStrategy != flv1.AllocationStrategyLRU {
+		return PruneReleasedIP(released, spec.IPReuseDelay, now)
+	}
+	if len(released) <= maxReleasedHistory {
+		return released
+	}
+	released = slices.SortedStableFunc(slices.Values(released), func(a, b flv1.ReleasedIP) int {
+		return a.ReleasedTimestamp.Compare(b.ReleasedTimestamp.Time)
+	})
+	return released[len(released)-maxReleasedHistory:]
+}
+
+// RecordReleasedIP records the IP address released at now by the subnet
+// spec, see TrimReleasedIP.
+func RecordReleasedIP(
+	released []flv1.ReleasedIP, ip net.IP, spec *flv1.SubnetSpec, now time.Time,
+) []flv1.ReleasedIP {
+	if spec.AllocationStrategy != flv1.AllocationStrategyLRU {
+		return AddReleasedIP(released, ip, spec.IPReuseDelay, now)
+	}
+	released = RemoveReleasedIP(released, ip)
+	if len(ip) == 0 {
+		return released
+	}
+	released = append(slices.Clone(released), flv1.ReleasedIP{
+		Addr:              ip.To16(),
+		ReleasedTimestamp: metav1.NewTime(now.UTC()),
+	})
+	return TrimReleasedIP(released, spec, now)
+}
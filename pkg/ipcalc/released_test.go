@@ -0,0 +1,82 @@
+package ipcalc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_PruneReleasedIP(t *testing.T) {
+	now := time.Now()
+	released := []flv1.ReleasedIP{
+		{
+			Addr:              net.ParseIP("10.0.0.1"),
+			ReleasedTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+		},
+		{
+			Addr:              net.ParseIP("10.0.0.2"),
+			ReleasedTimestamp: metav1.NewTime(now.Add(-time.Second)),
+		},
+	}
+	assert.Nil(t, PruneReleasedIP(released, 0, now))
+	assert.Nil(t, PruneReleasedIP(nil, 30, now))
+	assert.Equal(t, PruneReleasedIP(released, 30, now), released[1:])
+	assert.Equal(t, PruneReleasedIP(released, 120, now), released)
+}
+
+func Test_AddReleasedIP(t *testing.T) {
+	now := time.Now()
+	r := AddReleasedIP(nil, net.ParseIP("10.0.0.1"), 0, now)
+	assert.Nil(t, r)
+
+	r = AddReleasedIP(nil, net.ParseIP("10.0.0.1"), 30, now)
+	assert.Equal(t, len(r), 1)
+	assert.Equal(t, r[0].Addr, net.ParseIP("10.0.0.1"))
+
+	// Release the same IP again updates the timestamp.
+	r = AddReleasedIP(r, net.ParseIP("10.0.0.1"), 30, now.Add(time.Second*10))
+	assert.Equal(t, len(r), 1)
+	assert.Equal(t, r[0].ReleasedTimestamp.Unix(), now.Add(time.Second*10).Unix())
+
+	// Expired IPs are removed.
+	r = AddReleasedIP(r, net.ParseIP("10.0.0.2"), 30, now.Add(time.Minute))
+	assert.Equal(t, len(r), 1)
+	assert.Equal(t, r[0].Addr, net.ParseIP("10.0.0.2"))
+
+	assert.Nil(t, RemoveReleasedIP(r, net.ParseIP("10.0.0.2")))
+	assert.Equal(t, RemoveReleasedIP(r, net.ParseIP("10.0.0.3")), r)
+}
+
+func Test_RecordReleasedIP(t *testing.T) {
+	now := time.Now()
+	spec := &flv1.SubnetSpec{
+		AllocationStrategy: flv1.AllocationStrategyLRU,
+	}
+	// The released IPs are recorded without reuse delay for lru strategy.
+	released := RecordReleasedIP(nil, net.ParseIP("10.0.0.1"), spec, now)
+	assert.Equal(t, 1, len(released))
+	released = RecordReleasedIP(released, net.ParseIP("10.0.0.2"), spec, now.Add(time.Hour))
+	assert.Equal(t, 2, len(released))
+	assert.Equal(t, 2, len(TrimReleasedIP(released, spec, now.Add(time.Hour*24))))
+
+	// Re-released address is updated.
+	released = RecordReleasedIP(released, net.ParseIP("10.0.0.1"), spec, now.Add(time.Hour*2))
+	assert.Equal(t, 2, len(released))
+	assert.True(t, released[1].Addr.Equal(net.ParseIP("10.0.0.1")))
+
+	// The history is limited.
+	for i := 0; i < maxReleasedHistory+10; i++ {
+		released = RecordReleasedIP(released, net.IPv4(10, 1, byte(i>>8), byte(i)),
+			spec, now.Add(time.Duration(i)*time.Second))
+	}
+	assert.Equal(t, maxReleasedHistory, len(released))
+
+	// Not recorded for sequential strategy without reuse delay.
+	spec.AllocationStrategy = ""
+	assert.Nil(t, TrimReleasedIP(released, spec, now))
+	assert.Nil(t, RecordReleasedIP(nil, net.ParseIP("10.0.0.1"), spec, now))
+}
@@ -0,0 +1,148 @@
+package ipcalc
+
+import (
+	"cmp"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+)
+
+// Strategy decides which available IP address is allocated by the Allocator.
+type Strategy interface {
+	// Allocate allocates an available IP address (**16 bytes**) from the
+	// Allocator and marks it as used.
+	// The released is the released IP addresses history of the subnet.
+	// ErrNoAvailableIP error will be returned if no IP address resource available.
+	Allocate(a *Allocator, released []flv1.ReleasedIP) (net.IP, error)
+}
+
+// StrategyFunc is an adapter to allow the use of ordinary functions as
+// Strategy.
+type StrategyFunc func(a *Allocator, released []flv1.ReleasedIP) (net.IP, error)
+
+func (f StrategyFunc) Allocate(a *Allocator, released []flv1.ReleasedIP) (net.IP, error) {
+	return f(a, released)
+}
+
+var strategies = map[string]Strategy{
+	flv1.AllocationStrategySequential: StrategyFunc(allocateSequential),
+	flv1.AllocationStrategyRandom:     StrategyFunc(allocateRandom),
+	flv1.AllocationStrategyLRU:        StrategyFunc(allocateLRU),
+}
+
+// RegisterStrategy registers the allocation strategy by name,
+// it should be called in init functions.
+func RegisterStrategy(name string, s Strategy) {
+	strategies[name] = s
+}
+
+// GetStrategy returns the allocation strategy by name,
+// the sequential strategy is returned if the name is empty.
+func GetStrategy(name string) (Strategy, error) {
+	if name == "" {
+		name = flv1.AllocationStrategySequential
+	}
+	s, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized allocation strategy [%v]", name)
+	}
+	return s, nil
+}
+
+// allocateSequential allocates the lowest available IP address.
+func allocateSequential(a *Allocator, _ []flv1.ReleasedIP) (net.IP, error) {
+	return a.Allocate()
+}
+
+// allocateRandom allocates an available IP address randomly.
+func allocateRandom(a *Allocator, _ []flv1.ReleasedIP) (net.IP, error) {
+	free := a.Free()
+	if free.Sign() <= 0 {
+		return nil, ErrNoAvailableIP
+	}
+	k, err := rand.Int(rand.Reader, free)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate random number: %w", err)
+	}
+	for _, r := range a.poolSet.intervals {
+		n := r.size()
+		n.Sub(n, a.used.countIn(r.start, r.end))
+		if k.Cmp(n) >= 0 {
+			k.Sub(k, n)
+			continue
+		}
+		v := a.used.nthFree(r.start, k)
+		a.reserve(v)
+		return v.ip(), nil
+	}
+	return nil, ErrNoAvailableIP
+}
+
+// allocateLRU allocates the least recently used IP address,
+// the addresses never released are preferred, then the address released
+// longest ago.
+func allocateLRU(a *Allocator, released []flv1.ReleasedIP) (net.IP, error) {
+	excluded := &IPSet{
+		intervals: slices.Clone(a.used.intervals),
+	}
+	for _, r := range released {
+		excluded.Add(r.Addr)
+	}
+	for _, r := range a.pool {
+		v, ok := excluded.firstFree(r.start, r.end)
+		if !ok {
+			continue
+		}
+		a.reserve(v)
+		return v.ip(), nil
+	}
+	released = slices.SortedStableFunc(slices.Values(released), func(a, b flv1.ReleasedIP) int {
+		return cmp.Compare(a.ReleasedTimestamp.UnixNano(), b.ReleasedTimestamp.UnixNano())
+	})
+	for _, r := range released {
+		v, ok := ipToInt(r.Addr)
+		if !ok || !a.poolSet.contains(v) || a.used.contains(v) {
+			continue
+		}
+		a.reserve(v)
+		return v.ip(), nil
+	}
+	return nil, ErrNoAvailableIP
+}
+
+// intFromBig converts the big integer to ipInt, the caller should ensure
+// the value is in the 128-bit unsigned integer range.
+func intFromBig(b *big.Int) ipInt {
+	mask := new(big.Int).SetUint64(^uint64(0))
+	return ipInt{
+		hi: new(big.Int).Rsh(b, 64).Uint64(),
+		lo: new(big.Int).And(b, mask).Uint64(),
+	}
+}
+
+// nthFree returns the k-th (starts from 0) address not in the set starting
+// from lo, the caller should ensure the address exists.
+func (s *IPSet) nthFree(lo ipInt, k *big.Int) ipInt {
+	k = new(big.Int).Set(k)
+	cur := lo
+	for i := s.search(lo); i < len(s.intervals); i++ {
+		r := s.intervals[i]
+		if r.start.cmp(cur) > 0 {
+			gap := r.start.big()
+			gap.Sub(gap, cur.big())
+			if k.Cmp(gap) < 0 {
+				break
+			}
+			k.Sub(k, gap)
+		}
+		if r.end.isMax() {
+			break
+		}
+		cur = r.end.next()
+	}
+	return intFromBig(k.Add(k, cur.big()))
+}
@@ -0,0 +1,135 @@
+package ipcalc
+
+import (
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_GetStrategy(t *testing.T) {
+	for _, name := range []string{
+		"",
+		flv1.AllocationStrategySequential,
+		flv1.AllocationStrategyRandom,
+		flv1.AllocationStrategyLRU,
+	} {
+		s, err := GetStrategy(name)
+		assert.Nil(t, err)
+		assert.NotNil(t, s)
+	}
+	_, err := GetStrategy("unknown")
+	assert.NotNil(t, err)
+}
+
+func Test_allocateRandom(t *testing.T) {
+	a, err := NewAllocator("192.168.1.0/24", []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.100"),
+			To:   net.ParseIP("192.168.1.110"),
+		},
+		{
+			From: net.ParseIP("192.168.1.10"),
+			To:   net.ParseIP("192.168.1.12"),
+		},
+	}, []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.102"),
+			To:   net.ParseIP("192.168.1.105"),
+		},
+	})
+	assert.Nil(t, err)
+	s, _ := GetStrategy(flv1.AllocationStrategyRandom)
+
+	// 10 available addresses.
+	set := NewIPSet(nil)
+	for i := 0; i < 10; i++ {
+		ip, err := s.Allocate(a, nil)
+		assert.Nil(t, err)
+		assert.True(t, set.Add(ip), "duplicated IP %v", ip)
+		assert.True(t, a.InPool(ip))
+		assert.False(t, ipInRange(ip, "192.168.1.102", "192.168.1.105"))
+	}
+	ip, err := s.Allocate(a, nil)
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, ErrNoAvailableIP)
+
+	// IPv6
+	a, err = NewAllocator("fd00::/64", nil, nil)
+	assert.Nil(t, err)
+	ip, err = s.Allocate(a, nil)
+	assert.Nil(t, err)
+	assert.True(t, a.IsUsed(ip))
+}
+
+func ipInRange(ip net.IP, from, to string) bool {
+	return IPInRanges(ip, []flv1.IPRange{
+		{From: net.ParseIP(from), To: net.ParseIP(to)},
+	})
+}
+
+func Test_nthFree(t *testing.T) {
+	s := NewIPSet([]flv1.IPRange{
+		{From: net.ParseIP("10.0.0.2"), To: net.ParseIP("10.0.0.3")},
+		{From: net.ParseIP("10.0.0.5"), To: net.ParseIP("10.0.0.5")},
+	})
+	lo, _ := ipToInt(net.ParseIP("10.0.0.1"))
+	for k, expected := range []string{"10.0.0.1", "10.0.0.4", "10.0.0.6", "10.0.0.7"} {
+		v := s.nthFree(lo, bigInt(k))
+		assert.Equal(t, net.ParseIP(expected), v.ip())
+	}
+	lo, _ = ipToInt(net.ParseIP("10.0.0.2"))
+	assert.Equal(t, net.ParseIP("10.0.0.4"), s.nthFree(lo, bigInt(0)).ip())
+}
+
+func Test_allocateLRU(t *testing.T) {
+	a, err := NewAllocator("192.168.1.0/24", []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.10"),
+			To:   net.ParseIP("192.168.1.14"),
+		},
+	}, []flv1.IPRange{
+		{
+			From: net.ParseIP("192.168.1.11"),
+			To:   net.ParseIP("192.168.1.11"),
+		},
+	})
+	assert.Nil(t, err)
+	s, _ := GetStrategy(flv1.AllocationStrategyLRU)
+
+	now := time.Now()
+	released := []flv1.ReleasedIP{
+		{
+			Addr:              net.ParseIP("192.168.1.12"),
+			ReleasedTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+		},
+		{
+			Addr:              net.ParseIP("192.168.1.10"),
+			ReleasedTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+		},
+		{
+			Addr:              net.ParseIP("192.168.1.14"),
+			ReleasedTimestamp: metav1.NewTime(now.Add(-time.Second)),
+		},
+	}
+	// The never released address first, then the address released
+	// longest ago.
+	for _, expected := range []string{
+		"192.168.1.13", "192.168.1.10", "192.168.1.12", "192.168.1.14",
+	} {
+		ip, err := s.Allocate(a, released)
+		assert.Nil(t, err)
+		assert.Equal(t, net.ParseIP(expected), ip)
+	}
+	ip, err := s.Allocate(a, released)
+	assert.Nil(t, ip)
+	assert.ErrorIs(t, err, ErrNoAvailableIP)
+}
+
+func bigInt(i int) *big.Int {
+	return big.NewInt(int64(i))
+}
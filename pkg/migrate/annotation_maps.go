@@ -29,9 +29,10 @@ const (
 	macvlanV1NetAttatchDefNameMulti  = `[{"name":"static-macvlan-cni-attach","interface":"eth1"}]`
 	macvlanV1NetAttatchDefNameSingle = `[{"name":"static-macvlan-cni-attach","interface":"eth0"}]`
 
-	macvlanV1AnnotationIP     = "macvlan.pandaria.cattle.io/ip"
-	macvlanV1AnnotationSubnet = "macvlan.pandaria.cattle.io/subnet"
-	macvlanV1SubnetNamespace  = "kube-system"
+	macvlanV1AnnotationIP                    = "macvlan.pandaria.cattle.io/ip"
+	macvlanV1AnnotationSubnet                = "macvlan.pandaria.cattle.io/subnet"
+	macvlanV1AnnotationIPDelayReuseTimestamp = "macvlan.panda.io/ipDelayReuseTimestamp"
+	macvlanV1SubnetNamespace                 = "kube-system"
 )
 
 func updateAnnotation(o metav1.Object) map[string]string {
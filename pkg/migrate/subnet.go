@@ -242,6 +242,7 @@ func newFlatNetworkSubnet(ms *types.MacvlanSubnet) (*flv1.FlatNetworkSubnet, err
 				AddPodIPToHost:            false,
 				FlatNetworkDefaultGateway: ms.Spec.PodDefaultGateway.Enable,
 			},
+			IPReuseDelay: ms.Spec.IPDelayReuse,
 		},
 	}
 
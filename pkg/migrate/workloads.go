@@ -3,16 +3,22 @@ package migrate
 import (
 	"context"
 	"fmt"
+	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 
+	flv1 "github.com/cnrancher/rancher-flat-network/pkg/apis/flatnetwork.pandaria.io/v1"
+	flcommon "github.com/cnrancher/rancher-flat-network/pkg/common"
 	"github.com/cnrancher/rancher-flat-network/pkg/controller/workload"
+	"github.com/cnrancher/rancher-flat-network/pkg/ipcalc"
 	"github.com/cnrancher/rancher-flat-network/pkg/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -154,6 +160,11 @@ func (m *migrator) processPodTemplateAnnotation(
 		return fmt.Errorf("failed to update %T [%v/%v] annotation: %w",
 			o, o.GetNamespace(), o.GetName(), err)
 	}
+	// Migrate the delay reuse timestamp before the annotation is removed.
+	if err = m.migrateIPDelayReuse(o, annotation); err != nil {
+		return fmt.Errorf("failed to migrate %T [%v/%v] IP delay reuse: %w",
+			o, o.GetNamespace(), o.GetName(), err)
+	}
 	if err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		o, err = m.getWorkload(o)
 		if err != nil {
@@ -214,3 +225,85 @@ func (m *migrator) updateWorkload(o metav1.Object) (metav1.Object, error) {
 	}
 	return nil, fmt.Errorf("unrecognized workload type %T", o)
 }
+
+// migrateIPDelayReuse records the V1 workload IPs released at the
+// ipDelayReuseTimestamp into the released IPs of the V2 IP blocks, so the IPs
+// are still not reused until the subnet ipReuseDelay expired after migration.
+func (m *migrator) migrateIPDelayReuse(o metav1.Object, annotation map[string]string) error {
+	s := annotation[macvlanV1AnnotationIPDelayReuseTimestamp]
+	if s == "" {
+		return nil
+	}
+	released, err := parseIPDelayReuseTimestamp(s)
+	if err != nil {
+		logrus.Warnf("skip migrate %T [%v/%v] IP delay reuse: %v",
+			o, o.GetNamespace(), o.GetName(), err)
+		return nil
+	}
+	ips, err := flcommon.CheckPodAnnotationIPs(annotation[macvlanV1AnnotationIP])
+	if err != nil || len(ips) == 0 {
+		return err
+	}
+	subnet, err := m.wctx.FlatNetwork.FlatNetworkSubnet().Get(
+		flv1.SubnetNamespace, annotation[macvlanV1AnnotationSubnet], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get FlatNetworkSubnet [%v]: %w",
+			annotation[macvlanV1AnnotationSubnet], err)
+	}
+	delay := time.Duration(subnet.Spec.IPReuseDelay) * time.Second
+	if delay <= 0 || !released.Add(delay).After(time.Now()) {
+		return nil
+	}
+	for _, ip := range ips {
+		if err := m.addIPBlockReleasedIP(subnet, ip, released); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("record %T [%v/%v] IPs %v released at [%v] to subnet [%v]",
+		o, o.GetNamespace(), o.GetName(), ips, released.Format(time.RFC3339), subnet.Name)
+	return nil
+}
+
+// addIPBlockReleasedIP records the IP address released at the time into the
+// IP block of the address, the IP block is created if not exists.
+// The address in use is not recorded.
+func (m *migrator) addIPBlockReleasedIP(
+	subnet *flv1.FlatNetworkSubnet, ip net.IP, released time.Time,
+) error {
+	name := flcommon.GetIPBlockName(subnet.Name, ip)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		block, err := m.wctx.FlatNetwork.FlatNetworkIPBlock().Get(
+			flv1.SubnetNamespace, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			block, err = m.wctx.FlatNetwork.FlatNetworkIPBlock().Create(
+				flcommon.NewIPBlock(subnet, ip))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get IP block [%v]: %w", name, err)
+		}
+		if ipcalc.IPInRanges(ip, block.Status.UsedIP) {
+			return nil
+		}
+		updated := block.DeepCopy()
+		updated.Status.ReleasedIP = ipcalc.RecordReleasedIP(
+			updated.Status.ReleasedIP, ip, &subnet.Spec, released)
+		if reflect.DeepEqual(updated.Status, block.Status) {
+			return nil
+		}
+		_, err = m.wctx.FlatNetwork.FlatNetworkIPBlock().UpdateStatus(updated)
+		return err
+	})
+}
+
+// parseIPDelayReuseTimestamp parses the V1 ipDelayReuseTimestamp annotation,
+// which is the Unix timestamp (seconds) or the RFC3339 time.
+func parseIPDelayReuseTimestamp(s string) (time.Time, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(i, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ipDelayReuseTimestamp %q", s)
+	}
+	return t, nil
+}
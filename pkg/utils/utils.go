@@ -65,7 +65,10 @@ func IsPodEnabledFlatNetwork(pod *corev1.Pod) bool {
 	if pod.Annotations == nil {
 		return false
 	}
-	if pod.Annotations[flv1.AnnotationIP] == "" || pod.Annotations[flv1.AnnotationSubnet] == "" {
+	if pod.Annotations[flv1.AnnotationIP] == "" {
+		return false
+	}
+	if pod.Annotations[flv1.AnnotationSubnet] == "" && pod.Annotations[flv1.AnnotationSubnetPool] == "" {
 		return false
 	}
 	return true